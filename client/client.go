@@ -0,0 +1,478 @@
+// Package client talks to a superghost server over its JSON API (/api/v1).
+//
+// A Client keeps its own cookie jar, which holds the per-room cookies issued
+// when joining. Since those cookies are what identify a player, use one Client
+// per player.
+package client
+
+import (
+  "bytes"
+  "context"
+  "encoding/json"
+  "fmt"
+  "io"
+  "net/http"
+  "net/http/cookiejar"
+  "net/url"
+  "strconv"
+  "strings"
+  "superghost"
+  "time"
+)
+
+type Client struct {
+  baseURL string
+  httpClient *http.Client
+}
+
+// Creates a client for the server at baseURL, e.g. "https://wordy.boo" or the
+// URL of an httptest.Server.
+func New(baseURL string) *Client {
+  return NewWithHTTPClient(baseURL, http.DefaultClient)
+}
+
+// Like New, but requests are made with a copy of httpClient. The copy gets its
+// own cookie jar unless httpClient already has one.
+func NewWithHTTPClient(baseURL string, httpClient *http.Client) *Client {
+  c := new(Client)
+  c.baseURL = strings.TrimSuffix(baseURL, "/") + "/api/v1"
+  c.httpClient = new(http.Client)
+  *c.httpClient = *httpClient
+  if c.httpClient.Jar == nil {
+    jar, err := cookiejar.New(nil)
+    if err != nil {
+      panic(err)  // cookiejar.New never fails without options
+    }
+    c.httpClient.Jar = jar
+  }
+  return c
+}
+
+// Returned for every error response from the server. Compare it against the
+// superghost sentinels with errors.Is, e.g.
+//   errors.Is(err, superghost.ErrNotYourTurn)
+type APIError struct {
+  StatusCode int
+  Code string
+  Message string
+  // How long the server asked for before trying again, when it's rate
+  // limiting the client or full. 0 if it didn't say.
+  RetryAfter time.Duration
+}
+
+func (e *APIError) Error() string {
+  return fmt.Sprintf("%d %s: %s", e.StatusCode, e.Code, e.Message)
+}
+
+func (e *APIError) Is(target error) bool {
+  t, ok := target.(*superghost.Error)
+  return ok && t.Code == e.Code
+}
+
+func roomPath(roomID string, action string) string {
+  path := "/rooms/" + url.PathEscape(roomID)
+  if action != "" {
+    path += "/" + action
+  }
+  return path
+}
+
+func tournamentPath(tournamentID string, action string) string {
+  path := "/tournaments/" + url.PathEscape(tournamentID)
+  if action != "" {
+    path += "/" + action
+  }
+  return path
+}
+
+// Sends body (if not nil) as JSON and decodes the response into out (if not
+// nil).
+func (c *Client) do(ctx context.Context, method, path string,
+                    body interface{}, out interface{}) error {
+  var reader io.Reader
+  if body != nil {
+    b, err := json.Marshal(body)
+    if err != nil {
+      return err
+    }
+    reader = bytes.NewReader(b)
+  }
+  req, err := http.NewRequestWithContext(ctx, method, c.baseURL + path, reader)
+  if err != nil {
+    return err
+  }
+  if body != nil {
+    req.Header.Set("Content-Type", "application/json")
+  }
+
+  res, err := c.httpClient.Do(req)
+  if err != nil {
+    return err
+  }
+  defer res.Body.Close()
+
+  b, err := io.ReadAll(res.Body)
+  if err != nil {
+    return err
+  }
+  if res.StatusCode < 200 || res.StatusCode >= 300 {
+    apiErr := &APIError{ StatusCode: res.StatusCode }
+    if seconds, err := strconv.Atoi(res.Header.Get("Retry-After"));
+        err == nil {
+      apiErr.RetryAfter = time.Duration(seconds) * time.Second
+    }
+    var jerr struct {
+      Code string `json:"code"`
+      Message string `json:"message"`
+    }
+    if json.Unmarshal(b, &jerr) == nil {
+      apiErr.Code, apiErr.Message = jerr.Code, jerr.Message
+    } else {
+      apiErr.Message = string(b)
+    }
+    return apiErr
+  }
+  if out == nil || len(b) == 0 {
+    return nil
+  }
+  return json.Unmarshal(b, out)
+}
+
+func (c *Client) ListRooms(ctx context.Context) (
+    []superghost.JRoomMetadata, error) {
+  var rooms []superghost.JRoomMetadata
+  err := c.do(ctx, http.MethodGet, "/rooms", nil, &rooms)
+  return rooms, err
+}
+
+// How the server's dictionary cache is doing. Fails with a not-found error if
+// the server doesn't cache its dictionary.
+func (c *Client) DictionaryStats(ctx context.Context) (
+    *superghost.DictionaryStats, error) {
+  stats := new(superghost.DictionaryStats)
+  err := c.do(ctx, http.MethodGet, "/dictionary-stats", nil, stats)
+  return stats, err
+}
+
+// Today's puzzle and how far the client has got with it
+func (c *Client) Puzzle(ctx context.Context) (
+    *superghost.PuzzleProgress, error) {
+  progress := new(superghost.PuzzleProgress)
+  err := c.do(ctx, http.MethodGet, "/puzzle", nil, progress)
+  return progress, err
+}
+
+// Guesses prefix + stem + suffix for today's puzzle. The first guess that's
+// checked makes the client a puzzle player; its cookie jar keeps it one,
+// streak and all.
+func (c *Client) GuessPuzzle(ctx context.Context, prefix, suffix string) (
+    *superghost.PuzzleGuess, error) {
+  guess := new(superghost.PuzzleGuess)
+  err := c.do(ctx, http.MethodPost, "/puzzle/guess",
+              map[string]string{ "Prefix": prefix, "Suffix": suffix }, guess)
+  return guess, err
+}
+
+// Returns the new room's ID
+func (c *Client) CreateRoom(ctx context.Context,
+                            config superghost.Config) (string, error) {
+  var res struct {
+    ID string
+  }
+  err := c.do(ctx, http.MethodPost, "/rooms", config, &res)
+  return res.ID, err
+}
+
+// Returns the new tournament's ID. The client becomes its organizer, the only
+// one who can start it.
+func (c *Client) CreateTournament(
+    ctx context.Context, config superghost.TournamentConfig) (string, error) {
+  var res struct {
+    ID string
+  }
+  err := c.do(ctx, http.MethodPost, "/tournaments", config, &res)
+  return res.ID, err
+}
+
+// The tournament's bracket and standings
+func (c *Client) Tournament(ctx context.Context, tournamentID string) (
+    *superghost.JTournament, error) {
+  t := new(superghost.JTournament)
+  err := c.do(ctx, http.MethodGet, tournamentPath(tournamentID, ""), nil, t)
+  return t, err
+}
+
+// The client's cookie jar keeps the cookie that lets it into its matches'
+// rooms
+func (c *Client) RegisterForTournament(
+    ctx context.Context, tournamentID, username string) (
+    *superghost.JTournament, error) {
+  t := new(superghost.JTournament)
+  err := c.do(ctx, http.MethodPost,
+              tournamentPath(tournamentID, "registration"),
+              map[string]string{ "Username": username }, t)
+  return t, err
+}
+
+func (c *Client) StartTournament(ctx context.Context, tournamentID string) (
+    *superghost.JTournament, error) {
+  t := new(superghost.JTournament)
+  err := c.do(ctx, http.MethodPost,
+              tournamentPath(tournamentID, "start"), nil, t)
+  return t, err
+}
+
+func (c *Client) Config(ctx context.Context, roomID string) (
+    *superghost.Config, error) {
+  config := new(superghost.Config)
+  err := c.do(ctx, http.MethodGet, roomPath(roomID, "config"), nil, config)
+  return config, err
+}
+
+// Replaces the room's allowed and blocked words (host only, between games)
+func (c *Client) SetWordLists(ctx context.Context, roomID string,
+                              allowed, blocked []string) (
+    *superghost.Config, error) {
+  config := new(superghost.Config)
+  err := c.do(ctx, http.MethodPost, roomPath(roomID, "word-lists"),
+              map[string][]string {
+                "AllowedWords": allowed,
+                "BlockedWords": blocked,
+              }, config)
+  return config, err
+}
+
+// Returns the room's state including its full log
+func (c *Client) State(ctx context.Context, roomID string) (
+    *superghost.JRoom, error) {
+  return c.roomAction(ctx, http.MethodGet, roomID, "", nil)
+}
+
+// Blocks until the room's state changes. The log only holds items added since
+// the previous update.
+func (c *Client) NextState(ctx context.Context, roomID string) (
+    *superghost.JRoom, error) {
+  return c.roomAction(ctx, http.MethodGet, roomID, "next-state", nil)
+}
+
+// Blocks until someone sends a chat message
+func (c *Client) NextChat(ctx context.Context, roomID string) (
+    *superghost.Message, error) {
+  msg := new(superghost.Message)
+  err := c.do(ctx, http.MethodGet, roomPath(roomID, "next-chat"), nil, msg)
+  return msg, err
+}
+
+func (c *Client) roomAction(ctx context.Context, method, roomID, action string,
+                            body interface{}) (*superghost.JRoom, error) {
+  room := new(superghost.JRoom)
+  if err := c.do(ctx, method, roomPath(roomID, action), body, room); err != nil {
+    return nil, err
+  }
+  return room, nil
+}
+
+// Joins the room as username. The cookie identifying the player is kept in
+// the client's jar for the other room actions.
+func (c *Client) Join(ctx context.Context, roomID, username string) (
+    *superghost.JRoom, error) {
+  return c.JoinTeam(ctx, roomID, username, 0)
+}
+
+// What QuickPlay looks for. Zero values take the server's defaults: a
+// two-player superghost game in English, against anyone.
+type QuickPlayPreferences struct {
+  Variant superghost.Variant
+  Language superghost.Language
+  Players int
+  Rating int
+}
+
+// Waits to be matched with other players, then returns the ID of the room
+// made for them, which the client has already joined as username. Cancel ctx
+// to stop waiting.
+func (c *Client) QuickPlay(ctx context.Context, username string,
+                           prefs QuickPlayPreferences) (string, error) {
+  var res struct {
+    RoomID string
+  }
+  err := c.do(ctx, http.MethodPost, "/quick-play",
+              map[string]interface{} {
+                "Username": username,
+                "Variant": prefs.Variant,
+                "Language": prefs.Language,
+                "Players": prefs.Players,
+                "Rating": prefs.Rating,
+              }, &res)
+  return res.RoomID, err
+}
+
+// Joins a room playing in teams on the given team (from 1). 0 picks the
+// smallest team.
+func (c *Client) JoinTeam(ctx context.Context, roomID, username string,
+                          team int) (*superghost.JRoom, error) {
+  return c.roomAction(ctx, http.MethodPost, roomID, "join",
+                      map[string]interface{} {
+                        "Username": username,
+                        "Team": team,
+                      })
+}
+
+// Joins a room with a password, by its password or an invite from the host.
+// team is as for JoinTeam.
+func (c *Client) JoinWithCredentials(
+    ctx context.Context, roomID, username string, team int,
+    credentials superghost.JoinCredentials) (*superghost.JRoom, error) {
+  return c.roomAction(ctx, http.MethodPost, roomID, "join",
+                      map[string]interface{} {
+                        "Username": username,
+                        "Team": team,
+                        "Password": credentials.Password,
+                        "Invite": credentials.Invite,
+                      })
+}
+
+// Makes an invite others can join the room with, skipping its password. Only
+// the host can. A lifetime of 0 means a day.
+func (c *Client) Invite(ctx context.Context, roomID string,
+                        lifetime time.Duration) (*superghost.Invite, error) {
+  invite := new(superghost.Invite)
+  err := c.do(ctx, http.MethodPost, roomPath(roomID, "invites"),
+              map[string]interface{}{ "Lifetime": lifetime }, invite)
+  if err != nil {
+    return nil, err
+  }
+  return invite, nil
+}
+
+// Exactly one of prefix and suffix should be a letter; the other should be
+// empty.
+func (c *Client) Affix(ctx context.Context, roomID, prefix, suffix string) (
+    *superghost.JRoom, error) {
+  return c.roomAction(ctx, http.MethodPost, roomID, "affix",
+                      map[string]string{ "Prefix": prefix, "Suffix": suffix })
+}
+
+// Puts letter before the index-th letter of the stem. Only superduperghost
+// rooms allow this.
+func (c *Client) Insert(ctx context.Context, roomID string, index int,
+                        letter string) (*superghost.JRoom, error) {
+  return c.roomAction(ctx, http.MethodPost, roomID, "insertion",
+                      map[string]interface{} {
+                        "Index": index,
+                        "Letter": letter,
+                      })
+}
+
+// Reverses the stem. Only xghost rooms allow this, once per round.
+func (c *Client) Reverse(ctx context.Context, roomID string) (
+    *superghost.JRoom, error) {
+  return c.roomAction(ctx, http.MethodPost, roomID, "reversal", nil)
+}
+
+// Asks for safe letters and an example word. Only practice rooms give hints,
+// and only on the caller's turn; every one is counted against them.
+func (c *Client) Hint(ctx context.Context, roomID string) (
+    *superghost.Hint, error) {
+  hint := new(superghost.Hint)
+  err := c.do(ctx, http.MethodGet, roomPath(roomID, "hint"), nil, hint)
+  return hint, err
+}
+
+// Fetches the move-by-move analysis of a finished game, numbered from 1
+func (c *Client) Analysis(ctx context.Context, roomID string, game int) (
+    *superghost.GameAnalysis, error) {
+  analysis := new(superghost.GameAnalysis)
+  err := c.do(ctx, http.MethodGet,
+              roomPath(roomID, "analysis/" + strconv.Itoa(game)), nil, analysis)
+  return analysis, err
+}
+
+// Votes to overturn (or uphold) the dictionary's ruling in jury mode
+func (c *Client) VoteOnRuling(ctx context.Context, roomID string,
+                              overturn bool) (*superghost.JRoom, error) {
+  return c.roomAction(ctx, http.MethodPost, roomID, "jury-vote",
+                      map[string]bool{ "Overturn": overturn })
+}
+
+// Pauses the game if the caller is the host; otherwise votes to pause it
+func (c *Client) Pause(ctx context.Context, roomID string) (
+    *superghost.JRoom, error) {
+  return c.roomAction(ctx, http.MethodPost, roomID, "pause", nil)
+}
+
+// Resumes a paused game if the caller is the host; otherwise votes for it
+func (c *Client) Resume(ctx context.Context, roomID string) (
+    *superghost.JRoom, error) {
+  return c.roomAction(ctx, http.MethodPost, roomID, "resume", nil)
+}
+
+func (c *Client) ChallengeIsWord(ctx context.Context, roomID string) (
+    *superghost.JRoom, error) {
+  return c.roomAction(ctx, http.MethodPost, roomID, "challenge-is-word", nil)
+}
+
+func (c *Client) ChallengeContinuation(ctx context.Context, roomID string) (
+    *superghost.JRoom, error) {
+  return c.roomAction(ctx, http.MethodPost, roomID, "challenge-continuation",
+                      nil)
+}
+
+// Rebuts a continuation challenge with prefix + stem + suffix
+func (c *Client) Rebut(ctx context.Context, roomID, prefix, suffix string) (
+    *superghost.JRoom, error) {
+  return c.roomAction(ctx, http.MethodPost, roomID, "rebuttal",
+                      map[string]string{ "Prefix": prefix, "Suffix": suffix })
+}
+
+// Rebuts a continuation challenge with a whole word, which in
+// superduperghost may have letters inside the stem
+func (c *Client) RebutWithWord(ctx context.Context, roomID, word string) (
+    *superghost.JRoom, error) {
+  return c.roomAction(ctx, http.MethodPost, roomID, "rebuttal",
+                      map[string]string{ "Word": word })
+}
+
+func (c *Client) Concede(ctx context.Context, roomID string) (
+    *superghost.JRoom, error) {
+  return c.roomAction(ctx, http.MethodPost, roomID, "concession", nil)
+}
+
+func (c *Client) Kick(ctx context.Context, roomID, username string) (
+    *superghost.JRoom, error) {
+  return c.roomAction(ctx, http.MethodPost, roomID, "kick",
+                      map[string]string{ "Username": username })
+}
+
+// Stops a player from chatting (host only)
+func (c *Client) Mute(ctx context.Context, roomID, username string) (
+    *superghost.JRoom, error) {
+  return c.roomAction(ctx, http.MethodPost, roomID, "mute",
+                      map[string]string{ "Username": username })
+}
+
+func (c *Client) Unmute(ctx context.Context, roomID, username string) (
+    *superghost.JRoom, error) {
+  return c.roomAction(ctx, http.MethodPost, roomID, "unmute",
+                      map[string]string{ "Username": username })
+}
+
+func (c *Client) Chat(ctx context.Context, roomID, content string) (
+    *superghost.Message, error) {
+  msg := new(superghost.Message)
+  err := c.do(ctx, http.MethodPost, roomPath(roomID, "chat"),
+              map[string]string{ "Content": content }, msg)
+  return msg, err
+}
+
+// Deletes a chat message (host only). Everyone subscribed is told to take it
+// down.
+func (c *Client) DeleteMessage(ctx context.Context, roomID string,
+                               id int) error {
+  return c.do(ctx, http.MethodDelete,
+              roomPath(roomID, "chat/" + strconv.Itoa(id)), nil, nil)
+}
+
+func (c *Client) Leave(ctx context.Context, roomID string) error {
+  return c.do(ctx, http.MethodPost, roomPath(roomID, "leave"), nil, nil)
+}
@@ -0,0 +1,211 @@
+package client
+
+import (
+  "context"
+  "errors"
+  "net/http/httptest"
+  "sgserver"
+  "superghost"
+  "testing"
+  "time"
+)
+
+func newTestServer() *httptest.Server {
+  s := sgserver.NewSuperghostServer(make(map[string]*sgserver.RoomWrapper))
+  s.Dictionary = superghost.NewWordList([]string{"GHOST", "STEM"})
+  return httptest.NewServer(s.Router)
+}
+
+func TestPlayRound(t *testing.T) {
+  server := newTestServer()
+  defer server.Close()
+  ctx := context.Background()
+
+  alice, bob := New(server.URL), New(server.URL)
+  roomID, err := alice.CreateRoom(ctx, superghost.Config {
+    MaxPlayers: 2,
+    MinWordLength: 4,
+    IsPublic: true,
+  })
+  if err != nil {
+    t.Fatal(err)
+  }
+  rooms, err := bob.ListRooms(ctx)
+  if err != nil || len(rooms) != 1 || rooms[0].ID != roomID {
+    t.Fatalf("expected room %s to be listed, got %+v (%v)", roomID, rooms, err)
+  }
+  if _, err := alice.Join(ctx, roomID, "alice"); err != nil {
+    t.Fatal(err)
+  }
+  if _, err := bob.Join(ctx, roomID, "bob"); err != nil {
+    t.Fatal(err)
+  }
+
+  players := map[string]*Client{ "alice": alice, "bob": bob }
+  room, err := alice.State(ctx, roomID)
+  if err != nil {
+    t.Fatal(err)
+  }
+  for _, letter := range []string{"S", "T", "E"} {
+    room, err = players[room.CurrentPlayerUsername].Affix(ctx, roomID, "",
+                                                          letter)
+    if err != nil {
+      t.Fatal(err)
+    }
+  }
+  if room.Stem != "STE" {
+    t.Fatalf("expected stem STE, got %s", room.Stem)
+  }
+
+  // The player who isn't up gets a typed error
+  _, err = players[room.LastPlayerUsername].Affix(ctx, roomID, "", "M")
+  if !errors.Is(err, superghost.ErrNotYourTurn) {
+    t.Fatalf("expected not-your-turn error, got %v", err)
+  }
+
+  challenger := room.CurrentPlayerUsername
+  room, err = players[challenger].ChallengeContinuation(ctx, roomID)
+  if err != nil {
+    t.Fatal(err)
+  }
+  room, err = players[room.CurrentPlayerUsername].Rebut(ctx, roomID, "", "M")
+  if err != nil {
+    t.Fatal(err)
+  }
+  for _, p := range room.Players {
+    if (p.Username == challenger) != (p.Score == 1) {
+      t.Fatalf("only the challenger should have lost: %+v", room.Players)
+    }
+  }
+
+  if err := bob.Leave(ctx, roomID); err != nil {
+    t.Fatal(err)
+  }
+}
+
+func TestModeration(t *testing.T) {
+  server := newTestServer()
+  defer server.Close()
+  ctx := context.Background()
+
+  alice, bob := New(server.URL), New(server.URL)
+  roomID, err := alice.CreateRoom(ctx, superghost.Config{ MaxPlayers: 2 })
+  if err != nil {
+    t.Fatal(err)
+  }
+  if _, err := alice.Join(ctx, roomID, "alice"); err != nil {
+    t.Fatal(err)
+  }
+  if _, err := bob.Join(ctx, roomID, "bob"); err != nil {
+    t.Fatal(err)
+  }
+
+  msg, err := bob.Chat(ctx, roomID, "spam")
+  if err != nil {
+    t.Fatal(err)
+  }
+  if _, err := alice.Mute(ctx, roomID, "bob"); err != nil {
+    t.Fatal(err)
+  }
+  if _, err := bob.Chat(ctx, roomID, "more spam");
+      !errors.Is(err, superghost.ErrMuted) {
+    t.Fatalf("expected muted error, got %v", err)
+  }
+  if err := alice.DeleteMessage(ctx, roomID, msg.ID); err != nil {
+    t.Fatal(err)
+  }
+  room, err := alice.Unmute(ctx, roomID, "bob")
+  if err != nil {
+    t.Fatal(err)
+  }
+  if len(room.Muted) != 0 || len(room.Chat) != 0 {
+    t.Fatalf("expected nobody muted and no chat, got %v and %+v",
+             room.Muted, room.Chat)
+  }
+}
+
+func TestSubscribe(t *testing.T) {
+  server := newTestServer()
+  defer server.Close()
+  ctx, cancel := context.WithTimeout(context.Background(), 5 * time.Second)
+  defer cancel()
+
+  alice, bob := New(server.URL), New(server.URL)
+  roomID, err := alice.CreateRoom(ctx, superghost.Config{ MaxPlayers: 2 })
+  if err != nil {
+    t.Fatal(err)
+  }
+  if _, err := alice.Join(ctx, roomID, "alice"); err != nil {
+    t.Fatal(err)
+  }
+
+  states := make(chan *superghost.JRoom)
+  chats := make(chan *superghost.Message)
+  done := make(chan error)
+  subCtx, stop := context.WithCancel(ctx)
+  go func() {
+    done <- alice.Subscribe(subCtx, roomID, Handlers {
+      OnState: func(room *superghost.JRoom) {
+        select {
+          case states <- room:
+          case <-subCtx.Done():
+        }
+      },
+      OnChat: func(msg *superghost.Message) {
+        select {
+          case chats <- msg:
+          case <-subCtx.Done():
+        }
+      },
+    })
+  }()
+
+  // The subscription can only see updates made after it starts polling, so
+  // keep poking the room until it notices.
+  go func() {
+    for subCtx.Err() == nil {
+      bob.Chat(ctx, roomID, "hello")
+      time.Sleep(10 * time.Millisecond)
+    }
+  }()
+  if _, err := bob.Join(ctx, roomID, "bob"); err != nil {
+    t.Fatal(err)
+  }
+
+  for gotChat := false; !gotChat; {
+    select {
+      case msg := <-chats:
+        if msg.Sender != "bob" || msg.Content != "hello" {
+          t.Fatalf("unexpected message %+v", msg)
+        }
+        gotChat = true
+      case <-states:
+      case <-ctx.Done():
+        t.Fatal("never got a chat message")
+    }
+  }
+
+  // Likewise, keep making moves until the subscription sees one
+  go func() {
+    for subCtx.Err() == nil {
+      alice.Affix(ctx, roomID, "", "G")
+      bob.Affix(ctx, roomID, "", "G")
+      time.Sleep(10 * time.Millisecond)
+    }
+  }()
+  for {
+    select {
+      case room := <-states:
+        if room.Stem != "" {
+          stop()
+          if err := <-done; err != context.Canceled {
+            t.Fatalf("expected Subscribe to end with ctx, got %v", err)
+          }
+          return
+        }
+      case <-chats:
+      case <-ctx.Done():
+        t.Fatal("never saw a move")
+    }
+  }
+}
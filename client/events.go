@@ -0,0 +1,94 @@
+package client
+
+import (
+  "context"
+  "superghost"
+  "time"
+)
+
+// How long to wait before polling again after a failed poll
+const kRetryDelay = time.Second
+
+// Callbacks for Subscribe. Any of them may be nil. They are never called
+// concurrently with each other.
+type Handlers struct {
+  OnState func(*superghost.JRoom)
+  // Also called when the host deletes a message, with a notice that has
+  // Deleted set and the deleted message's ID
+  OnChat func(*superghost.Message)
+  // Called whenever a poll fails. Polling resumes after a short delay.
+  OnError func(error)
+}
+
+type event struct {
+  room *superghost.JRoom
+  msg *superghost.Message
+  err error
+}
+
+// Calls the handlers for every state change and chat message in the room until
+// ctx is done, then returns ctx.Err(). Handlers run on the calling goroutine.
+//
+// The server only delivers updates to requests that are waiting when the
+// update happens, so an update that lands while the previous one is being
+// handled can be missed. Every state still carries the full list of players,
+// and State can be used to catch up on the log.
+func (c *Client) Subscribe(ctx context.Context, roomID string,
+                           handlers Handlers) error {
+  ctx, cancel := context.WithCancel(ctx)
+  defer cancel()
+
+  events := make(chan event)
+  go c.poll(ctx, events, func() event {
+    room, err := c.NextState(ctx, roomID)
+    return event{ room: room, err: err }
+  })
+  if handlers.OnChat != nil {
+    go c.poll(ctx, events, func() event {
+      msg, err := c.NextChat(ctx, roomID)
+      return event{ msg: msg, err: err }
+    })
+  }
+
+  for {
+    select {
+      case <-ctx.Done():
+        return ctx.Err()
+
+      case e := <-events:
+        switch {
+          case e.err != nil:
+            if handlers.OnError != nil {
+              handlers.OnError(e.err)
+            }
+          case e.room != nil:
+            if handlers.OnState != nil {
+              handlers.OnState(e.room)
+            }
+          case e.msg != nil:
+            handlers.OnChat(e.msg)
+        }
+    }
+  }
+}
+
+func (c *Client) poll(ctx context.Context, events chan<- event,
+                      next func() event) {
+  for ctx.Err() == nil {
+    e := next()
+    if ctx.Err() != nil {
+      return  // The error is just the cancellation
+    }
+    select {
+      case events <- e:
+      case <-ctx.Done():
+        return
+    }
+    if e.err != nil {
+      select {
+        case <-time.After(kRetryDelay):
+        case <-ctx.Done():
+      }
+    }
+  }
+}
@@ -0,0 +1,14 @@
+module client
+
+go 1.17
+
+replace sgserver => ../server
+
+replace superghost => ../superghost
+
+require (
+	sgserver v0.0.0-00010101000000-000000000000
+	superghost v0.0.0-00010101000000-000000000000
+)
+
+require github.com/go-chi/chi/v5 v5.0.7 // indirect
@@ -1,19 +1,58 @@
 "use strict";
 
 class ChatManager extends ListManager {
+  textarea_;
+
   constructor(ol, form, textarea) {
     super(ol);
+    this.textarea_ = textarea;
 
     form.addEventListener('submit', ChatManager.handleSendChat);
     textarea.addEventListener("keydown", ChatManager.handleChatKeydown);
   }
 
+  // Shows the delete buttons to the host, and stops muted players typing
+  update(room, myUsername) {
+    const isHost = room.Players.length > 0 &&
+        room.Players[0].Username == myUsername;
+    this.ol_.dataset.isHost = isHost ? "true" : "false";
+    const isMuted = (room.Muted ?? []).includes(myUsername);
+    this.textarea_.disabled = isMuted;
+    this.textarea_.placeholder = isMuted ? kErrorMessages["muted"] : "";
+  }
+
+  // Replaces whatever is shown with the room's chat history
+  setHistory(messages) {
+    Client.clearElement(this.ol_);
+    for (const msg of messages ?? []) {
+      this.append(msg);
+    }
+    this.scrollToBottom();
+  }
+
   append(msg) {
+    // The host deleted a message
+    if (msg.Deleted) {
+      const li = this.ol_.querySelector(`li[data-id="${msg.ID}"]`);
+      if (li) {
+        li.remove();
+      }
+      return;
+    }
+
     const isScrolledToBottom = this.checkScrolledToBottom();
 
     const newLI = document.createElement("li");
+    newLI.dataset.id = msg.ID;
     this.ol_.appendChild(newLI);
 
+    // Hidden unless you're the host
+    const deleteButton = Client.createStandaloneButton("Delete");
+    deleteButton.classList.add("delete-message-button");
+    deleteButton.addEventListener('click',
+                                  () => ChatManager.deleteChat(msg.ID));
+    newLI.appendChild(deleteButton);
+
     newLI.appendChild(Client.createUsernameSpan(msg.Sender));
 
     let content = document.createTextNode(": " + msg.Content);
@@ -40,9 +79,8 @@ class ChatManager extends ListManager {
      return fetch(window.location.pathname + '/next-chat')
          .then(response => {
            if (!response.ok) {
-             response.text().then(t => {
-               throw new Error(t);
-             });
+             return ServerError.fromResponse(response)
+                 .then(err => {throw err;});
            }
            return response.json();
          });
@@ -59,6 +97,17 @@ class ChatManager extends ListManager {
     }
   }
 
+  // Everyone, including us, takes it down when the notice comes through
+  static deleteChat(id) {
+    fetch(window.location.pathname + '/chat/' + id, { method: 'DELETE' })
+        .then(response => {
+          if (!response.ok) {
+            ServerError.fromResponse(response).then(err => console.error(err));
+          }
+        })
+        .catch(err => console.error(err));
+  }
+
   static handleSendChat(e) {
     e.preventDefault();
     const data = new URLSearchParams(new FormData(e.target));
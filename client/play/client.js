@@ -23,14 +23,25 @@ class Client {
     this.configManager_ = new ConfigManager(
         document.getElementById("config-span"),
         document.getElementById("show-config-button"),
-        document.getElementById("config-dialog"));
+        document.getElementById("config-dialog"),
+        document.getElementById("word-lists-form"),
+        document.getElementById("word-lists-err"),
+        document.getElementById("invite-form"));
     this.dashboardManager_ = new DashboardManager({
       dashboard: document.getElementById("dashboard"),
       affixForm: document.getElementById("affix-form"),
+      insertForm: document.getElementById("insert-form"),
       rebutForm: document.getElementById("rebut-form"),
       concedeButton: document.getElementById("concede-button"),
       challengeContinuationButton: document.getElementById("ch-cont-button"),
       challengeIsWordButton: document.getElementById("ch-word-button"),
+      reverseButton: document.getElementById("reverse-button"),
+      hintButton: document.getElementById("hint-button"),
+      hintSpan: document.getElementById("hint-span"),
+      overturnButton: document.getElementById("overturn-button"),
+      upholdButton: document.getElementById("uphold-button"),
+      pauseButton: document.getElementById("pause-button"),
+      resumeButton: document.getElementById("resume-button"),
       shortStatusSpan: document.getElementById("short-status"),
       activeStemSpans: document.getElementsByClassName("active-stem"),
       onlyEnabledOnMyTurn:
@@ -46,9 +57,12 @@ class Client {
   renderGameState(room) {
     const deadline = Date.parse(room.CurrentPlayerDeadline)
     this.dashboardManager_.update(room, this.myUsername_);
+    this.configManager_.update(room, this.myUsername_);
+    this.chatManager_.update(room, this.myUsername_);
     this.playersManager_.update(
         room.Players, room.State, room.CurrentPlayerUsername, deadline,
-        this.myUsername_, this.configManager_.config().MaxPlayers);
+        this.myUsername_, this.configManager_.config().MaxPlayers,
+        room.Muted ?? []);
     this.gameLogManager_.push(room.LogPush);
   }
 
@@ -60,7 +74,8 @@ class Client {
               if (response.ok) {
                 return response.json();
               }
-              response.text().then(txt => {throw new Error(txt);});
+              return ServerError.fromResponse(response)
+                  .then(err => {throw err;});
             });
 
         console.log(gameState);
@@ -72,8 +87,15 @@ class Client {
   }
 
   async enterGameLoop() {
-    await this.configManager_.forceGetConfig();
+    if (!await this.configManager_.forceGetConfig()) {
+      // Rooms with a password are hidden until the player joins
+      document.getElementById("join-dialog").showModal();
+      return;
+    }
     this.configManager_.populateDisplay();
+    this.dashboardManager_.setVariant(this.configManager_.config().Variant);
+    this.dashboardManager_.setPractice(this.configManager_.config().Practice);
+    this.joinManager_.setTeamCount(this.configManager_.config().TeamCount);
     // The only case where cancel-leave response is not ok is when the server
     // doesn't recognize the player
     const cancelLeaveResponse = await Client.cancelLeave();
@@ -87,9 +109,13 @@ class Client {
           if (response.ok) {
             return response.json();
           }
-          response.text().then(txt => {throw new Error(txt);});
+          return ServerError.fromResponse(response)
+              .then(err => {throw err;});
+        })
+        .then(room => {
+          this.renderGameState(room);
+          this.chatManager_.setHistory(room.Chat);
         })
-        .then(room => this.renderGameState(room))
         .catch(err => console.error(err));
 
     // Subscribe to changes
@@ -135,7 +161,7 @@ class Client {
     fetch(path, { method: 'POST', body: data })
         .then(response => {
           if (!response.ok) {
-            console.error(response.text());
+            ServerError.fromResponse(response).then(err => console.error(err));
           }
           try {
             e.target.reset();
@@ -3,10 +3,91 @@
 class ConfigManager {
   span_;
   config_;
+  wordListsForm_;
+  wordListsErr_;
+  inviteForm_;
 
-  constructor(span, showConfigButton, configDialog) {
+  constructor(span, showConfigButton, configDialog, wordListsForm,
+              wordListsErr, inviteForm) {
     this.span_ = span;
-    showConfigButton.addEventListener('click', () => configDialog.showModal());
+    this.wordListsForm_ = wordListsForm;
+    this.wordListsErr_ = wordListsErr;
+    this.inviteForm_ = inviteForm;
+    // The host may have changed the word lists since we last looked
+    showConfigButton.addEventListener('click', () => {
+      this.refresh().then(() => configDialog.showModal());
+    });
+    wordListsForm.addEventListener('submit',
+                                   this.handleSaveWordLists.bind(this));
+    inviteForm.addEventListener('submit', this.handleInvite.bind(this));
+  }
+
+  // Only the host can edit the word lists, and only between games. They can
+  // invite players whenever.
+  update(room, myUsername) {
+    const isHost = room.Players.length > 0 &&
+        room.Players[0].Username == myUsername;
+    this.wordListsForm_.hidden = !(isHost && room.State == "waiting to start");
+    this.inviteForm_.hidden = !isHost;
+  }
+
+  async refresh() {
+    const config = await fetch(window.location.pathname + '/config')
+        .then(response => response.json())
+        .catch(error => console.error("Error getting config: " + error));
+    if (config) {
+      this.config_ = config;
+      this.populateDisplay();
+    }
+  }
+
+  populateWordListsForm() {
+    const elements = this.wordListsForm_.elements;
+    elements["AllowedWords"].value =
+        (this.config_.AllowedWords || []).join("\n");
+    elements["BlockedWords"].value =
+        (this.config_.BlockedWords || []).join("\n");
+  }
+
+  handleSaveWordLists(e) {
+    e.preventDefault();
+    this.wordListsErr_.textContent = "";
+    const data = new URLSearchParams(new FormData(e.target));
+    fetch(window.location.pathname + '/word-lists',
+          { method: 'POST', body: data })
+        .then(response => {
+          if (!response.ok) {
+            return ServerError.fromResponse(response).then(err => {
+              this.wordListsErr_.textContent = err.message;
+            });
+          }
+          return response.json().then(config => {
+            this.config_ = config;
+            this.populateDisplay();
+          });
+        })
+        .catch(err => console.error(err));
+  }
+
+  handleInvite(e) {
+    e.preventDefault();
+    const link = this.inviteForm_.elements["invite-link"];
+    const err = document.getElementById("invite-err");
+    err.textContent = "";
+    fetch(window.location.pathname + '/invites', { method: 'POST' })
+        .then(response => {
+          if (!response.ok) {
+            return ServerError.fromResponse(response).then(serverErr => {
+              err.textContent = serverErr.message;
+            });
+          }
+          return response.json().then(invite => {
+            link.value = window.location.origin + invite.Link;
+            link.hidden = false;
+            link.select();
+          });
+        })
+        .catch(err => console.error(err));
   }
 
   config() {
@@ -22,6 +103,7 @@ class ConfigManager {
 
   populateDisplay() {
     Client.clearElement(this.span_);
+    this.populateWordListsForm();
 
     for (const key in this.config_) {
       const keyWords = key.match(/([A-Z]?[^A-Z]*)/g).slice(0,-1);
@@ -29,6 +111,12 @@ class ConfigManager {
       this.span_.appendChild(document.createTextNode(keyFormatted + ": "));
       switch (key) {
         // Any specialized formatting goes here
+        case "AllowedWords":
+        case "BlockedWords":
+          this.span_.appendChild(
+              ConfigManager.createConfigValue(this.config_[key].join(", ")));
+          this.span_.appendChild(document.createElement("br"));
+          break;
         default:
           this.span_.appendChild(
               ConfigManager.createConfigValue(this.config_[key]));
@@ -37,11 +125,20 @@ class ConfigManager {
     }
   }
 
-	async forceGetConfig() {
-		while (!this.config_) {
-			this.config_ = await fetch(window.location.pathname + '/config')
-					.then(response => response.json())
-					.catch(error => console.error("Error getting config: " + error));
+  // Resolves false if the room has a password and the player hasn't joined,
+  // since only its players can see its config
+  async forceGetConfig() {
+    while (!this.config_) {
+      const response = await fetch(window.location.pathname + '/config')
+          .catch(error => console.error("Error getting config: " + error));
+      if (response?.status == 401) {
+        return false;
+      }
+      if (response?.ok) {
+        this.config_ = await response.json()
+            .catch(error => console.error("Error getting config: " + error));
+      }
     }
+    return true;
   }
 }
@@ -3,30 +3,58 @@
 class DashboardManager {
   dashboard_;
   affixForm_;
+  insertForm_;
   rebutForm_;
   onlyEnabledOnMyTurn_;
   activeStemSpans_;
   shortStatusSpan_;
+  hintSpan_;
 
   // Use an opts struct to make this less error-prone
   constructor(opts) {
     this.dashboard_ = opts.dashboard;
     this.affixForm_ = opts.affixForm;
+    this.insertForm_ = opts.insertForm;
     this.rebutForm_ = opts.rebutForm;
     this.challengeContinuationButton_ = opts.challengeContinuationButton;
     this.challengeIsWordButton_ = opts.challengeIsWordButton;
     this.onlyEnabledOnMyTurn_ = opts.onlyEnabledOnMyTurn;
     this.activeStemSpans_ = opts.activeStemSpans;
     this.shortStatusSpan_ = opts.shortStatusSpan;
+    this.hintSpan_ = opts.hintSpan;
 
     this.affixForm_.addEventListener('submit', this.handleAffix.bind(this));
+    this.insertForm_.addEventListener('submit', this.handleInsert.bind(this));
     this.rebutForm_.addEventListener('submit', this.handleRebut.bind(this));
     opts.challengeContinuationButton.addEventListener(
         'click', this.handleChallengeContinuation.bind(this));
     opts.challengeIsWordButton.addEventListener(
         'click', this.handleChallengeIsWord.bind(this));
+    opts.reverseButton.addEventListener('click',
+                                        this.handleReverse.bind(this));
+    opts.hintButton.addEventListener('click', this.handleHint.bind(this));
     opts.concedeButton.addEventListener('click',
                                          this.handleConcede.bind(this));
+    opts.overturnButton.addEventListener(
+        'click', (e) => this.handleJuryVote(e, true));
+    opts.upholdButton.addEventListener(
+        'click', (e) => this.handleJuryVote(e, false));
+    opts.pauseButton.addEventListener('click', this.handlePause.bind(this));
+    opts.resumeButton.addEventListener('click', this.handleResume.bind(this));
+  }
+
+  // Classic ghost only allows suffixes, so there's nothing to prefix with.
+  // Anything only some variants use is hidden by the stylesheet.
+  setVariant(variant) {
+    this.dashboard_.dataset.variant = variant;
+    for (const form of [this.affixForm_, this.rebutForm_]) {
+      form.elements["prefix"].hidden = (variant == "ghost");
+    }
+  }
+
+  // Only practice rooms give hints
+  setPractice(practice) {
+    this.dashboard_.dataset.practice = practice ? "true" : "false";
   }
 
   update(room, myUsername) {
@@ -35,6 +63,33 @@ class DashboardManager {
                            room.State, myUsername);
     this.updateButtons(room.State, room.CurrentPlayerUsername, myUsername);
     this.updateActiveStemSpans(room.Stem);
+    this.updatePause(room.Paused, room.PauseVotes || []);
+    this.updateJury(room.Jury, myUsername);
+  }
+
+  updateJury(jury, myUsername) {
+    this.dashboard_.dataset.isJuror =
+        (jury != null && jury.Jurors.includes(myUsername)) ? "true" : "false";
+    if (jury == null) {
+      return;
+    }
+    this.shortStatusSpan_.appendChild(document.createTextNode(
+        ` The jury has voted ${jury.VotesToOverturn} to overturn, ` +
+        `${jury.VotesToUphold} to uphold.`));
+  }
+
+  // Only the host's vote counts on its own, so show how many others agree
+  updatePause(isPaused, votes) {
+    this.dashboard_.dataset.paused = isPaused ? "true" : "false";
+    if (isPaused) {
+      Client.clearElement(this.shortStatusSpan_);
+      this.shortStatusSpan_.appendChild(
+          document.createTextNode("The game is paused."));
+    }
+    if (votes.length > 0) {
+      this.shortStatusSpan_.appendChild(document.createTextNode(
+          ` ${votes.length} voted to ${isPaused ? "resume" : "pause"}.`));
+    }
   }
 
   updateShortStatus(nextPlayer, lastPlayer, state, myUsername) {
@@ -65,6 +120,10 @@ class DashboardManager {
         this.shortStatusSpan_.appendChild(
             document.createTextNode("Waiting for 2+ players."));
         break;
+      case "jury":
+        this.shortStatusSpan_.appendChild(document.createTextNode(
+            "The other players are voting on the dictionary's ruling."));
+        break;
       default:  // (Indicative of a bug)
         this.shortStatusSpan_.appendChild(document.createTextNode("? (Not implemented)"));
     }
@@ -88,7 +147,9 @@ class DashboardManager {
   }
 
   resetGameForms() {
+    Client.clearElement(this.hintSpan_);
     this.affixForm_.reset();
+    this.insertForm_.reset();
     this.rebutForm_.reset();
   }
 
@@ -99,6 +160,13 @@ class DashboardManager {
         e, window.location.pathname + '/affix', data)
   }
 
+  handleInsert(e) {
+    e.preventDefault();
+    const data = new URLSearchParams(new FormData(e.target));
+    Client.postDataResetTargetOnSuccess(
+        e, window.location.pathname + '/insertion', data)
+  }
+
   handleRebut(e) {
     e.preventDefault();
     const data = new URLSearchParams(new FormData(e.target));
@@ -111,6 +179,45 @@ class DashboardManager {
         e, window.location.pathname + '/challenge-continuation', null)
   }
 
+  handleReverse(e) {
+    Client.postDataResetTargetOnSuccess(
+        e, window.location.pathname + '/reversal', null)
+  }
+
+  // Hints are for whoever asked, so they're shown here rather than broadcast
+  handleHint(e) {
+    fetch(window.location.pathname + '/hint')
+        .then(response => {
+          if (!response.ok) {
+            return ServerError.fromResponse(response)
+                .then(err => {throw err;});
+          }
+          return response.json();
+        })
+        .then(hint => this.showHint(hint))
+        .catch(err => console.error(err));
+  }
+
+  showHint(hint) {
+    const moves = hint.Moves.map(m => {
+      if (m.Reverse) {
+        return "reversing";
+      }
+      if (m.Letter) {
+        return `${m.Letter} before letter ${m.Index + 1}`;
+      }
+      return m.Prefix ? m.Prefix + "…" : "…" + m.Suffix;
+    });
+    let text = moves.length > 0 ?
+        "Try " + moves.join(" or ") + "." :
+        "Nothing is safe: challenge!";
+    if (hint.Example) {
+      text += ` (e.g. ${hint.Example})`;
+    }
+    Client.clearElement(this.hintSpan_);
+    this.hintSpan_.appendChild(document.createTextNode(text));
+  }
+
   handleChallengeIsWord(e) {
     Client.postDataResetTargetOnSuccess(
         e, window.location.pathname + '/challenge-is-word', null)
@@ -121,6 +228,22 @@ class DashboardManager {
         e, window.location.pathname + '/concession', null)
   }
 
+  handleJuryVote(e, overturn) {
+    const data = new URLSearchParams({Overturn: overturn});
+    Client.postDataResetTargetOnSuccess(
+        e, window.location.pathname + '/jury-vote', data)
+  }
+
+  handlePause(e) {
+    Client.postDataResetTargetOnSuccess(
+        e, window.location.pathname + '/pause', null)
+  }
+
+  handleResume(e) {
+    Client.postDataResetTargetOnSuccess(
+        e, window.location.pathname + '/resume', null)
+  }
+
   static createPlayersOrYourSpan(player, isMe) {
     const el = document.createElement("span");
     if (isMe) {
@@ -0,0 +1,57 @@
+"use strict";
+
+// Server errors come back as {code, message}. The message is English and may
+// contain request-specific details; these are the strings we show instead,
+// keyed by code. Anything missing falls back to the server's message.
+const kErrorMessages = {
+  "invalid-credentials": "You are not a player in this room.",
+  "not-your-turn": "It is not your turn.",
+  "wrong-state": "You can't do that right now.",
+  "invalid-move": "Enter exactly one letter, before OR after the stem.",
+  "below-min-length": "That is shorter than the minimum word length.",
+  "empty-stem": "The stem is empty.",
+  "invalid-word": "Words may only contain letters.",
+  "word-used": "That word has already been used.",
+  "dictionary-unavailable":
+      "The dictionary is unavailable right now. Please try again.",
+  "room-full": "This room is full.",
+  "invalid-username": "Usernames may only contain letters and numbers.",
+  "username-taken": "That username is already taken.",
+  "player-not-found": "That player is not in this room.",
+  "not-host": "Only the host can do that.",
+  "eliminated": "You can't do that once you have been eliminated.",
+  "empty-message": "Can't send an empty message.",
+  "message-too-long": "Messages can be at most 500 characters.",
+  "message-blocked": "That message isn't allowed here.",
+  "message-not-found": "That message is already gone.",
+  "muted": "The host has muted you.",
+  "already-leaving": "You are already leaving this room.",
+  "not-entrant": "Only the players drawn into this match can join it.",
+  "already-queued": "You are already waiting to play.",
+  "wrong-password": "That isn't the room's password.",
+  "invalid-invite": "That invite has expired. Ask the host for a new one.",
+  "rate-limited": "You're doing that too often. Please wait a moment.",
+  "too-many-rooms":
+      "The server is full right now. Please try again in a few minutes.",
+  "no-puzzle": "There is no puzzle today. Please try again later.",
+};
+
+class ServerError extends Error {
+  code;
+
+  constructor(code, message) {
+    super(kErrorMessages[code] ?? message);
+    this.code = code;
+  }
+
+  // Builds an error from a non-ok response, whether or not its body is JSON.
+  static async fromResponse(response) {
+    const txt = await response.text();
+    try {
+      const body = JSON.parse(txt);
+      return new ServerError(body.code, body.message);
+    } catch (err) {
+      return new ServerError(null, `${response.status} ${txt}`);
+    }
+  }
+}
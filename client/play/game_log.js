@@ -24,6 +24,22 @@ class GameLogManager extends ListManager {
       link.appendChild(document.createTextNode(word));
       return link;
     }
+    // "AARDVARK (noun): a nocturnal burrowing mammal...", with the
+    // etymology, if any, on hover.
+    function describeDefinition(word, definition) {
+      const div = document.createElement("div");
+      div.classList.add("definition");
+      div.appendChild(bold(word));
+      if (definition.PartOfSpeech) {
+        div.appendChild(
+            document.createTextNode(" (" + definition.PartOfSpeech + ")"));
+      }
+      div.appendChild(document.createTextNode(": " + definition.Text));
+      if (definition.Etymology) {
+        div.title = definition.Etymology;
+      }
+      return div;
+    }
     function valOrEmpty(val) {
       return (typeof val === "undefined") ? "" : val;
     }
@@ -72,6 +88,9 @@ class GameLogManager extends ListManager {
         txt.appendChild(document.createTextNode(" a word! +1 "));
         txt.appendChild(Client.createUsernameSpan(msg.To));
         txt.appendChild(document.createTextNode("."));
+        if (msg.Definition) {
+          txt.appendChild(describeDefinition(msg.Stem, msg.Definition));
+        }
         return txt;
 
       case "ChallengedPlayerLeft":
@@ -83,6 +102,14 @@ class GameLogManager extends ListManager {
 
       case "Rebut":
         txt.appendChild(Client.createUsernameSpan(msg.From));
+        if (msg.Word) {
+          txt.appendChild(document.createTextNode(" rebutted "));
+          txt.appendChild(Client.createStemSpan(msg.Stem));
+          txt.appendChild(document.createTextNode(" with "));
+          txt.appendChild(Client.createStemSpan(bold(msg.Word)));
+          txt.appendChild(document.createTextNode("."));
+          return txt;
+        }
         txt.appendChild(document.createTextNode(" rebutted with "));
         txt.appendChild(Client.createStemSpan(bold(valOrEmpty(msg.Prefix))));
         txt.appendChild(Client.createStemSpan(msg.Stem));
@@ -98,6 +125,23 @@ class GameLogManager extends ListManager {
         txt.appendChild(Client.createStemSpan(bold(valOrEmpty(msg.Suffix))));
         return txt;
 
+      case "Insert":
+        txt.appendChild(Client.createUsernameSpan(msg.From));
+        txt.appendChild(document.createTextNode(": "));
+        txt.appendChild(
+            Client.createStemSpan(valOrEmpty(msg.Stem.slice(0, msg.Index))));
+        txt.appendChild(Client.createStemSpan(bold(msg.Letter)));
+        txt.appendChild(
+            Client.createStemSpan(valOrEmpty(msg.Stem.slice(msg.Index))));
+        return txt;
+
+      case "Reverse":
+        txt.appendChild(Client.createUsernameSpan(msg.From));
+        txt.appendChild(document.createTextNode(" reversed "));
+        txt.appendChild(Client.createStemSpan(valOrEmpty(msg.Stem)));
+        txt.appendChild(document.createTextNode("."));
+        return txt;
+
       case "Concede":
         txt.appendChild(Client.createUsernameSpan(msg.From));
         txt.appendChild(document.createTextNode(" conceded the round. +1 "));
@@ -123,6 +167,13 @@ class GameLogManager extends ListManager {
         txt.appendChild(Client.createUsernameSpan(msg.To));
         txt.appendChild(document.createTextNode(
             " won the game! Ready up to play another."));
+        if (msg.Game) {
+          const link = document.createElement('a');
+          link.href = window.location.pathname + '/analysis/' + msg.Game;
+          link.target = '_blank';
+          link.textContent = ' See how it went.';
+          txt.appendChild(link);
+        }
         return txt;
 
       case "GameStart":
@@ -142,6 +193,39 @@ class GameLogManager extends ListManager {
             "There aren't enough players continue play."));
         return txt;
 
+      case "SuddenDeath":
+        txt.appendChild(document.createTextNode(
+            "Sudden death! Whoever loses the next round is out."));
+        return txt;
+
+      case "JuryVerdict":
+        txt.appendChild(document.createTextNode("The jury "));
+        txt.appendChild(
+            bold(msg.Success ? "overturned" : "upheld"));
+        txt.appendChild(document.createTextNode(" the ruling on "));
+        txt.appendChild(
+            Client.createStemSpan(document.createTextNode(msg.Stem)));
+        txt.appendChild(document.createTextNode(
+            ` (${msg.VotesToOverturn || 0}-${msg.VotesToUphold || 0}). +1 `));
+        txt.appendChild(Client.createUsernameSpan(msg.To));
+        txt.appendChild(document.createTextNode("."));
+        return txt;
+
+      case "Pause":
+        txt.appendChild(Client.createUsernameSpan(msg.From));
+        txt.appendChild(document.createTextNode(" paused the game."));
+        return txt;
+
+      case "Resume":
+        txt.appendChild(Client.createUsernameSpan(msg.From));
+        txt.appendChild(document.createTextNode(" resumed the game."));
+        return txt;
+
+      case "Hint":
+        txt.appendChild(Client.createUsernameSpan(msg.From));
+        txt.appendChild(document.createTextNode(" asked for a hint."));
+        return txt;
+
       case "ReadyUp":
         txt.appendChild(Client.createUsernameSpan(msg.From));
         txt.appendChild(document.createTextNode(" is ready."));
@@ -9,24 +9,50 @@ class JoinManager {
     this.joinErrorSpan_ = joinErrorSpan;
 
     this.joinForm_.addEventListener('submit', this.handleJoin.bind(this));
+    // Invite links let players in without the password
+    if (JoinManager.invite()) {
+      document.getElementById("join-password-wrapper").hidden = true;
+    }
+  }
+
+  static invite() {
+    return new URLSearchParams(window.location.search).get("invite");
+  }
+
+  // Lets the player pick a team when the room plays in teams
+  setTeamCount(teamCount) {
+    if (!(teamCount >= 2)) {
+      return;
+    }
+    const select = this.joinForm_.elements["team"];
+    for (let i = 1; i <= teamCount; i++) {
+      const option = document.createElement("option");
+      option.value = i;
+      option.appendChild(document.createTextNode(`Team ${i}`));
+      select.appendChild(option);
+    }
+    document.getElementById("join-team-wrapper").hidden = false;
   }
 
   renderJoinErr(err) {
-    Client.clearElement(this.joinErr_);
-    this.joinErr_.appendChild(document.createTextNode(err));
+    Client.clearElement(this.joinErrorSpan_);
+    this.joinErrorSpan_.appendChild(document.createTextNode(err));
   }
 
   handleJoin(e) {
     e.preventDefault();
     const data = new URLSearchParams(new FormData(e.target));
+    if (JoinManager.invite()) {
+      data.append("invite", JoinManager.invite());
+    }
 
     fetch(window.location.pathname + '/join', { method: 'POST', body: data, })
         .then(response => {
           if (response.ok) {
             window.location.reload();
           } else {
-            response.text().then(txt => {
-              this.renderJoinErr(txt);
+            ServerError.fromResponse(response).then(err => {
+              this.renderJoinErr(err.message);
             });
           }
         })
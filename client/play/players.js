@@ -9,17 +9,18 @@ class PlayersManager extends ListManager {
   }
 
   update(players, state, currentPlayerUsername,
-         currentPlayerDeadline, myUsername, maxPlayers) {
+         currentPlayerDeadline, myUsername, maxPlayers, muted) {
     Client.clearElement(this.ol_);
 
     const hostIsMe = players.length > 0 && players[0].Username == myUsername;
     for (const playerObj of players) {
       const isCurrentPlayer = playerObj.Username == currentPlayerUsername;
       const isMe = playerObj.Username == myUsername;
+      const isMuted = muted.includes(playerObj.Username);
       const deadline = isCurrentPlayer ? currentPlayerDeadline : null;
       // Make the display for this player
       const display = new PlayerDisplay(playerObj, state, isCurrentPlayer, isMe,
-                                        hostIsMe, deadline);
+                                        hostIsMe, isMuted, deadline);
 
       this.ol_.appendChild(display.li());
     }
@@ -52,18 +53,20 @@ class PlayerDisplay {
   isCurrentPlayer;
   isMe_;
   hostIsMe_;
+  isMuted_;
   deadline_;
   li_;
   timer_;
   eventListenerManager_;
 
-  constructor(playerObj, state, isCurrentPlayer, isMe, hostIsMe,
+  constructor(playerObj, state, isCurrentPlayer, isMe, hostIsMe, isMuted,
               deadline = null) {
     this.playerObj_ = playerObj;
     this.state_ = state;
     this.isCurrentPlayer = isCurrentPlayer;
     this.isMe_ = isMe;
     this.hostIsMe_ = hostIsMe;
+    this.isMuted_ = isMuted;
     this.deadline_ = deadline;
     this.eventListenerManager_ = new EventListenerManager();
 
@@ -97,17 +100,32 @@ class PlayerDisplay {
     if (isMe) {
       username.appendChild(document.createTextNode(" (you)"));
     }
+    if (playerObj.Team) {
+      username.appendChild(
+          document.createTextNode(` (Team ${playerObj.Team})`));
+    }
+    if (isMuted) {
+      username.appendChild(document.createTextNode(" (muted)"));
+    }
+    if (playerObj.HintsUsed) {
+      username.appendChild(document.createTextNode(
+          ` (${playerObj.HintsUsed} hint${playerObj.HintsUsed > 1 ? "s" : ""})`));
+    }
     leftCol.appendChild(username);
 
     const score = document.createElement("div");
     score.classList.add("player-score");
     score.appendChild(document.createTextNode(
-        PlayerDisplay.scoreToString(playerObj.Score)));
+        playerObj.ScoreDisplay ||
+            PlayerDisplay.scoreToString(playerObj.Score)));
     leftCol.appendChild(score);
 
     if (isCurrentPlayer) {
-      this.timer_ = new TickingPlayerTimer(deadline);
       this.li_.dataset.activePlayer = this.state;
+    }
+    // No deadline means the clock isn't running (e.g. the game is paused)
+    if (isCurrentPlayer && deadline > 0) {
+      this.timer_ = new TickingPlayerTimer(deadline);
     } else {
       this.timer_ = new FixedPlayerTimer(playerObj.TimeRemaining);
     }
@@ -133,6 +151,14 @@ class PlayerDisplay {
           PlayerDisplay.createKickHandler(this.playerObj_.Username);
       this.eventListenerManager_.push(kickButton, 'click', kickHandler);
       menu.appendChild(kickButton);
+
+      const muteAction = this.isMuted_ ? "unmute" : "mute";
+      const muteButton = Client.createStandaloneButton(
+          this.isMuted_ ? "Unmute" : "Mute");
+      const muteHandler =
+          PlayerDisplay.createMuteHandler(this.playerObj_.Username, muteAction);
+      this.eventListenerManager_.push(muteButton, 'click', muteHandler);
+      menu.appendChild(muteButton);
     }
 
     const closeMenu = Client.createStandaloneButton("Close");
@@ -150,6 +176,15 @@ class PlayerDisplay {
     return str;
   }
 
+  // action is "mute" or "unmute"
+  static createMuteHandler(username, action) {
+    return function(e) {
+      const data = new URLSearchParams({Username: username});
+      Client.postDataResetTargetOnSuccess(
+          e, window.location.pathname + '/' + action, data)
+    };
+  }
+
   static createKickHandler(username) {
     return function(e) {
       const data = new URLSearchParams({Username: username});
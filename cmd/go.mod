@@ -0,0 +1,17 @@
+module sgcmd
+
+go 1.17
+
+replace client => ../client
+
+replace sgserver => ../server
+
+replace superghost => ../superghost
+
+require (
+	client v0.0.0-00010101000000-000000000000
+	sgserver v0.0.0-00010101000000-000000000000
+	superghost v0.0.0-00010101000000-000000000000
+)
+
+require github.com/go-chi/chi/v5 v5.0.7 // indirect
@@ -0,0 +1,188 @@
+package main
+
+import (
+  "client"
+  "context"
+  "errors"
+  "fmt"
+  "math/rand"
+  "strings"
+  "superghost"
+  "sync"
+  "time"
+)
+
+// How often a bot challenges a stem it knows can be continued, just to
+// exercise rebuttals
+const kBluffChallengeRate = 0.05
+
+// Gives up on a room after this many moves without the game ending
+const kMaxMovesPerRoom = 2000
+
+type roomResult struct {
+  gamesFinished int
+  moves int
+  failedMoves int
+  updatesReceived int
+  logItemsReceived int
+  logItemsTotal int
+  err error
+}
+
+// Plays games in one room with a handful of scripted players
+type roomRunner struct {
+  roomID string
+  usernames []string
+  players map[string]*client.Client
+  words *wordIndex
+  minWordLength int
+  rng *rand.Rand
+  requestTimeout time.Duration
+  recorder *latencyRecorder
+}
+
+// Runs one request with the per-request timeout, noting if it ran out
+func (rr *roomRunner) call(ctx context.Context,
+                           f func(context.Context) error) error {
+  ctx, cancel := context.WithTimeout(ctx, rr.requestTimeout)
+  defer cancel()
+  err := f(ctx)
+  if errors.Is(err, context.DeadlineExceeded) {
+    rr.recorder.recordTimeout()
+  }
+  return err
+}
+
+func (rr *roomRunner) join(ctx context.Context) error {
+  for _, username := range rr.usernames {
+    err := rr.call(ctx, func(ctx context.Context) error {
+      _, err := rr.players[username].Join(ctx, rr.roomID, username)
+      return err
+    })
+    if err != nil {
+      return fmt.Errorf("%s couldn't join %s: %w", username, rr.roomID, err)
+    }
+  }
+  return nil
+}
+
+// Counts what a subscriber sees of the room until ctx is done
+func (rr *roomRunner) observe(ctx context.Context, result *roomResult,
+                              wg *sync.WaitGroup) {
+  defer wg.Done()
+  observer := rr.players[rr.usernames[0]]
+  for ctx.Err() == nil {
+    room, err := observer.NextState(ctx, rr.roomID)
+    if err != nil {
+      time.Sleep(10 * time.Millisecond)  // Don't spin if the server is down
+      continue
+    }
+    result.updatesReceived++
+    result.logItemsReceived += len(room.LogPush)
+  }
+}
+
+// Plays until a game has been won (or something goes wrong)
+func (rr *roomRunner) run(ctx context.Context) roomResult {
+  var result roomResult
+  if result.err = rr.join(ctx); result.err != nil {
+    return result
+  }
+
+  // The joins happen before anyone is listening, so don't count them
+  var logItemsBefore int
+  if room, err := rr.players[rr.usernames[0]].State(ctx, rr.roomID);
+      err == nil {
+    logItemsBefore = len(room.LogPush)
+  }
+
+  observerCtx, stopObserving := context.WithCancel(ctx)
+  var wg sync.WaitGroup
+  wg.Add(1)
+  go rr.observe(observerCtx, &result, &wg)
+
+  for result.moves < kMaxMovesPerRoom {
+    var room *superghost.JRoom
+    err := rr.call(ctx, func(ctx context.Context) (err error) {
+      room, err = rr.players[rr.usernames[0]].State(ctx, rr.roomID)
+      return err
+    })
+    if err != nil {
+      result.err = err
+      break
+    }
+    if room.State == "waiting to start" {
+      result.gamesFinished++
+      break
+    }
+    result.moves++
+    if err := rr.move(ctx, room); err != nil {
+      result.failedMoves++
+    }
+  }
+  if result.moves >= kMaxMovesPerRoom {
+    result.err = fmt.Errorf("room %s: game never ended", rr.roomID)
+  }
+
+  // Give the observer a moment to see the last update before comparing
+  time.Sleep(50 * time.Millisecond)
+  stopObserving()
+  wg.Wait()
+  if room, err := rr.players[rr.usernames[0]].State(ctx, rr.roomID);
+      err == nil {
+    result.logItemsTotal = len(room.LogPush) - logItemsBefore
+  }
+  return result
+}
+
+// Makes whatever move the player who is up would make
+func (rr *roomRunner) move(ctx context.Context,
+                           room *superghost.JRoom) error {
+  player := rr.players[room.CurrentPlayerUsername]
+  stem := room.Stem
+  continuations := rr.words.containing(stem)
+
+  return rr.call(ctx, func(ctx context.Context) error {
+    if room.State == "rebut" {
+      if len(continuations) == 0 {
+        _, err := player.Concede(ctx, rr.roomID)
+        return err
+      }
+      word := continuations[rr.rng.Intn(len(continuations))]
+      i := strings.Index(word, stem)
+      _, err := player.Rebut(ctx, rr.roomID, word[:i], word[i + len(stem):])
+      return err
+    }
+
+    if len(stem) >= rr.minWordLength && rr.words.isWord[stem] {
+      _, err := player.ChallengeIsWord(ctx, rr.roomID)
+      return err
+    }
+    if len(stem) > 0 && (len(continuations) == 0 ||
+                         rr.rng.Float64() < kBluffChallengeRate) {
+      _, err := player.ChallengeContinuation(ctx, rr.roomID)
+      return err
+    }
+    if len(continuations) == 0 {
+      // Only happens with an empty stem and an empty word list
+      _, err := player.Affix(ctx, rr.roomID, "", "A")
+      return err
+    }
+
+    // Grow the stem towards a random word containing it
+    word := continuations[rr.rng.Intn(len(continuations))]
+    i := strings.Index(word, stem)
+    canPrefix, canSuffix := i > 0, i + len(stem) < len(word)
+    var err error
+    if canPrefix && (!canSuffix || rr.rng.Intn(2) == 0) {
+      _, err = player.Affix(ctx, rr.roomID, word[i-1:i], "")
+    } else if canSuffix {
+      _, err = player.Affix(ctx, rr.roomID, "",
+                            word[i + len(stem):i + len(stem) + 1])
+    } else {
+      // The stem is a word that's too short to challenge; leave it
+      _, err = player.Concede(ctx, rr.roomID)
+    }
+    return err
+  })
+}
@@ -0,0 +1,294 @@
+// superghost-loadtest plays many games at once against a superghost server
+// and reports how it held up.
+//
+// Usage:
+//   superghost-loadtest [-server URL] [-rooms N] [-players M] [-words FILE] ...
+//
+// Without -server, a server is started in-process on a local port, which also
+// lets the report include the server's goroutines and memory and check the
+// rooms' timer goroutines for deadlocks. A server elsewhere rate limits the
+// bots like any other clients sharing an address, so it needs its limits
+// raised first.
+package main
+
+import (
+  "client"
+  "context"
+  "flag"
+  "fmt"
+  "io"
+  "math/rand"
+  "net/http"
+  "net/http/httptest"
+  "os"
+  "runtime"
+  "sgserver"
+  "strconv"
+  "strings"
+  "superghost"
+  "sync"
+  "time"
+)
+
+type options struct {
+  serverURL string
+  rooms int
+  playersPerRoom int
+  words []string
+  minWordLength int
+  eliminationThreshold int
+  turnTime time.Duration
+  requestTimeout time.Duration
+  seed int64
+}
+
+type report struct {
+  inProcess bool
+  elapsed time.Duration
+  results []roomResult
+  recorder *latencyRecorder
+
+  goroutinesBefore int
+  goroutinesPeak int
+  goroutinesAfter int
+  memStats runtime.MemStats
+
+  // Only filled in for in-process runs
+  timerGoroutines int
+  suspectedDeadlocks []string
+}
+
+// Samples the goroutine count until stop is closed, returning the peak
+func sampleGoroutines(stop <-chan struct{}, peak *int, wg *sync.WaitGroup) {
+  defer wg.Done()
+  ticker := time.NewTicker(50 * time.Millisecond)
+  defer ticker.Stop()
+  for {
+    if n := runtime.NumGoroutine(); n > *peak {
+      *peak = n
+    }
+    select {
+      case <-stop:
+        return
+      case <-ticker.C:
+    }
+  }
+}
+
+// Splits a dump of every goroutine's stack into one string per goroutine
+func goroutineStacks() []string {
+  buf := make([]byte, 1 << 20)
+  for {
+    n := runtime.Stack(buf, true)
+    if n < len(buf) {
+      return strings.Split(string(buf[:n]), "\n\n")
+    }
+    buf = make([]byte, 2 * len(buf))
+  }
+}
+
+// Looks for rooms' turn timer goroutines. Once every game is over there should
+// be none; one that is waiting on the room's mutex is probably deadlocked.
+func inspectTimerGoroutines(r *report) {
+  for _, stack := range goroutineStacks() {
+    if !strings.Contains(stack, "superghost.(*Room).startTurnAndCountdown") {
+      continue
+    }
+    r.timerGoroutines++
+    if strings.Contains(stack, "sync.(*Mutex).Lock") ||
+        strings.Contains(stack, "sync.(*RWMutex).Lock") {
+      r.suspectedDeadlocks = append(r.suspectedDeadlocks, stack)
+    }
+  }
+}
+
+func runLoadTest(opts options) (*report, error) {
+  r := new(report)
+  r.goroutinesBefore = runtime.NumGoroutine()
+
+  words := newWordIndex(opts.words)
+  serverURL := opts.serverURL
+  if serverURL == "" {
+    r.inProcess = true
+    s := sgserver.NewSuperghostServer(make(map[string]*sgserver.RoomWrapper))
+    s.Dictionary = words.dictionary()
+    // Every bot shares one address, which the limits are for
+    s.Limits = sgserver.RateLimits{}
+    ts := httptest.NewServer(s.Router)
+    defer ts.Close()
+    serverURL = ts.URL
+  }
+
+  transport := http.DefaultTransport.(*http.Transport).Clone()
+  transport.MaxIdleConnsPerHost = 2 * opts.rooms * opts.playersPerRoom
+  r.recorder = newLatencyRecorder(transport)
+  httpClient := &http.Client{ Transport: r.recorder }
+
+  stopSampling := make(chan struct{})
+  var samplerWG sync.WaitGroup
+  samplerWG.Add(1)
+  go sampleGoroutines(stopSampling, &r.goroutinesPeak, &samplerWG)
+
+  ctx := context.Background()
+  start := time.Now()
+  r.results = make([]roomResult, opts.rooms)
+  var wg sync.WaitGroup
+  for i := 0; i < opts.rooms; i++ {
+    rr := &roomRunner {
+      usernames: make([]string, opts.playersPerRoom),
+      players: make(map[string]*client.Client),
+      words: words,
+      minWordLength: opts.minWordLength,
+      rng: rand.New(rand.NewSource(opts.seed + int64(i))),
+      requestTimeout: opts.requestTimeout,
+      recorder: r.recorder,
+    }
+    for j := range rr.usernames {
+      rr.usernames[j] = "bot" + strconv.Itoa(j)
+      rr.players[rr.usernames[j]] =
+          client.NewWithHTTPClient(serverURL, httpClient)
+    }
+
+    var err error
+    createErr := rr.call(ctx, func(ctx context.Context) error {
+      rr.roomID, err = rr.players["bot0"].CreateRoom(ctx, superghost.Config {
+        MaxPlayers: opts.playersPerRoom,
+        MinWordLength: opts.minWordLength,
+        EliminationThreshold: opts.eliminationThreshold,
+        AllowRepeatWords: true,
+        PlayerTimePerWord: opts.turnTime,
+      })
+      return err
+    })
+    if createErr != nil {
+      close(stopSampling)
+      return nil, fmt.Errorf("couldn't create room %d: %w", i, createErr)
+    }
+
+    wg.Add(1)
+    go func(i int) {
+      defer wg.Done()
+      r.results[i] = rr.run(ctx)
+    }(i)
+  }
+  wg.Wait()
+  r.elapsed = time.Since(start)
+
+  close(stopSampling)
+  samplerWG.Wait()
+  transport.CloseIdleConnections()
+  if r.inProcess {
+    inspectTimerGoroutines(r)
+  }
+  r.goroutinesAfter = runtime.NumGoroutine()
+  runtime.ReadMemStats(&r.memStats)
+  return r, nil
+}
+
+func (r *report) failed() bool {
+  if len(r.suspectedDeadlocks) > 0 || r.recorder.timeouts > 0 {
+    return true
+  }
+  for _, result := range r.results {
+    if result.err != nil {
+      return true
+    }
+  }
+  return false
+}
+
+func (r *report) print(w io.Writer) {
+  var games, moves, failedMoves, updates, itemsReceived, itemsTotal int
+  for _, result := range r.results {
+    games += result.gamesFinished
+    moves += result.moves
+    failedMoves += result.failedMoves
+    updates += result.updatesReceived
+    itemsReceived += result.logItemsReceived
+    itemsTotal += result.logItemsTotal
+  }
+  missed := itemsTotal - itemsReceived
+  if missed < 0 {
+    missed = 0
+  }
+
+  fmt.Fprintf(w, "%d rooms, %d games finished in %s\n", len(r.results), games,
+              r.elapsed.Round(time.Millisecond))
+  fmt.Fprintf(w, "%d moves (%d rejected)\n\n", moves, failedMoves)
+  r.recorder.report(w)
+  fmt.Fprintf(w, "\n/next-state: %d updates received, %d of %d log items " +
+              "never delivered\n", updates, missed, itemsTotal)
+
+  process := "load tester"
+  if r.inProcess {
+    process = "server + load tester"
+  }
+  fmt.Fprintf(w, "goroutines (%s): %d before, %d peak, %d after\n", process,
+              r.goroutinesBefore, r.goroutinesPeak, r.goroutinesAfter)
+  fmt.Fprintf(w, "memory (%s): %d KiB heap, %d KiB from OS, %d GCs\n", process,
+              r.memStats.HeapAlloc / 1024, r.memStats.Sys / 1024,
+              r.memStats.NumGC)
+
+  for i, result := range r.results {
+    if result.err != nil {
+      fmt.Fprintf(w, "room %d failed: %s\n", i, result.err.Error())
+    }
+  }
+  if r.recorder.timeouts > 0 {
+    fmt.Fprintf(w, "%d requests timed out (possible deadlock)\n",
+                r.recorder.timeouts)
+  }
+  if r.inProcess {
+    fmt.Fprintf(w, "turn timer goroutines still running: %d\n",
+                r.timerGoroutines)
+  }
+  for _, stack := range r.suspectedDeadlocks {
+    fmt.Fprintf(w, "\nPOSSIBLE DEADLOCK: timer goroutine waiting on the " +
+                "room's mutex\n%s\n", stack)
+  }
+}
+
+func main() {
+  var opts options
+  flag.StringVar(&opts.serverURL, "server", "",
+                 "the server to test (default: start one in-process)")
+  flag.IntVar(&opts.rooms, "rooms", 50, "how many rooms to play in at once")
+  flag.IntVar(&opts.playersPerRoom, "players", 3, "players per room")
+  wordsPath := flag.String("words", "",
+                           "word list, one per line (default: a built-in " +
+                           "list); must match the server's dictionary")
+  flag.IntVar(&opts.minWordLength, "min-length", 4, "minimum word length")
+  flag.IntVar(&opts.eliminationThreshold, "elimination", 3,
+              "letters until a player is eliminated")
+  flag.DurationVar(&opts.turnTime, "turn-time", 10 * time.Second,
+                   "each player's time per word (0 to disable the clock)")
+  flag.DurationVar(&opts.requestTimeout, "timeout", 10 * time.Second,
+                   "how long a request may take before it counts as stuck")
+  flag.Int64Var(&opts.seed, "seed", time.Now().UnixNano(),
+                "random seed for the bots")
+  flag.Parse()
+
+  opts.words = _defaultWords
+  if *wordsPath != "" {
+    words, err := loadWords(*wordsPath)
+    if err != nil {
+      fmt.Fprintln(os.Stderr, err)
+      os.Exit(1)
+    }
+    opts.words = words
+  }
+  if opts.playersPerRoom < 2 {
+    fmt.Fprintln(os.Stderr, "-players must be at least 2")
+    os.Exit(1)
+  }
+
+  r, err := runLoadTest(opts)
+  if err != nil {
+    fmt.Fprintln(os.Stderr, err)
+    os.Exit(1)
+  }
+  r.print(os.Stdout)
+  if r.failed() {
+    os.Exit(1)
+  }
+}
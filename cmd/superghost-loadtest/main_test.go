@@ -0,0 +1,66 @@
+package main
+
+import (
+  "net/http/httptest"
+  "strings"
+  "testing"
+  "time"
+)
+
+func TestEndpointName(t *testing.T) {
+  cases := map[string]string {
+    "/api/v1/rooms": "GET /api/v1/rooms",
+    "/api/v1/rooms/ABC123": "GET /api/v1/rooms/{roomID}",
+    "/api/v1/rooms/ABC123/next-state": "GET /api/v1/rooms/{roomID}/next-state",
+  }
+  for path, expected := range cases {
+    req := httptest.NewRequest("GET", path, nil)
+    if name := endpointName(req); name != expected {
+      t.Errorf("endpointName(%q) = %q (expected %q)", path, name, expected)
+    }
+  }
+}
+
+func TestPercentile(t *testing.T) {
+  sorted := make([]time.Duration, 0)
+  for i := 1; i <= 100; i++ {
+    sorted = append(sorted, time.Duration(i))
+  }
+  if p := percentile(sorted, 0.5); p != 51 && p != 50 {
+    t.Errorf("p50 = %d", p)
+  }
+  if p := percentile(sorted, 0.99); p != 99 && p != 100 {
+    t.Errorf("p99 = %d", p)
+  }
+  if p := percentile(nil, 0.5); p != 0 {
+    t.Errorf("p50 of nothing = %d", p)
+  }
+}
+
+// A small in-process run should finish every game without anything getting
+// stuck
+func TestRunLoadTest(t *testing.T) {
+  r, err := runLoadTest(options {
+    rooms: 4,
+    playersPerRoom: 3,
+    words: _defaultWords,
+    minWordLength: 4,
+    eliminationThreshold: 1,
+    turnTime: 10 * time.Second,
+    requestTimeout: 5 * time.Second,
+    seed: 1,
+  })
+  if err != nil {
+    t.Fatal(err)
+  }
+  var out strings.Builder
+  r.print(&out)
+  if r.failed() {
+    t.Errorf("load test failed:\n%s", out.String())
+  }
+  for i, result := range r.results {
+    if result.gamesFinished != 1 {
+      t.Errorf("room %d finished %d games", i, result.gamesFinished)
+    }
+  }
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+  "context"
+  "errors"
+  "fmt"
+  "io"
+  "net/http"
+  "regexp"
+  "sort"
+  "sync"
+  "time"
+)
+
+// Room IDs are replaced with this so requests group by endpoint
+var _roomIDPattern = regexp.MustCompile(`^/api/v1/rooms/[^/]+`)
+
+// Waiting is the whole point of these, so their latency means nothing
+var _longPollEndpoints = map[string]bool {
+  "GET /api/v1/rooms/{roomID}/next-state": true,
+  "GET /api/v1/rooms/{roomID}/next-chat": true,
+}
+
+type endpointStats struct {
+  latencies []time.Duration
+  errors int
+}
+
+// An http.RoundTripper that times every request by endpoint
+type latencyRecorder struct {
+  next http.RoundTripper
+
+  mutex sync.Mutex
+  endpoints map[string]*endpointStats
+  timeouts int
+}
+
+func newLatencyRecorder(next http.RoundTripper) *latencyRecorder {
+  lr := new(latencyRecorder)
+  lr.next = next
+  lr.endpoints = make(map[string]*endpointStats)
+  return lr
+}
+
+func endpointName(req *http.Request) string {
+  path := req.URL.Path
+  if loc := _roomIDPattern.FindStringIndex(path); loc != nil &&
+      path != "/api/v1/rooms" {
+    path = "/api/v1/rooms/{roomID}" + path[loc[1]:]
+  }
+  return req.Method + " " + path
+}
+
+func (lr *latencyRecorder) RoundTrip(req *http.Request) (*http.Response,
+                                                          error) {
+  start := time.Now()
+  res, err := lr.next.RoundTrip(req)
+  elapsed := time.Since(start)
+
+  lr.mutex.Lock()
+  defer lr.mutex.Unlock()
+  name := endpointName(req)
+  stats, ok := lr.endpoints[name]
+  if !ok {
+    stats = new(endpointStats)
+    lr.endpoints[name] = stats
+  }
+  stats.latencies = append(stats.latencies, elapsed)
+  // Cancelled long polls are how observers stop, not failures
+  if (err != nil && !errors.Is(err, context.Canceled)) ||
+      (err == nil && res.StatusCode >= 500) {
+    stats.errors++
+  }
+  return res, err
+}
+
+func (lr *latencyRecorder) recordTimeout() {
+  lr.mutex.Lock()
+  defer lr.mutex.Unlock()
+  lr.timeouts++
+}
+
+// The latency below which a fraction p of the sorted latencies fall
+func percentile(sorted []time.Duration, p float64) time.Duration {
+  if len(sorted) == 0 {
+    return 0
+  }
+  i := int(p * float64(len(sorted) - 1) + 0.5)
+  return sorted[i]
+}
+
+func (lr *latencyRecorder) report(w io.Writer) {
+  lr.mutex.Lock()
+  defer lr.mutex.Unlock()
+
+  names := make([]string, 0, len(lr.endpoints))
+  for name := range lr.endpoints {
+    names = append(names, name)
+  }
+  sort.Strings(names)
+
+  fmt.Fprintf(w, "%-48s %7s %6s %9s %9s %9s %9s\n",
+              "endpoint", "count", "errors", "p50", "p90", "p99", "max")
+  for _, name := range names {
+    stats := lr.endpoints[name]
+    sorted := append([]time.Duration(nil), stats.latencies...)
+    sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+    if _longPollEndpoints[name] {
+      fmt.Fprintf(w, "%-48s %7d %6d %9s\n", name, len(sorted), stats.errors,
+                  "(long poll)")
+      continue
+    }
+    fmt.Fprintf(w, "%-48s %7d %6d %9s %9s %9s %9s\n", name, len(sorted),
+                stats.errors, round(percentile(sorted, 0.5)),
+                round(percentile(sorted, 0.9)), round(percentile(sorted, 0.99)),
+                round(sorted[len(sorted) - 1]))
+  }
+}
+
+func round(d time.Duration) time.Duration {
+  return d.Round(10 * time.Microsecond)
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+  "bufio"
+  "os"
+  "strings"
+  "superghost"
+)
+
+// Used when no word list is given. Plenty to play with, and small enough that
+// scanning it on every move is cheap.
+var _defaultWords = strings.Fields(`
+  ABLE ABOUT ABOVE ACTOR ADMIT ADOPT AFTER AGAIN AGENT AGREE ALARM ALBUM ALERT
+  ALIVE ALLOW ALONE ALONG ANGER ANGLE ANGRY APPLE APPLY ARENA ARGUE ARISE
+  ASIDE AWARD AWARE BAKER BASIC BEACH BEGIN BEING BELOW BENCH BIRTH BLACK
+  BLAME BLIND BLOCK BLOOD BOARD BOOST BRAIN BRAND BREAD BREAK BRICK BRIEF
+  BRING BROAD BROWN BUILD CABIN CABLE CANDLE CARRY CATCH CAUSE CHAIN CHAIR
+  CHART CHASE CHEAP CHECK CHEST CHIEF CHILD CLAIM CLASS CLEAN CLEAR CLIMB
+  CLOCK CLOSE CLOUD COACH COAST COUNT COURT COVER CRAFT CRASH CREAM CRIME
+  CROSS CROWD CYCLE DANCE DEATH DELAY DEPTH DOUBT DRAFT DRAMA DREAM DRESS
+  DRINK DRIVE EARTH EIGHT ELITE EMPTY ENEMY ENJOY ENTER ENTRY EQUAL ERROR
+  EVENT EXACT EXIST EXTRA FAITH FALSE FIELD FIFTY FIGHT FINAL FLOOR FOCUS
+  FORCE FRAME FRESH FRONT FRUIT GHOST GIANT GLASS GRAND GRANT GRASS GREAT
+  GREEN GROUP GUARD GUEST GUIDE HAPPY HEART HEAVY HORSE HOTEL HOUSE HUMAN
+  IDEAL IMAGE INDEX INNER INPUT ISSUE JOINT JUDGE KNIFE LARGE LASER LATER
+  LAUGH LAYER LEARN LEAST LEAVE LEGAL LEMON LEVEL LIGHT LIMIT LOCAL LOGIC
+  LUNCH MAGIC MAJOR MARCH MATCH METAL MINOR MODEL MONEY MONTH MOTOR MOUNT
+  MOUSE MOUTH MUSIC NIGHT NOISE NORTH NOVEL NURSE OCEAN OFFER ORDER OTHER
+  OWNER PAINT PANEL PAPER PARTY PEACE PHASE PHONE PIANO PIECE PILOT PLACE
+  PLAIN PLANE PLANT PLATE POINT POUND POWER PRESS PRICE PRIDE PRIME PRINT
+  PRIZE PROOF PROUD QUEEN QUICK QUIET RADIO RANGE RAPID RATIO REACH READY
+  RIVER ROBOT ROUND ROUTE ROYAL RURAL SCALE SCENE SCOPE SCORE SENSE SHAPE
+  SHARE SHARP SHEEP SHEET SHELF SHELL SHIFT SHIRT SHOCK SHORT SIGHT SKILL
+  SLEEP SMALL SMART SMILE SMOKE SOLID SOUND SOUTH SPACE SPARE SPEAK SPEED
+  SPEND SPORT STAFF STAGE STAND START STATE STEAM STEEL STICK STILL STOCK
+  STONE STORE STORM STORY STUDY STYLE SUGAR TABLE TASTE TEACH THEME THICK
+  THING THINK THREE TIGER TITLE TODAY TOPIC TOTAL TOUCH TOWER TRACK TRADE
+  TRAIN TREND TRIAL TRUST TRUTH UNCLE UNION UNITY UPPER URBAN USUAL VALUE
+  VIDEO VISIT VOICE WASTE WATCH WATER WHEEL WHITE WHOLE WOMAN WORLD WORRY
+  WRITE YOUNG YOUTH
+`)
+
+// Reads a word list in the format superghost.LoadWordList takes
+func loadWords(path string) ([]string, error) {
+  f, err := os.Open(path)
+  if err != nil {
+    return nil, err
+  }
+  defer f.Close()
+
+  words := make([]string, 0)
+  scanner := bufio.NewScanner(f)
+  for scanner.Scan() {
+    line := strings.TrimSpace(scanner.Text())
+    if line == "" || strings.HasPrefix(line, "#") {
+      continue
+    }
+    words = append(words, line)
+  }
+  return words, scanner.Err()
+}
+
+// Answers the questions a bot needs answered about its word list
+type wordIndex struct {
+  words []string
+  isWord map[string]bool
+}
+
+func newWordIndex(words []string) *wordIndex {
+  wi := new(wordIndex)
+  wi.isWord = make(map[string]bool)
+  for _, w := range words {
+    w = strings.ToUpper(strings.TrimSpace(w))
+    if w == "" || wi.isWord[w] {
+      continue
+    }
+    wi.isWord[w] = true
+    wi.words = append(wi.words, w)
+  }
+  return wi
+}
+
+func (wi *wordIndex) containing(stem string) []string {
+  matches := make([]string, 0)
+  for _, w := range wi.words {
+    if strings.Contains(w, stem) {
+      matches = append(matches, w)
+    }
+  }
+  return matches
+}
+
+func (wi *wordIndex) dictionary() superghost.Dictionary {
+  return superghost.NewWordList(wi.words)
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+  "fmt"
+  "strconv"
+  "strings"
+)
+
+type commandKind int
+const (
+  kPrefix commandKind = iota
+  kSuffix
+  kInsert
+  kReverse
+  kChallengeIsWord
+  kChallengeContinuation
+  kRebut
+  kConcede
+  kOverturn
+  kUphold
+  kPause
+  kResume
+  kHint
+  kAnalysis
+  kKick
+  kMute
+  kUnmute
+  kSay
+  kHelp
+  kQuit
+)
+
+type command struct {
+  kind commandKind
+  // The letter for kPrefix, kSuffix & kInsert, the word for kRebut, the
+  // username for kKick, kMute & kUnmute and the message for kSay
+  arg string
+  // Where to insert the letter for kInsert, and the game for kAnalysis
+  index int
+}
+
+const kHelpText =
+`p X      add X to the start of the stem      s X      add X to the end
+w        challenge: the stem is a word        c        challenge: no word
+i N X    insert X before letter N (from 0; superduperghost only)
+rev      reverse the stem (xghost only; once per round)
+r WORD   rebut with a word containing stem   concede  give up the round
+overturn vote against a ruling (jury mode)  uphold   vote for it
+pause    pause the clock (host; else a vote)  resume   restart it
+hint     suggest letters (practice rooms only; counted against you)
+analysis N  review finished game N move by move
+kick U   kick U (host only)                   say ...  chat
+mute U   stop U chatting (host only)          unmute U let U chat again
+help     show this help                       quit     leave the room`
+
+// Turns a line typed by the user into a command
+func parseCommand(line string) (command, error) {
+  fields := strings.Fields(line)
+  if len(fields) == 0 {
+    return command{}, fmt.Errorf("type 'help' for a list of commands")
+  }
+  verb := strings.ToLower(fields[0])
+  args := fields[1:]
+
+  // Commands with exactly one argument
+  oneArg := map[string]commandKind {
+    "p": kPrefix, "prefix": kPrefix,
+    "s": kSuffix, "suffix": kSuffix,
+    "r": kRebut, "rebut": kRebut,
+    "kick": kKick,
+    "mute": kMute,
+    "unmute": kUnmute,
+  }
+  if kind, ok := oneArg[verb]; ok {
+    if len(args) != 1 {
+      return command{}, fmt.Errorf("'%s' takes exactly one argument", verb)
+    }
+    if (kind == kPrefix || kind == kSuffix) && len([]rune(args[0])) != 1 {
+      return command{}, fmt.Errorf("'%s' takes a single letter", verb)
+    }
+    return command{ kind: kind, arg: args[0] }, nil
+  }
+
+  if verb == "i" || verb == "insert" {
+    if len(args) != 2 {
+      return command{}, fmt.Errorf("'%s' takes a position and a letter", verb)
+    }
+    index, err := strconv.Atoi(args[0])
+    if err != nil || index < 0 {
+      return command{}, fmt.Errorf("'%s' is not a position", args[0])
+    }
+    if len([]rune(args[1])) != 1 {
+      return command{}, fmt.Errorf("'%s' takes a single letter", verb)
+    }
+    return command{ kind: kInsert, arg: args[1], index: index }, nil
+  }
+
+  if verb == "analysis" {
+    if len(args) != 1 {
+      return command{}, fmt.Errorf("'%s' takes a game number", verb)
+    }
+    game, err := strconv.Atoi(args[0])
+    if err != nil || game < 1 {
+      return command{}, fmt.Errorf("'%s' is not a game number", args[0])
+    }
+    return command{ kind: kAnalysis, index: game }, nil
+  }
+
+  // Commands without arguments
+  noArgs := map[string]commandKind {
+    "w": kChallengeIsWord, "word": kChallengeIsWord,
+    "c": kChallengeContinuation, "continue": kChallengeContinuation,
+    "rev": kReverse, "reverse": kReverse,
+    "concede": kConcede,
+    "overturn": kOverturn,
+    "uphold": kUphold,
+    "pause": kPause,
+    "resume": kResume,
+    "hint": kHint,
+    "help": kHelp, "?": kHelp,
+    "quit": kQuit, "q": kQuit,
+  }
+  if kind, ok := noArgs[verb]; ok {
+    if len(args) != 0 {
+      return command{}, fmt.Errorf("'%s' takes no arguments", verb)
+    }
+    return command{ kind: kind }, nil
+  }
+
+  if verb == "say" {
+    content := strings.TrimSpace(strings.TrimSpace(line)[len(fields[0]):])
+    if content == "" {
+      return command{}, fmt.Errorf("say what?")
+    }
+    return command{ kind: kSay, arg: content }, nil
+  }
+
+  return command{}, fmt.Errorf("unknown command '%s' (try 'help')", verb)
+}
+
+// Splits a rebuttal word into the prefix and suffix around the stem, using the
+// first place the stem appears.
+func splitRebuttal(word, stem string) (prefix, suffix string, err error) {
+  word = strings.ToUpper(word)
+  i := strings.Index(word, stem)
+  if i < 0 {
+    return "", "", fmt.Errorf("%s does not contain %s", word, stem)
+  }
+  return word[:i], word[i + len(stem):], nil
+}
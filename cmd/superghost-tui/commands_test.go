@@ -0,0 +1,110 @@
+package main
+
+import (
+  "bufio"
+  "client"
+  "context"
+  "net/http/httptest"
+  "sgserver"
+  "strings"
+  "superghost"
+  "testing"
+)
+
+func TestParseCommand(t *testing.T) {
+  good := map[string]command {
+    "p a": { kind: kPrefix, arg: "a" },
+    "S b": { kind: kSuffix, arg: "b" },
+    "i 2 x": { kind: kInsert, arg: "x", index: 2 },
+    "rev": { kind: kReverse },
+    "w": { kind: kChallengeIsWord },
+    "continue": { kind: kChallengeContinuation },
+    "r testing": { kind: kRebut, arg: "testing" },
+    "concede": { kind: kConcede },
+    "pause": { kind: kPause },
+    "hint": { kind: kHint },
+    "analysis 2": { kind: kAnalysis, index: 2 },
+    "uphold": { kind: kUphold },
+    "kick bob": { kind: kKick, arg: "bob" },
+    "mute bob": { kind: kMute, arg: "bob" },
+    "UNMUTE bob": { kind: kUnmute, arg: "bob" },
+    "say  hello there ": { kind: kSay, arg: "hello there" },
+    "q": { kind: kQuit },
+  }
+  for line, expected := range good {
+    cmd, err := parseCommand(line)
+    if err != nil || cmd != expected {
+      t.Errorf("parseCommand(%q) = %+v, %v (expected %+v)",
+               line, cmd, err, expected)
+    }
+  }
+
+  for _, line := range []string{"", "p", "p ab", "s a b", "w now", "say",
+                                "dance", "i x", "i -1 a", "i 1 ab",
+                                "analysis", "analysis 0", "analysis x",
+                                "mute", "unmute a b"} {
+    if _, err := parseCommand(line); err == nil {
+      t.Errorf("parseCommand(%q) should have failed", line)
+    }
+  }
+}
+
+func TestSplitRebuttal(t *testing.T) {
+  prefix, suffix, err := splitRebuttal("testing", "ST")
+  if err != nil || prefix != "TE" || suffix != "ING" {
+    t.Errorf("got %q, %q, %v", prefix, suffix, err)
+  }
+  if _, _, err := splitRebuttal("ghost", "ST"); err != nil {
+    t.Errorf("GHOST contains ST: %v", err)
+  }
+  if _, _, err := splitRebuttal("ghoul", "ST"); err == nil {
+    t.Errorf("GHOUL does not contain ST")
+  }
+}
+
+// Plays a few scripted commands against a real server
+func TestScriptedSession(t *testing.T) {
+  s := sgserver.NewSuperghostServer(make(map[string]*sgserver.RoomWrapper))
+  s.Dictionary = superghost.NewWordList([]string{"GHOST"})
+  server := httptest.NewServer(s.Router)
+  defer server.Close()
+  ctx := context.Background()
+
+  alice, bob := client.New(server.URL), client.New(server.URL)
+  roomID, err := alice.CreateRoom(ctx, superghost.Config{ MaxPlayers: 2 })
+  if err != nil {
+    t.Fatal(err)
+  }
+  if _, err := alice.Join(ctx, roomID, "alice"); err != nil {
+    t.Fatal(err)
+  }
+  if _, err := bob.Join(ctx, roomID, "bob"); err != nil {
+    t.Fatal(err)
+  }
+
+  var out strings.Builder
+  sess := &session {
+    c: alice,
+    v: &view{ roomID: roomID, username: "alice" },
+    out: &out,
+    style: _plainStyle,
+    strict: true,
+  }
+  script := bufio.NewScanner(strings.NewReader("s g\nsay hi\nquit\n"))
+  if err := sess.run(ctx, script); err != nil {
+    t.Fatal(err)
+  }
+  if !strings.Contains(out.String(), "stem: G") {
+    t.Errorf("the affix never showed up:\n%s", out.String())
+  }
+
+  // A failing command ends a strict session
+  if _, err := alice.Join(ctx, roomID, "alice"); err != nil {
+    t.Fatal(err)
+  }
+  sess.v = &view{ roomID: roomID, username: "alice" }
+  script = bufio.NewScanner(strings.NewReader("s h\nquit\n"))
+  if err := sess.run(ctx, script); err == nil {
+    t.Errorf("expected an error for playing out of turn")
+  }
+}
@@ -0,0 +1,120 @@
+// superghost-tui plays superghost from a terminal.
+//
+// Usage:
+//   superghost-tui [-server URL] [-room ID] [-name USERNAME] [-plain] [-strict]
+//                  [-password PASSWORD | -invite TOKEN]
+//
+// Without -room, the public rooms are listed and you pick one. Once in a room,
+// type 'help' for the list of commands. With -plain and -strict, commands can
+// be piped in from a script, which makes this a quick end-to-end test of a
+// server.
+package main
+
+import (
+  "bufio"
+  "client"
+  "context"
+  "flag"
+  "fmt"
+  "os"
+  "strconv"
+  "strings"
+  "superghost"
+)
+
+func prompt(lines *bufio.Scanner, question string) (string, error) {
+  fmt.Print(question)
+  if !lines.Scan() {
+    if err := lines.Err(); err != nil {
+      return "", err
+    }
+    return "", fmt.Errorf("no input")
+  }
+  return strings.TrimSpace(lines.Text()), nil
+}
+
+// Lists the public rooms and asks the user to pick one
+func chooseRoom(ctx context.Context, c *client.Client,
+                lines *bufio.Scanner) (string, error) {
+  rooms, err := c.ListRooms(ctx)
+  if err != nil {
+    return "", err
+  }
+  if len(rooms) == 0 {
+    return "", fmt.Errorf("there are no public rooms (use -room to join a " +
+                          "private one)")
+  }
+  fmt.Println("    ID      players  min length  elimination")
+  for i, r := range rooms {
+    fmt.Printf("%2d  %-6s  %2d / %-2d  %10d  %11d\n", i + 1, r.ID,
+               r.PlayerCount, r.MaxPlayers, r.MinWordLength,
+               r.EliminationThreshold)
+  }
+  answer, err := prompt(lines, "room number or ID: ")
+  if err != nil {
+    return "", err
+  }
+  if i, err := strconv.Atoi(answer); err == nil && i >= 1 && i <= len(rooms) {
+    return rooms[i - 1].ID, nil
+  }
+  return strings.ToUpper(answer), nil
+}
+
+func main() {
+  serverURL := flag.String("server", "http://localhost:9090",
+                           "the superghost server to connect to")
+  roomID := flag.String("room", "", "the room to join")
+  username := flag.String("name", "", "your username")
+  team := flag.Int("team", 0,
+                   "the team to join, if the room plays in teams (default: " +
+                   "the smallest)")
+  password := flag.String("password", "",
+                          "the room's password, if it has one")
+  invite := flag.String("invite", "",
+                        "an invite from the host, instead of the password")
+  plain := flag.Bool("plain", false,
+                     "don't use terminal escape codes (for scripting)")
+  strict := flag.Bool("strict", false, "exit as soon as a command fails")
+  flag.Parse()
+
+  ctx := context.Background()
+  c := client.New(*serverURL)
+  lines := bufio.NewScanner(os.Stdin)
+
+  var err error
+  if *roomID == "" {
+    if *roomID, err = chooseRoom(ctx, c, lines); err != nil {
+      fmt.Fprintln(os.Stderr, err)
+      os.Exit(1)
+    }
+  }
+  for *username == "" {
+    if *username, err = prompt(lines, "username: "); err != nil {
+      fmt.Fprintln(os.Stderr, err)
+      os.Exit(1)
+    }
+  }
+  _, err = c.JoinWithCredentials(
+      ctx, *roomID, *username, *team,
+      superghost.JoinCredentials{ Password: *password, Invite: *invite })
+  if err != nil {
+    fmt.Fprintf(os.Stderr, "couldn't join %s: %s\n", *roomID,
+                errorMessage(err))
+    os.Exit(1)
+  }
+
+  s := &session {
+    c: c,
+    v: &view{ roomID: *roomID, username: *username },
+    out: os.Stdout,
+    style: _ansiStyle,
+    strict: *strict,
+  }
+  if *plain {
+    s.style = _plainStyle
+  }
+  if err := s.run(ctx, lines); err != nil {
+    fmt.Fprintln(os.Stderr, err)
+    os.Exit(1)
+  }
+}
@@ -0,0 +1,315 @@
+package main
+
+import (
+  "fmt"
+  "io"
+  "strconv"
+  "strings"
+  "superghost"
+  "time"
+)
+
+// How many of the most recent log items and chat messages are shown
+const kLogLines = 10
+const kChatLines = 5
+
+type style struct {
+  clearScreen string
+  bold string
+  dim string
+  reset string
+}
+
+var _ansiStyle = style {
+  clearScreen: "\033[H\033[2J",
+  bold: "\033[1m",
+  dim: "\033[2m",
+  reset: "\033[0m",
+}
+
+// For output that isn't going to a terminal
+var _plainStyle = style {
+  clearScreen: "\n========\n",
+}
+
+// Everything the screen shows
+type view struct {
+  roomID string
+  username string
+  room *superghost.JRoom
+  log []superghost.LogItem
+  chat []superghost.Message
+  status string
+}
+
+// The full state also has the chat history, which is what's left of the chat
+// after deletions
+func (v *view) applyState(room *superghost.JRoom, isFullLog bool) {
+  if isFullLog {
+    v.log = room.LogPush
+    v.chat = room.Chat
+  } else {
+    v.log = append(v.log, room.LogPush...)
+  }
+  v.room = room
+}
+
+func (v *view) applyChat(msg *superghost.Message) {
+  if !msg.Deleted {
+    v.chat = append(v.chat, *msg)
+    return
+  }
+  chat := make([]superghost.Message, 0, len(v.chat))
+  for _, m := range v.chat {
+    if m.ID != msg.ID {
+      chat = append(chat, m)
+    }
+  }
+  v.chat = chat
+}
+
+func isMuted(room *superghost.JRoom, username string) bool {
+  for _, u := range room.Muted {
+    if u == username {
+      return true
+    }
+  }
+  return false
+}
+
+func lastN(n, length int) int {
+  if length > n {
+    return length - n
+  }
+  return 0
+}
+
+func formatDuration(d time.Duration) string {
+  if d < 0 {
+    d = 0
+  }
+  return fmt.Sprintf("%d:%02d", int(d.Minutes()), int(d.Seconds()) % 60)
+}
+
+// Renders the item as a sentence, e.g. "alice challenged bob to continue"
+func describeLogItem(item superghost.LogItem) string {
+  switch string(item.Type) {
+    case "Join":
+      return item.From + " joined"
+    case "Leave":
+      return item.From + " left"
+    case "ChallengeIsWord":
+      return item.From + " says " + item.To + " made a word"
+    case "ChallengeContinuation":
+      return item.From + " challenged " + item.To + " to continue"
+    case "ChallengeResult":
+      verdict := "is not a word"
+      if item.Success != nil && *item.Success {
+        verdict = "is a word"
+      }
+      line := fmt.Sprintf("%s %s; %s loses the round", item.Stem, verdict,
+                          item.To)
+      if item.Definition != nil {
+        line += fmt.Sprintf(" (%s: %s)", item.Stem, item.Definition.Text)
+      }
+      return line
+    case "ChallengedPlayerLeft":
+      return item.To + " left before answering " + item.From
+    case "Rebut":
+      if item.Word != "" {
+        return fmt.Sprintf("%s rebutted [%s] with %s", item.From, item.Stem,
+                           item.Word)
+      }
+      return fmt.Sprintf("%s rebutted with %s[%s]%s", item.From,
+                         item.Prefix, item.Stem, item.Suffix)
+    case "Affix":
+      return fmt.Sprintf("%s: %s[%s]%s", item.From, item.Prefix, item.Stem,
+                         item.Suffix)
+    case "Insert":
+      index := 0
+      if item.Index != nil {
+        index = *item.Index
+      }
+      // The index counts letters, which aren't always one byte
+      stem := []rune(item.Stem)
+      return fmt.Sprintf("%s: %s[%s]%s", item.From, string(stem[:index]),
+                         item.Letter, string(stem[index:]))
+    case "Reverse":
+      return fmt.Sprintf("%s reversed %s", item.From, item.Stem)
+    case "Concede":
+      return item.From + " conceded"
+    case "Eliminated":
+      return item.From + " was eliminated"
+    case "Kick":
+      return item.From + " kicked " + item.To
+    case "GameOver":
+      if item.Game > 0 {
+        return fmt.Sprintf("%s won the game! (\"analysis %d\" to review it)",
+                           item.To, item.Game)
+      }
+      return item.To + " won the game!"
+    case "GameStart":
+      return "the game started"
+    case "Timeout":
+      return item.From + " ran out of time"
+    case "InsufficientPlayers":
+      return "not enough players to keep going"
+    case "SuddenDeath":
+      return "sudden death: the next round's loser is out"
+    case "JuryVerdict":
+      verdict := "upheld"
+      if item.Success != nil && *item.Success {
+        verdict = "overturned"
+      }
+      return fmt.Sprintf("the jury %s the ruling on %s (%d-%d); %s loses",
+                         verdict, item.Stem, item.VotesToOverturn,
+                         item.VotesToUphold, item.To)
+    case "Pause":
+      return item.From + " paused the game"
+    case "Resume":
+      return item.From + " resumed the game"
+    case "ReadyUp":
+      return item.From + " is ready"
+    case "Hint":
+      return item.From + " asked for a hint"
+    default:
+      return string(item.Type)
+  }
+}
+
+// "try S+ or +A (e.g. STEM)"
+// Shows letters added to the start as "X+", to the end as "+X" and inserted
+// before the second letter as "X@1"
+func formatMove(m superghost.HintMove) string {
+  switch {
+    case m.Reverse:
+      return "reverse"
+    case m.Index != nil:
+      return fmt.Sprintf("%s@%d", m.Letter, *m.Index)
+    case m.Prefix != "":
+      return m.Prefix + "+"
+  }
+  return "+" + m.Suffix
+}
+
+func formatMoves(ms []superghost.HintMove) string {
+  moves := make([]string, 0, len(ms))
+  for _, m := range ms {
+    moves = append(moves, formatMove(m))
+  }
+  return strings.Join(moves, " or ")
+}
+
+func formatHint(hint *superghost.Hint) string {
+  if len(hint.Moves) == 0 {
+    return "no safe moves: challenge!"
+  }
+  line := "try " + formatMoves(hint.Moves)
+  if hint.Example != "" {
+    line += " (e.g. " + hint.Example + ")"
+  }
+  return line
+}
+
+// Lists the moves that weren't safe when a safe one was there to be played
+func formatAnalysis(analysis *superghost.GameAnalysis) string {
+  var b strings.Builder
+  fmt.Fprintf(&b, "game %d, won by %s", analysis.Game, analysis.Winner)
+  mistakes := 0
+  for i, round := range analysis.Rounds {
+    for _, m := range round.Moves {
+      if m.WasSafe || m.ForcedLosing {
+        continue
+      }
+      mistakes++
+      fmt.Fprintf(&b, "\nround %d: %s played %s on '%s'; safe was %s",
+                  i + 1, m.Player,
+                  formatMove(superghost.HintMove{
+                    Prefix: m.Prefix, Suffix: m.Suffix,
+                    Letter: m.Letter, Index: m.Index, Reverse: m.Reversed,
+                  }),
+                  m.Stem, formatMoves(m.SafeMoves))
+    }
+  }
+  if mistakes == 0 {
+    b.WriteString("\nno mistakes: every move was as safe as it could be")
+  }
+  return b.String()
+}
+
+func render(w io.Writer, v *view, st style) {
+  var b strings.Builder
+  b.WriteString(st.clearScreen)
+  fmt.Fprintf(&b, "%swordy.boo%s  room %s  playing as %s\n\n",
+              st.bold, st.reset, v.roomID, v.username)
+
+  if v.room != nil {
+    stem := v.room.Stem
+    if stem == "" {
+      stem = "(empty)"
+    }
+    state := v.room.State
+    if v.room.Paused {
+      state += " (paused)"
+    } else if len(v.room.PauseVotes) > 0 {
+      state += fmt.Sprintf(" (%d voted to pause)", len(v.room.PauseVotes))
+    }
+    fmt.Fprintf(&b, "stem: %s%s%s   state: %s\n\n",
+                st.bold, stem, st.reset, state)
+    if j := v.room.Jury; j != nil {
+      ruling := "not a word"
+      if j.IsWord {
+        ruling = "a word"
+      }
+      fmt.Fprintf(&b, "jury: the dictionary says %s is %s. %d to overturn, " +
+                  "%d to uphold (jurors: %s)\n\n", j.Word, ruling,
+                  j.VotesToOverturn, j.VotesToUphold,
+                  strings.Join(j.Jurors, ", "))
+    }
+
+    deadline := v.room.CurrentPlayerDeadline
+    for _, p := range v.room.Players {
+      marker := "  "
+      clock := p.TimeRemaining
+      if p.Username == v.room.CurrentPlayerUsername {
+        marker = "> "
+        if deadline.After(time.Unix(0, 0)) {
+          clock = time.Until(deadline)
+        }
+      }
+      score := p.ScoreDisplay
+      if score == "" {
+        score = strconv.Itoa(int(p.Score))
+      }
+      line := fmt.Sprintf("%s%-16s score %-12s %s", marker, p.Username, score,
+                          formatDuration(clock))
+      if p.Team != 0 {
+        line += fmt.Sprintf("  team %d", p.Team)
+      }
+      if p.HintsUsed != 0 {
+        line += fmt.Sprintf("  %d hints", p.HintsUsed)
+      }
+      if isMuted(v.room, p.Username) {
+        line += "  muted"
+      }
+      if p.IsEliminated {
+        line = st.dim + line + " (eliminated)" + st.reset
+      }
+      b.WriteString(line + "\n")
+    }
+  }
+
+  b.WriteString("\n-- log --\n")
+  for _, item := range v.log[lastN(kLogLines, len(v.log)):] {
+    b.WriteString(describeLogItem(item) + "\n")
+  }
+  b.WriteString("\n-- chat --\n")
+  for _, msg := range v.chat[lastN(kChatLines, len(v.chat)):] {
+    fmt.Fprintf(&b, "%s: %s\n", msg.Sender, msg.Content)
+  }
+  if v.status != "" {
+    b.WriteString("\n" + v.status + "\n")
+  }
+  b.WriteString("\n> ")
+  io.WriteString(w, b.String())
+}
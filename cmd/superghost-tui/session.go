@@ -0,0 +1,195 @@
+package main
+
+import (
+  "bufio"
+  "client"
+  "context"
+  "errors"
+  "fmt"
+  "io"
+  "superghost"
+  "time"
+)
+
+// A player's connection to one room, from joining until they quit
+type session struct {
+  c *client.Client
+  v *view
+  out io.Writer
+  style style
+  // If set, the session ends as soon as a command fails. Useful when the
+  // input is a script rather than a person.
+  strict bool
+}
+
+func errorMessage(err error) string {
+  var apiErr *client.APIError
+  if errors.As(err, &apiErr) && apiErr.Message != "" {
+    return apiErr.Message
+  }
+  return err.Error()
+}
+
+// Reads commands from lines until the user quits or the input ends, leaving the
+// room either way.
+func (s *session) run(ctx context.Context, lines *bufio.Scanner) error {
+  ctx, cancel := context.WithCancel(ctx)
+  defer cancel()
+
+  room, err := s.c.State(ctx, s.v.roomID)
+  if err != nil {
+    return err
+  }
+  s.v.applyState(room, true)
+
+  states := make(chan *superghost.JRoom)
+  chats := make(chan *superghost.Message)
+  go s.c.Subscribe(ctx, s.v.roomID, client.Handlers {
+    OnState: func(room *superghost.JRoom) {
+      select {
+        case states <- room:
+        case <-ctx.Done():
+      }
+    },
+    OnChat: func(msg *superghost.Message) {
+      select {
+        case chats <- msg:
+        case <-ctx.Done():
+      }
+    },
+  })
+
+  input := make(chan string)
+  go func() {
+    defer close(input)
+    for lines.Scan() {
+      select {
+        case input <- lines.Text():
+        case <-ctx.Done():
+          return
+      }
+    }
+  }()
+
+  // Keeps the clock of the player who is up ticking
+  ticker := time.NewTicker(time.Second)
+  defer ticker.Stop()
+
+  render(s.out, s.v, s.style)
+  for {
+    select {
+      case room := <-states:
+        s.v.applyState(room, false)
+
+      case msg := <-chats:
+        s.v.applyChat(msg)
+
+      case line, ok := <-input:
+        if !ok {
+          return s.leave()
+        }
+        quit, err := s.execute(ctx, line)
+        if err != nil && s.strict {
+          s.leave()
+          return fmt.Errorf("'%s' failed: %s", line, errorMessage(err))
+        }
+        if quit {
+          return s.leave()
+        }
+
+      case <-ticker.C:
+        if s.v.room == nil || s.v.room.CurrentPlayerDeadline.Unix() <= 0 {
+          continue  // Nothing is counting down
+        }
+
+      case <-ctx.Done():
+        return ctx.Err()
+    }
+    render(s.out, s.v, s.style)
+  }
+}
+
+func (s *session) leave() error {
+  ctx, cancel := context.WithTimeout(context.Background(), 5 * time.Second)
+  defer cancel()
+  return s.c.Leave(ctx, s.v.roomID)
+}
+
+// Runs the command on the line, updating the view with the outcome. Returns
+// whether the user wants to quit.
+func (s *session) execute(ctx context.Context, line string) (bool, error) {
+  cmd, err := parseCommand(line)
+  if err != nil {
+    s.v.status = err.Error()
+    return false, err
+  }
+
+  var room *superghost.JRoom
+  roomID := s.v.roomID
+  switch cmd.kind {
+    case kPrefix:
+      room, err = s.c.Affix(ctx, roomID, cmd.arg, "")
+    case kSuffix:
+      room, err = s.c.Affix(ctx, roomID, "", cmd.arg)
+    case kInsert:
+      room, err = s.c.Insert(ctx, roomID, cmd.index, cmd.arg)
+    case kReverse:
+      room, err = s.c.Reverse(ctx, roomID)
+    case kChallengeIsWord:
+      room, err = s.c.ChallengeIsWord(ctx, roomID)
+    case kChallengeContinuation:
+      room, err = s.c.ChallengeContinuation(ctx, roomID)
+    case kRebut:
+      // Words with letters inside the stem (superduperghost) can only be
+      // sent whole
+      prefix, suffix, splitErr := splitRebuttal(cmd.arg, s.v.room.Stem)
+      if splitErr != nil {
+        room, err = s.c.RebutWithWord(ctx, roomID, cmd.arg)
+      } else {
+        room, err = s.c.Rebut(ctx, roomID, prefix, suffix)
+      }
+    case kConcede:
+      room, err = s.c.Concede(ctx, roomID)
+    case kOverturn, kUphold:
+      room, err = s.c.VoteOnRuling(ctx, roomID, cmd.kind == kOverturn)
+    case kPause:
+      room, err = s.c.Pause(ctx, roomID)
+    case kResume:
+      room, err = s.c.Resume(ctx, roomID)
+    case kHint:
+      var hint *superghost.Hint
+      if hint, err = s.c.Hint(ctx, roomID); err == nil {
+        s.v.status = formatHint(hint)
+        return false, nil
+      }
+    case kAnalysis:
+      var analysis *superghost.GameAnalysis
+      if analysis, err = s.c.Analysis(ctx, roomID, cmd.index); err == nil {
+        s.v.status = formatAnalysis(analysis)
+        return false, nil
+      }
+    case kKick:
+      room, err = s.c.Kick(ctx, roomID, cmd.arg)
+    case kMute:
+      room, err = s.c.Mute(ctx, roomID, cmd.arg)
+    case kUnmute:
+      room, err = s.c.Unmute(ctx, roomID, cmd.arg)
+    case kSay:
+      _, err = s.c.Chat(ctx, roomID, cmd.arg)
+    case kHelp:
+      s.v.status = kHelpText
+      return false, nil
+    case kQuit:
+      return true, nil
+  }
+
+  if err != nil {
+    s.v.status = errorMessage(err)
+    return false, err
+  }
+  s.v.status = ""
+  if room != nil {
+    s.v.applyState(room, true)
+  }
+  return false, nil
+}
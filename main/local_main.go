@@ -5,6 +5,7 @@ import (
 	"sgserver"
   "fmt"
   "os"
+  "time"
 )
 
 func main() {
@@ -13,6 +14,10 @@ func main() {
   }
 	rooms := make(map[string]*sgserver.RoomWrapper)
 	server := sgserver.NewSuperghostServer(rooms)
+  err := server.UseCachedDictionary("dictionary_cache.json", time.Minute)
+  if err != nil {
+    panic(err)
+  }
   fmt.Println("Starting server...")
 	http.ListenAndServe(":9090", server.Router)
 }
@@ -6,8 +6,22 @@ import (
   "net/http"
   "os"
   "sgserver"
+  "superghost"
+  "time"
 )
 
+var _dictionaryCache = flag.String("dictionary-cache",
+                                   "dictionary_cache.json",
+                                   "where dictionary lookups are cached")
+
+var _definitions = flag.String("definitions", "",
+                               "a file of definitions, one JSON entry per " +
+                               "line, to use instead of the bundled ones")
+
+var _bannedWords = flag.String("banned-words", "",
+                                "a file of words to mask in chat, separated " +
+                                "by whitespace or commas")
+
 func main() {
   flag.Parse()
   if flag.NArg() != 2 {
@@ -20,6 +34,24 @@ func main() {
 
   rooms := make(map[string]*sgserver.RoomWrapper)
   server := sgserver.NewSuperghostServer(rooms)
+  err := server.UseCachedDictionary(*_dictionaryCache, time.Minute * 5)
+  if err != nil {
+    panic(err)
+  }
+  if *_definitions != "" {
+    server.Definitions, err = superghost.OpenDefinitionFile(*_definitions)
+    if err != nil {
+      panic(err)
+    }
+  }
+  if *_bannedWords != "" {
+    b, err := os.ReadFile(*_bannedWords)
+    if err != nil {
+      panic(err)
+    }
+    server.ChatFilter = superghost.NewWordFilter(
+        superghost.ParseWordList(string(b)))
+  }
 
   fmt.Println("Starting server...")
   panic(http.ListenAndServeTLS(":443", cert, key, server.Router))
@@ -0,0 +1,1043 @@
+package sgserver
+
+import (
+  "encoding/json"
+  "fmt"
+  "github.com/go-chi/chi/v5"
+  "io"
+  "net/http"
+  "strconv"
+  "net/url"
+  "strings"
+  "superghost"
+  "time"
+)
+
+// Everything under /api/v1 speaks JSON in both directions. Room actions
+// authenticate with the same per-room cookie the HTML client uses, issued by
+// the join route with a path scoped to the API.
+
+type JCreateRoomResponse struct {
+  ID string
+}
+
+type JJoinRequest struct {
+  Username string
+  // In team play, the team to join (from 1). 0 picks the smallest team.
+  Team int
+  // Rooms with a password need either it or an invite from the host
+  Password string
+  Invite string
+}
+
+type JInviteRequest struct {
+  // How long the invite works for. 0 means a day; it can't be more than a
+  // week.
+  Lifetime time.Duration
+}
+
+type JInviteResponse struct {
+  // Passed as Invite when joining
+  Token string
+  Expires time.Time
+  // The room's page, which joins with the invite
+  Link string
+}
+
+func newJInviteResponse(roomPath string,
+                        invite *superghost.Invite) JInviteResponse {
+  return JInviteResponse {
+    Token: invite.Token,
+    Expires: invite.Expires,
+    Link: roomPath + "?invite=" + url.QueryEscape(invite.Token),
+  }
+}
+
+type JAffixRequest struct {
+  Prefix string
+  Suffix string
+}
+
+type JInsertRequest struct {
+  // Where the letter goes: 0 puts it before the stem, len(stem) after
+  Index int
+  Letter string
+}
+
+// Either a prefix and suffix for the stem, or the whole word
+type JRebuttalRequest struct {
+  Prefix string
+  Suffix string
+  Word string
+}
+
+type JWordListsRequest struct {
+  AllowedWords []string
+  BlockedWords []string
+}
+
+// Overturn is false to let the dictionary's ruling stand
+type JJuryVoteRequest struct {
+  Overturn bool
+}
+
+type JKickRequest struct {
+  Username string
+}
+
+type JMuteRequest struct {
+  Username string
+}
+
+type JChatRequest struct {
+  Content string
+}
+
+type JCreateTournamentResponse struct {
+  ID string
+}
+
+type JRegisterRequest struct {
+  Username string
+}
+
+// Letters to add to either end of the day's stem
+type JPuzzleGuessRequest struct {
+  Prefix string
+  Suffix string
+}
+
+// Where the puzzle cookie is scoped, so it's only sent to the puzzle routes
+const kPuzzleCookiePath = "/api/v1/puzzle"
+
+// Describes one route of the API. The same table drives the router and the
+// OpenAPI document, so the two can't drift apart.
+type apiRoute struct {
+  method string
+  // Relative to /api/v1
+  pattern string
+  summary string
+  // Zero values of the request and response body types, or nil if there is no
+  // body
+  request interface{}
+  response interface{}
+  // Whether the route needs the per-room cookie
+  authenticated bool
+  // Whether the route needs the per-room cookie when the room has a password
+  private bool
+  handler http.HandlerFunc
+}
+
+func (s *SuperghostServer) apiV1Routes() []apiRoute {
+  return []apiRoute {
+    {
+      method: http.MethodGet,
+      pattern: "/openapi.json",
+      summary: "This document",
+      handler: s.apiOpenAPI,
+    },
+    {
+      method: http.MethodGet,
+      pattern: "/dictionary-stats",
+      summary: "How often the server's dictionary cache answers lookups " +
+               "itself, and whether the remote dictionary is failing",
+      response: superghost.DictionaryStats{},
+      handler: s.apiDictionaryStats,
+    },
+    {
+      method: http.MethodGet,
+      pattern: "/puzzle",
+      summary: "Get today's puzzle (find words containing its stem) and " +
+               "your progress with it",
+      response: superghost.PuzzleProgress{},
+      handler: s.apiPuzzle,
+    },
+    {
+      method: http.MethodPost,
+      pattern: "/puzzle/guess",
+      summary: "Guess a word for today's puzzle by adding letters to either " +
+               "end of its stem. Your first guess sets a cookie identifying " +
+               "you, which keeps your streak.",
+      request: JPuzzleGuessRequest{},
+      response: superghost.PuzzleGuess{},
+      handler: s.apiPuzzleGuess,
+    },
+    {
+      method: http.MethodPost,
+      pattern: "/quick-play",
+      summary: "Wait to be matched with players who want the same variant, " +
+               "language and number of players, then join the room made " +
+               "for you all. Sets the cookie used by the room actions. If " +
+               "nobody turns up in time, you get a public room of your own.",
+      request: JQuickPlayRequest{},
+      response: JQuickPlayResponse{},
+      handler: s.apiQuickPlay,
+    },
+    {
+      method: http.MethodPost,
+      pattern: "/tournaments",
+      summary: "Create a tournament. Sets the organizer's cookie, which is " +
+               "needed to start it.",
+      request: superghost.TournamentConfig{},
+      response: JCreateTournamentResponse{},
+      handler: s.apiCreateTournament,
+    },
+    {
+      method: http.MethodGet,
+      pattern: "/tournaments/{tournamentID}",
+      summary: "Get the tournament's bracket (every round's matches and the " +
+               "rooms they're played in) and standings",
+      response: superghost.JTournament{},
+      handler: s.apiTournament,
+    },
+    {
+      method: http.MethodPost,
+      pattern: "/tournaments/{tournamentID}/registration",
+      summary: "Register a player, before the tournament starts. Sets the " +
+               "cookie players need to join their matches' rooms, which " +
+               "they join under this username.",
+      request: JRegisterRequest{},
+      response: superghost.JTournament{},
+      handler: s.apiRegister,
+    },
+    {
+      method: http.MethodPost,
+      pattern: "/tournaments/{tournamentID}/start",
+      summary: "Close registration and draw the first round (organizer only)",
+      response: superghost.JTournament{},
+      handler: s.apiStartTournament,
+    },
+    {
+      method: http.MethodGet,
+      pattern: "/rooms",
+      summary: "List public rooms",
+      response: []superghost.JRoomMetadata{},
+      handler: s.apiListRooms,
+    },
+    {
+      method: http.MethodPost,
+      pattern: "/rooms",
+      summary: "Create a room",
+      request: superghost.Config{},
+      response: JCreateRoomResponse{},
+      handler: s.apiCreateRoom,
+    },
+    {
+      method: http.MethodGet,
+      pattern: "/rooms/{roomID}",
+      summary: "Get the room's state, including its full log",
+      response: superghost.JRoom{},
+      private: true,
+      handler: s.apiRoomState,
+    },
+    {
+      method: http.MethodGet,
+      pattern: "/rooms/{roomID}/next-state",
+      summary: "Wait for the room's state to change, then get it. Only the " +
+               "log items added since the previous update are included.",
+      response: superghost.JRoom{},
+      private: true,
+      handler: s.apiNextState,
+    },
+    {
+      method: http.MethodGet,
+      pattern: "/rooms/{roomID}/config",
+      summary: "Get the room's config",
+      response: superghost.Config{},
+      private: true,
+      handler: s.apiConfig,
+    },
+    {
+      method: http.MethodGet,
+      pattern: "/rooms/{roomID}/analysis/{gameNumber}",
+      summary: "Get a move-by-move analysis of one of the room's finished " +
+               "games, numbered from 1: which letters were safe and what " +
+               "the stem could have become",
+      response: superghost.GameAnalysis{},
+      private: true,
+      handler: s.apiAnalysis,
+    },
+    {
+      method: http.MethodPost,
+      pattern: "/rooms/{roomID}/word-lists",
+      summary: "Replace the words the room accepts or rejects whatever the " +
+               "dictionary says (host only, before the game starts)",
+      request: JWordListsRequest{},
+      response: superghost.Config{},
+      authenticated: true,
+      handler: s.apiWordLists,
+    },
+    {
+      method: http.MethodPost,
+      pattern: "/rooms/{roomID}/join",
+      summary: "Join the room. Sets the cookie used by the other room actions.",
+      request: JJoinRequest{},
+      response: superghost.JRoom{},
+      handler: s.apiJoin,
+    },
+    {
+      method: http.MethodPost,
+      pattern: "/rooms/{roomID}/invites",
+      summary: "Make an invite, which lets whoever has it join without the " +
+               "room's password until it expires (host only)",
+      request: JInviteRequest{},
+      response: JInviteResponse{},
+      authenticated: true,
+      handler: s.apiInvite,
+    },
+    {
+      method: http.MethodPost,
+      pattern: "/rooms/{roomID}/affix",
+      summary: "Add a letter to either end of the stem",
+      request: JAffixRequest{},
+      response: superghost.JRoom{},
+      authenticated: true,
+      handler: s.apiAffix,
+    },
+    {
+      method: http.MethodPost,
+      pattern: "/rooms/{roomID}/insertion",
+      summary: "Insert a letter anywhere in the stem (superduperghost only)",
+      request: JInsertRequest{},
+      response: superghost.JRoom{},
+      authenticated: true,
+      handler: s.apiInsert,
+    },
+    {
+      method: http.MethodPost,
+      pattern: "/rooms/{roomID}/reversal",
+      summary: "Reverse the stem instead of adding a letter (xghost only, " +
+               "once per round)",
+      response: superghost.JRoom{},
+      authenticated: true,
+      handler: s.apiReverse,
+    },
+    {
+      method: http.MethodGet,
+      pattern: "/rooms/{roomID}/hint",
+      summary: "Get a few safe letters and an example word for the stem " +
+               "(practice rooms only, on your turn). Every hint is counted " +
+               "and logged.",
+      response: superghost.Hint{},
+      authenticated: true,
+      handler: s.apiHint,
+    },
+    {
+      method: http.MethodPost,
+      pattern: "/rooms/{roomID}/challenge-is-word",
+      summary: "Claim the stem is already a word",
+      response: superghost.JRoom{},
+      authenticated: true,
+      handler: s.apiChallengeIsWord,
+    },
+    {
+      method: http.MethodPost,
+      pattern: "/rooms/{roomID}/challenge-continuation",
+      summary: "Claim the stem can't be extended into a word",
+      response: superghost.JRoom{},
+      authenticated: true,
+      handler: s.apiChallengeContinuation,
+    },
+    {
+      method: http.MethodPost,
+      pattern: "/rooms/{roomID}/rebuttal",
+      summary: "Answer a continuation challenge with a word containing the " +
+               "stem",
+      request: JRebuttalRequest{},
+      response: superghost.JRoom{},
+      authenticated: true,
+      handler: s.apiRebuttal,
+    },
+    {
+      method: http.MethodPost,
+      pattern: "/rooms/{roomID}/concession",
+      summary: "Concede the round",
+      response: superghost.JRoom{},
+      authenticated: true,
+      handler: s.apiConcede,
+    },
+    {
+      method: http.MethodPost,
+      pattern: "/rooms/{roomID}/jury-vote",
+      summary: "Vote on whether to overturn the dictionary's ruling on a " +
+               "challenge (jury mode; players not in the challenge only)",
+      request: JJuryVoteRequest{},
+      response: superghost.JRoom{},
+      authenticated: true,
+      handler: s.apiJuryVote,
+    },
+    {
+      method: http.MethodPost,
+      pattern: "/rooms/{roomID}/pause",
+      summary: "Stop the clock. The host pauses at once; otherwise it's a " +
+               "vote, and the game pauses when every other player has voted.",
+      response: superghost.JRoom{},
+      authenticated: true,
+      handler: s.apiPause,
+    },
+    {
+      method: http.MethodPost,
+      pattern: "/rooms/{roomID}/resume",
+      summary: "Restart the clock after a pause, by the host or by every " +
+               "other player's vote",
+      response: superghost.JRoom{},
+      authenticated: true,
+      handler: s.apiResume,
+    },
+    {
+      method: http.MethodPost,
+      pattern: "/rooms/{roomID}/kick",
+      summary: "Remove another player (host only)",
+      request: JKickRequest{},
+      response: superghost.JRoom{},
+      authenticated: true,
+      handler: s.apiKick,
+    },
+    {
+      method: http.MethodPost,
+      pattern: "/rooms/{roomID}/mute",
+      summary: "Stop a player from chatting (host only)",
+      request: JMuteRequest{},
+      response: superghost.JRoom{},
+      authenticated: true,
+      handler: s.apiMute,
+    },
+    {
+      method: http.MethodPost,
+      pattern: "/rooms/{roomID}/unmute",
+      summary: "Let a muted player chat again (host only)",
+      request: JMuteRequest{},
+      response: superghost.JRoom{},
+      authenticated: true,
+      handler: s.apiUnmute,
+    },
+    {
+      method: http.MethodPost,
+      pattern: "/rooms/{roomID}/chat",
+      summary: "Send a chat message to everyone in the room",
+      request: JChatRequest{},
+      response: superghost.Message{},
+      authenticated: true,
+      handler: s.apiChat,
+    },
+    {
+      method: http.MethodGet,
+      pattern: "/rooms/{roomID}/next-chat",
+      summary: "Wait for the next chat message, then get it. Deleted " +
+               "messages come through as notices with Deleted set.",
+      response: superghost.Message{},
+      private: true,
+      handler: s.apiNextChat,
+    },
+    {
+      method: http.MethodDelete,
+      pattern: "/rooms/{roomID}/chat/{messageID}",
+      summary: "Delete a chat message (host only)",
+      authenticated: true,
+      handler: s.apiDeleteMessage,
+    },
+    {
+      method: http.MethodPost,
+      pattern: "/rooms/{roomID}/leave",
+      summary: "Leave the room",
+      authenticated: true,
+      handler: s.apiLeave,
+    },
+  }
+}
+
+func (s *SuperghostServer) apiV1(r chi.Router) {
+  for _, route := range s.apiV1Routes() {
+    handler := http.Handler(route.handler)
+    // Verify roomID is valid and add it to request ctx
+    if strings.HasPrefix(route.pattern, "/rooms/{roomID}") {
+      if route.private {
+        handler = s.middlewareCheckViewer(handler)
+      }
+      handler = s.middlewareGetRoom(handler)
+    }
+    if strings.HasPrefix(route.pattern, "/tournaments/{tournamentID}") {
+      handler = s.middlewareGetTournament(handler)
+    }
+    r.Method(route.method, route.pattern, handler)
+  }
+  r.NotFound(func(w http.ResponseWriter, r *http.Request) {
+    writeNotFound(w)
+  })
+  r.MethodNotAllowed(func(w http.ResponseWriter, r *http.Request) {
+    writeMethodNotAllowed(w)
+  })
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+  b, err := json.Marshal(v)
+  if err != nil {
+    writeError(w, err)
+    return
+  }
+  w.Header().Set("Content-Type", "application/json; charset=utf-8")
+  w.WriteHeader(status)
+  w.Write(b)
+}
+
+func writeJSONBytes(w http.ResponseWriter, status int, b []byte) {
+  w.Header().Set("Content-Type", "application/json; charset=utf-8")
+  w.WriteHeader(status)
+  w.Write(b)
+}
+
+// Decodes the request body into v. An empty body leaves v untouched.
+func decodeJSONBody(r *http.Request, v interface{}) error {
+  decoder := json.NewDecoder(r.Body)
+  decoder.DisallowUnknownFields()
+  if err := decoder.Decode(v); err != nil && err != io.EOF {
+    return fmt.Errorf("invalid JSON body: %s", err.Error())
+  }
+  return nil
+}
+
+// Responds with the room's full state. Used after every successful action so
+// clients don't need a second request to see its effect.
+func writeRoomState(w http.ResponseWriter, status int, rw *RoomWrapper) {
+  b, err := rw.Room.MarshalJSONFullLog()
+  if err != nil {
+    writeError(w, err)
+    return
+  }
+  writeJSONBytes(w, status, b)
+}
+
+func (s *SuperghostServer) apiOpenAPI(w http.ResponseWriter, r *http.Request) {
+  writeJSONBytes(w, http.StatusOK, s.openAPISpec)
+}
+
+func (s *SuperghostServer) apiDictionaryStats(w http.ResponseWriter,
+                                              r *http.Request) {
+  cache, ok := s.Dictionary.(*superghost.CachedDictionary)
+  if !ok {
+    writeNotFound(w)
+    return
+  }
+  writeJSON(w, http.StatusOK, cache.Stats())
+}
+
+func (s *SuperghostServer) apiPuzzle(w http.ResponseWriter, r *http.Request) {
+  progress, err := s.Puzzles.Progress(s.Puzzles.Identify(r.Cookies()))
+  if err != nil {
+    writeError(w, err)
+    return
+  }
+  writeJSON(w, http.StatusOK, progress)
+}
+
+func (s *SuperghostServer) apiPuzzleGuess(w http.ResponseWriter,
+                                          r *http.Request) {
+  if !s.allowPuzzleGuess(w, r) {
+    return
+  }
+  var req JPuzzleGuessRequest
+  if err := decodeJSONBody(r, &req); err != nil {
+    writeBadRequest(w, err)
+    return
+  }
+  guess, cookie, err := s.Puzzles.Guess(s.Puzzles.Identify(r.Cookies()),
+                                        kPuzzleCookiePath, req.Prefix,
+                                        req.Suffix)
+  if err != nil {
+    writeError(w, err)
+    return
+  }
+  if cookie != nil {
+    http.SetCookie(w, cookie)
+  }
+  writeJSON(w, http.StatusOK, guess)
+}
+
+func (s *SuperghostServer) apiListRooms(w http.ResponseWriter,
+                                       r *http.Request) {
+  writeJSON(w, http.StatusOK, s.publicRoomMetadata())
+}
+
+func (s *SuperghostServer) apiCreateRoom(w http.ResponseWriter,
+                                        r *http.Request) {
+  if !s.allowRoomCreation(w, r) {
+    return
+  }
+  var config superghost.Config
+  if err := decodeJSONBody(r, &config); err != nil {
+    writeBadRequest(w, err)
+    return
+  }
+  roomID, err := s.createRoom(config, nil)
+  if err != nil {
+    writeCreateRoomError(w, err)
+    return
+  }
+  w.Header().Set("Location", "/api/v1/rooms/" + roomID)
+  writeJSON(w, http.StatusCreated, JCreateRoomResponse{ ID: roomID })
+}
+
+func (s *SuperghostServer) apiQuickPlay(w http.ResponseWriter,
+                                       r *http.Request) {
+  var req JQuickPlayRequest
+  if err := decodeJSONBody(r, &req); err != nil {
+    writeBadRequest(w, err)
+    return
+  }
+  match := s.quickPlay(w, r, req, "/api/v1/rooms/")
+  if match == nil {
+    return
+  }
+  http.SetCookie(w, match.cookie)
+  w.Header().Set("Location", "/api/v1/rooms/" + match.roomID)
+  writeJSON(w, http.StatusOK, JQuickPlayResponse{ RoomID: match.roomID })
+}
+
+func (s *SuperghostServer) apiCreateTournament(w http.ResponseWriter,
+                                              r *http.Request) {
+  if !s.allowRoomCreation(w, r) {
+    return
+  }
+  var config superghost.TournamentConfig
+  if err := decodeJSONBody(r, &config); err != nil {
+    writeBadRequest(w, err)
+    return
+  }
+  ID, cookie, err := s.createTournament(config)
+  if err != nil {
+    writeBadRequest(w, err)
+    return
+  }
+  http.SetCookie(w, cookie)
+  w.Header().Set("Location", "/api/v1/tournaments/" + ID)
+  writeJSON(w, http.StatusCreated, JCreateTournamentResponse{ ID: ID })
+}
+
+func (s *SuperghostServer) apiTournament(w http.ResponseWriter,
+                                        r *http.Request) {
+  writeJSON(w, http.StatusOK,
+            r.Context().Value("tournament").(*superghost.Tournament))
+}
+
+func (s *SuperghostServer) apiRegister(w http.ResponseWriter,
+                                      r *http.Request) {
+  t := r.Context().Value("tournament").(*superghost.Tournament)
+
+  if !s.allowJoin(w, r) {
+    return
+  }
+  var req JRegisterRequest
+  if err := decodeJSONBody(r, &req); err != nil {
+    writeBadRequest(w, err)
+    return
+  }
+  // Both the API's rooms and the HTML client's need the cookie
+  cookie, err := t.Register(req.Username, "/")
+  if err != nil {
+    writeError(w, err)
+    return
+  }
+  http.SetCookie(w, cookie)
+  writeJSON(w, http.StatusOK, t)
+}
+
+func (s *SuperghostServer) apiStartTournament(w http.ResponseWriter,
+                                             r *http.Request) {
+  t := r.Context().Value("tournament").(*superghost.Tournament)
+
+  if err := t.Start(r.Cookies()); err != nil {
+    writeError(w, err)
+    return
+  }
+  writeJSON(w, http.StatusOK, t)
+}
+
+func (s *SuperghostServer) apiRoomState(w http.ResponseWriter,
+                                       r *http.Request) {
+  writeRoomState(w, http.StatusOK,
+                 r.Context().Value("roomWrapper").(*RoomWrapper))
+}
+
+func (s *SuperghostServer) apiNextState(w http.ResponseWriter,
+                                       r *http.Request) {
+  roomWrapper := r.Context().Value("roomWrapper").(*RoomWrapper)
+  myChan := roomWrapper.UpdateListeners.AddListener()
+  select {
+    case state := <-myChan:
+      writeJSONBytes(w, http.StatusOK, []byte(state))
+    case <-r.Context().Done():
+  }
+}
+
+func (s *SuperghostServer) apiConfig(w http.ResponseWriter, r *http.Request) {
+  roomWrapper := r.Context().Value("roomWrapper").(*RoomWrapper)
+  b, err := roomWrapper.Room.MarshalJSONConfig()
+  if err != nil {
+    writeError(w, err)
+    return
+  }
+  writeJSONBytes(w, http.StatusOK, b)
+}
+
+func (s *SuperghostServer) apiAnalysis(w http.ResponseWriter,
+                                       r *http.Request) {
+  roomWrapper := r.Context().Value("roomWrapper").(*RoomWrapper)
+
+  gameNumber, err := strconv.Atoi(chi.URLParam(r, "gameNumber"))
+  if err != nil {
+    writeBadRequest(w, fmt.Errorf("game number must be an integer"))
+    return
+  }
+  analysis, err := roomWrapper.Room.Analysis(gameNumber)
+  if err != nil {
+    writeError(w, err)
+    return
+  }
+  writeJSON(w, http.StatusOK, analysis)
+}
+
+func (s *SuperghostServer) apiWordLists(w http.ResponseWriter,
+                                        r *http.Request) {
+  roomWrapper := r.Context().Value("roomWrapper").(*RoomWrapper)
+
+  var req JWordListsRequest
+  if err := decodeJSONBody(r, &req); err != nil {
+    writeBadRequest(w, err)
+    return
+  }
+  err := roomWrapper.Room.SetWordLists(r.Cookies(), req.AllowedWords,
+                                       req.BlockedWords)
+  if err != nil {
+    writeError(w, err)
+    return
+  }
+  b, err := roomWrapper.Room.MarshalJSONConfig()
+  if err != nil {
+    writeError(w, err)
+    return
+  }
+  writeJSONBytes(w, http.StatusOK, b)
+}
+
+func (s *SuperghostServer) apiJoin(w http.ResponseWriter, r *http.Request) {
+  ctx := r.Context()
+  roomID := ctx.Value("roomID").(string)
+  roomWrapper := ctx.Value("roomWrapper").(*RoomWrapper)
+
+  if !s.allowJoin(w, r) {
+    return
+  }
+  var req JJoinRequest
+  if err := decodeJSONBody(r, &req); err != nil {
+    writeBadRequest(w, err)
+    return
+  }
+  cookie, err := roomWrapper.Room.AddPlayerWithCredentials(
+      req.Username, "/api/v1/rooms/" + roomID, req.Team,
+      superghost.JoinCredentials {
+        Password: req.Password,
+        Invite: req.Invite,
+        Cookies: r.Cookies(),
+      })
+  if err != nil {
+    writeError(w, err)
+    return
+  }
+  http.SetCookie(w, cookie)
+  writeRoomState(w, http.StatusOK, roomWrapper)
+  roomWrapper.BroadcastGameState()
+}
+
+// The link is to the HTML client's page for the room, since that's what
+// people share
+func (s *SuperghostServer) apiInvite(w http.ResponseWriter, r *http.Request) {
+  ctx := r.Context()
+  roomID := ctx.Value("roomID").(string)
+  roomWrapper := ctx.Value("roomWrapper").(*RoomWrapper)
+
+  var req JInviteRequest
+  if err := decodeJSONBody(r, &req); err != nil {
+    writeBadRequest(w, err)
+    return
+  }
+  if req.Lifetime < 0 {
+    writeBadRequest(w, fmt.Errorf("Lifetime must not be negative"))
+    return
+  }
+  invite, err := roomWrapper.Room.Invite(r.Cookies(), req.Lifetime)
+  if err != nil {
+    writeError(w, err)
+    return
+  }
+  writeJSON(w, http.StatusCreated,
+            newJInviteResponse("/rooms/" + roomID, invite))
+}
+
+func (s *SuperghostServer) apiAffix(w http.ResponseWriter, r *http.Request) {
+  roomWrapper := r.Context().Value("roomWrapper").(*RoomWrapper)
+
+  var req JAffixRequest
+  if err := decodeJSONBody(r, &req); err != nil {
+    writeBadRequest(w, err)
+    return
+  }
+  err := roomWrapper.Room.AffixLetter(r.Cookies(), req.Prefix, req.Suffix)
+  if err != nil {
+    writeError(w, err)
+    return
+  }
+  writeRoomState(w, http.StatusOK, roomWrapper)
+  roomWrapper.BroadcastGameState()
+}
+
+func (s *SuperghostServer) apiInsert(w http.ResponseWriter, r *http.Request) {
+  roomWrapper := r.Context().Value("roomWrapper").(*RoomWrapper)
+
+  var req JInsertRequest
+  if err := decodeJSONBody(r, &req); err != nil {
+    writeBadRequest(w, err)
+    return
+  }
+  err := roomWrapper.Room.InsertLetter(r.Cookies(), req.Index, req.Letter)
+  if err != nil {
+    writeError(w, err)
+    return
+  }
+  writeRoomState(w, http.StatusOK, roomWrapper)
+  roomWrapper.BroadcastGameState()
+}
+
+func (s *SuperghostServer) apiReverse(w http.ResponseWriter,
+                                      r *http.Request) {
+  roomWrapper := r.Context().Value("roomWrapper").(*RoomWrapper)
+
+  if err := roomWrapper.Room.ReverseStem(r.Cookies()); err != nil {
+    writeError(w, err)
+    return
+  }
+  writeRoomState(w, http.StatusOK, roomWrapper)
+  roomWrapper.BroadcastGameState()
+}
+
+func (s *SuperghostServer) apiHint(w http.ResponseWriter, r *http.Request) {
+  roomWrapper := r.Context().Value("roomWrapper").(*RoomWrapper)
+
+  hint, err := roomWrapper.Room.Hint(r.Cookies())
+  if err != nil {
+    writeError(w, err)
+    return
+  }
+  writeJSON(w, http.StatusOK, hint)
+  roomWrapper.BroadcastGameState()
+}
+
+func (s *SuperghostServer) apiJuryVote(w http.ResponseWriter,
+                                       r *http.Request) {
+  roomWrapper := r.Context().Value("roomWrapper").(*RoomWrapper)
+
+  var req JJuryVoteRequest
+  if err := decodeJSONBody(r, &req); err != nil {
+    writeBadRequest(w, err)
+    return
+  }
+  err := roomWrapper.Room.VoteOnRuling(r.Cookies(), req.Overturn)
+  if err != nil {
+    writeError(w, err)
+    return
+  }
+  writeRoomState(w, http.StatusOK, roomWrapper)
+  roomWrapper.BroadcastGameState()
+}
+
+func (s *SuperghostServer) apiPause(w http.ResponseWriter, r *http.Request) {
+  roomWrapper := r.Context().Value("roomWrapper").(*RoomWrapper)
+
+  if err := roomWrapper.Room.Pause(r.Cookies()); err != nil {
+    writeError(w, err)
+    return
+  }
+  writeRoomState(w, http.StatusOK, roomWrapper)
+  roomWrapper.BroadcastGameState()
+}
+
+func (s *SuperghostServer) apiResume(w http.ResponseWriter, r *http.Request) {
+  roomWrapper := r.Context().Value("roomWrapper").(*RoomWrapper)
+
+  if err := roomWrapper.Room.Resume(r.Cookies()); err != nil {
+    writeError(w, err)
+    return
+  }
+  writeRoomState(w, http.StatusOK, roomWrapper)
+  roomWrapper.BroadcastGameState()
+}
+
+func (s *SuperghostServer) apiChallengeIsWord(w http.ResponseWriter,
+                                             r *http.Request) {
+  roomWrapper := r.Context().Value("roomWrapper").(*RoomWrapper)
+
+  if err := roomWrapper.Room.ChallengeIsWord(r.Cookies()); err != nil {
+    writeError(w, err)
+    return
+  }
+  writeRoomState(w, http.StatusOK, roomWrapper)
+  roomWrapper.BroadcastGameState()
+}
+
+func (s *SuperghostServer) apiChallengeContinuation(w http.ResponseWriter,
+                                                   r *http.Request) {
+  roomWrapper := r.Context().Value("roomWrapper").(*RoomWrapper)
+
+  if err := roomWrapper.Room.ChallengeContinuation(r.Cookies()); err != nil {
+    writeError(w, err)
+    return
+  }
+  writeRoomState(w, http.StatusOK, roomWrapper)
+  roomWrapper.BroadcastGameState()
+}
+
+func (s *SuperghostServer) apiRebuttal(w http.ResponseWriter,
+                                      r *http.Request) {
+  roomWrapper := r.Context().Value("roomWrapper").(*RoomWrapper)
+
+  var req JRebuttalRequest
+  if err := decodeJSONBody(r, &req); err != nil {
+    writeBadRequest(w, err)
+    return
+  }
+  var err error
+  if req.Word != "" {
+    err = roomWrapper.Room.RebutChallengeWithWord(r.Cookies(), req.Word)
+  } else {
+    err = roomWrapper.Room.RebutChallenge(r.Cookies(), req.Prefix, req.Suffix)
+  }
+  if err != nil {
+    writeError(w, err)
+    return
+  }
+  writeRoomState(w, http.StatusOK, roomWrapper)
+  roomWrapper.BroadcastGameState()
+}
+
+func (s *SuperghostServer) apiConcede(w http.ResponseWriter, r *http.Request) {
+  roomWrapper := r.Context().Value("roomWrapper").(*RoomWrapper)
+
+  if err := roomWrapper.Room.Concede(r.Cookies()); err != nil {
+    writeError(w, err)
+    return
+  }
+  writeRoomState(w, http.StatusOK, roomWrapper)
+  roomWrapper.BroadcastGameState()
+}
+
+func (s *SuperghostServer) apiKick(w http.ResponseWriter, r *http.Request) {
+  roomWrapper := r.Context().Value("roomWrapper").(*RoomWrapper)
+
+  var req JKickRequest
+  if err := decodeJSONBody(r, &req); err != nil {
+    writeBadRequest(w, err)
+    return
+  }
+  if err := roomWrapper.Room.Kick(r.Cookies(), req.Username); err != nil {
+    writeError(w, err)
+    return
+  }
+  writeRoomState(w, http.StatusOK, roomWrapper)
+  roomWrapper.BroadcastGameState()
+}
+
+func (s *SuperghostServer) apiMute(w http.ResponseWriter, r *http.Request) {
+  s.apiSetMuted(w, r, true)
+}
+
+func (s *SuperghostServer) apiUnmute(w http.ResponseWriter,
+                                     r *http.Request) {
+  s.apiSetMuted(w, r, false)
+}
+
+func (s *SuperghostServer) apiSetMuted(w http.ResponseWriter, r *http.Request,
+                                       muted bool) {
+  roomWrapper := r.Context().Value("roomWrapper").(*RoomWrapper)
+
+  var req JMuteRequest
+  if err := decodeJSONBody(r, &req); err != nil {
+    writeBadRequest(w, err)
+    return
+  }
+  if err := roomWrapper.Room.Mute(r.Cookies(), req.Username,
+                                  muted); err != nil {
+    writeError(w, err)
+    return
+  }
+  writeRoomState(w, http.StatusOK, roomWrapper)
+  roomWrapper.BroadcastGameState()
+}
+
+func (s *SuperghostServer) apiChat(w http.ResponseWriter, r *http.Request) {
+  ctx := r.Context()
+  roomID := ctx.Value("roomID").(string)
+  roomWrapper := ctx.Value("roomWrapper").(*RoomWrapper)
+
+  if !s.allowChat(w, r, roomID, roomWrapper) {
+    return
+  }
+  var req JChatRequest
+  if err := decodeJSONBody(r, &req); err != nil {
+    writeBadRequest(w, err)
+    return
+  }
+  msg, err := roomWrapper.Room.Chat(r.Cookies(), req.Content)
+  if err != nil {
+    writeError(w, err)
+    return
+  }
+  b, err := json.Marshal(msg)
+  if err != nil {
+    panic(err)
+  }
+  writeJSONBytes(w, http.StatusOK, b)
+  roomWrapper.ChatListeners.Broadcast(string(b))
+}
+
+func (s *SuperghostServer) apiNextChat(w http.ResponseWriter,
+                                      r *http.Request) {
+  roomWrapper := r.Context().Value("roomWrapper").(*RoomWrapper)
+  myChan := roomWrapper.ChatListeners.AddListener()
+  select {
+    case msg := <-myChan:
+      writeJSONBytes(w, http.StatusOK, []byte(msg))
+    case <-r.Context().Done():
+  }
+}
+
+func (s *SuperghostServer) apiDeleteMessage(w http.ResponseWriter,
+                                            r *http.Request) {
+  roomWrapper := r.Context().Value("roomWrapper").(*RoomWrapper)
+
+  messageID, err := strconv.Atoi(chi.URLParam(r, "messageID"))
+  if err != nil {
+    writeBadRequest(w, fmt.Errorf("message ID must be an integer"))
+    return
+  }
+  notice, err := roomWrapper.Room.DeleteMessage(r.Cookies(), messageID)
+  if err != nil {
+    writeError(w, err)
+    return
+  }
+  b, err := json.Marshal(notice)
+  if err != nil {
+    panic(err)
+  }
+  w.WriteHeader(http.StatusNoContent)
+  roomWrapper.ChatListeners.Broadcast(string(b))
+}
+
+func (s *SuperghostServer) apiLeave(w http.ResponseWriter, r *http.Request) {
+  roomWrapper := r.Context().Value("roomWrapper").(*RoomWrapper)
+
+  if err := roomWrapper.Room.Leave(r.Cookies()); err != nil {
+    writeError(w, err)
+    return
+  }
+  w.WriteHeader(http.StatusNoContent)
+  roomWrapper.BroadcastGameState()
+}
@@ -0,0 +1,1055 @@
+package sgserver
+
+import (
+  "bytes"
+  "encoding/json"
+  "net/http"
+  "net/http/httptest"
+  "strconv"
+  "strings"
+  "superghost"
+  "testing"
+  "time"
+)
+
+type apiTestClient struct {
+  t *testing.T
+  server *SuperghostServer
+  usernameToCookies map[string][]*http.Cookie
+  // "METHOD pattern" of every route the test has called
+  exercised map[string]bool
+}
+
+func newAPITestClient(t *testing.T) *apiTestClient {
+  c := new(apiTestClient)
+  c.t = t
+  c.server = NewSuperghostServer(make(map[string]*RoomWrapper))
+  c.server.Dictionary = superghost.NewCachedDictionary(
+      superghost.NewWordList([]string{"GHOST", "STEM", "TESTING"}),
+      superghost.DictionaryCacheConfig{})
+  // Tests make requests much faster than people do. The rate limit tests set
+  // their own.
+  c.server.Limits = RateLimits{}
+  c.usernameToCookies = make(map[string][]*http.Cookie)
+  c.exercised = make(map[string]bool)
+  return c
+}
+
+// Calls the route matching pattern with {roomID} filled in, as username (or
+// anonymously if username is empty). body is marshalled as JSON unless nil.
+func (c *apiTestClient) do(method, pattern, roomID, username string,
+                           body interface{}) *httptest.ResponseRecorder {
+  return c.doWithParams(method, pattern, map[string]string{"roomID": roomID},
+                        username, body)
+}
+
+// Like do, for routes with more path parameters than just the room
+func (c *apiTestClient) doWithParams(
+    method, pattern string, params map[string]string, username string,
+    body interface{}) *httptest.ResponseRecorder {
+  c.exercised[method + " " + pattern] = true
+
+  var reader *bytes.Reader
+  if body == nil {
+    reader = bytes.NewReader(nil)
+  } else {
+    b, err := json.Marshal(body)
+    if err != nil {
+      c.t.Fatalf("couldn't marshal body: %s", err.Error())
+    }
+    reader = bytes.NewReader(b)
+  }
+  path := pattern
+  for name, value := range params {
+    path = strings.Replace(path, "{" + name + "}", value, 1)
+  }
+  path = "/api/v1" + path
+  req := httptest.NewRequest(method, path, reader)
+  req.Header.Set("Content-Type", "application/json")
+  for _, cookie := range c.usernameToCookies[username] {
+    req.AddCookie(cookie)
+  }
+
+  rec := httptest.NewRecorder()
+  c.server.Router.ServeHTTP(rec, req)
+
+  if cookies := rec.Result().Cookies(); len(cookies) > 0 {
+    c.usernameToCookies[cookies[0].Name] = cookies
+  }
+  return rec
+}
+
+func (c *apiTestClient) expectStatus(rec *httptest.ResponseRecorder,
+                                     status int) {
+  c.t.Helper()
+  if rec.Code != status {
+    c.t.Fatalf("got status %d (expected %d): %s",
+               rec.Code, status, rec.Body.String())
+  }
+}
+
+func (c *apiTestClient) expectError(rec *httptest.ResponseRecorder, status int,
+                                    code string) {
+  c.t.Helper()
+  c.expectStatus(rec, status)
+  var jerr JError
+  if err := json.Unmarshal(rec.Body.Bytes(), &jerr); err != nil {
+    c.t.Fatalf("error body is not JSON: %s", rec.Body.String())
+  }
+  if jerr.Code != code || jerr.Message == "" {
+    c.t.Fatalf("got error %+v (expected code '%s')", jerr, code)
+  }
+}
+
+func (c *apiTestClient) decode(rec *httptest.ResponseRecorder,
+                               v interface{}) {
+  c.t.Helper()
+  if err := json.Unmarshal(rec.Body.Bytes(), v); err != nil {
+    c.t.Fatalf("couldn't decode %s: %s", rec.Body.String(), err.Error())
+  }
+}
+
+func (c *apiTestClient) state(roomID string) superghost.JRoom {
+  c.t.Helper()
+  rec := c.do(http.MethodGet, "/rooms/{roomID}", roomID, "", nil)
+  c.expectStatus(rec, http.StatusOK)
+  var room superghost.JRoom
+  c.decode(rec, &room)
+  return room
+}
+
+// Blocks until someone is waiting on the listener group
+func waitForListener(t *testing.T, lg *ListenerGroup) {
+  deadline := time.Now().Add(time.Second)
+  for time.Now().Before(deadline) {
+    lg.ListenersMutex.RLock()
+    n := len(lg.Listeners)
+    lg.ListenersMutex.RUnlock()
+    if n > 0 {
+      return
+    }
+    time.Sleep(time.Millisecond)
+  }
+  t.Fatalf("nobody started listening")
+}
+
+func TestAPIV1Contract(t *testing.T) {
+  c := newAPITestClient(t)
+
+  // Create
+  rec := c.do(http.MethodPost, "/rooms", "", "", superghost.Config {
+    MaxPlayers: 3,
+    MinWordLength: 4,
+    IsPublic: true,
+  })
+  c.expectStatus(rec, http.StatusCreated)
+  var created JCreateRoomResponse
+  c.decode(rec, &created)
+  roomID := created.ID
+
+  // List
+  rec = c.do(http.MethodGet, "/rooms", "", "", nil)
+  c.expectStatus(rec, http.StatusOK)
+  var rooms []superghost.JRoomMetadata
+  c.decode(rec, &rooms)
+  if len(rooms) != 1 || rooms[0].ID != roomID {
+    t.Fatalf("expected exactly room %s to be listed, got %+v", roomID, rooms)
+  }
+
+  // Config
+  rec = c.do(http.MethodGet, "/rooms/{roomID}/config", roomID, "", nil)
+  c.expectStatus(rec, http.StatusOK)
+  var config superghost.Config
+  c.decode(rec, &config)
+  if config.MaxPlayers != 3 || config.MinWordLength != 4 {
+    t.Fatalf("config did not round trip: %+v", config)
+  }
+
+  // Join
+  for _, username := range []string{"alice", "bob", "carol"} {
+    rec = c.do(http.MethodPost, "/rooms/{roomID}/join", roomID, "",
+               JJoinRequest{ Username: username })
+    c.expectStatus(rec, http.StatusOK)
+    if _, ok := c.usernameToCookies[username]; !ok {
+      t.Fatalf("joining as %s did not set a cookie", username)
+    }
+  }
+  rec = c.do(http.MethodPost, "/rooms/{roomID}/join", roomID, "",
+             JJoinRequest{ Username: "dave" })
+  c.expectError(rec, http.StatusConflict, superghost.ErrRoomFull.Code)
+
+  // Affix "STE", subscribing to the first update
+  room := c.state(roomID)
+  if room.State != "edit" || len(room.Players) != 3 {
+    t.Fatalf("unexpected state after joining: %+v", room)
+  }
+  nextStateCh := make(chan *httptest.ResponseRecorder)
+  go func() {
+    nextStateCh <- c.do(http.MethodGet, "/rooms/{roomID}/next-state", roomID,
+                        "", nil)
+  }()
+  waitForListener(t, c.server.Rooms[roomID].UpdateListeners)
+  for _, letter := range []string{"S", "T", "E"} {
+    current := c.state(roomID).CurrentPlayerUsername
+    rec = c.do(http.MethodPost, "/rooms/{roomID}/affix", roomID, current,
+               JAffixRequest{ Suffix: letter })
+    c.expectStatus(rec, http.StatusOK)
+  }
+  rec = <-nextStateCh
+  c.expectStatus(rec, http.StatusOK)
+  c.decode(rec, &room)
+  if room.Stem != "S" {
+    t.Fatalf("next-state should hold the first update, got stem '%s'",
+             room.Stem)
+  }
+
+  // Insertion is only for superduperghost
+  rec = c.do(http.MethodPost, "/rooms/{roomID}/insertion", roomID,
+             c.state(roomID).CurrentPlayerUsername,
+             JInsertRequest{ Index: 1, Letter: "A" })
+  c.expectError(rec, http.StatusBadRequest, superghost.ErrInvalidMove.Code)
+
+  // So is reversal for xghost
+  rec = c.do(http.MethodPost, "/rooms/{roomID}/reversal", roomID,
+             c.state(roomID).CurrentPlayerUsername, nil)
+  c.expectError(rec, http.StatusBadRequest, superghost.ErrInvalidMove.Code)
+
+  // And hints for practice rooms
+  rec = c.do(http.MethodGet, "/rooms/{roomID}/hint", roomID,
+             c.state(roomID).CurrentPlayerUsername, nil)
+  c.expectError(rec, http.StatusForbidden, superghost.ErrNotPractice.Code)
+
+  // Challenge continuation and rebut with a real word
+  room = c.state(roomID)
+  rec = c.do(http.MethodPost, "/rooms/{roomID}/affix", roomID,
+             room.LastPlayerUsername, JAffixRequest{ Suffix: "M" })
+  c.expectError(rec, http.StatusForbidden, superghost.ErrNotYourTurn.Code)
+  challenger := room.CurrentPlayerUsername
+  rec = c.do(http.MethodPost, "/rooms/{roomID}/challenge-continuation",
+             roomID, challenger, nil)
+  c.expectStatus(rec, http.StatusOK)
+  c.decode(rec, &room)
+  if room.State != "rebut" {
+    t.Fatalf("expected rebut state after challenge, got %s", room.State)
+  }
+  rec = c.do(http.MethodPost, "/rooms/{roomID}/rebuttal", roomID,
+             room.CurrentPlayerUsername, JRebuttalRequest{ Suffix: "M" })
+  c.expectStatus(rec, http.StatusOK)
+  c.decode(rec, &room)
+  for _, p := range room.Players {
+    if (p.Username == challenger) != (p.Score == 1) {
+      t.Fatalf("only the challenger should have lost: %+v", room.Players)
+    }
+  }
+
+  // Challenge that a word was made
+  for _, letter := range []string{"G", "H", "O", "S", "T"} {
+    current := c.state(roomID).CurrentPlayerUsername
+    rec = c.do(http.MethodPost, "/rooms/{roomID}/affix", roomID, current,
+               JAffixRequest{ Suffix: letter })
+    c.expectStatus(rec, http.StatusOK)
+  }
+  room = c.state(roomID)
+  rec = c.do(http.MethodPost, "/rooms/{roomID}/challenge-is-word", roomID,
+             room.CurrentPlayerUsername, nil)
+  c.expectStatus(rec, http.StatusOK)
+  var afterChallenge superghost.JRoom
+  c.decode(rec, &afterChallenge)
+  lastItem := afterChallenge.LogPush[len(afterChallenge.LogPush) - 1]
+  if lastItem.To != room.LastPlayerUsername || !*lastItem.Success {
+    t.Fatalf("expected %s to lose the challenge, got %+v",
+             room.LastPlayerUsername, lastItem)
+  }
+
+  // Without jury mode there's never anything to vote on
+  rec = c.do(http.MethodPost, "/rooms/{roomID}/jury-vote", roomID, "bob",
+             JJuryVoteRequest{ Overturn: true })
+  c.expectError(rec, http.StatusConflict, superghost.ErrWrongState.Code)
+
+  // Pause (bob's vote alone isn't enough) and resume
+  current := c.state(roomID).CurrentPlayerUsername
+  rec = c.do(http.MethodPost, "/rooms/{roomID}/pause", roomID, "bob", nil)
+  c.expectStatus(rec, http.StatusOK)
+  c.decode(rec, &room)
+  if room.Paused || len(room.PauseVotes) != 1 {
+    t.Fatalf("one vote shouldn't pause the game: %+v", room)
+  }
+  rec = c.do(http.MethodPost, "/rooms/{roomID}/pause", roomID, "alice", nil)
+  c.expectStatus(rec, http.StatusOK)
+  c.decode(rec, &room)
+  if !room.Paused {
+    t.Fatalf("the host should be able to pause the game")
+  }
+  rec = c.do(http.MethodPost, "/rooms/{roomID}/affix", roomID, current,
+             JAffixRequest{ Prefix: "A" })
+  c.expectError(rec, http.StatusConflict, superghost.ErrPaused.Code)
+  rec = c.do(http.MethodPost, "/rooms/{roomID}/resume", roomID, "alice", nil)
+  c.expectStatus(rec, http.StatusOK)
+
+  // Concede
+  rec = c.do(http.MethodPost, "/rooms/{roomID}/affix", roomID, current,
+             JAffixRequest{ Prefix: "A" })
+  c.expectStatus(rec, http.StatusOK)
+  rec = c.do(http.MethodPost, "/rooms/{roomID}/concession", roomID, current,
+             nil)
+  c.expectStatus(rec, http.StatusOK)
+
+  // Chat
+  nextChatCh := make(chan *httptest.ResponseRecorder)
+  go func() {
+    nextChatCh <- c.do(http.MethodGet, "/rooms/{roomID}/next-chat", roomID,
+                       "", nil)
+  }()
+  waitForListener(t, c.server.Rooms[roomID].ChatListeners)
+  rec = c.do(http.MethodPost, "/rooms/{roomID}/chat", roomID, "bob",
+             JChatRequest{ Content: "hi" })
+  c.expectStatus(rec, http.StatusOK)
+  var msg superghost.Message
+  c.decode(<-nextChatCh, &msg)
+  if msg.Sender != "bob" || msg.Content != "hi" {
+    t.Fatalf("unexpected chat message %+v", msg)
+  }
+  rec = c.do(http.MethodPost, "/rooms/{roomID}/chat", roomID, "",
+             JChatRequest{ Content: "hi" })
+  c.expectError(rec, http.StatusUnauthorized,
+                superghost.ErrInvalidCredentials.Code)
+
+  // Moderation, which only the host does
+  rec = c.do(http.MethodPost, "/rooms/{roomID}/mute", roomID, "alice",
+             JMuteRequest{ Username: "bob" })
+  c.expectStatus(rec, http.StatusOK)
+  c.decode(rec, &room)
+  if len(room.Muted) != 1 || room.Muted[0] != "bob" {
+    t.Fatalf("expected bob to be muted, got %v", room.Muted)
+  }
+  rec = c.do(http.MethodPost, "/rooms/{roomID}/unmute", roomID, "alice",
+             JMuteRequest{ Username: "bob" })
+  c.expectStatus(rec, http.StatusOK)
+  go func() {
+    nextChatCh <- c.do(http.MethodGet, "/rooms/{roomID}/next-chat", roomID,
+                       "", nil)
+  }()
+  waitForListener(t, c.server.Rooms[roomID].ChatListeners)
+  params := map[string]string{"roomID": roomID,
+                              "messageID": strconv.Itoa(msg.ID)}
+  rec = c.doWithParams(http.MethodDelete, "/rooms/{roomID}/chat/{messageID}",
+                       params, "alice", nil)
+  c.expectStatus(rec, http.StatusNoContent)
+  c.decode(<-nextChatCh, &msg)
+  if !msg.Deleted {
+    t.Fatalf("expected a deletion notice, got %+v", msg)
+  }
+
+  // Kick
+  rec = c.do(http.MethodPost, "/rooms/{roomID}/kick", roomID, "bob",
+             JKickRequest{ Username: "carol" })
+  c.expectError(rec, http.StatusForbidden, superghost.ErrNotHost.Code)
+  rec = c.do(http.MethodPost, "/rooms/{roomID}/kick", roomID, "alice",
+             JKickRequest{ Username: "carol" })
+  c.expectStatus(rec, http.StatusOK)
+
+  // Invites, which only the host makes
+  rec = c.do(http.MethodPost, "/rooms/{roomID}/invites", roomID, "bob",
+             JInviteRequest{})
+  c.expectError(rec, http.StatusForbidden, superghost.ErrNotHost.Code)
+  rec = c.do(http.MethodPost, "/rooms/{roomID}/invites", roomID, "alice",
+             JInviteRequest{ Lifetime: time.Hour })
+  c.expectStatus(rec, http.StatusCreated)
+
+  // Leave
+  rec = c.do(http.MethodPost, "/rooms/{roomID}/leave", roomID, "bob", nil)
+  c.expectStatus(rec, http.StatusNoContent)
+  if n := len(c.state(roomID).Players); n != 1 {
+    t.Fatalf("expected 1 player after kick and leave, got %d", n)
+  }
+
+  // Word lists, now that the game is waiting to start again
+  rec = c.do(http.MethodPost, "/rooms/{roomID}/word-lists", roomID, "alice",
+             JWordListsRequest{ AllowedWords: []string{"wordy", "boo"},
+                                BlockedWords: []string{"stem"} })
+  c.expectStatus(rec, http.StatusOK)
+  c.decode(rec, &config)
+  if len(config.AllowedWords) != 2 || config.AllowedWords[0] != "BOO" ||
+      len(config.BlockedWords) != 1 {
+    t.Fatalf("word lists did not round trip: %+v", config)
+  }
+
+  // Nobody has won a game yet
+  rec = c.doWithParams(http.MethodGet, "/rooms/{roomID}/analysis/{gameNumber}",
+                       map[string]string{"roomID": roomID, "gameNumber": "1"},
+                       "", nil)
+  c.expectError(rec, http.StatusNotFound, superghost.ErrNoAnalysis.Code)
+
+  // Dictionary stats. The challenges above looked words up.
+  rec = c.do(http.MethodGet, "/dictionary-stats", "", "", nil)
+  c.expectStatus(rec, http.StatusOK)
+  var stats superghost.DictionaryStats
+  c.decode(rec, &stats)
+  if stats.Misses < 1 || stats.BreakerOpen {
+    t.Fatalf("unexpected dictionary stats: %+v", stats)
+  }
+
+  // The daily puzzle. New players are only given a cookie, named like a
+  // player, on their first checked guess.
+  rec = c.do(http.MethodGet, "/puzzle", "", "", nil)
+  c.expectStatus(rec, http.StatusOK)
+  var progress superghost.PuzzleProgress
+  c.decode(rec, &progress)
+  if progress.Puzzle.Stem == "" || progress.Streak != 0 ||
+      c.usernameToCookies["puzzle-player"] != nil {
+    t.Fatalf("unexpected puzzle progress: %+v", progress)
+  }
+  rec = c.do(http.MethodPost, "/puzzle/guess", "", "", JPuzzleGuessRequest{})
+  c.expectError(rec, http.StatusBadRequest, superghost.ErrInvalidMove.Code)
+  if c.usernameToCookies["puzzle-player"] != nil {
+    t.Fatalf("expected no puzzle cookie for a guess that wasn't checked")
+  }
+  var guess superghost.PuzzleGuess
+  for misses := 1; misses <= 2; misses++ {
+    rec = c.do(http.MethodPost, "/puzzle/guess", "", "puzzle-player",
+               JPuzzleGuessRequest{ Prefix: "QX", Suffix: "ZQ" })
+    c.expectStatus(rec, http.StatusOK)
+    c.decode(rec, &guess)
+    if guess.IsWord || guess.Progress.Misses != misses ||
+        c.usernameToCookies["puzzle-player"] == nil {
+      t.Fatalf("unexpected guess: %+v", guess)
+    }
+  }
+
+  // Quick play. Bad requests are turned away before anyone is queued.
+  rec = c.do(http.MethodPost, "/quick-play", "", "",
+             JQuickPlayRequest{ Username: "carol", Players: 1 })
+  c.expectError(rec, http.StatusBadRequest, kBadRequestCode)
+  rec = c.do(http.MethodPost, "/quick-play", "", "",
+             JQuickPlayRequest{ Username: "not ok" })
+  c.expectError(rec, http.StatusBadRequest,
+                superghost.ErrInvalidUsername.Code)
+
+  // Tournaments. The organizer's cookie is named like a player.
+  rec = c.do(http.MethodPost, "/tournaments", "", "",
+             superghost.TournamentConfig{ Format: "round-robin" })
+  c.expectError(rec, http.StatusBadRequest, kBadRequestCode)
+  rec = c.do(http.MethodPost, "/tournaments", "", "",
+             superghost.TournamentConfig{ Name: "Office" })
+  c.expectStatus(rec, http.StatusCreated)
+  var createdTournament JCreateTournamentResponse
+  c.decode(rec, &createdTournament)
+  tournament := map[string]string{"tournamentID": createdTournament.ID}
+  for _, username := range []string{"alice", "bob"} {
+    rec = c.doWithParams(http.MethodPost,
+                         "/tournaments/{tournamentID}/registration",
+                         tournament, "", JRegisterRequest{ Username: username })
+    c.expectStatus(rec, http.StatusOK)
+  }
+  rec = c.doWithParams(http.MethodPost, "/tournaments/{tournamentID}/start",
+                       tournament, "alice", nil)
+  c.expectError(rec, http.StatusForbidden, superghost.ErrNotOrganizer.Code)
+  rec = c.doWithParams(http.MethodPost, "/tournaments/{tournamentID}/start",
+                       tournament, "organizer", nil)
+  c.expectStatus(rec, http.StatusOK)
+  rec = c.doWithParams(http.MethodGet, "/tournaments/{tournamentID}",
+                       tournament, "", nil)
+  c.expectStatus(rec, http.StatusOK)
+
+  // The spec
+  rec = c.do(http.MethodGet, "/openapi.json", "", "", nil)
+  c.expectStatus(rec, http.StatusOK)
+
+  for _, route := range c.server.apiV1Routes() {
+    if !c.exercised[route.method + " " + route.pattern] {
+      t.Errorf("route %s %s was never exercised", route.method, route.pattern)
+    }
+  }
+}
+
+func TestAPIV1LanguageRooms(t *testing.T) {
+  c := newAPITestClient(t)
+  for _, language := range []superghost.Language{
+      superghost.LanguageSpanish, superghost.LanguageGerman,
+      superghost.LanguageFrench} {
+    rec := c.do(http.MethodPost, "/rooms", "", "",
+                superghost.Config{ MaxPlayers: 2, Language: language })
+    c.expectStatus(rec, http.StatusCreated)
+    var created JCreateRoomResponse
+    c.decode(rec, &created)
+    rec = c.do(http.MethodGet, "/rooms/{roomID}/config", created.ID, "", nil)
+    c.expectStatus(rec, http.StatusOK)
+    var config superghost.Config
+    c.decode(rec, &config)
+    if config.Language != language {
+      t.Fatalf("expected a %s room, got %+v", language, config)
+    }
+  }
+}
+
+func TestAPIV1Errors(t *testing.T) {
+  c := newAPITestClient(t)
+
+  rec := c.do(http.MethodGet, "/rooms/{roomID}", "NOPE", "", nil)
+  c.expectError(rec, http.StatusNotFound, kNotFoundCode)
+
+  rec = c.do(http.MethodPost, "/rooms", "", "", superghost.Config{})
+  c.expectError(rec, http.StatusBadRequest, kBadRequestCode)
+
+  rec = c.do(http.MethodPost, "/rooms", "", "",
+             map[string]interface{} {"MaxPlayers": 2, "NotAField": true})
+  c.expectError(rec, http.StatusBadRequest, kBadRequestCode)
+
+  rec = c.do(http.MethodPost, "/rooms", "", "",
+             superghost.Config{ MaxPlayers: 2, Variant: "hangman" })
+  c.expectError(rec, http.StatusBadRequest, kBadRequestCode)
+
+  rec = c.do(http.MethodPost, "/rooms", "", "",
+             superghost.Config{ MaxPlayers: 2, Scoring: "golf" })
+  c.expectError(rec, http.StatusBadRequest, kBadRequestCode)
+
+  rec = c.do(http.MethodPost, "/rooms", "", "",
+             superghost.Config{ MaxPlayers: 2, Language: "tlh" })
+  c.expectError(rec, http.StatusBadRequest, kBadRequestCode)
+
+  // Spanish has Ñ but not Ç
+  rec = c.do(http.MethodPost, "/rooms", "", "",
+             superghost.Config{ MaxPlayers: 2, Language: "es",
+                                AllowedWords: []string{"garçon"} })
+  c.expectError(rec, http.StatusBadRequest, kBadRequestCode)
+
+  rec = c.do(http.MethodPost, "/rooms", "", "",
+             superghost.Config{ MaxPlayers: 2, TimeDelay: -time.Second })
+  c.expectError(rec, http.StatusBadRequest, kBadRequestCode)
+
+  rec = c.do(http.MethodPost, "/rooms", "", "",
+             superghost.Config{ MaxPlayers: 2,
+                                BlockedWords: []string{"two words"} })
+  c.expectError(rec, http.StatusBadRequest, kBadRequestCode)
+
+  rec = c.do(http.MethodPost, "/rooms", "", "",
+             superghost.Config{ MaxPlayers: 2, EliminationWord: "R2D2" })
+  c.expectError(rec, http.StatusBadRequest, kBadRequestCode)
+
+  rec = c.do(http.MethodDelete, "/rooms", "", "", nil)
+  c.expectError(rec, http.StatusMethodNotAllowed, kMethodNotAllowedCode)
+}
+
+func TestAPIV1Superduperghost(t *testing.T) {
+  c := newAPITestClient(t)
+
+  rec := c.do(http.MethodPost, "/rooms", "", "", superghost.Config {
+    Variant: superghost.VariantSuperduperghost,
+    MaxPlayers: 2,
+    MinWordLength: 4,
+  })
+  c.expectStatus(rec, http.StatusCreated)
+  var created JCreateRoomResponse
+  c.decode(rec, &created)
+  roomID := created.ID
+  for _, username := range []string{"alice", "bob"} {
+    rec = c.do(http.MethodPost, "/rooms/{roomID}/join", roomID, "",
+               JJoinRequest{ Username: username })
+    c.expectStatus(rec, http.StatusOK)
+  }
+
+  // "GT", then "GST"
+  rec = c.do(http.MethodPost, "/rooms/{roomID}/affix", roomID, "alice",
+             JAffixRequest{ Suffix: "G" })
+  c.expectStatus(rec, http.StatusOK)
+  rec = c.do(http.MethodPost, "/rooms/{roomID}/affix", roomID, "bob",
+             JAffixRequest{ Suffix: "T" })
+  c.expectStatus(rec, http.StatusOK)
+  rec = c.do(http.MethodPost, "/rooms/{roomID}/insertion", roomID, "alice",
+             JInsertRequest{ Index: 1, Letter: "s" })
+  c.expectStatus(rec, http.StatusOK)
+  var room superghost.JRoom
+  c.decode(rec, &room)
+  if room.Stem != "GST" {
+    t.Fatalf("expected stem GST, got %s", room.Stem)
+  }
+  last := room.LogPush[len(room.LogPush) - 1]
+  if last.Type != "Insert" || last.Letter != "S" || *last.Index != 1 {
+    t.Fatalf("unexpected log item for the insertion: %+v", last)
+  }
+
+  rec = c.do(http.MethodPost, "/rooms/{roomID}/challenge-continuation",
+             roomID, "bob", nil)
+  c.expectStatus(rec, http.StatusOK)
+  rec = c.do(http.MethodPost, "/rooms/{roomID}/rebuttal", roomID, "alice",
+             JRebuttalRequest{ Word: "ghost" })
+  c.expectStatus(rec, http.StatusOK)
+  c.decode(rec, &room)
+  for _, p := range room.Players {
+    if (p.Username == "bob") != (p.Score == 1) {
+      t.Fatalf("only the challenger should have lost: %+v", room.Players)
+    }
+  }
+}
+
+func TestAPIV1Teams(t *testing.T) {
+  c := newAPITestClient(t)
+
+  rec := c.do(http.MethodPost, "/rooms", "", "", superghost.Config {
+    MaxPlayers: 4,
+    TeamCount: 2,
+  })
+  c.expectStatus(rec, http.StatusCreated)
+  var created JCreateRoomResponse
+  c.decode(rec, &created)
+  roomID := created.ID
+
+  rec = c.do(http.MethodPost, "/rooms/{roomID}/join", roomID, "",
+             JJoinRequest{ Username: "alice", Team: 3 })
+  c.expectError(rec, http.StatusBadRequest, superghost.ErrInvalidTeam.Code)
+  for _, username := range []string{"alice", "bob"} {
+    rec = c.do(http.MethodPost, "/rooms/{roomID}/join", roomID, "",
+               JJoinRequest{ Username: username, Team: 2 })
+    c.expectStatus(rec, http.StatusOK)
+  }
+  rec = c.do(http.MethodPost, "/rooms/{roomID}/join", roomID, "",
+             JJoinRequest{ Username: "carol" })
+  c.expectStatus(rec, http.StatusOK)
+
+  room := c.state(roomID)
+  if len(room.Teams) != 2 || len(room.Teams[0].Usernames) != 1 ||
+      room.Teams[0].Usernames[0] != "carol" ||
+      len(room.Teams[1].Usernames) != 2 {
+    t.Fatalf("unexpected teams: %+v", room.Teams)
+  }
+  if room.State != "edit" {
+    t.Fatalf("two teams with players should start the game, got %s",
+             room.State)
+  }
+
+  rec = c.do(http.MethodPost, "/rooms", "", "", superghost.Config {
+    MaxPlayers: 2,
+    TeamCount: 3,
+  })
+  c.expectError(rec, http.StatusBadRequest, kBadRequestCode)
+}
+
+func TestAPIV1Jury(t *testing.T) {
+  c := newAPITestClient(t)
+
+  rec := c.do(http.MethodPost, "/rooms", "", "", superghost.Config {
+    MaxPlayers: 3,
+    MinWordLength: 4,
+    JuryTime: time.Minute,
+  })
+  c.expectStatus(rec, http.StatusCreated)
+  var created JCreateRoomResponse
+  c.decode(rec, &created)
+  roomID := created.ID
+  for _, username := range []string{"alice", "bob", "carol"} {
+    rec = c.do(http.MethodPost, "/rooms/{roomID}/join", roomID, "",
+               JJoinRequest{ Username: username })
+    c.expectStatus(rec, http.StatusOK)
+  }
+
+  // Spell STEM and challenge it
+  for _, letter := range []string{"S", "T", "E", "M"} {
+    current := c.state(roomID).CurrentPlayerUsername
+    rec = c.do(http.MethodPost, "/rooms/{roomID}/affix", roomID, current,
+               JAffixRequest{ Suffix: letter })
+    c.expectStatus(rec, http.StatusOK)
+  }
+  room := c.state(roomID)
+  challenged := room.LastPlayerUsername
+  rec = c.do(http.MethodPost, "/rooms/{roomID}/challenge-is-word", roomID,
+             room.CurrentPlayerUsername, nil)
+  c.expectStatus(rec, http.StatusOK)
+  c.decode(rec, &room)
+  if room.State != "jury" || room.Jury == nil || len(room.Jury.Jurors) != 1 {
+    t.Fatalf("expected a one-person jury, got %+v", room)
+  }
+
+  rec = c.do(http.MethodPost, "/rooms/{roomID}/jury-vote", roomID, challenged,
+             JJuryVoteRequest{ Overturn: true })
+  c.expectError(rec, http.StatusForbidden, superghost.ErrNotJuror.Code)
+  rec = c.do(http.MethodPost, "/rooms/{roomID}/jury-vote", roomID,
+             room.Jury.Jurors[0], JJuryVoteRequest{ Overturn: true })
+  c.expectStatus(rec, http.StatusOK)
+  var afterVote superghost.JRoom
+  c.decode(rec, &afterVote)
+  if afterVote.State != "edit" || afterVote.Jury != nil {
+    t.Fatalf("the vote should have closed: %+v", afterVote)
+  }
+  for _, p := range afterVote.Players {
+    if (p.Username == room.CurrentPlayerUsername) != (p.Score == 1) {
+      t.Fatalf("overturning should score the challenger: %+v",
+               afterVote.Players)
+    }
+  }
+}
+
+func TestAPIV1Practice(t *testing.T) {
+  c := newAPITestClient(t)
+
+  rec := c.do(http.MethodPost, "/rooms", "", "", superghost.Config {
+    MaxPlayers: 2,
+    MinWordLength: 4,
+    Practice: true,
+  })
+  c.expectStatus(rec, http.StatusCreated)
+  var created JCreateRoomResponse
+  c.decode(rec, &created)
+  roomID := created.ID
+  for _, username := range []string{"alice", "bob"} {
+    rec = c.do(http.MethodPost, "/rooms/{roomID}/join", roomID, "",
+               JJoinRequest{ Username: username })
+    c.expectStatus(rec, http.StatusOK)
+  }
+  current := c.state(roomID).CurrentPlayerUsername
+  rec = c.do(http.MethodPost, "/rooms/{roomID}/affix", roomID, current,
+             JAffixRequest{ Suffix: "S" })
+  c.expectStatus(rec, http.StatusOK)
+
+  current = c.state(roomID).CurrentPlayerUsername
+  rec = c.do(http.MethodGet, "/rooms/{roomID}/hint", roomID, current, nil)
+  c.expectStatus(rec, http.StatusOK)
+  var hint superghost.Hint
+  c.decode(rec, &hint)
+  // The server's dictionary isn't a word list, so hints come from the
+  // bundled one
+  if len(hint.Moves) == 0 || !strings.Contains(hint.Example, "S") {
+    t.Fatalf("unexpected hint: %+v", hint)
+  }
+  for _, p := range c.state(roomID).Players {
+    if (p.Username == current) != (p.HintsUsed == 1) {
+      t.Fatalf("only %s should have used a hint: %+v", current, p)
+    }
+  }
+}
+
+func TestAPIV1Analysis(t *testing.T) {
+  c := newAPITestClient(t)
+
+  rec := c.do(http.MethodPost, "/rooms", "", "", superghost.Config {
+    MaxPlayers: 2,
+    MinWordLength: 4,
+    EliminationThreshold: 1,
+  })
+  c.expectStatus(rec, http.StatusCreated)
+  var created JCreateRoomResponse
+  c.decode(rec, &created)
+  roomID := created.ID
+  for _, username := range []string{"alice", "bob"} {
+    rec = c.do(http.MethodPost, "/rooms/{roomID}/join", roomID, "",
+               JJoinRequest{ Username: username })
+    c.expectStatus(rec, http.StatusOK)
+  }
+
+  // Spell STEM and lose the game on it
+  for _, letter := range []string{"S", "T", "E", "M"} {
+    current := c.state(roomID).CurrentPlayerUsername
+    rec = c.do(http.MethodPost, "/rooms/{roomID}/affix", roomID, current,
+               JAffixRequest{ Suffix: letter })
+    c.expectStatus(rec, http.StatusOK)
+  }
+  rec = c.do(http.MethodPost, "/rooms/{roomID}/challenge-is-word", roomID,
+             c.state(roomID).CurrentPlayerUsername, nil)
+  c.expectStatus(rec, http.StatusOK)
+
+  params := map[string]string{"roomID": roomID, "gameNumber": "1"}
+  rec = c.doWithParams(http.MethodGet, "/rooms/{roomID}/analysis/{gameNumber}",
+                       params, "", nil)
+  c.expectStatus(rec, http.StatusOK)
+  var analysis superghost.GameAnalysis
+  c.decode(rec, &analysis)
+  if len(analysis.Rounds) != 1 || len(analysis.Rounds[0].Moves) != 4 ||
+      analysis.Rounds[0].Moves[3].WasSafe {
+    t.Fatalf("unexpected analysis: %+v", analysis)
+  }
+
+  params["gameNumber"] = "first"
+  rec = c.doWithParams(http.MethodGet, "/rooms/{roomID}/analysis/{gameNumber}",
+                       params, "", nil)
+  c.expectError(rec, http.StatusBadRequest, kBadRequestCode)
+}
+
+func TestAPIV1QuickPlay(t *testing.T) {
+  c := newAPITestClient(t)
+
+  // Alice waits for someone to play with...
+  aliceRec := httptest.NewRecorder()
+  done := make(chan struct{})
+  go func() {
+    b, _ := json.Marshal(JQuickPlayRequest{ Username: "alice" })
+    req := httptest.NewRequest(http.MethodPost, "/api/v1/quick-play",
+                               bytes.NewReader(b))
+    c.server.Router.ServeHTTP(aliceRec, req)
+    close(done)
+  }()
+  deadline := time.Now().Add(time.Second)
+  for {
+    c.server.matchmaker.mutex.Lock()
+    queued := len(c.server.matchmaker.queue)
+    c.server.matchmaker.mutex.Unlock()
+    if queued == 1 {
+      break
+    }
+    if time.Now().After(deadline) {
+      t.Fatalf("alice was never queued")
+    }
+    time.Sleep(time.Millisecond)
+  }
+  // ...and can't queue twice
+  rec := c.do(http.MethodPost, "/quick-play", "", "",
+              JQuickPlayRequest{ Username: "alice" })
+  c.expectError(rec, http.StatusConflict, kAlreadyQueuedCode)
+
+  // Bob, who wants the same game, completes the pair
+  rec = c.do(http.MethodPost, "/quick-play", "", "",
+             JQuickPlayRequest{ Username: "bob", Players: 2 })
+  c.expectStatus(rec, http.StatusOK)
+  var bobMatch JQuickPlayResponse
+  c.decode(rec, &bobMatch)
+  <-done
+  c.expectStatus(aliceRec, http.StatusOK)
+  var aliceMatch JQuickPlayResponse
+  c.decode(aliceRec, &aliceMatch)
+  if aliceMatch.RoomID != bobMatch.RoomID {
+    t.Fatalf("alice and bob were put in different rooms: %s and %s",
+             aliceMatch.RoomID, bobMatch.RoomID)
+  }
+  c.usernameToCookies["alice"] = aliceRec.Result().Cookies()
+
+  // Both already have working cookies
+  roomID := bobMatch.RoomID
+  room := c.state(roomID)
+  if len(room.Players) != 2 {
+    t.Fatalf("expected 2 players, got %+v", room.Players)
+  }
+  rec = c.do(http.MethodPost, "/rooms/{roomID}/affix", roomID,
+             room.CurrentPlayerUsername, JAffixRequest{ Suffix: "S" })
+  c.expectStatus(rec, http.StatusOK)
+}
+
+func TestAPIV1Tournament(t *testing.T) {
+  c := newAPITestClient(t)
+
+  rec := c.do(http.MethodPost, "/tournaments", "", "",
+              superghost.TournamentConfig {
+                Room: superghost.Config {
+                  MinWordLength: 4,
+                  EliminationThreshold: 1,
+                },
+              })
+  c.expectStatus(rec, http.StatusCreated)
+  var created JCreateTournamentResponse
+  c.decode(rec, &created)
+  tournament := map[string]string{"tournamentID": created.ID}
+
+  // Matches are between two players, not teams
+  rec = c.do(http.MethodPost, "/tournaments", "", "",
+             superghost.TournamentConfig {
+               Room: superghost.Config{ TeamCount: 2 },
+             })
+  c.expectError(rec, http.StatusBadRequest, kBadRequestCode)
+
+  for _, username := range []string{"alice", "bob"} {
+    rec = c.doWithParams(http.MethodPost,
+                         "/tournaments/{tournamentID}/registration",
+                         tournament, "", JRegisterRequest{ Username: username })
+    c.expectStatus(rec, http.StatusOK)
+  }
+  rec = c.doWithParams(http.MethodPost, "/tournaments/{tournamentID}/start",
+                       tournament, "organizer", nil)
+  c.expectStatus(rec, http.StatusOK)
+  var jt superghost.JTournament
+  c.decode(rec, &jt)
+  if len(jt.Rounds) != 1 || len(jt.Rounds[0].Matches) != 1 {
+    t.Fatalf("unexpected bracket: %+v", jt)
+  }
+  roomID := jt.Rounds[0].Matches[0].RoomID
+
+  // Only the players drawn into the match can join its room, with the cookie
+  // they were given when they registered
+  rec = c.do(http.MethodPost, "/rooms/{roomID}/join", roomID, "",
+             JJoinRequest{ Username: "mallory" })
+  c.expectError(rec, http.StatusForbidden, superghost.ErrNotEntrant.Code)
+  rec = c.do(http.MethodPost, "/rooms/{roomID}/join", roomID, "entrant-bob",
+             JJoinRequest{ Username: "alice" })
+  c.expectError(rec, http.StatusForbidden, superghost.ErrNotEntrant.Code)
+  for _, username := range []string{"alice", "bob"} {
+    rec = c.do(http.MethodPost, "/rooms/{roomID}/join", roomID,
+               "entrant-" + username, JJoinRequest{ Username: username })
+    c.expectStatus(rec, http.StatusOK)
+  }
+
+  // Whoever spells STEM loses the game, and with it the tournament
+  for _, letter := range []string{"S", "T", "E", "M"} {
+    current := c.state(roomID).CurrentPlayerUsername
+    rec = c.do(http.MethodPost, "/rooms/{roomID}/affix", roomID, current,
+               JAffixRequest{ Suffix: letter })
+    c.expectStatus(rec, http.StatusOK)
+  }
+  winner := c.state(roomID).CurrentPlayerUsername
+  rec = c.do(http.MethodPost, "/rooms/{roomID}/challenge-is-word", roomID,
+             winner, nil)
+  c.expectStatus(rec, http.StatusOK)
+
+  rec = c.doWithParams(http.MethodGet, "/tournaments/{tournamentID}",
+                       tournament, "", nil)
+  c.expectStatus(rec, http.StatusOK)
+  c.decode(rec, &jt)
+  if jt.State != "finished" || jt.Winner != winner ||
+      jt.Rounds[0].Matches[0].Winner != winner {
+    t.Fatalf("expected %s to win the tournament: %+v", winner, jt)
+  }
+}
+
+func TestAPIV1PrivateRoom(t *testing.T) {
+  c := newAPITestClient(t)
+
+  rec := c.do(http.MethodPost, "/rooms", "", "", superghost.Config {
+    MaxPlayers: 3,
+    Password: "hunter2",
+    LongID: true,
+  })
+  c.expectStatus(rec, http.StatusCreated)
+  var created JCreateRoomResponse
+  c.decode(rec, &created)
+  roomID := created.ID
+  if len(roomID) != kLongRoomIDLength {
+    t.Fatalf("expected a long room ID, got '%s'", roomID)
+  }
+  // Only the room's players can see it
+  for _, pattern := range []string{"/rooms/{roomID}", "/rooms/{roomID}/config",
+                                   "/rooms/{roomID}/next-state",
+                                   "/rooms/{roomID}/next-chat"} {
+    rec = c.do(http.MethodGet, pattern, roomID, "", nil)
+    c.expectError(rec, http.StatusUnauthorized,
+                  superghost.ErrInvalidCredentials.Code)
+  }
+
+  rec = c.do(http.MethodPost, "/rooms/{roomID}/join", roomID, "",
+             JJoinRequest{ Username: "alice" })
+  c.expectError(rec, http.StatusForbidden, superghost.ErrWrongPassword.Code)
+  rec = c.do(http.MethodPost, "/rooms/{roomID}/join", roomID, "",
+             JJoinRequest{ Username: "alice", Password: "hunter2" })
+  c.expectStatus(rec, http.StatusOK)
+  rec = c.do(http.MethodGet, "/rooms/{roomID}/config", roomID, "alice", nil)
+  c.expectStatus(rec, http.StatusOK)
+  if strings.Contains(rec.Body.String(), "hunter2") {
+    t.Fatalf("the config gave away the password: %s", rec.Body.String())
+  }
+
+  rec = c.do(http.MethodPost, "/rooms/{roomID}/invites", roomID, "alice",
+             JInviteRequest{ Lifetime: -time.Hour })
+  c.expectError(rec, http.StatusBadRequest, kBadRequestCode)
+  rec = c.do(http.MethodPost, "/rooms/{roomID}/invites", roomID, "alice", nil)
+  c.expectStatus(rec, http.StatusCreated)
+  var invite JInviteResponse
+  c.decode(rec, &invite)
+  if !strings.HasPrefix(invite.Link, "/rooms/" + roomID + "?invite=") {
+    t.Fatalf("unexpected invite link '%s'", invite.Link)
+  }
+
+  rec = c.do(http.MethodPost, "/rooms/{roomID}/join", roomID, "",
+             JJoinRequest{ Username: "bob", Invite: invite.Token + "x" })
+  c.expectError(rec, http.StatusForbidden, superghost.ErrInvalidInvite.Code)
+  rec = c.do(http.MethodPost, "/rooms/{roomID}/join", roomID, "",
+             JJoinRequest{ Username: "bob", Invite: invite.Token })
+  c.expectStatus(rec, http.StatusOK)
+}
+
+func TestAPIV1ChatModeration(t *testing.T) {
+  c := newAPITestClient(t)
+  c.server.ChatFilter = superghost.NewWordFilter([]string{"heck"})
+
+  rec := c.do(http.MethodPost, "/rooms", "", "",
+              superghost.Config{ MaxPlayers: 3 })
+  c.expectStatus(rec, http.StatusCreated)
+  var created JCreateRoomResponse
+  c.decode(rec, &created)
+  roomID := created.ID
+  for _, username := range []string{"alice", "bob"} {
+    rec = c.do(http.MethodPost, "/rooms/{roomID}/join", roomID, "",
+               JJoinRequest{ Username: username })
+    c.expectStatus(rec, http.StatusOK)
+  }
+
+  rec = c.do(http.MethodPost, "/rooms/{roomID}/chat", roomID, "bob",
+             JChatRequest{ Content: "what the heck" })
+  c.expectStatus(rec, http.StatusOK)
+  var msg superghost.Message
+  c.decode(rec, &msg)
+  if msg.Content != "what the ****" {
+    t.Fatalf("expected the filter to mask 'heck', got '%s'", msg.Content)
+  }
+  rec = c.do(http.MethodPost, "/rooms/{roomID}/chat", roomID, "bob",
+             JChatRequest{ Content: "sorry" })
+  c.expectStatus(rec, http.StatusOK)
+
+  rec = c.do(http.MethodPost, "/rooms/{roomID}/mute", roomID, "bob",
+             JMuteRequest{ Username: "alice" })
+  c.expectError(rec, http.StatusForbidden, superghost.ErrNotHost.Code)
+  rec = c.do(http.MethodPost, "/rooms/{roomID}/mute", roomID, "alice",
+             JMuteRequest{ Username: "nobody" })
+  c.expectError(rec, http.StatusNotFound, superghost.ErrPlayerNotFound.Code)
+  rec = c.do(http.MethodPost, "/rooms/{roomID}/mute", roomID, "alice",
+             JMuteRequest{ Username: "bob" })
+  c.expectStatus(rec, http.StatusOK)
+  rec = c.do(http.MethodPost, "/rooms/{roomID}/chat", roomID, "bob",
+             JChatRequest{ Content: "hello?" })
+  c.expectError(rec, http.StatusForbidden, superghost.ErrMuted.Code)
+
+  params := map[string]string{"roomID": roomID, "messageID": "first"}
+  rec = c.doWithParams(http.MethodDelete, "/rooms/{roomID}/chat/{messageID}",
+                       params, "alice", nil)
+  c.expectError(rec, http.StatusBadRequest, kBadRequestCode)
+  params["messageID"] = strconv.Itoa(msg.ID)
+  rec = c.doWithParams(http.MethodDelete, "/rooms/{roomID}/chat/{messageID}",
+                       params, "bob", nil)
+  c.expectError(rec, http.StatusForbidden, superghost.ErrNotHost.Code)
+  rec = c.doWithParams(http.MethodDelete, "/rooms/{roomID}/chat/{messageID}",
+                       params, "alice", nil)
+  c.expectStatus(rec, http.StatusNoContent)
+  params["messageID"] = "100"
+  rec = c.doWithParams(http.MethodDelete, "/rooms/{roomID}/chat/{messageID}",
+                       params, "alice", nil)
+  c.expectError(rec, http.StatusNotFound, superghost.ErrMessageNotFound.Code)
+
+  // Players who join later see what's left of the chat
+  rec = c.do(http.MethodPost, "/rooms/{roomID}/join", roomID, "",
+             JJoinRequest{ Username: "carol" })
+  c.expectStatus(rec, http.StatusOK)
+  var room superghost.JRoom
+  c.decode(rec, &room)
+  if len(room.Chat) != 1 || room.Chat[0].Content != "sorry" {
+    t.Fatalf("expected only bob's apology in the history, got %+v",
+             room.Chat)
+  }
+}
+
+func TestOpenAPISpecCoversEveryRoute(t *testing.T) {
+  c := newAPITestClient(t)
+
+  var spec struct {
+    OpenAPI string
+    Paths map[string]map[string]struct {
+      Responses map[string]interface{}
+    }
+    Components struct {
+      Schemas map[string]interface{}
+    }
+  }
+  rec := c.do(http.MethodGet, "/openapi.json", "", "", nil)
+  c.expectStatus(rec, http.StatusOK)
+  c.decode(rec, &spec)
+
+  for _, route := range c.server.apiV1Routes() {
+    operation, ok :=
+        spec.Paths["/api/v1" + route.pattern][strings.ToLower(route.method)]
+    if !ok {
+      t.Errorf("%s %s is missing from the spec", route.method, route.pattern)
+      continue
+    }
+    if _, ok := operation.Responses["default"]; !ok {
+      t.Errorf("%s %s does not document its errors",
+               route.method, route.pattern)
+    }
+  }
+  for _, name := range []string{"JRoom", "JPlayer", "Config", "JError"} {
+    if _, ok := spec.Components.Schemas[name]; !ok {
+      t.Errorf("schema %s is missing from the spec", name)
+    }
+  }
+}
@@ -0,0 +1,129 @@
+package sgserver
+
+import (
+  "encoding/json"
+  "errors"
+  "net/http"
+  "superghost"
+)
+
+// The body of every error response. Code is meant for machines (the client
+// keys its translations off of it), Message is meant for humans.
+type JError struct {
+  Code string `json:"code"`
+  Message string `json:"message"`
+}
+
+// Codes for errors that originate in the server rather than in a room
+const (
+  kBadRequestCode = "bad-request"
+  kMethodNotAllowedCode = "method-not-allowed"
+  kNotFoundCode = "not-found"
+  kAlreadyQueuedCode = "already-queued"
+  kRateLimitedCode = "rate-limited"
+  kTooManyRoomsCode = "too-many-rooms"
+  kInternalCode = "internal"
+)
+
+var _errorCodeToStatus = map[string]int {
+  superghost.ErrInvalidCredentials.Code: http.StatusUnauthorized,
+  superghost.ErrNotYourTurn.Code: http.StatusForbidden,
+  superghost.ErrNotHost.Code: http.StatusForbidden,
+  superghost.ErrEliminated.Code: http.StatusForbidden,
+  superghost.ErrNotJuror.Code: http.StatusForbidden,
+  superghost.ErrNotPractice.Code: http.StatusForbidden,
+  superghost.ErrNotEntrant.Code: http.StatusForbidden,
+  superghost.ErrNotOrganizer.Code: http.StatusForbidden,
+  superghost.ErrWrongPassword.Code: http.StatusForbidden,
+  superghost.ErrInvalidInvite.Code: http.StatusForbidden,
+  superghost.ErrMuted.Code: http.StatusForbidden,
+  superghost.ErrWrongState.Code: http.StatusConflict,
+  superghost.ErrRoomFull.Code: http.StatusConflict,
+  superghost.ErrUsernameTaken.Code: http.StatusConflict,
+  superghost.ErrAlreadyLeaving.Code: http.StatusConflict,
+  superghost.ErrPaused.Code: http.StatusConflict,
+  superghost.ErrInvalidMove.Code: http.StatusBadRequest,
+  superghost.ErrBelowMinLength.Code: http.StatusBadRequest,
+  superghost.ErrEmptyStem.Code: http.StatusBadRequest,
+  superghost.ErrInvalidWord.Code: http.StatusBadRequest,
+  superghost.ErrInvalidTeam.Code: http.StatusBadRequest,
+  superghost.ErrWordUsed.Code: http.StatusBadRequest,
+  superghost.ErrInvalidUsername.Code: http.StatusBadRequest,
+  superghost.ErrEmptyMessage.Code: http.StatusBadRequest,
+  superghost.ErrMessageTooLong.Code: http.StatusBadRequest,
+  superghost.ErrMessageBlocked.Code: http.StatusBadRequest,
+  superghost.ErrInvalidLanguage.Code: http.StatusBadRequest,
+  superghost.ErrPlayerNotFound.Code: http.StatusNotFound,
+  superghost.ErrNoAnalysis.Code: http.StatusNotFound,
+  superghost.ErrMessageNotFound.Code: http.StatusNotFound,
+  superghost.ErrDictionaryUnavailable.Code: http.StatusServiceUnavailable,
+  superghost.ErrNoPuzzle.Code: http.StatusServiceUnavailable,
+}
+
+func writeJError(w http.ResponseWriter, status int, jerr JError) {
+  b, err := json.Marshal(jerr)
+  if err != nil {
+    panic(err)  // Two strings will always marshal
+  }
+  w.Header().Set("Content-Type", "application/json; charset=utf-8")
+  w.Header().Set("X-Content-Type-Options", "nosniff")
+  w.WriteHeader(status)
+  w.Write(b)
+}
+
+// Writes err as a JSON error response. Errors from the superghost package get
+// the status matching their code; anything else is an internal error.
+func writeError(w http.ResponseWriter, err error) {
+  if errors.Is(err, errTooManyRooms) {
+    writeRetryAfter(w, kTooManyRoomsRetryAfter)
+    writeJError(w, http.StatusServiceUnavailable, JError {
+      Code: kTooManyRoomsCode,
+      Message: err.Error(),
+    })
+    return
+  }
+  var sgErr *superghost.Error
+  if !errors.As(err, &sgErr) {
+    writeJError(w, http.StatusInternalServerError, JError {
+      Code: kInternalCode,
+      Message: err.Error(),
+    })
+    return
+  }
+  status, ok := _errorCodeToStatus[sgErr.Code]
+  if !ok {
+    status = http.StatusInternalServerError
+  }
+  writeJError(w, status, JError{ Code: sgErr.Code, Message: sgErr.Message })
+}
+
+// For malformed requests that never made it to the room
+func writeBadRequest(w http.ResponseWriter, err error) {
+  writeJError(w, http.StatusBadRequest, JError {
+    Code: kBadRequestCode,
+    Message: err.Error(),
+  })
+}
+
+// Errors making a room are the config's fault, unless the server is full
+func writeCreateRoomError(w http.ResponseWriter, err error) {
+  if errors.Is(err, errTooManyRooms) {
+    writeError(w, err)
+  } else {
+    writeBadRequest(w, err)
+  }
+}
+
+func writeMethodNotAllowed(w http.ResponseWriter) {
+  writeJError(w, http.StatusMethodNotAllowed, JError {
+    Code: kMethodNotAllowedCode,
+    Message: "method not allowed",
+  })
+}
+
+func writeNotFound(w http.ResponseWriter) {
+  writeJError(w, http.StatusNotFound, JError {
+    Code: kNotFoundCode,
+    Message: "not found",
+  })
+}
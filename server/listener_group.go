@@ -19,7 +19,8 @@ func (lg *ListenerGroup) AddListener() chan string {
   lg.ListenersMutex.Lock()
   defer lg.ListenersMutex.Unlock()
 
-  newChan := make(chan string)
+  // Buffered so Broadcast never blocks on a listener that has gone away
+  newChan := make(chan string, 1)
   lg.Listeners = append(lg.Listeners, newChan)
   return newChan
 }
@@ -0,0 +1,323 @@
+package sgserver
+
+import (
+  "errors"
+  "fmt"
+  "net/http"
+  "superghost"
+  "sync"
+  "time"
+)
+
+const (
+  kDefaultQuickPlayPlayers = 2
+  kMaxQuickPlayPlayers = 8
+  // How long the oldest player in a queue waits before a room is started with
+  // whoever is there, even if that's only them
+  kQuickPlayTimeout = 30 * time.Second
+  // Rated players start off only matched with players this close to them...
+  kRatingWindow = 100
+  // ...and the window widens by this much every second they wait
+  kRatingWindowGrowth = 10
+)
+
+type JQuickPlayRequest struct {
+  Username string
+  // Empty means superghost
+  Variant superghost.Variant
+  // Empty means English
+  Language superghost.Language
+  // How many players to play with, including yourself. 0 means
+  // kDefaultQuickPlayPlayers.
+  Players int
+  // Players with a rating are only matched with players whose ratings are
+  // close to theirs, at first. 0 means unrated, happy to play anyone.
+  Rating int
+}
+
+type JQuickPlayResponse struct {
+  RoomID string
+}
+
+// The preferences players must share to be matched
+type quickPlayKey struct {
+  variant superghost.Variant
+  language superghost.Language
+  players int
+}
+
+type quickPlayMatch struct {
+  // Empty if err came from making the room rather than joining it
+  roomID string
+  cookie *http.Cookie
+  err error
+}
+
+// A player waiting to be matched
+type quickPlayTicket struct {
+  request JQuickPlayRequest
+  key quickPlayKey
+  // The room's ID is added to this to scope the player's cookie
+  cookiePathPrefix string
+  joined time.Time
+  // Sent the player's room once they're in it. Buffered, so it never blocks.
+  matched chan quickPlayMatch
+}
+
+func newQuickPlayTicket(req JQuickPlayRequest, cookiePathPrefix string,
+                        now time.Time) *quickPlayTicket {
+  if req.Variant == "" {
+    req.Variant = superghost.VariantSuperghost
+  }
+  if req.Language == "" {
+    req.Language = superghost.LanguageEnglish
+  }
+  if req.Players == 0 {
+    req.Players = kDefaultQuickPlayPlayers
+  }
+  return &quickPlayTicket {
+    request: req,
+    key: quickPlayKey{ req.Variant, req.Language, req.Players },
+    cookiePathPrefix: cookiePathPrefix,
+    joined: now,
+    matched: make(chan quickPlayMatch, 1),
+  }
+}
+
+// The room config quick play rooms with these preferences are made from
+func (k quickPlayKey) config() superghost.Config {
+  return superghost.Config {
+    Variant: k.variant,
+    Language: k.language,
+    MaxPlayers: k.players,
+    MinWordLength: 4,
+    EliminationThreshold: 5,
+    PlayerTimePerWord: 30 * time.Second,
+  }
+}
+
+// Players waiting for quick play, oldest first. It only decides who plays
+// together; the server makes the rooms.
+type matchmaker struct {
+  mutex sync.Mutex
+  queue []*quickPlayTicket
+  timeout time.Duration
+}
+
+func newMatchmaker() *matchmaker {
+  m := new(matchmaker)
+  m.queue = make([]*quickPlayTicket, 0)
+  m.timeout = kQuickPlayTimeout
+  return m
+}
+
+// Returns false if a player with the same username is already waiting
+func (m *matchmaker) add(t *quickPlayTicket) bool {
+  m.mutex.Lock()
+  defer m.mutex.Unlock()
+
+  for _, queued := range m.queue {
+    if queued.request.Username == t.request.Username {
+      return false
+    }
+  }
+  m.queue = append(m.queue, t)
+  return true
+}
+
+// Takes t out of the queue. Returns false if it had already been matched.
+func (m *matchmaker) remove(t *quickPlayTicket) bool {
+  m.mutex.Lock()
+  defer m.mutex.Unlock()
+
+  for i, queued := range m.queue {
+    if queued == t {
+      m.queue = append(m.queue[:i], m.queue[i + 1:]...)
+      return true
+    }
+  }
+  return false
+}
+
+// Takes every group that's ready to play out of the queue: groups with as
+// many players as they want, and, once the oldest player in a group has waited
+// long enough, whoever is there.
+func (m *matchmaker) groups(now time.Time) [][]*quickPlayTicket {
+  m.mutex.Lock()
+  defer m.mutex.Unlock()
+
+  groups := make([][]*quickPlayTicket, 0)
+  grouped := make(map[*quickPlayTicket]bool)
+  for i, t := range m.queue {
+    if grouped[t] {
+      continue
+    }
+    waited := now.Sub(t.joined)
+    timedOut := waited >= m.timeout
+    group := []*quickPlayTicket{t}
+    for _, other := range m.queue[i + 1:] {
+      if len(group) == t.key.players {
+        break
+      }
+      if !grouped[other] && other.key == t.key &&
+          (timedOut || ratingsMatch(group, other, waited)) &&
+          !hasUsername(group, other.request.Username) {
+        group = append(group, other)
+      }
+    }
+    if len(group) == t.key.players || timedOut {
+      groups = append(groups, group)
+      for _, member := range group {
+        grouped[member] = true
+      }
+    }
+  }
+
+  queue := make([]*quickPlayTicket, 0, len(m.queue))
+  for _, t := range m.queue {
+    if !grouped[t] {
+      queue = append(queue, t)
+    }
+  }
+  m.queue = queue
+  return groups
+}
+
+// Whether t's rating is close enough to everyone's in group, given the
+// oldest of them has waited for waited. Unrated players match anyone.
+func ratingsMatch(group []*quickPlayTicket, t *quickPlayTicket,
+                  waited time.Duration) bool {
+  if t.request.Rating == 0 {
+    return true
+  }
+  window := kRatingWindow + int(waited.Seconds()) * kRatingWindowGrowth
+  for _, member := range group {
+    if member.request.Rating == 0 {
+      continue
+    }
+    diff := member.request.Rating - t.request.Rating
+    if diff > window || -diff > window {
+      return false
+    }
+  }
+  return true
+}
+
+func hasUsername(group []*quickPlayTicket, username string) bool {
+  for _, member := range group {
+    if member.request.Username == username {
+      return true
+    }
+  }
+  return false
+}
+
+// Makes a room for each group and joins its players to it. A group smaller
+// than the room it wanted gets a public room, so others can fill it.
+func (s *SuperghostServer) startQuickPlayRooms(
+    groups [][]*quickPlayTicket) {
+  for _, group := range groups {
+    config := group[0].key.config()
+    config.IsPublic = len(group) < config.MaxPlayers
+    roomID, err := s.createRoom(config, nil)
+    if err != nil {
+      for _, t := range group {
+        t.matched <- quickPlayMatch{ err: err }
+      }
+      continue
+    }
+    rw, _ := s.roomWrapper(roomID)
+    for _, t := range group {
+      cookie, err := rw.Room.AddPlayer(t.request.Username,
+                                       t.cookiePathPrefix + roomID)
+      t.matched <- quickPlayMatch{ roomID: roomID, cookie: cookie, err: err }
+    }
+    rw.BroadcastGameState()
+  }
+}
+
+func validateQuickPlayRequest(req JQuickPlayRequest) error {
+  if !superghost.IsValidUsername(req.Username) {
+    return superghost.ErrInvalidUsername
+  }
+  if req.Variant != "" && !req.Variant.IsValid() {
+    return fmt.Errorf("unknown variant '%s'", req.Variant)
+  }
+  if req.Language != "" && !req.Language.IsValid() {
+    return fmt.Errorf("unknown language '%s'", req.Language)
+  }
+  if req.Players != 0 &&
+      (req.Players < 2 || req.Players > kMaxQuickPlayPlayers) {
+    return fmt.Errorf("Players must be between 2 and %d",
+                      kMaxQuickPlayPlayers)
+  }
+  if req.Rating < 0 {
+    return fmt.Errorf("Rating must not be negative")
+  }
+  return nil
+}
+
+// Queues the player and waits until they're in a room. Returns the room, or
+// nil if they couldn't be queued (having written the error) or gave up
+// waiting (when the request is cancelled).
+func (s *SuperghostServer) quickPlay(w http.ResponseWriter, r *http.Request,
+                                     req JQuickPlayRequest,
+                                     cookiePathPrefix string) *quickPlayMatch {
+  if !s.allowJoin(w, r) {
+    return nil
+  }
+  if err := validateQuickPlayRequest(req); err != nil {
+    if errors.Is(err, superghost.ErrInvalidUsername) {
+      writeError(w, err)
+    } else {
+      writeBadRequest(w, err)
+    }
+    return nil
+  }
+  t := newQuickPlayTicket(req, cookiePathPrefix, time.Now())
+  if !s.matchmaker.add(t) {
+    writeJError(w, http.StatusConflict, JError {
+      Code: kAlreadyQueuedCode,
+      Message: "'" + req.Username + "' is already waiting to play",
+    })
+    return nil
+  }
+  s.startQuickPlayRooms(s.matchmaker.groups(time.Now()))
+
+  select {
+    case match := <-t.matched:
+      if match.err != nil && match.roomID == "" {
+        writeCreateRoomError(w, match.err)
+        return nil
+      }
+      if match.err != nil {
+        writeError(w, match.err)
+        return nil
+      }
+      return &match
+    case <-r.Context().Done():
+      if s.matchmaker.remove(t) {
+        return nil
+      }
+      // Matched just as they gave up, so leave the room for them
+      match := <-t.matched
+      if match.err == nil {
+        if rw, ok := s.roomWrapper(match.roomID); ok {
+          rw.Room.Leave([]*http.Cookie{match.cookie})
+          rw.BroadcastGameState()
+        }
+      }
+      return nil
+  }
+}
+
+func (s *SuperghostServer) periodicallyStartQuickPlayRooms(
+    period time.Duration) {
+  ticker := time.NewTicker(period)
+  defer ticker.Stop()
+
+  for {
+    <-ticker.C
+    s.startQuickPlayRooms(s.matchmaker.groups(time.Now()))
+  }
+}
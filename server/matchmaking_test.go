@@ -0,0 +1,87 @@
+package sgserver
+
+import (
+  "testing"
+  "time"
+)
+
+func queueForTest(m *matchmaker, now time.Time,
+                  requests ...JQuickPlayRequest) []*quickPlayTicket {
+  tickets := make([]*quickPlayTicket, 0, len(requests))
+  for _, req := range requests {
+    t := newQuickPlayTicket(req, "/rooms/", now)
+    m.add(t)
+    tickets = append(tickets, t)
+  }
+  return tickets
+}
+
+func usernames(group []*quickPlayTicket) []string {
+  names := make([]string, 0, len(group))
+  for _, t := range group {
+    names = append(names, t.request.Username)
+  }
+  return names
+}
+
+func TestMatchmakerGroupsByPreference(t *testing.T) {
+  m := newMatchmaker()
+  now := time.Now()
+  queueForTest(m, now,
+               JQuickPlayRequest{ Username: "a", Players: 3 },
+               JQuickPlayRequest{ Username: "b", Variant: "ghost" },
+               JQuickPlayRequest{ Username: "c", Players: 3 },
+               JQuickPlayRequest{ Username: "d", Language: "es" },
+               JQuickPlayRequest{ Username: "e", Players: 3 })
+  groups := m.groups(now)
+  if len(groups) != 1 || len(groups[0]) != 3 ||
+      usernames(groups[0])[2] != "e" {
+    t.Fatalf("expected a, c and e to be grouped, got %v", groups)
+  }
+  if len(m.queue) != 2 {
+    t.Fatalf("expected b and d to still be waiting, got %d", len(m.queue))
+  }
+}
+
+func TestMatchmakerRatings(t *testing.T) {
+  m := newMatchmaker()
+  now := time.Now()
+  queueForTest(m, now,
+               JQuickPlayRequest{ Username: "a", Rating: 1000 },
+               JQuickPlayRequest{ Username: "b", Rating: 1500 },
+               JQuickPlayRequest{ Username: "c", Rating: 1050 })
+  groups := m.groups(now)
+  if len(groups) != 1 || usernames(groups[0])[1] != "c" {
+    t.Fatalf("expected a and c to be grouped, got %v", groups)
+  }
+
+  // The window widens as b waits, until an unlikely opponent will do
+  queueForTest(m, now, JQuickPlayRequest{ Username: "d", Rating: 1800 })
+  if groups := m.groups(now.Add(10 * time.Second)); len(groups) != 0 {
+    t.Fatalf("expected b and d to be too far apart, got %v", groups)
+  }
+  if groups := m.groups(now.Add(20 * time.Second)); len(groups) != 1 {
+    t.Fatalf("expected b and d to be grouped, got %v", groups)
+  }
+}
+
+func TestMatchmakerTimeout(t *testing.T) {
+  m := newMatchmaker()
+  now := time.Now()
+  queueForTest(m, now, JQuickPlayRequest{ Username: "a", Players: 4 })
+  queueForTest(m, now.Add(time.Second),
+               JQuickPlayRequest{ Username: "b", Players: 4 })
+  if groups := m.groups(now.Add(kQuickPlayTimeout / 2)); len(groups) != 0 {
+    t.Fatalf("expected everyone to still be waiting, got %v", groups)
+  }
+  groups := m.groups(now.Add(kQuickPlayTimeout))
+  if len(groups) != 1 || len(groups[0]) != 2 {
+    t.Fatalf("expected a and b to be grouped, got %v", groups)
+  }
+
+  // Even alone
+  queueForTest(m, now, JQuickPlayRequest{ Username: "c" })
+  if groups := m.groups(now.Add(kQuickPlayTimeout)); len(groups) != 1 {
+    t.Fatalf("expected c to be given a room, got %v", groups)
+  }
+}
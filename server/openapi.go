@@ -0,0 +1,226 @@
+package sgserver
+
+import (
+  "encoding/json"
+  "net/http"
+  "reflect"
+  "regexp"
+  "strconv"
+  "strings"
+  "superghost"
+  "time"
+)
+
+// Builds the OpenAPI 3 document for /api/v1 from apiV1Routes. Schemas are
+// derived from the Go types with reflection, following the same rules as
+// encoding/json.
+
+// Types that marshal themselves as some other type
+var _schemaSubstitutes = map[reflect.Type]reflect.Type {
+  reflect.TypeOf(superghost.Room{}): reflect.TypeOf(superghost.JRoom{}),
+}
+
+var _pathParamPattern = regexp.MustCompile(`{([^}]+)}`)
+
+type openAPISchemaBuilder struct {
+  // Named struct types end up here and are referenced with $ref
+  components map[string]interface{}
+}
+
+func (sb *openAPISchemaBuilder) schema(t reflect.Type) map[string]interface{} {
+  if sub, ok := _schemaSubstitutes[t]; ok {
+    t = sub
+  }
+  switch t {
+    case reflect.TypeOf(time.Time{}):
+      return map[string]interface{} {"type": "string", "format": "date-time"}
+    case reflect.TypeOf(time.Duration(0)):
+      return map[string]interface{} {
+        "type": "integer",
+        "format": "int64",
+        "description": "nanoseconds",
+      }
+  }
+
+  switch t.Kind() {
+    case reflect.Ptr:
+      return sb.schema(t.Elem())
+    case reflect.Bool:
+      return map[string]interface{} {"type": "boolean"}
+    case reflect.String:
+      return map[string]interface{} {"type": "string"}
+    case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+         reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32,
+         reflect.Uint64:
+      return map[string]interface{} {"type": "integer"}
+    case reflect.Float32, reflect.Float64:
+      return map[string]interface{} {"type": "number"}
+    case reflect.Slice, reflect.Array:
+      return map[string]interface{} {
+        "type": "array",
+        "items": sb.schema(t.Elem()),
+      }
+    case reflect.Map:
+      return map[string]interface{} {
+        "type": "object",
+        "additionalProperties": sb.schema(t.Elem()),
+      }
+    case reflect.Struct:
+      return sb.structSchema(t)
+    default:
+      // Interfaces etc. could be anything
+      return map[string]interface{} {}
+  }
+}
+
+func (sb *openAPISchemaBuilder) structSchema(
+    t reflect.Type) map[string]interface{} {
+  name := t.Name()
+  ref := map[string]interface{} {"$ref": "#/components/schemas/" + name}
+  if _, ok := sb.components[name]; ok {
+    return ref
+  }
+  // Placeholder so recursive types terminate
+  sb.components[name] = nil
+
+  properties := make(map[string]interface{})
+  for i := 0; i < t.NumField(); i++ {
+    field := t.Field(i)
+    if field.PkgPath != "" {
+      continue  // unexported
+    }
+    tag := field.Tag.Get("json")
+    if tag == "-" {
+      continue
+    }
+    jsonName := strings.Split(tag, ",")[0]
+    if jsonName == "" {
+      jsonName = field.Name
+    }
+    properties[jsonName] = sb.schema(field.Type)
+  }
+  sb.components[name] = map[string]interface{} {
+    "type": "object",
+    "properties": properties,
+  }
+  return ref
+}
+
+func (sb *openAPISchemaBuilder) jsonContent(
+    v interface{}) map[string]interface{} {
+  return map[string]interface{} {
+    "application/json": map[string]interface{} {
+      "schema": sb.schema(reflect.TypeOf(v)),
+    },
+  }
+}
+
+func (s *SuperghostServer) buildOpenAPISpec() []byte {
+  sb := &openAPISchemaBuilder{ components: make(map[string]interface{}) }
+  errorResponse := map[string]interface{} {
+    "description": "The request failed. The code says why.",
+    "content": sb.jsonContent(JError{}),
+  }
+
+  paths := make(map[string]interface{})
+  for _, route := range s.apiV1Routes() {
+    operation := map[string]interface{} {
+      "summary": route.summary,
+    }
+
+    parameters := make([]interface{}, 0)
+    for _, match := range _pathParamPattern.FindAllStringSubmatch(
+        route.pattern, -1) {
+      parameters = append(parameters, map[string]interface{} {
+        "name": match[1],
+        "in": "path",
+        "required": true,
+        "schema": map[string]interface{} {"type": "string"},
+      })
+    }
+    if len(parameters) > 0 {
+      operation["parameters"] = parameters
+    }
+
+    if route.request != nil {
+      operation["requestBody"] = map[string]interface{} {
+        "required": true,
+        "content": sb.jsonContent(route.request),
+      }
+    }
+
+    responses := map[string]interface{} {"default": errorResponse}
+    successStatus := http.StatusOK
+    if route.method == http.MethodPost &&
+        (route.pattern == "/rooms" || route.pattern == "/tournaments" ||
+         route.pattern == "/rooms/{roomID}/invites") {
+      successStatus = http.StatusCreated
+    }
+    if route.response != nil {
+      responses[strconv.Itoa(successStatus)] = map[string]interface{} {
+        "description": http.StatusText(successStatus),
+        "content": sb.jsonContent(route.response),
+      }
+    } else if route.pattern == "/openapi.json" {
+      responses[strconv.Itoa(successStatus)] = map[string]interface{} {
+        "description": "An OpenAPI 3 document",
+        "content": map[string]interface{} {
+          "application/json": map[string]interface{} {},
+        },
+      }
+    } else {
+      responses[strconv.Itoa(http.StatusNoContent)] = map[string]interface{} {
+        "description": http.StatusText(http.StatusNoContent),
+      }
+    }
+    operation["responses"] = responses
+
+    if route.authenticated {
+      operation["security"] = []interface{} {
+        map[string]interface{} {"roomCookie": []interface{}{}},
+      }
+    }
+    // The empty requirement lets anyone in when the room has no password
+    if route.private {
+      operation["security"] = []interface{} {
+        map[string]interface{} {},
+        map[string]interface{} {"roomCookie": []interface{}{}},
+      }
+    }
+
+    path := "/api/v1" + route.pattern
+    if _, ok := paths[path]; !ok {
+      paths[path] = make(map[string]interface{})
+    }
+    paths[path].(map[string]interface{})[strings.ToLower(route.method)] =
+        operation
+  }
+
+  spec := map[string]interface{} {
+    "openapi": "3.0.3",
+    "info": map[string]interface{} {
+      "title": "wordy.boo",
+      "version": "1",
+    },
+    "paths": paths,
+    "components": map[string]interface{} {
+      "schemas": sb.components,
+      "securitySchemes": map[string]interface{} {
+        "roomCookie": map[string]interface{} {
+          "type": "apiKey",
+          "in": "cookie",
+          // The cookie is actually named after the player's username, which
+          // OpenAPI has no way to express.
+          "name": "username",
+          "description": "Issued by the join route. The cookie's name is " +
+                         "the player's username.",
+        },
+      },
+    },
+  }
+  b, err := json.Marshal(spec)
+  if err != nil {
+    panic(err)  // The spec is built from maps of strings
+  }
+  return b
+}
@@ -0,0 +1,191 @@
+package sgserver
+
+import (
+  "errors"
+  "math"
+  "net"
+  "net/http"
+  "strconv"
+  "sync"
+  "time"
+)
+
+// How often something may be done: a burst of Burst at once, then PerSecond
+// more every second. The zero value means no limit.
+type RateLimit struct {
+  PerSecond float64
+  Burst int
+}
+
+func (l RateLimit) isUnlimited() bool {
+  return l.PerSecond <= 0 || l.Burst <= 0
+}
+
+// What each client may do. Clients are known by their IP address (the server
+// is meant to face the internet directly, so it's never a proxy's), and chat
+// is limited for each player too, since many players can share an address.
+type RateLimits struct {
+  RoomCreation RateLimit
+  // Joining rooms, quick play and tournament registration
+  Join RateLimit
+  ChatPerIP RateLimit
+  ChatPerPlayer RateLimit
+  // Daily puzzle guesses, which may each look a word up
+  PuzzleGuess RateLimit
+  // How many rooms may be open at once, across everyone. 0 means no limit.
+  MaxRooms int
+}
+
+var DefaultRateLimits = RateLimits {
+  RoomCreation: RateLimit{ PerSecond: 0.1, Burst: 5 },
+  Join: RateLimit{ PerSecond: 1, Burst: 10 },
+  ChatPerIP: RateLimit{ PerSecond: 5, Burst: 20 },
+  ChatPerPlayer: RateLimit{ PerSecond: 1, Burst: 5 },
+  PuzzleGuess: RateLimit{ PerSecond: 1, Burst: 10 },
+  MaxRooms: 1000,
+}
+
+// When the server is full, rooms are only freed as idle ones are deleted, so
+// there's no knowing when to come back. This is a guess.
+const kTooManyRoomsRetryAfter = time.Minute
+
+var errTooManyRooms = errors.New(
+    "the server has as many rooms as it can take; try again later")
+
+type tokenBucket struct {
+  tokens float64
+  last time.Time
+  // When the bucket will have filled back up, after which it's no different
+  // from a new one
+  full time.Time
+}
+
+// A token bucket for each key. Keys name the thing being limited and who's
+// doing it, like "join 192.0.2.1". Safe for concurrent use.
+type rateLimiter struct {
+  mutex sync.Mutex
+  buckets map[string]*tokenBucket
+}
+
+func newRateLimiter() *rateLimiter {
+  l := new(rateLimiter)
+  l.buckets = make(map[string]*tokenBucket)
+  return l
+}
+
+// Takes a token from key's bucket. If there isn't one, returns false and how
+// long until there will be.
+func (l *rateLimiter) allow(key string, limit RateLimit,
+                            now time.Time) (bool, time.Duration) {
+  if limit.isUnlimited() {
+    return true, 0
+  }
+  l.mutex.Lock()
+  defer l.mutex.Unlock()
+
+  burst := float64(limit.Burst)
+  b, ok := l.buckets[key]
+  if !ok {
+    b = &tokenBucket{ tokens: burst, last: now }
+    l.buckets[key] = b
+  }
+  b.tokens = math.Min(burst,
+                      b.tokens + now.Sub(b.last).Seconds() * limit.PerSecond)
+  b.last = now
+  if b.tokens < 1 {
+    wait := (1 - b.tokens) / limit.PerSecond
+    return false, time.Duration(wait * float64(time.Second))
+  }
+  b.tokens--
+  refill := (burst - b.tokens) / limit.PerSecond
+  b.full = now.Add(time.Duration(refill * float64(time.Second)))
+  return true, 0
+}
+
+// Forgets buckets that have filled back up
+func (l *rateLimiter) prune(now time.Time) {
+  l.mutex.Lock()
+  defer l.mutex.Unlock()
+
+  for key, b := range l.buckets {
+    if !now.Before(b.full) {
+      delete(l.buckets, key)
+    }
+  }
+}
+
+func (l *rateLimiter) periodicallyPrune(period time.Duration) {
+  ticker := time.NewTicker(period)
+  defer ticker.Stop()
+
+  for {
+    <-ticker.C
+    l.prune(time.Now())
+  }
+}
+
+func clientIP(r *http.Request) string {
+  host, _, err := net.SplitHostPort(r.RemoteAddr)
+  if err != nil {
+    return r.RemoteAddr
+  }
+  return host
+}
+
+// Takes a token for the request from key's bucket, or answers 429 if there
+// isn't one. Returns whether the request may go ahead.
+func (s *SuperghostServer) allow(w http.ResponseWriter, key string,
+                                 limit RateLimit) bool {
+  ok, retryAfter := s.limiter.allow(key, limit, time.Now())
+  if !ok {
+    writeTooManyRequests(w, retryAfter)
+  }
+  return ok
+}
+
+func (s *SuperghostServer) allowRoomCreation(w http.ResponseWriter,
+                                            r *http.Request) bool {
+  return s.allow(w, "create " + clientIP(r), s.Limits.RoomCreation)
+}
+
+func (s *SuperghostServer) allowJoin(w http.ResponseWriter,
+                                    r *http.Request) bool {
+  return s.allow(w, "join " + clientIP(r), s.Limits.Join)
+}
+
+func (s *SuperghostServer) allowPuzzleGuess(w http.ResponseWriter,
+                                           r *http.Request) bool {
+  return s.allow(w, "puzzle " + clientIP(r), s.Limits.PuzzleGuess)
+}
+
+// Players who haven't joined are only limited by their address; the room
+// turns their message away anyway.
+func (s *SuperghostServer) allowChat(w http.ResponseWriter, r *http.Request,
+                                    roomID string, rw *RoomWrapper) bool {
+  if !s.allow(w, "chat " + clientIP(r), s.Limits.ChatPerIP) {
+    return false
+  }
+  username, ok := rw.Room.GetValidCookie(r.Cookies())
+  return !ok ||
+      s.allow(w, "chat " + roomID + " " + username, s.Limits.ChatPerPlayer)
+}
+
+// Retry-After is in whole seconds, rounded up so clients that wait that long
+// are let in
+func writeRetryAfter(w http.ResponseWriter, retryAfter time.Duration) int {
+  seconds := int(math.Ceil(retryAfter.Seconds()))
+  if seconds < 1 {
+    seconds = 1
+  }
+  w.Header().Set("Retry-After", strconv.Itoa(seconds))
+  return seconds
+}
+
+func writeTooManyRequests(w http.ResponseWriter, retryAfter time.Duration) {
+  seconds := writeRetryAfter(w, retryAfter)
+  writeJError(w, http.StatusTooManyRequests, JError {
+    Code: kRateLimitedCode,
+    Message: "too many requests; try again in " + strconv.Itoa(seconds) +
+             " seconds",
+  })
+}
@@ -0,0 +1,161 @@
+package sgserver
+
+import (
+  "net/http"
+  "superghost"
+  "testing"
+  "time"
+)
+
+func TestRateLimiterRefills(t *testing.T) {
+  l := newRateLimiter()
+  limit := RateLimit{ PerSecond: 2, Burst: 3 }
+  now := time.Now()
+  for i := 0; i < 3; i++ {
+    if ok, _ := l.allow("a", limit, now); !ok {
+      t.Fatalf("request %d of the burst was turned away", i + 1)
+    }
+  }
+  ok, retryAfter := l.allow("a", limit, now)
+  if ok || retryAfter != 500 * time.Millisecond {
+    t.Fatalf("expected to wait 500ms after the burst, got %v (%v)",
+             retryAfter, ok)
+  }
+  // Other keys have their own buckets
+  if ok, _ := l.allow("b", limit, now); !ok {
+    t.Fatalf("b was limited by a's requests")
+  }
+
+  now = now.Add(500 * time.Millisecond)
+  if ok, _ := l.allow("a", limit, now); !ok {
+    t.Fatalf("a token should have come back after 500ms")
+  }
+  if ok, _ := l.allow("a", limit, now); ok {
+    t.Fatalf("only one token should have come back")
+  }
+
+  // Full buckets are forgotten, since they're the same as new ones
+  l.prune(now)
+  if _, ok := l.buckets["a"]; !ok {
+    t.Fatalf("a's empty bucket was pruned")
+  }
+  l.prune(now.Add(2 * time.Second))
+  if len(l.buckets) != 0 {
+    t.Fatalf("expected every bucket to be pruned, got %v", l.buckets)
+  }
+}
+
+func TestRateLimiterUnlimited(t *testing.T) {
+  l := newRateLimiter()
+  for i := 0; i < 100; i++ {
+    if ok, _ := l.allow("a", RateLimit{}, time.Now()); !ok {
+      t.Fatalf("the zero limit should never limit")
+    }
+  }
+  if len(l.buckets) != 0 {
+    t.Fatalf("unlimited requests shouldn't need a bucket")
+  }
+}
+
+func TestAPIV1RateLimits(t *testing.T) {
+  c := newAPITestClient(t)
+  c.server.Limits = RateLimits {
+    RoomCreation: RateLimit{ PerSecond: 0.001, Burst: 2 },
+    Join: RateLimit{ PerSecond: 0.001, Burst: 2 },
+    ChatPerIP: RateLimit{ PerSecond: 0.001, Burst: 3 },
+    ChatPerPlayer: RateLimit{ PerSecond: 0.001, Burst: 2 },
+    PuzzleGuess: RateLimit{ PerSecond: 0.001, Burst: 1 },
+  }
+
+  var roomID string
+  for i := 0; i < 2; i++ {
+    rec := c.do(http.MethodPost, "/rooms", "", "",
+                superghost.Config{ MaxPlayers: 3 })
+    c.expectStatus(rec, http.StatusCreated)
+    var created JCreateRoomResponse
+    c.decode(rec, &created)
+    roomID = created.ID
+  }
+  rec := c.do(http.MethodPost, "/rooms", "", "",
+              superghost.Config{ MaxPlayers: 3 })
+  c.expectError(rec, http.StatusTooManyRequests, kRateLimitedCode)
+  if rec.Header().Get("Retry-After") != "1000" {
+    t.Fatalf("expected to be told to wait 1000s, got Retry-After '%s'",
+             rec.Header().Get("Retry-After"))
+  }
+
+  for _, username := range []string{"alice", "bob"} {
+    rec = c.do(http.MethodPost, "/rooms/{roomID}/join", roomID, "",
+               JJoinRequest{ Username: username })
+    c.expectStatus(rec, http.StatusOK)
+  }
+  rec = c.do(http.MethodPost, "/rooms/{roomID}/join", roomID, "",
+             JJoinRequest{ Username: "carol" })
+  c.expectError(rec, http.StatusTooManyRequests, kRateLimitedCode)
+
+  // alice runs out first, then everyone at her address does
+  for i := 0; i < 2; i++ {
+    rec = c.do(http.MethodPost, "/rooms/{roomID}/chat", roomID, "alice",
+               JChatRequest{ Content: "hi" })
+    c.expectStatus(rec, http.StatusOK)
+  }
+  rec = c.do(http.MethodPost, "/rooms/{roomID}/chat", roomID, "alice",
+             JChatRequest{ Content: "hi" })
+  c.expectError(rec, http.StatusTooManyRequests, kRateLimitedCode)
+  rec = c.do(http.MethodPost, "/rooms/{roomID}/chat", roomID, "bob",
+             JChatRequest{ Content: "hi" })
+  c.expectError(rec, http.StatusTooManyRequests, kRateLimitedCode)
+
+  rec = c.do(http.MethodPost, "/puzzle/guess", "", "",
+             JPuzzleGuessRequest{ Prefix: "QX" })
+  c.expectStatus(rec, http.StatusOK)
+  rec = c.do(http.MethodPost, "/puzzle/guess", "", "puzzle-player",
+             JPuzzleGuessRequest{ Prefix: "QX" })
+  c.expectError(rec, http.StatusTooManyRequests, kRateLimitedCode)
+}
+
+func TestAPIV1MaxRooms(t *testing.T) {
+  c := newAPITestClient(t)
+  c.server.Limits = RateLimits{ MaxRooms: 1 }
+
+  rec := c.do(http.MethodPost, "/rooms", "", "",
+              superghost.Config{ MaxPlayers: 2 })
+  c.expectStatus(rec, http.StatusCreated)
+  rec = c.do(http.MethodPost, "/rooms", "", "",
+             superghost.Config{ MaxPlayers: 2 })
+  c.expectError(rec, http.StatusServiceUnavailable, kTooManyRoomsCode)
+  if rec.Header().Get("Retry-After") == "" {
+    t.Fatalf("expected a Retry-After header")
+  }
+  // A bad config is still the client's fault
+  rec = c.do(http.MethodPost, "/rooms", "", "", superghost.Config{})
+  c.expectError(rec, http.StatusBadRequest, kBadRequestCode)
+
+  // Quick play can't make a room either, even once it stops waiting for
+  // more players
+  c.server.matchmaker.timeout = 0
+  rec = c.do(http.MethodPost, "/quick-play", "", "",
+             JQuickPlayRequest{ Username: "alice" })
+  c.expectError(rec, http.StatusServiceUnavailable, kTooManyRoomsCode)
+  if rec.Header().Get("Retry-After") == "" {
+    t.Fatalf("expected a Retry-After header from quick play")
+  }
+}
+
+func TestTeardownStopsListening(t *testing.T) {
+  rw, err := NewRoomWrapper(superghost.Config{ MaxPlayers: 2 })
+  if err != nil {
+    t.Fatalf("couldn't make a room: %s", err)
+  }
+  done := make(chan struct{})
+  go func() {
+    rw.ListenForAsyncUpdateSignals()
+    close(done)
+  }()
+  rw.Teardown()
+  select {
+    case <-done:
+    case <-time.After(time.Second):
+      t.Fatalf("the room's listener kept going after teardown")
+  }
+}
@@ -3,6 +3,7 @@ package sgserver
 import (
   "superghost"
   "fmt"
+  "sync"
   "time"
 )
 
@@ -13,20 +14,33 @@ type RoomWrapper struct {
   ChatListeners *ListenerGroup
 
   asyncUpdateCh chan struct{}
+  // Closed when the room is torn down
+  doneCh chan struct{}
+
+  // Called with the winner of each game, if it isn't nil
+  onGameOver func(winner string)
+  gamesOverMutex sync.Mutex
+  // How many games onGameOver has been called for
+  gamesOver int
 }
 
-func NewRoomWrapper(config superghost.Config) *RoomWrapper {
+func NewRoomWrapper(config superghost.Config) (*RoomWrapper, error) {
   rw := new(RoomWrapper)
 
   rw.asyncUpdateCh = make(chan struct{})
-  rw.Room = superghost.NewRoom(config, rw.asyncUpdateCh)
+  rw.doneCh = make(chan struct{})
+  room, err := superghost.NewRoom(config, rw.asyncUpdateCh)
+  if err != nil {
+    return nil, err
+  }
+  rw.Room = room
 
   rw.UpdateListeners = newListenerGroup()
   rw.ChatListeners = newListenerGroup()
 
   go rw.ListenForAsyncUpdateSignals()
 
-  return rw
+  return rw, nil
 }
 
 func (rw *RoomWrapper) BroadcastGameState() {
@@ -38,11 +52,39 @@ func (rw *RoomWrapper) BroadcastGameState() {
   // For debugging purposes, print the game state
   fmt.Println(time.Now().String() + ": "  + s)
   rw.UpdateListeners.Broadcast(s)
+  rw.reportGamesOver()
+}
+
+// Games end on players' moves and on timeouts alike, and either way the new
+// state is broadcast, so that's when finished games are looked for
+func (rw *RoomWrapper) reportGamesOver() {
+  if rw.onGameOver == nil {
+    return
+  }
+  rw.gamesOverMutex.Lock()
+  defer rw.gamesOverMutex.Unlock()
+
+  winners := rw.Room.Winners()
+  for ; rw.gamesOver < len(winners); rw.gamesOver++ {
+    rw.onGameOver(winners[rw.gamesOver])
+  }
 }
 
+// Returns once the room is torn down
 func (rw *RoomWrapper) ListenForAsyncUpdateSignals() {
   for {
-    <-rw.asyncUpdateCh
-    rw.BroadcastGameState()
+    select {
+      case <-rw.asyncUpdateCh:
+        rw.BroadcastGameState()
+      case <-rw.doneCh:
+        return
+    }
   }
 }
+
+// Stops the room's timers and the goroutine listening to them. The room
+// mustn't be used afterwards.
+func (rw *RoomWrapper) Teardown() {
+  rw.Room.Teardown()
+  close(rw.doneCh)
+}
@@ -12,23 +12,64 @@ import (
   "strconv"
   "strings"
   "superghost"
+  "sync"
   "text/template"
   "time"
 )
 
+const (
+  kRoomIDLength = 6
+  // About 128 bits, for rooms that shouldn't be found by guessing
+  kLongRoomIDLength = 26
+  kMaxPasswordLength = 128
+)
+
 type SuperghostServer struct {
   Rooms map[string]*RoomWrapper
+  // Rooms are made by tournaments as well as by requests
+  roomsMutex sync.RWMutex
   Router chi.Router
+
+  // Used by every English room created from now on. Nil means each room picks
+  // its own default.
+  Dictionary superghost.Dictionary
+  // Used by every English room created from now on to define words found in
+  // challenges. Nil means the bundled definitions.
+  Definitions superghost.Definer
+  // The daily puzzle, for when there's nobody to play with
+  Puzzles *superghost.PuzzleBook
+  // Used by every room created from now on. Nil means chat isn't filtered.
+  ChatFilter superghost.ChatFilter
+
+  tournaments map[string]*superghost.Tournament
+  tournamentsMutex sync.RWMutex
+
+  matchmaker *matchmaker
+
+  // DefaultRateLimits unless changed before the server starts serving
+  Limits RateLimits
+  limiter *rateLimiter
+
+  openAPISpec []byte
 }
 
 func NewSuperghostServer(rooms map[string]*RoomWrapper) *SuperghostServer {
   server := new(SuperghostServer)
   server.Rooms = rooms
+  server.Puzzles = superghost.NewPuzzleBook(superghost.LanguageEnglish)
+  server.tournaments = make(map[string]*superghost.Tournament)
+  server.matchmaker = newMatchmaker()
+  server.Limits = DefaultRateLimits
+  server.limiter = newRateLimiter()
 
   server.Router = chi.NewRouter()
 
   server.Router.Get("/", server.home)
   server.Router.Get("/static/*", server.static)
+  server.Router.Post("/quick-play", server.quickPlayForm)
+
+  server.openAPISpec = server.buildOpenAPISpec()
+  server.Router.Route("/api/v1", server.apiV1)
 
   server.Router.Route("/rooms", func (r chi.Router) {
     r.Get("/", server.rooms)
@@ -41,17 +82,31 @@ func NewSuperghostServer(rooms map[string]*RoomWrapper) *SuperghostServer {
       r.Get("/", server.room)
       r.Head("/", server.room)
       r.Post("/join", server.join)
-      r.Get("/next-state", server.nextState)
-      r.Get("/current-state", server.currentState)
+      r.Post("/invites", server.invite)
+      r.With(server.middlewareCheckViewer).Get("/next-state", server.nextState)
+      r.With(server.middlewareCheckViewer).Get("/current-state",
+                                               server.currentState)
       r.Post("/affix", server.affix)
+      r.Post("/insertion", server.insertion)
+      r.Post("/reversal", server.reversal)
+      r.Get("/hint", server.hint)
       r.Post("/challenge-is-word", server.challengeIsWord)
       r.Post("/challenge-continuation", server.challengeContinuation)
       r.Post("/rebuttal", server.rebuttal)
       r.Post("/concession", server.concession)
+      r.Post("/jury-vote", server.juryVote)
+      r.Post("/pause", server.pause)
+      r.Post("/resume", server.resume)
       r.Post("/kick", server.kick)
-      r.Get("/config", server.config)
+      r.Post("/mute", server.mute)
+      r.Post("/unmute", server.unmute)
+      r.With(server.middlewareCheckViewer).Get("/config", server.config)
+      r.With(server.middlewareCheckViewer).Get("/analysis/{gameNumber}",
+                                               server.analysis)
+      r.Post("/word-lists", server.wordLists)
       r.Post("/chat", server.chat)
-      r.Get("/next-chat", server.chat)
+      r.With(server.middlewareCheckViewer).Get("/next-chat", server.chat)
+      r.Delete("/chat/{messageID}", server.deleteMessage)
       r.Post("/leave", server.leave)
       r.Post("/cancellable-leave", server.cancellableLeave)
       r.Post("/cancel-leave", server.cancelLeave)
@@ -59,6 +114,8 @@ func NewSuperghostServer(rooms map[string]*RoomWrapper) *SuperghostServer {
   })
 
   go server.periodicallyDeleteIdleRooms(time.Minute * 30)
+  go server.periodicallyStartQuickPlayRooms(time.Second)
+  go server.limiter.periodicallyPrune(time.Minute)
 
   return server
 }
@@ -71,12 +128,20 @@ func redirectURIList(w http.ResponseWriter, URI string) {
   fmt.Fprint(w, "") // No body, just the header
 }
 
+func (s *SuperghostServer) roomWrapper(ID string) (*RoomWrapper, bool) {
+  s.roomsMutex.RLock()
+  defer s.roomsMutex.RUnlock()
+
+  rw, ok := s.Rooms[ID]
+  return rw, ok
+}
+
 func (s *SuperghostServer) middlewareGetRoom(next http.Handler) http.Handler {
   return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		ID := chi.URLParam(r, "roomID")
-		wrapper, ok := s.Rooms[ID]
+		wrapper, ok := s.roomWrapper(ID)
 		if !ok {
-			http.NotFound(w, r)
+			writeNotFound(w)
 			return
 		}
     ctx := context.WithValue(r.Context(), "roomID", ID)
@@ -86,6 +151,20 @@ func (s *SuperghostServer) middlewareGetRoom(next http.Handler) http.Handler {
   })
 }
 
+// Keeps a room with a password's state, chat, config and analyses to its
+// players. Must come after middlewareGetRoom.
+func (s *SuperghostServer) middlewareCheckViewer(
+    next http.Handler) http.Handler {
+  return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+    roomWrapper := r.Context().Value("roomWrapper").(*RoomWrapper)
+    if err := roomWrapper.Room.CheckViewer(r.Cookies()); err != nil {
+      writeError(w, err)
+      return
+    }
+    next.ServeHTTP(w, r)
+  })
+}
+
 func (s *SuperghostServer) static(w http.ResponseWriter, r *http.Request) {
   // build the file path by joining the base directory with the requested file
   // path
@@ -117,7 +196,7 @@ func (s *SuperghostServer) home(w http.ResponseWriter, r *http.Request) {
       return
 
     default:
-      http.Error(w, "", http.StatusMethodNotAllowed)
+      writeMethodNotAllowed(w)
   }
 }
 
@@ -126,17 +205,7 @@ func (s *SuperghostServer) rooms(w http.ResponseWriter, r *http.Request) {
 
     // Send a list of the public games in play
     case http.MethodGet:
-      arr := make([]superghost.JRoomMetadata, len(s.Rooms))
-
-      i := 0
-      for k := range s.Rooms {
-        if !s.Rooms[k].Room.IsPublic() {
-          continue
-        }
-        arr[i] = s.Rooms[k].Room.Metadata(k)
-        i++
-      }
-      b, err := json.Marshal(arr[:i])
+      b, err := json.Marshal(s.publicRoomMetadata())
       if err != nil {
         http.Error(w, "unexpected internal error",
                    http.StatusInternalServerError)
@@ -146,53 +215,331 @@ func (s *SuperghostServer) rooms(w http.ResponseWriter, r *http.Request) {
       return
 
     case http.MethodPost:
+      if !s.allowRoomCreation(w, r) {
+        return
+      }
       // validate params
       err := r.ParseForm()
       if err != nil {
-        http.Error(w, err.Error(), http.StatusBadRequest)
+        writeBadRequest(w, err)
         return
       }
       maxPlayers, err := strconv.Atoi(r.FormValue("MaxPlayers"))
       if err != nil {
-        http.Error(w, err.Error(), http.StatusBadRequest)
+        writeBadRequest(w, err)
         return
       }
       minWordLength, err := strconv.Atoi(r.FormValue("MinWordLength"))
       if err != nil {
-        http.Error(w, err.Error(), http.StatusBadRequest)
+        writeBadRequest(w, err)
         return
       }
       eliminationThreshold, err :=
           strconv.Atoi(r.FormValue("EliminationThreshold"))
       if err != nil {
-        http.Error(w, err.Error(), http.StatusBadRequest)
+        writeBadRequest(w, err)
         return
       }
       isPublic := r.FormValue("IsPublic") == "on"
       allowRepeatWords := r.FormValue("AllowRepeatWords") == "on"
       pauseAtRoundStart := r.FormValue("PauseAtRoundStart") == "on"
+      suddenDeath := r.FormValue("SuddenDeath") == "on"
+      practice := r.FormValue("Practice") == "on"
+      longID := r.FormValue("LongID") == "on"
 
       playerTimePerWord, err := strconv.Atoi(r.FormValue("PlayerTimePerWord"))
       if err != nil {
-        http.Error(w, err.Error(), http.StatusBadRequest)
+        writeBadRequest(w, err)
+        return
+      }
+      // The other durations are optional, and also in seconds
+      var durations [5]time.Duration
+      for i, name := range []string{"PlayerTimePerGame", "TimeIncrement",
+                                    "TimeDelay", "RebuttalTime", "JuryTime"} {
+        if r.FormValue(name) == "" {
+          continue
+        }
+        seconds, err := strconv.Atoi(r.FormValue(name))
+        if err != nil {
+          writeBadRequest(w, err)
+          return
+        }
+        durations[i] = time.Duration(seconds) * time.Second
+      }
+      teamCount := 0
+      if r.FormValue("TeamCount") != "" {
+        if teamCount, err = strconv.Atoi(r.FormValue("TeamCount")); err != nil {
+          writeBadRequest(w, err)
+          return
+        }
       }
 
-      roomID := superghost.GetRandBase32String(6)
-      s.Rooms[roomID] = NewRoomWrapper(superghost.Config{
+      roomID, err := s.createRoom(superghost.Config{
+            Variant: superghost.Variant(r.FormValue("Variant")),
+            Language: superghost.Language(r.FormValue("Language")),
             MaxPlayers: maxPlayers,
             MinWordLength: minWordLength,
             IsPublic: isPublic,
             EliminationThreshold: eliminationThreshold,
             AllowRepeatWords: allowRepeatWords,
             PlayerTimePerWord: time.Duration(playerTimePerWord) * time.Second,
+            PlayerTimePerGame: durations[0],
+            TimeIncrement: durations[1],
+            TimeDelay: durations[2],
+            RebuttalTime: durations[3],
+            JuryTime: durations[4],
+            AllowedWords:
+                superghost.ParseWordList(r.FormValue("AllowedWords")),
+            BlockedWords:
+                superghost.ParseWordList(r.FormValue("BlockedWords")),
             PauseAtRoundStart: pauseAtRoundStart,
-          })
+            TeamCount: teamCount,
+            Scoring: superghost.ScoringRule(r.FormValue("Scoring")),
+            EliminationWord: r.FormValue("EliminationWord"),
+            SuddenDeath: suddenDeath,
+            Practice: practice,
+            Password: r.FormValue("Password"),
+            LongID: longID,
+          }, nil)
+      if err != nil {
+        writeCreateRoomError(w, err)
+        return
+      }
       redirectURIList(w, "/rooms/" + roomID)
       return
 
     default:
-      http.Error(w, "", http.StatusMethodNotAllowed)
+      writeMethodNotAllowed(w)
+  }
+}
+
+// Sends the player straight to their room, already joined
+func (s *SuperghostServer) quickPlayForm(w http.ResponseWriter,
+                                         r *http.Request) {
+  if err := r.ParseForm(); err != nil {
+    writeBadRequest(w, err)
+    return
+  }
+  req := JQuickPlayRequest {
+    Username: r.FormValue("username"),
+    Variant: superghost.Variant(r.FormValue("Variant")),
+    Language: superghost.Language(r.FormValue("Language")),
   }
+  // The number of players and rating are optional
+  for name, v := range map[string]*int{"Players": &req.Players,
+                                       "Rating": &req.Rating} {
+    if r.FormValue(name) == "" {
+      continue
+    }
+    n, err := strconv.Atoi(r.FormValue(name))
+    if err != nil {
+      writeBadRequest(w, err)
+      return
+    }
+    *v = n
+  }
+  match := s.quickPlay(w, r, req, "/rooms/")
+  if match == nil {
+    return
+  }
+  http.SetCookie(w, match.cookie)
+  redirectURIList(w, "/rooms/" + match.roomID)
+}
+
+// Checks a config from a client before any room is made from it
+func validateRoomConfig(config superghost.Config) error {
+  if config.MaxPlayers < 2 {
+    return fmt.Errorf("MaxPlayers must be at least 2")
+  }
+  if config.MinWordLength < 0 || config.EliminationThreshold < 0 ||
+      config.PlayerTimePerWord < 0 || config.TeamCount < 0 {
+    return fmt.Errorf(
+        "MinWordLength, EliminationThreshold, PlayerTimePerWord and " +
+        "TeamCount must not be negative")
+  }
+  if config.PlayerTimePerGame < 0 || config.TimeIncrement < 0 ||
+      config.TimeDelay < 0 || config.RebuttalTime < 0 || config.JuryTime < 0 {
+    return fmt.Errorf(
+        "PlayerTimePerGame, TimeIncrement, TimeDelay, RebuttalTime and " +
+        "JuryTime must not be negative")
+  }
+  if config.TeamCount > config.MaxPlayers {
+    return fmt.Errorf("TeamCount can't be more than MaxPlayers")
+  }
+  if len(config.Password) > kMaxPasswordLength {
+    return fmt.Errorf("Password can't be longer than %d characters",
+                      kMaxPasswordLength)
+  }
+  if config.Variant != "" && !config.Variant.IsValid() {
+    return fmt.Errorf("unknown variant '%s'", config.Variant)
+  }
+  if config.Scoring != "" && !config.Scoring.IsValid() {
+    return fmt.Errorf("unknown scoring rule '%s'", config.Scoring)
+  }
+  if config.EliminationWord != "" &&
+      !superghost.IsValidEliminationWord(config.EliminationWord) {
+    return fmt.Errorf("EliminationWord must only contain letters")
+  }
+  if config.Language != "" && !config.Language.IsValid() {
+    return fmt.Errorf("unknown language '%s'", config.Language)
+  }
+  alphabet := config.Language.Alphabet()
+  if _, err := superghost.NormalizeWordList(config.AllowedWords,
+                                            alphabet); err != nil {
+    return err
+  }
+  if _, err := superghost.NormalizeWordList(config.BlockedWords,
+                                            alphabet); err != nil {
+    return err
+  }
+  return nil
+}
+
+// Every way of making a room (the form on the home page, the JSON API,
+// tournaments, ...) goes through here. onGameOver, if it isn't nil, is called
+// with the winner of each game played in the room. Returns the new room's ID.
+func (s *SuperghostServer) createRoom(
+    config superghost.Config, onGameOver func(winner string)) (string, error) {
+  if err := validateRoomConfig(config); err != nil {
+    return "", err
+  }
+  isEnglish := config.Language == "" ||
+      config.Language == superghost.LanguageEnglish
+  if config.Dictionary == nil && isEnglish {
+    config.Dictionary = s.Dictionary
+  }
+  if config.Definitions == nil && isEnglish {
+    config.Definitions = s.Definitions
+  }
+  if config.ChatFilter == nil {
+    config.ChatFilter = s.ChatFilter
+  }
+
+  s.roomsMutex.Lock()
+  defer s.roomsMutex.Unlock()
+
+  if s.Limits.MaxRooms > 0 && len(s.Rooms) >= s.Limits.MaxRooms {
+    return "", errTooManyRooms
+  }
+  rw, err := NewRoomWrapper(config)
+  if err != nil {
+    return "", err
+  }
+  rw.onGameOver = onGameOver
+  roomID := superghost.GetRandBase32String(kRoomIDLength)
+  if config.LongID {
+    roomID = superghost.GetRandBase32String(kLongRoomIDLength)
+  }
+  s.Rooms[roomID] = rw
+  return roomID, nil
+}
+
+// Makes a tournament whose matches are played in rooms made like any other.
+// Returns its ID and the organizer's cookie.
+func (s *SuperghostServer) createTournament(
+    config superghost.TournamentConfig) (string, *http.Cookie, error) {
+  if config.Format != "" && !config.Format.IsValid() {
+    return "", nil, fmt.Errorf("unknown format '%s'", config.Format)
+  }
+  if config.Rounds < 0 {
+    return "", nil, fmt.Errorf("Rounds must not be negative")
+  }
+  if config.MatchTime < 0 {
+    return "", nil, fmt.Errorf("MatchTime must not be negative")
+  }
+  // Every match is between two players
+  if config.Room.TeamCount != 0 {
+    return "", nil, fmt.Errorf("tournament matches can't be played in teams")
+  }
+  config.Room.MaxPlayers = 2
+  if err := validateRoomConfig(config.Room); err != nil {
+    return "", nil, err
+  }
+
+  s.tournamentsMutex.Lock()
+  defer s.tournamentsMutex.Unlock()
+
+  ID := superghost.GetRandBase32String(6)
+  rooms := &tournamentRooms{ s: s, tournamentID: ID }
+  t, cookie := superghost.NewTournament(config, "/api/v1/tournaments/" + ID,
+                                        rooms)
+  rooms.t = t
+  s.tournaments[ID] = t
+  return ID, cookie, nil
+}
+
+// Where a tournament's matches are played: rooms like any other, whose
+// winners are reported back to it
+type tournamentRooms struct {
+  s *SuperghostServer
+  tournamentID string
+  t *superghost.Tournament
+}
+
+func (tr *tournamentRooms) Create(config superghost.Config) (string, error) {
+  var roomID string
+  roomID, err := tr.s.createRoom(config, func(winner string) {
+    // Rooms only let the match's players in, so they're the only winners
+    if err := tr.t.ReportWinner(roomID, winner); err != nil {
+      fmt.Println("couldn't report a winner in tournament " +
+                  tr.tournamentID + ": " + err.Error())
+    }
+  })
+  return roomID, err
+}
+
+func (tr *tournamentRooms) Remove(roomID string) {
+  tr.s.deleteRoom(roomID)
+}
+
+func (tr *tournamentRooms) Players(roomID string) []string {
+  tr.s.roomsMutex.RLock()
+  rw, ok := tr.s.Rooms[roomID]
+  tr.s.roomsMutex.RUnlock()
+  if !ok {
+    return nil
+  }
+  return rw.Room.Usernames()
+}
+
+func (s *SuperghostServer) deleteRoom(roomID string) {
+  s.roomsMutex.Lock()
+  rw, ok := s.Rooms[roomID]
+  delete(s.Rooms, roomID)
+  s.roomsMutex.Unlock()
+  if ok {
+    rw.Teardown()
+  }
+}
+
+func (s *SuperghostServer) middlewareGetTournament(
+    next http.Handler) http.Handler {
+  return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+    ID := chi.URLParam(r, "tournamentID")
+    s.tournamentsMutex.RLock()
+    t, ok := s.tournaments[ID]
+    s.tournamentsMutex.RUnlock()
+    if !ok {
+      writeNotFound(w)
+      return
+    }
+    next.ServeHTTP(w, r.WithContext(
+        context.WithValue(r.Context(), "tournament", t)))
+  })
+}
+
+func (s *SuperghostServer) publicRoomMetadata() []superghost.JRoomMetadata {
+  s.roomsMutex.RLock()
+  defer s.roomsMutex.RUnlock()
+
+  arr := make([]superghost.JRoomMetadata, 0, len(s.Rooms))
+  for k := range s.Rooms {
+    if !s.Rooms[k].Room.IsPublic() {
+      continue
+    }
+    arr = append(arr, s.Rooms[k].Room.Metadata(k))
+  }
+  return arr
 }
 
 func (s *SuperghostServer) room(w http.ResponseWriter, r *http.Request) {
@@ -215,7 +562,7 @@ func (s *SuperghostServer) room(w http.ResponseWriter, r *http.Request) {
       return
 
     default:
-      http.Error(w, "", http.StatusMethodNotAllowed)
+      writeMethodNotAllowed(w)
   }
 }
 
@@ -241,11 +588,27 @@ func (s *SuperghostServer) join(w http.ResponseWriter, r *http.Request) {
 
     case http.MethodPost:
       fmt.Println("here!")
+      if !s.allowJoin(w, r) {
+        return
+      }
       r.ParseForm()
-      cookie, err := roomWrapper.Room.AddPlayer(r.FormValue("username"),
-                                                "/rooms/" + roomID)
+      team := 0
+      if r.FormValue("team") != "" {
+        var err error
+        if team, err = strconv.Atoi(r.FormValue("team")); err != nil {
+          writeBadRequest(w, err)
+          return
+        }
+      }
+      cookie, err := roomWrapper.Room.AddPlayerWithCredentials(
+          r.FormValue("username"), "/rooms/" + roomID, team,
+          superghost.JoinCredentials {
+            Password: r.FormValue("password"),
+            Invite: r.FormValue("invite"),
+            Cookies: r.Cookies(),
+          })
       if err != nil {
-        http.Error(w, err.Error(), http.StatusBadRequest)
+        writeError(w, err)
         return
       }
       http.SetCookie(w, cookie)
@@ -255,8 +618,22 @@ func (s *SuperghostServer) join(w http.ResponseWriter, r *http.Request) {
       return
 
     default:
-      http.Error(w, "", http.StatusMethodNotAllowed)
+      writeMethodNotAllowed(w)
+  }
+}
+
+// Responds with a new invite and the link to share it by
+func (s *SuperghostServer) invite(w http.ResponseWriter, r *http.Request) {
+  ctx := r.Context()
+  roomID := ctx.Value("roomID").(string)
+  roomWrapper := ctx.Value("roomWrapper").(*RoomWrapper)
+
+  invite, err := roomWrapper.Room.Invite(r.Cookies(), 0)
+  if err != nil {
+    writeError(w, err)
+    return
   }
+  writeJSON(w, http.StatusOK, newJInviteResponse("/rooms/" + roomID, invite))
 }
 
 func (s *SuperghostServer) affix(w http.ResponseWriter, r *http.Request) {
@@ -270,14 +647,146 @@ func (s *SuperghostServer) affix(w http.ResponseWriter, r *http.Request) {
       err := roomWrapper.Room.AffixLetter(r.Cookies(), r.FormValue("prefix"),
                                           r.FormValue("suffix"))
       if err != nil {
-        http.Error(w, err.Error(), http.StatusBadRequest)
+        writeError(w, err)
+        return
+      }
+      fmt.Fprint(w, "success")
+      roomWrapper.BroadcastGameState()
+
+    default:
+      writeMethodNotAllowed(w)
+  }
+}
+
+func (s *SuperghostServer) insertion(w http.ResponseWriter,
+                                       r *http.Request) {
+  ctx := r.Context()
+  roomWrapper := ctx.Value("roomWrapper").(*RoomWrapper)
+
+  switch r.Method {
+
+    case http.MethodPost:
+      r.ParseForm()
+      index, err := strconv.Atoi(r.FormValue("index"))
+      if err != nil {
+        writeBadRequest(w, err)
+        return
+      }
+      err = roomWrapper.Room.InsertLetter(r.Cookies(), index,
+                                          r.FormValue("letter"))
+      if err != nil {
+        writeError(w, err)
         return
       }
       fmt.Fprint(w, "success")
       roomWrapper.BroadcastGameState()
 
     default:
-      http.Error(w, "", http.StatusMethodNotAllowed)
+      writeMethodNotAllowed(w)
+  }
+}
+
+func (s *SuperghostServer) reversal(w http.ResponseWriter, r *http.Request) {
+  ctx := r.Context()
+  roomWrapper := ctx.Value("roomWrapper").(*RoomWrapper)
+
+  switch r.Method {
+    case http.MethodPost:
+      err := roomWrapper.Room.ReverseStem(r.Cookies())
+      if err != nil {
+        writeError(w, err)
+        return
+      }
+      roomWrapper.BroadcastGameState()
+
+    default:
+      writeMethodNotAllowed(w)
+  }
+}
+
+func (s *SuperghostServer) hint(w http.ResponseWriter, r *http.Request) {
+  ctx := r.Context()
+  roomWrapper := ctx.Value("roomWrapper").(*RoomWrapper)
+
+  switch r.Method {
+    case http.MethodGet:
+      hint, err := roomWrapper.Room.Hint(r.Cookies())
+      if err != nil {
+        writeError(w, err)
+        return
+      }
+      b, err := json.Marshal(hint)
+      if err != nil {
+        writeError(w, err)
+        return
+      }
+      w.Header().Set("Content-Type", "application/json; charset=utf-8")
+      w.Write(b)
+      roomWrapper.BroadcastGameState()
+
+    default:
+      writeMethodNotAllowed(w)
+  }
+}
+
+func (s *SuperghostServer) juryVote(w http.ResponseWriter, r *http.Request) {
+  ctx := r.Context()
+  roomWrapper := ctx.Value("roomWrapper").(*RoomWrapper)
+
+  err := r.ParseForm()
+  if err != nil {
+    writeBadRequest(w, err)
+    return
+  }
+  overturn := r.FormValue("Overturn") == "true"
+
+  switch r.Method {
+    case http.MethodPost:
+      err := roomWrapper.Room.VoteOnRuling(r.Cookies(), overturn)
+      if err != nil {
+        writeError(w, err)
+        return
+      }
+      roomWrapper.BroadcastGameState()
+
+    default:
+      writeMethodNotAllowed(w)
+  }
+}
+
+func (s *SuperghostServer) pause(w http.ResponseWriter, r *http.Request) {
+  ctx := r.Context()
+  roomWrapper := ctx.Value("roomWrapper").(*RoomWrapper)
+
+  switch r.Method {
+    case http.MethodPost:
+      err := roomWrapper.Room.Pause(r.Cookies())
+      if err != nil {
+        writeError(w, err)
+        return
+      }
+      roomWrapper.BroadcastGameState()
+
+    default:
+      writeMethodNotAllowed(w)
+  }
+}
+
+func (s *SuperghostServer) resume(w http.ResponseWriter, r *http.Request) {
+  ctx := r.Context()
+  roomWrapper := ctx.Value("roomWrapper").(*RoomWrapper)
+
+  switch r.Method {
+    case http.MethodPost:
+      err := roomWrapper.Room.Resume(r.Cookies())
+      if err != nil {
+        writeError(w, err)
+        return
+      }
+      roomWrapper.BroadcastGameState()
+
+    default:
+      writeMethodNotAllowed(w)
   }
 }
 
@@ -290,13 +799,13 @@ func (s *SuperghostServer) challengeIsWord(w http.ResponseWriter,
     case http.MethodPost:
       err := roomWrapper.Room.ChallengeIsWord(r.Cookies())
       if err != nil {
-        http.Error(w, err.Error(), http.StatusInternalServerError)
+        writeError(w, err)
         return
       }
       roomWrapper.BroadcastGameState()
 
     default:
-      http.Error(w, "", http.StatusMethodNotAllowed)
+      writeMethodNotAllowed(w)
   }
 }
 
@@ -310,13 +819,13 @@ func (s *SuperghostServer) challengeContinuation(w http.ResponseWriter,
 
       err := roomWrapper.Room.ChallengeContinuation(r.Cookies())
       if err != nil {
-        http.Error(w, err.Error(), http.StatusBadRequest)
+        writeError(w, err)
         return
       }
       roomWrapper.BroadcastGameState()
 
     default:
-      http.Error(w, "", http.StatusMethodNotAllowed)
+      writeMethodNotAllowed(w)
   }
 }
 
@@ -328,16 +837,21 @@ func (s *SuperghostServer) rebuttal(w http.ResponseWriter, r *http.Request) {
     case http.MethodPost:
       // it must be your turn to challenge.
       r.ParseForm()
-      err := roomWrapper.Room.RebutChallenge(
-          r.Cookies(), r.FormValue("prefix"), r.FormValue("suffix"))
+      var err error
+      if word := r.FormValue("word"); word != "" {
+        err = roomWrapper.Room.RebutChallengeWithWord(r.Cookies(), word)
+      } else {
+        err = roomWrapper.Room.RebutChallenge(
+            r.Cookies(), r.FormValue("prefix"), r.FormValue("suffix"))
+      }
       if err != nil {
-        http.Error(w, err.Error(), http.StatusBadRequest)
+        writeError(w, err)
         return
       }
       roomWrapper.BroadcastGameState()
 
     default:
-      http.Error(w, "", http.StatusMethodNotAllowed)
+      writeMethodNotAllowed(w)
   }
 }
 
@@ -354,7 +868,7 @@ func (s *SuperghostServer) currentState(w http.ResponseWriter,
       }
       fmt.Fprint(w, string(b))
     default:
-      http.Error(w, "", http.StatusMethodNotAllowed)
+      writeMethodNotAllowed(w)
   }
 }
 
@@ -366,10 +880,14 @@ func (s *SuperghostServer) nextState(w http.ResponseWriter, r *http.Request) {
 
     case http.MethodGet:
       myChan := roomWrapper.UpdateListeners.AddListener()
-      fmt.Fprint(w, <-myChan)
+      select {
+        case state := <-myChan:
+          fmt.Fprint(w, state)
+        case <-r.Context().Done():
+      }
 
     default:
-      http.Error(w, "", http.StatusMethodNotAllowed)
+      writeMethodNotAllowed(w)
   }
 }
 
@@ -382,14 +900,14 @@ func (s *SuperghostServer) concession(w http.ResponseWriter, r *http.Request) {
     case http.MethodPost:
       err := roomWrapper.Room.Concede(r.Cookies())
       if err != nil {
-        http.Error(w, err.Error(), http.StatusBadRequest)
+        writeError(w, err)
         return
       }
       fmt.Fprint(w, "success")
       roomWrapper.BroadcastGameState()
 
     default:
-      http.Error(w, "", http.StatusMethodNotAllowed)
+      writeMethodNotAllowed(w)
   }
 }
 
@@ -402,14 +920,14 @@ func (s *SuperghostServer) leave(w http.ResponseWriter, r *http.Request) {
     case http.MethodPost:
       err := roomWrapper.Room.Leave(r.Cookies())
       if err != nil {
-        http.Error(w, err.Error(), http.StatusBadRequest)
+        writeError(w, err)
         return
       }
       redirectURIList(w, "/")
       roomWrapper.BroadcastGameState()
 
     default:
-      http.Error(w, "", http.StatusMethodNotAllowed)
+      writeMethodNotAllowed(w)
   }
 }
 
@@ -423,13 +941,13 @@ func (s *SuperghostServer) cancellableLeave(w http.ResponseWriter,
     case http.MethodPost:
       err := roomWrapper.Room.ScheduleLeave(r.Cookies())
       if err != nil {
-        http.Error(w, err.Error(), http.StatusBadRequest)
+        writeError(w, err)
         return
       }
       fmt.Fprintln(w, "you are now scheduled to leave the game")
 
     default:
-      http.Error(w, "", http.StatusMethodNotAllowed)
+      writeMethodNotAllowed(w)
   }
 }
 
@@ -442,13 +960,13 @@ func (s *SuperghostServer) cancelLeave(w http.ResponseWriter, r *http.Request) {
     case http.MethodPost:
       err := roomWrapper.Room.CancelLeaveIfScheduled(r.Cookies())
       if err != nil {
-        http.Error(w, err.Error(), http.StatusBadRequest)
+        writeError(w, err)
         return
       }
       fmt.Fprintln(w, "you are no longer scheduled to leave the game")
 
     default:
-      http.Error(w, "", http.StatusMethodNotAllowed)
+      writeMethodNotAllowed(w)
   }
 }
 
@@ -467,7 +985,68 @@ func (s *SuperghostServer) config(w http.ResponseWriter, r *http.Request) {
       fmt.Fprint(w, string(b))
 
     default:
-      http.Error(w, "", http.StatusMethodNotAllowed)
+      writeMethodNotAllowed(w)
+  }
+}
+
+func (s *SuperghostServer) analysis(w http.ResponseWriter, r *http.Request) {
+  ctx := r.Context()
+  roomWrapper := ctx.Value("roomWrapper").(*RoomWrapper)
+
+  switch r.Method {
+    case http.MethodGet:
+      gameNumber, err := strconv.Atoi(chi.URLParam(r, "gameNumber"))
+      if err != nil {
+        writeBadRequest(w, err)
+        return
+      }
+      analysis, err := roomWrapper.Room.Analysis(gameNumber)
+      if err != nil {
+        writeError(w, err)
+        return
+      }
+      b, err := json.Marshal(analysis)
+      if err != nil {
+        writeError(w, err)
+        return
+      }
+      w.Header().Set("Content-Type", "application/json; charset=utf-8")
+      w.Write(b)
+
+    default:
+      writeMethodNotAllowed(w)
+  }
+}
+
+// Takes the lists as pasted text and responds with the updated config
+func (s *SuperghostServer) wordLists(w http.ResponseWriter, r *http.Request) {
+  ctx := r.Context()
+  roomWrapper := ctx.Value("roomWrapper").(*RoomWrapper)
+
+  err := r.ParseForm()
+  if err != nil {
+    writeBadRequest(w, err)
+    return
+  }
+
+  switch r.Method {
+    case http.MethodPost:
+      err := roomWrapper.Room.SetWordLists(
+          r.Cookies(), superghost.ParseWordList(r.FormValue("AllowedWords")),
+          superghost.ParseWordList(r.FormValue("BlockedWords")))
+      if err != nil {
+        writeError(w, err)
+        return
+      }
+      b, err := roomWrapper.Room.MarshalJSONConfig()
+      if err != nil {
+        writeError(w, err)
+        return
+      }
+      fmt.Fprint(w, string(b))
+
+    default:
+      writeMethodNotAllowed(w)
   }
 }
 
@@ -479,12 +1058,19 @@ func (s *SuperghostServer) chat(w http.ResponseWriter, r *http.Request) {
 
     case http.MethodGet:
       myChan := roomWrapper.ChatListeners.AddListener()
-      fmt.Fprint(w, <-myChan)
+      select {
+        case msg := <-myChan:
+          fmt.Fprint(w, msg)
+        case <-r.Context().Done():
+      }
 
     case http.MethodPost:
+      if !s.allowChat(w, r, ctx.Value("roomID").(string), roomWrapper) {
+        return
+      }
       msg, err := roomWrapper.Room.Chat(r.Cookies(), r.FormValue("content"))
       if err != nil {
-        http.Error(w, err.Error(), http.StatusBadRequest)
+        writeError(w, err)
         return
       }
       fmt.Fprint(w, "success")
@@ -496,7 +1082,38 @@ func (s *SuperghostServer) chat(w http.ResponseWriter, r *http.Request) {
       roomWrapper.ChatListeners.Broadcast(string(b))
 
     default:
-      http.Error(w, "", http.StatusMethodNotAllowed)
+      writeMethodNotAllowed(w)
+  }
+}
+
+func (s *SuperghostServer) deleteMessage(w http.ResponseWriter,
+                                         r *http.Request) {
+  ctx := r.Context()
+  roomWrapper := ctx.Value("roomWrapper").(*RoomWrapper)
+
+  switch r.Method {
+
+    case http.MethodDelete:
+      messageID, err := strconv.Atoi(chi.URLParam(r, "messageID"))
+      if err != nil {
+        writeBadRequest(w, err)
+        return
+      }
+      notice, err := roomWrapper.Room.DeleteMessage(r.Cookies(), messageID)
+      if err != nil {
+        writeError(w, err)
+        return
+      }
+      fmt.Fprint(w, "success")
+      // Tell everyone to take it down
+      b, err := json.Marshal(notice)
+      if err != nil {
+        panic(err)
+      }
+      roomWrapper.ChatListeners.Broadcast(string(b))
+
+    default:
+      writeMethodNotAllowed(w)
   }
 }
 
@@ -506,7 +1123,7 @@ func (s *SuperghostServer) kick(w http.ResponseWriter, r *http.Request) {
 
   err := r.ParseForm()
   if err != nil {
-    http.Error(w, err.Error(), http.StatusBadRequest)
+    writeBadRequest(w, err)
     return
   }
   recipient := r.FormValue("Username")
@@ -516,13 +1133,48 @@ func (s *SuperghostServer) kick(w http.ResponseWriter, r *http.Request) {
     case http.MethodPost:
       err := roomWrapper.Room.Kick(r.Cookies(), recipient)
       if err != nil {
-        http.Error(w, err.Error(), http.StatusBadRequest)
+        writeError(w, err)
+        return
+      }
+      roomWrapper.BroadcastGameState()
+
+    default:
+      writeMethodNotAllowed(w)
+  }
+}
+
+func (s *SuperghostServer) mute(w http.ResponseWriter, r *http.Request) {
+  s.setMuted(w, r, true)
+}
+
+func (s *SuperghostServer) unmute(w http.ResponseWriter, r *http.Request) {
+  s.setMuted(w, r, false)
+}
+
+func (s *SuperghostServer) setMuted(w http.ResponseWriter, r *http.Request,
+                                    muted bool) {
+  ctx := r.Context()
+  roomWrapper := ctx.Value("roomWrapper").(*RoomWrapper)
+
+  err := r.ParseForm()
+  if err != nil {
+    writeBadRequest(w, err)
+    return
+  }
+  username := r.FormValue("Username")
+
+  switch r.Method {
+
+    case http.MethodPost:
+      err := roomWrapper.Room.Mute(r.Cookies(), username, muted)
+      if err != nil {
+        writeError(w, err)
         return
       }
       roomWrapper.BroadcastGameState()
 
     default:
-      http.Error(w, "", http.StatusMethodNotAllowed)
+      writeMethodNotAllowed(w)
   }
 }
 
@@ -532,11 +1184,51 @@ func (s *SuperghostServer) periodicallyDeleteIdleRooms(period time.Duration) {
 
   for {
     <-ticker.C
+    var idle []*RoomWrapper
+    s.roomsMutex.Lock()
     for key, rw := range s.Rooms {
       if time.Since(rw.Room.LastTouch()) > period {
-        s.Rooms[key].Room.Teardown()
+        idle = append(idle, rw)
         delete(s.Rooms, key)
       }
     }
+    s.roomsMutex.Unlock()
+    // Tearing down waits for each room's lock, so don't hold up every other
+    // room meanwhile
+    for _, rw := range idle {
+      rw.Teardown()
+    }
+  }
+}
+
+// Puts a cache in front of WordsAPI for every English room created from now
+// on. While WordsAPI is failing, words in the bundled English word list are
+// still confirmed, and any other lookup fails.
+// The cache is loaded from path, and saved back to it every period.
+func (s *SuperghostServer) UseCachedDictionary(path string,
+                                               period time.Duration) error {
+  cache := superghost.NewCachedDictionary(superghost.WordsAPIDictionary{},
+                                          superghost.DictionaryCacheConfig {
+    Fallback: superghost.LanguageEnglish.WordList(),
+  })
+  if err := cache.Load(path); err != nil {
+    return err
+  }
+  s.Dictionary = cache
+  s.Puzzles.Dictionary = cache
+  go periodicallySaveDictionary(cache, path, period)
+  return nil
+}
+
+func periodicallySaveDictionary(cache *superghost.CachedDictionary,
+                                path string, period time.Duration) {
+  ticker := time.NewTicker(period)
+  defer ticker.Stop()
+
+  for {
+    <-ticker.C
+    if err := cache.Save(path); err != nil {
+      fmt.Println("couldn't save the dictionary cache: " + err.Error())
+    }
   }
 }
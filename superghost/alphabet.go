@@ -0,0 +1,188 @@
+package superghost
+
+import (
+  "compress/gzip"
+  "embed"
+  "io"
+  "sort"
+  "strings"
+  "sync"
+  "unicode/utf8"
+)
+
+// Which language a room plays in. This picks its alphabet and, unless the
+// room brings its own, its dictionary.
+type Language string
+const (
+  LanguageEnglish Language = "en"
+  LanguageSpanish Language = "es"
+  LanguageGerman Language = "de"
+  LanguageFrench Language = "fr"
+)
+
+func (l Language) IsValid() bool {
+  _, ok := _alphabets[l]
+  return ok
+}
+
+// The alphabet rooms in this language are played with. Empty means English.
+func (l Language) Alphabet() *Alphabet {
+  if a, ok := _alphabets[l]; ok {
+    return a
+  }
+  return _alphabets[LanguageEnglish]
+}
+
+// The letters a language is played with, and how anything typed is turned
+// into them. Everything is upper cased, accents typed as combining marks are
+// joined to their letter, and letters the language doesn't treat as their own
+// are folded into ones it does (French plays "É" as "E", German plays "ß" as
+// "SS").
+type Alphabet struct {
+  letters map[rune]bool
+  folds *strings.Replacer
+}
+
+func newAlphabet(extraLetters string, folds ...string) *Alphabet {
+  a := new(Alphabet)
+  a.letters = make(map[rune]bool)
+  for _, c := range "ABCDEFGHIJKLMNOPQRSTUVWXYZ" + extraLetters {
+    a.letters[c] = true
+  }
+  a.folds = strings.NewReplacer(folds...)
+  return a
+}
+
+// Upper case letters followed by a combining accent, and the single letter
+// that's usually written instead
+var _compositions = strings.NewReplacer(
+  "A\u0300", "À", "A\u0301", "Á", "A\u0302", "Â", "A\u0308", "Ä",
+  "C\u0327", "Ç",
+  "E\u0300", "È", "E\u0301", "É", "E\u0302", "Ê", "E\u0308", "Ë",
+  "I\u0301", "Í", "I\u0302", "Î", "I\u0308", "Ï",
+  "N\u0303", "Ñ",
+  "O\u0301", "Ó", "O\u0302", "Ô", "O\u0308", "Ö",
+  "U\u0300", "Ù", "U\u0301", "Ú", "U\u0302", "Û", "U\u0308", "Ü",
+  "Y\u0308", "Ÿ",
+)
+
+var _alphabets = map[Language]*Alphabet {
+  LanguageEnglish: newAlphabet(""),
+  // Ñ is a letter of its own, but accents only mark stress
+  LanguageSpanish: newAlphabet("Ñ",
+      "Á", "A", "É", "E", "Í", "I", "Ó", "O", "Ú", "U", "Ü", "U"),
+  // Umlauts are letters of their own, and ß is spelled out as SS like it is
+  // in capitals
+  LanguageGerman: newAlphabet("ÄÖÜ", "ß", "SS", "ẞ", "SS"),
+  // Accents are ignored, as in French crosswords
+  LanguageFrench: newAlphabet("",
+      "À", "A", "Â", "A", "Æ", "AE", "Ç", "C", "È", "E", "É", "E", "Ê", "E",
+      "Ë", "E", "Î", "I", "Ï", "I", "Ô", "O", "Œ", "OE", "Ù", "U", "Û", "U",
+      "Ü", "U", "Ÿ", "Y"),
+}
+
+// Every letter, in order
+func (a *Alphabet) letterList() []string {
+  letters := make([]string, 0, len(a.letters))
+  for c := range a.letters {
+    letters = append(letters, string(c))
+  }
+  sort.Strings(letters)
+  return letters
+}
+
+// Turns typed text into this alphabet's letters. Anything that isn't a letter
+// at all is left alone for IsLetters to reject.
+func (a *Alphabet) Normalize(s string) string {
+  return a.folds.Replace(_compositions.Replace(strings.ToUpper(s)))
+}
+
+// Whether s is one or more letters of this alphabet. s should already be
+// normalized.
+func (a *Alphabet) IsLetters(s string) bool {
+  if s == "" {
+    return false
+  }
+  for _, c := range s {
+    if !a.letters[c] {
+      return false
+    }
+  }
+  return true
+}
+
+// The length of s in letters rather than bytes
+func letterCount(s string) int {
+  return utf8.RuneCountInString(s)
+}
+
+// Word lists, one file per language named after its code. English rooms look
+// words up online, so its list is only a short fallback for when that fails.
+// The others are every form of every word in a Hunspell dictionary, and are
+// gzipped since they run to hundreds of thousands of words.
+//go:embed words
+var _wordFiles embed.FS
+
+var _languageWordLists = make(map[Language]*WordList)
+var _languageWordListsMutex sync.Mutex
+
+// The word list bundled for this language
+func (l Language) WordList() *WordList {
+  if !l.IsValid() {
+    l = LanguageEnglish
+  }
+  _languageWordListsMutex.Lock()
+  defer _languageWordListsMutex.Unlock()
+
+  if wl, ok := _languageWordLists[l]; ok {
+    return wl
+  }
+  f, err := l.openWordList()
+  if err != nil {
+    panic("no word list for language '" + string(l) + "'")
+  }
+  defer f.Close()
+  wl, err := readWordList(f, l.Alphabet())
+  if err != nil {
+    panic(err)
+  }
+  _languageWordLists[l] = wl
+  return wl
+}
+
+func (l Language) openWordList() (io.ReadCloser, error) {
+  name := "words/" + string(l) + ".txt"
+  if f, err := _wordFiles.Open(name); err == nil {
+    return f, nil
+  }
+  f, err := _wordFiles.Open(name + ".gz")
+  if err != nil {
+    return nil, err
+  }
+  gz, err := gzip.NewReader(f)
+  if err != nil {
+    f.Close()
+    return nil, err
+  }
+  return gzipFile{gz, f}, nil
+}
+
+// Closes the gzip reader and the file under it
+type gzipFile struct {
+  *gzip.Reader
+  f io.Closer
+}
+
+func (g gzipFile) Close() error {
+  g.Reader.Close()
+  return g.f.Close()
+}
+
+// The dictionary rooms in this language use when they aren't given one:
+// WordsAPI for English, and the bundled word list for everything else
+func (l Language) defaultDictionary() Dictionary {
+  if l == LanguageEnglish || !l.IsValid() {
+    return WordsAPIDictionary{}
+  }
+  return l.WordList()
+}
@@ -0,0 +1,178 @@
+package superghost
+
+// A look back at a finished game, move by move, to learn from mistakes. It's
+// worked out from the game's log and the room's word index.
+type GameAnalysis struct {
+  // Games are numbered from 1 in each room
+  Game int
+  Winner string
+  Rounds []RoundAnalysis
+}
+
+type RoundAnalysis struct {
+  Moves []MoveAnalysis
+}
+
+// One move: a letter added to the stem, or the stem reversed
+type MoveAnalysis struct {
+  Player string
+  // The stem before the move
+  Stem string
+  Prefix string `json:",omitempty"`
+  Suffix string `json:",omitempty"`
+  // The letter inserted and where (superduperghost)
+  Letter string `json:",omitempty"`
+  Index *int `json:",omitempty"`
+  // The stem was reversed (xghost)
+  Reversed bool `json:",omitempty"`
+  // Whether the move was one of SafeMoves
+  WasSafe bool
+  // Nothing was safe: every move spelled a word or left the stem in none
+  ForcedLosing bool
+  // Every move that could have been played safely
+  SafeMoves []HintMove
+  // The shortest word the stem could have become, or empty if there was none
+  Example string `json:",omitempty"`
+}
+
+// What's kept of a finished game to analyze it when it's first asked for
+type finishedGame struct {
+  winner string
+  // The game's log items. Items are never changed once they're logged.
+  log []LogItem
+  // The config and alphabet the game was played with, since the host can
+  // change the word lists before the next one
+  config Config
+  alphabet *Alphabet
+  // Nil until it's first asked for
+  analysis *GameAnalysis
+}
+
+// Returns the analysis of the room's gameNumber-th finished game. Working it
+// out means going through the whole word list for every move, so it's done
+// without the lock the first time it's asked for, and kept.
+func (r *Room) Analysis(gameNumber int) (*GameAnalysis, error) {
+  r.mutex.RLock()
+  if gameNumber < 1 || gameNumber > len(r.games) {
+    defer r.mutex.RUnlock()
+    return nil, ErrNoAnalysis.withMessage(
+        "no analysis for game %d: %d games have finished", gameNumber,
+        len(r.games))
+  }
+  game := r.games[gameNumber - 1]
+  analysis := game.analysis
+  r.mutex.RUnlock()
+  if analysis != nil {
+    return analysis, nil
+  }
+
+  analysis = game.analyze(gameNumber)
+  r.mutex.Lock()
+  defer r.mutex.Unlock()
+  // Someone else may have got there first
+  if game.analysis == nil {
+    game.analysis = analysis
+  }
+  return game.analysis, nil
+}
+
+// The winners of the room's finished games, in order
+func (r *Room) Winners() []string {
+  r.mutex.RLock()
+  defer r.mutex.RUnlock()
+
+  winners := make([]string, 0, len(r.games))
+  for _, game := range r.games {
+    winners = append(winners, game.winner)
+  }
+  return winners
+}
+
+// Keeps the game that just ended, which started at r.gameLogStart, for
+// analysis. Returns its number.
+func (r *Room) finishGame(winner string) int {
+  end := len(r.log.history)
+  r.games = append(r.games, &finishedGame{
+    winner: winner,
+    // Capped, so appending to the log never writes into the game's items
+    log: r.log.history[r.gameLogStart:end:end],
+    config: *r.config,
+    alphabet: r.alphabet,
+  })
+  return len(r.games)
+}
+
+// Whether a log item of this type means the round is over
+func endsRound(t logItemType) bool {
+  switch t {
+    case kChallengeResult, kJuryVerdict, kChallengedPlayerLeft, kTimeout,
+        kConcede, kInsufficientPlayers:
+      return true
+  }
+  return false
+}
+
+// Analyzes the game from its log. Words already played still count as words
+// the stem could have become, since they could have been when the move was
+// made.
+func (g *finishedGame) analyze(gameNumber int) *GameAnalysis {
+  index := newWordIndex(&g.config, g.alphabet, nil)
+  analysis := &GameAnalysis{
+    Game: gameNumber,
+    Winner: g.winner,
+    Rounds: make([]RoundAnalysis, 0),
+  }
+  // Nil between the end of one round and the first move of the next
+  var round *RoundAnalysis
+  isReversed := false
+  for _, item := range g.log {
+    if endsRound(item.Type) {
+      round = nil
+      isReversed = false
+      continue
+    }
+    var played HintMove
+    switch item.Type {
+      case kAffix:
+        played = HintMove{ Prefix: item.Prefix, Suffix: item.Suffix }
+      case kInsert:
+        played = HintMove{ Letter: item.Letter, Index: item.Index }
+      case kReverse:
+        played = HintMove{ Reverse: true }
+      default:
+        continue
+    }
+    if round == nil {
+      analysis.Rounds = append(analysis.Rounds, RoundAnalysis{
+        Moves: make([]MoveAnalysis, 0),
+      })
+      round = &analysis.Rounds[len(analysis.Rounds) - 1]
+    }
+    canReverse := g.config.Variant.allowsReversal() && !isReversed
+    safeMoves, example := index.analyze(item.Stem, 0, canReverse)
+    move := MoveAnalysis{
+      Player: item.From,
+      Stem: item.Stem,
+      Prefix: item.Prefix,
+      Suffix: item.Suffix,
+      Letter: item.Letter,
+      Index: item.Index,
+      Reversed: played.Reverse,
+      ForcedLosing: len(safeMoves) == 0,
+      SafeMoves: safeMoves,
+      Example: example,
+    }
+    // Different moves can make the same stem, so they're compared by that
+    next := played.apply(item.Stem)
+    for _, safe := range safeMoves {
+      if safe.apply(item.Stem) == next {
+        move.WasSafe = true
+      }
+    }
+    if played.Reverse {
+      isReversed = true
+    }
+    round.Moves = append(round.Moves, move)
+  }
+  return analysis
+}
@@ -0,0 +1,180 @@
+package superghost
+
+import (
+  "errors"
+  "net/http"
+  "sort"
+  "strings"
+  "unicode"
+  "unicode/utf8"
+)
+
+const (
+  // In characters, not bytes
+  kMaxMessageLength = 500
+  // How many of the most recent messages a room keeps for players who join
+  // later
+  kChatHistoryLength = 50
+)
+
+// IDs count up from 1 in each room. A message with Deleted set is a notice
+// that the host deleted the message with that ID; it has no Sender or
+// Content.
+type Message struct {
+  ID int
+  Sender string `json:",omitempty"`
+  Content string `json:",omitempty"`
+  Deleted bool `json:",omitempty"`
+}
+
+// Checks chat messages before anyone sees them. Filter returns the content to
+// send, which may be changed (to mask a word, say), or an error to turn the
+// message away. Filter is called with the room locked, so it must not block
+// on anything slow.
+type ChatFilter interface {
+  Filter(content string) (string, error)
+}
+
+// Masks banned words with asterisks. Only whole words are masked, regardless
+// of case, so banning "ass" leaves "class" alone.
+type WordFilter struct {
+  banned map[string]bool
+}
+
+func NewWordFilter(words []string) *WordFilter {
+  f := &WordFilter{ banned: make(map[string]bool) }
+  for _, w := range words {
+    if w = strings.ToLower(strings.TrimSpace(w)); w != "" {
+      f.banned[w] = true
+    }
+  }
+  return f
+}
+
+func (f *WordFilter) Filter(content string) (string, error) {
+  var b strings.Builder
+  word := make([]rune, 0)
+  flush := func() {
+    if f.banned[strings.ToLower(string(word))] {
+      b.WriteString(strings.Repeat("*", len(word)))
+    } else {
+      b.WriteString(string(word))
+    }
+    word = word[:0]
+  }
+  for _, c := range content {
+    if unicode.IsLetter(c) || unicode.IsDigit(c) {
+      word = append(word, c)
+      continue
+    }
+    flush()
+    b.WriteRune(c)
+  }
+  flush()
+  return b.String(), nil
+}
+
+// Validates the message, puts it through the room's filter and adds it to the
+// history. Returns the message as it should be broadcast.
+func (r *Room) Chat(cookies []*http.Cookie, content string) (*Message, error) {
+  r.mutex.Lock()
+  defer r.mutex.Unlock()
+
+  r.updateLastTouch()
+
+  username, ok := r.pm.getValidCookie(cookies)
+  if !ok {
+    return nil, ErrInvalidCredentials
+  }
+  if r.muted[username] {
+    return nil, ErrMuted
+  }
+  if len(content) == 0 {
+    return nil, ErrEmptyMessage
+  }
+  if utf8.RuneCountInString(content) > kMaxMessageLength {
+    return nil, ErrMessageTooLong.withMessage(
+        "messages can be at most %d characters", kMaxMessageLength)
+  }
+  if r.config.ChatFilter != nil {
+    filtered, err := r.config.ChatFilter.Filter(content)
+    if err != nil {
+      var sgErr *Error
+      if errors.As(err, &sgErr) {
+        return nil, err
+      }
+      return nil, ErrMessageBlocked.wrap(err)
+    }
+    content = filtered
+  }
+
+  r.lastMessageID++
+  msg := Message{ ID: r.lastMessageID, Sender: username, Content: content }
+  r.chat = append(r.chat, msg)
+  if len(r.chat) > kChatHistoryLength {
+    r.chat = append(r.chat[:0], r.chat[len(r.chat) - kChatHistoryLength:]...)
+  }
+  return &msg, nil
+}
+
+// Only the host can delete messages, and only ones still in the history.
+// Returns the notice to broadcast.
+func (r *Room) DeleteMessage(cookies []*http.Cookie,
+                             id int) (*Message, error) {
+  r.mutex.Lock()
+  defer r.mutex.Unlock()
+
+  r.updateLastTouch()
+
+  username, ok := r.pm.getValidCookie(cookies)
+  if !ok {
+    return nil, ErrInvalidCredentials
+  }
+  if username != r.pm.hostPlayer().username {
+    return nil, ErrNotHost.withMessage("only the host can delete messages")
+  }
+  for i, msg := range r.chat {
+    if msg.ID == id {
+      r.chat = append(r.chat[:i], r.chat[i + 1:]...)
+      return &Message{ ID: id, Deleted: true }, nil
+    }
+  }
+  return nil, ErrMessageNotFound.withMessage("no message with ID %d", id)
+}
+
+// Muted players can still play, but not chat. Mutes outlast leaving, so
+// players can't rejoin to get around them. Only the host can mute players.
+func (r *Room) Mute(cookies []*http.Cookie, username string,
+                    muted bool) error {
+  r.mutex.Lock()
+  defer r.mutex.Unlock()
+
+  r.updateLastTouch()
+
+  muterUsername, ok := r.pm.getValidCookie(cookies)
+  if !ok {
+    return ErrInvalidCredentials
+  }
+  if muterUsername != r.pm.hostPlayer().username {
+    return ErrNotHost.withMessage("only the host can mute players")
+  }
+  if !muted {
+    delete(r.muted, username)
+    return nil
+  }
+  if _, ok := r.pm.usernameToPlayer[username]; !ok {
+    return ErrPlayerNotFound.withMessage("player '%s' not found", username)
+  }
+  r.muted[username] = true
+  return nil
+}
+
+// Sorted, for stable output. The lock must be held.
+func (r *Room) mutedUsernames() []string {
+  usernames := make([]string, 0, len(r.muted))
+  for username := range r.muted {
+    usernames = append(usernames, username)
+  }
+  sort.Strings(usernames)
+  return usernames
+}
@@ -0,0 +1,36 @@
+package superghost
+
+import "time"
+
+// Whether any clock runs in this room
+func (c *Config) isTimed() bool {
+  return c.PlayerTimePerWord > 0 || c.PlayerTimePerGame > 0 ||
+      c.RebuttalTime > 0
+}
+
+// What a player's bank is filled to at the start of a round (or of a game,
+// with a per-game bank)
+func (c *Config) startingTime() time.Duration {
+  if c.PlayerTimePerGame > 0 {
+    return c.PlayerTimePerGame
+  }
+  return c.PlayerTimePerWord
+}
+
+// Whether the current turn is played on the player's own bank, as opposed to
+// the rebuttal clock
+func (r *Room) usesBank() bool {
+  return r.state != kRebut || r.config.RebuttalTime == 0
+}
+
+// How long the current player has for this turn, not counting the delay.
+// false if this turn isn't timed.
+func (r *Room) turnBudget() (time.Duration, bool) {
+  if !r.usesBank() {
+    return r.config.RebuttalTime, true
+  }
+  if r.config.startingTime() == 0 {
+    return 0, false
+  }
+  return r.pm.currentPlayer().timeRemaining, true
+}
@@ -0,0 +1,195 @@
+package superghost
+
+import (
+  "bufio"
+  "bytes"
+  "embed"
+  "encoding/json"
+  "io"
+  "os"
+  "strings"
+  "sync"
+)
+
+// The most definitions a DefinitionFile keeps parsed at once
+const kMaxCachedDefinitions = 10000
+
+// What a word means, shown once a challenge finds it's a word
+type Definition struct {
+  PartOfSpeech string `json:",omitempty"`
+  Text string
+  Etymology string `json:",omitempty"`
+}
+
+// Looks up definitions. Define is called with the room locked, so it must not
+// block on anything slow.
+type Definer interface {
+  // nil if the word isn't defined (or isn't yet)
+  Define(word string) *Definition
+}
+
+// One line of a definitions file, in the format of
+// https://dictionaryapi.dev/. Only the fields we show are read.
+type dictionaryEntry struct {
+  Word string `json:"word"`
+  Origin string `json:"origin,omitempty"`
+  Meanings []struct {
+    PartOfSpeech string `json:"partOfSpeech"`
+    Definitions []struct {
+      Definition string `json:"definition"`
+    } `json:"definitions"`
+  } `json:"meanings"`
+}
+
+type definitionSpan struct {
+  offset int64
+  length int
+}
+
+// Definitions read from a file with one JSON dictionary entry per line. The
+// file is indexed in the background when it's opened, and until that's done
+// no words are defined. After that each entry is read from the file the first
+// time its word is looked up, and kept for next time.
+type DefinitionFile struct {
+  data io.ReaderAt
+  // Closed once the index is built
+  indexed chan struct{}
+  // Word to where its entry is in data. Written only before indexed is closed.
+  index map[string]definitionSpan
+
+  mutex sync.Mutex
+  // Includes nil for words without a usable entry
+  cache map[string]*Definition
+}
+
+func NewDefinitionFile(data io.ReaderAt, size int64) *DefinitionFile {
+  df := new(DefinitionFile)
+  df.data = data
+  df.indexed = make(chan struct{})
+  df.index = make(map[string]definitionSpan)
+  df.cache = make(map[string]*Definition)
+  go df.buildIndex(size)
+  return df
+}
+
+// The file stays open for as long as the definitions are used
+func OpenDefinitionFile(path string) (*DefinitionFile, error) {
+  f, err := os.Open(path)
+  if err != nil {
+    return nil, err
+  }
+  info, err := f.Stat()
+  if err != nil {
+    f.Close()
+    return nil, err
+  }
+  return NewDefinitionFile(f, info.Size()), nil
+}
+
+func (df *DefinitionFile) buildIndex(size int64) {
+  defer close(df.indexed)
+
+  reader := bufio.NewReader(io.NewSectionReader(df.data, 0, size))
+  var offset int64
+  for {
+    line, err := reader.ReadBytes('\n')
+    var entry struct {
+      Word string `json:"word"`
+    }
+    if json.Unmarshal(line, &entry) == nil && entry.Word != "" {
+      word := strings.ToUpper(entry.Word)
+      // The first entry for a word is usually its most common sense
+      if _, ok := df.index[word]; !ok {
+        df.index[word] = definitionSpan{ offset: offset, length: len(line) }
+      }
+    }
+    offset += int64(len(line))
+    if err != nil {
+      return
+    }
+  }
+}
+
+func (df *DefinitionFile) isIndexed() bool {
+  select {
+    case <-df.indexed:
+      return true
+    default:
+      return false
+  }
+}
+
+func (df *DefinitionFile) Define(word string) *Definition {
+  if !df.isIndexed() {
+    return nil
+  }
+  df.mutex.Lock()
+  defer df.mutex.Unlock()
+
+  if definition, ok := df.cache[word]; ok {
+    return definition
+  }
+  definition := df.read(word)
+  if len(df.cache) >= kMaxCachedDefinitions {
+    // Any entry will do; the file is still there if it's needed again
+    for w := range df.cache {
+      delete(df.cache, w)
+      break
+    }
+  }
+  df.cache[word] = definition
+  return definition
+}
+
+// Reads word's entry from the file, or returns nil if it doesn't have a usable
+// one
+func (df *DefinitionFile) read(word string) *Definition {
+  span, ok := df.index[word]
+  if !ok {
+    return nil
+  }
+  line := make([]byte, span.length)
+  if _, err := df.data.ReadAt(line, span.offset); err != nil && err != io.EOF {
+    return nil
+  }
+  var entry dictionaryEntry
+  if err := json.Unmarshal(line, &entry); err != nil {
+    return nil
+  }
+  for _, meaning := range entry.Meanings {
+    for _, d := range meaning.Definitions {
+      if d.Definition != "" {
+        return &Definition{
+          PartOfSpeech: meaning.PartOfSpeech,
+          Text: d.Definition,
+          Etymology: entry.Origin,
+        }
+      }
+    }
+  }
+  return nil
+}
+
+// Starter definitions, one file per language named after its code
+//go:embed definitions
+var _definitionFiles embed.FS
+
+var _languageDefinitions = make(map[Language]Definer)
+var _languageDefinitionsMutex sync.Mutex
+
+// The definitions bundled for this language, or nil if there aren't any
+func (l Language) defaultDefinitions() Definer {
+  _languageDefinitionsMutex.Lock()
+  defer _languageDefinitionsMutex.Unlock()
+
+  if d, ok := _languageDefinitions[l]; ok {
+    return d
+  }
+  var definer Definer
+  b, err := _definitionFiles.ReadFile("definitions/" + string(l) + ".jsonl")
+  if err == nil {
+    definer = NewDefinitionFile(bytes.NewReader(b), int64(len(b)))
+  }
+  _languageDefinitions[l] = definer
+  return definer
+}
@@ -0,0 +1,187 @@
+package superghost
+
+import (
+  "bufio"
+  "encoding/json"
+  "io"
+  "net/http"
+  "net/url"
+  "os"
+  "sort"
+  "strconv"
+  "strings"
+  "time"
+)
+
+// A Dictionary decides which stems are words. Words are always passed in
+// upper case.
+type Dictionary interface {
+  IsWord(word string) (bool, error)
+}
+
+// Dictionaries that can search for words, not just check them, implement this
+// too. Hints and analyses use it to find words beyond the bundled word list.
+type WordFinder interface {
+  // Up to limit words containing every letter of stem in order, though not
+  // necessarily next to each other (so "GST" finds "GHOST")
+  WordsWithSubsequence(stem string, limit int) ([]string, error)
+}
+
+// How long a WordsAPI request can take before it counts as a failure. Rooms
+// hold their lock while a challenge is checked, so this bounds how long a slow
+// dictionary can freeze a game.
+const kWordsAPITimeout = 5 * time.Second
+
+var _wordsAPIClient = &http.Client{ Timeout: kWordsAPITimeout }
+
+// Looks words up with WordsAPI (https://www.wordsapi.com/) using the key in the
+// RAPIDAPI_KEY environment variable. This is the dictionary used by rooms that
+// don't specify one.
+type WordsAPIDictionary struct{}
+
+func (d WordsAPIDictionary) get(path string,
+                                query url.Values) (*http.Response, error) {
+  u := "https://wordsapiv1.p.rapidapi.com/words/" + path
+  if len(query) > 0 {
+    u += "?" + query.Encode()
+  }
+  req, _ := http.NewRequest("GET", u, nil)
+  req.Header.Add("X-RapidAPI-Key", os.Getenv("RAPIDAPI_KEY"))
+  req.Header.Add("X-RapidAPI-Host", "wordsapiv1.p.rapidapi.com")
+  // Execute the request
+  res, err := _wordsAPIClient.Do(req)
+  if err != nil {
+    return nil, ErrDictionaryUnavailable.wrap(err)
+  }
+  // Anything other than "found" or "not found" (rate limiting, bad key, ...)
+  // says nothing about the word itself
+  if res.StatusCode != http.StatusOK && res.StatusCode != http.StatusNotFound {
+    res.Body.Close()
+    return nil, ErrDictionaryUnavailable.withMessage(
+        "the dictionary returned status %d", res.StatusCode)
+  }
+  return res, nil
+}
+
+func (d WordsAPIDictionary) IsWord(word string) (bool, error) {
+  res, err := d.get(word, nil)
+  if err != nil {
+    return false, err
+  }
+  defer res.Body.Close()
+  return res.StatusCode == http.StatusOK, nil
+}
+
+func (d WordsAPIDictionary) WordsWithSubsequence(stem string,
+                                                 limit int) ([]string, error) {
+  pattern := "^"
+  for _, c := range strings.ToLower(stem) {
+    pattern += ".*" + string(c)
+  }
+  pattern += ".*$"
+  res, err := d.get("", url.Values {
+    "letterPattern": {pattern},
+    "limit": {strconv.Itoa(limit)},
+  })
+  if err != nil {
+    return nil, err
+  }
+  defer res.Body.Close()
+
+  var body struct {
+    Results struct {
+      Data []string `json:"data"`
+    } `json:"results"`
+  }
+  if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+    return nil, ErrDictionaryUnavailable.wrap(err)
+  }
+  words := make([]string, 0, len(body.Results.Data))
+  for _, w := range body.Results.Data {
+    // Multi-word entries ("ice cream") can't be played
+    if _alphaPattern.MatchString(w) {
+      words = append(words, strings.ToUpper(w))
+    }
+  }
+  return words, nil
+}
+
+// An in-memory set of words. Lookups never fail.
+type WordList struct {
+  words map[string]bool
+  // The same words, since going through a slice is much faster than a map
+  list []string
+}
+
+func NewWordList(words []string) *WordList {
+  wl := new(WordList)
+  wl.words = make(map[string]bool)
+  for _, w := range words {
+    wl.words[strings.ToUpper(strings.TrimSpace(w))] = true
+  }
+  delete(wl.words, "")
+  wl.list = make([]string, 0, len(wl.words))
+  for w := range wl.words {
+    wl.list = append(wl.list, w)
+  }
+  return wl
+}
+
+// Reads a word list with one word per line. Blank lines, lines starting with
+// '#' and anything that isn't letters only are ignored.
+func ReadWordList(r io.Reader) (*WordList, error) {
+  return readWordList(r, LanguageEnglish.Alphabet())
+}
+
+// Reads words in any alphabet, normalizing them as it goes
+func readWordList(r io.Reader, alphabet *Alphabet) (*WordList, error) {
+  words := make([]string, 0)
+  scanner := bufio.NewScanner(r)
+  for scanner.Scan() {
+    line := strings.TrimSpace(scanner.Text())
+    if len(line) == 0 || strings.HasPrefix(line, "#") {
+      continue
+    }
+    if line = alphabet.Normalize(line); !alphabet.IsLetters(line) {
+      continue
+    }
+    words = append(words, line)
+  }
+  if err := scanner.Err(); err != nil {
+    return nil, err
+  }
+  return NewWordList(words), nil
+}
+
+func LoadWordList(path string) (*WordList, error) {
+  f, err := os.Open(path)
+  if err != nil {
+    return nil, err
+  }
+  defer f.Close()
+  return ReadWordList(f)
+}
+
+func (wl *WordList) IsWord(word string) (bool, error) {
+  return wl.words[word], nil
+}
+
+// Returns matches in alphabetical order
+func (wl *WordList) WordsWithSubsequence(stem string,
+                                         limit int) ([]string, error) {
+  words := make([]string, 0)
+  for _, w := range wl.list {
+    if isSubsequence(stem, w) {
+      words = append(words, w)
+    }
+  }
+  sort.Strings(words)
+  if len(words) > limit {
+    words = words[:limit]
+  }
+  return words, nil
+}
+
+func (wl *WordList) Len() int {
+  return len(wl.words)
+}
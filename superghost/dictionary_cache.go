@@ -0,0 +1,334 @@
+package superghost
+
+import (
+  "container/list"
+  "encoding/json"
+  "os"
+  "sync"
+  "time"
+)
+
+const (
+  kDefaultCacheSize = 100000
+  // Dictionaries gain words now and then, so "not a word" is only trusted for
+  // so long. Words don't stop being words.
+  kDefaultNegativeTTL = 24 * time.Hour
+  kDefaultFailureThreshold = 3
+  kDefaultBreakerCooldown = 30 * time.Second
+)
+
+type DictionaryCacheConfig struct {
+  // The most words remembered at once. 0 means kDefaultCacheSize.
+  Size int
+  // How long a word is remembered as not being one. 0 means
+  // kDefaultNegativeTTL.
+  NegativeTTL time.Duration
+  // After this many failed lookups in a row, the remote dictionary isn't
+  // asked again until Cooldown has passed. 0 means kDefaultFailureThreshold.
+  FailureThreshold int
+  // 0 means kDefaultBreakerCooldown
+  Cooldown time.Duration
+  // Confirms words while the remote dictionary is failing. Lookups it doesn't
+  // know, or all of them if it's nil, fail with ErrDictionaryUnavailable.
+  Fallback Dictionary
+}
+
+// How well a CachedDictionary is doing since it was created
+type DictionaryStats struct {
+  Hits int
+  // Hits on words remembered as not being words
+  NegativeHits int
+  Misses int
+  // Hits / (Hits + Misses), or 0 before the first lookup
+  HitRatio float64
+  Entries int
+  Evictions int
+  // Lookups the remote dictionary failed, or wasn't asked because the
+  // breaker was open
+  RemoteErrors int
+  // Words confirmed by the fallback dictionary
+  Fallbacks int
+  BreakerOpen bool
+}
+
+type cacheEntry struct {
+  Word string
+  IsWord bool
+  // Zero for entries that never expire
+  Expires time.Time
+}
+
+// Wraps a remote dictionary (usually WordsAPI) with a least recently used cache
+// of its answers, and a circuit breaker that stops asking it while it's
+// failing. Only the remote dictionary's answers are cached; the fallback's
+// never are. Safe for concurrent use.
+type CachedDictionary struct {
+  remote Dictionary
+  config DictionaryCacheConfig
+
+  mutex sync.Mutex
+  // Most recently used at the front
+  order *list.List
+  entries map[string]*list.Element
+  breaker circuitBreaker
+  stats DictionaryStats
+
+  now func() time.Time
+}
+
+func NewCachedDictionary(remote Dictionary,
+                         config DictionaryCacheConfig) *CachedDictionary {
+  if config.Size <= 0 {
+    config.Size = kDefaultCacheSize
+  }
+  if config.NegativeTTL <= 0 {
+    config.NegativeTTL = kDefaultNegativeTTL
+  }
+  if config.FailureThreshold <= 0 {
+    config.FailureThreshold = kDefaultFailureThreshold
+  }
+  if config.Cooldown <= 0 {
+    config.Cooldown = kDefaultBreakerCooldown
+  }
+  d := new(CachedDictionary)
+  d.remote = remote
+  d.config = config
+  d.order = list.New()
+  d.entries = make(map[string]*list.Element)
+  d.now = time.Now
+  return d
+}
+
+func (d *CachedDictionary) IsWord(word string) (bool, error) {
+  d.mutex.Lock()
+  if isWord, ok := d.lookup(word); ok {
+    d.mutex.Unlock()
+    return isWord, nil
+  }
+  d.stats.Misses++
+  allowed := d.breaker.allow(d.now())
+  d.mutex.Unlock()
+
+  // The lock isn't held while the remote dictionary is asked, so a slow
+  // lookup in one room doesn't hold up the others
+  var isWord bool
+  var err error = ErrDictionaryUnavailable.withMessage(
+      "the dictionary is temporarily unavailable")
+  if allowed {
+    isWord, err = d.remote.IsWord(word)
+  }
+
+  d.mutex.Lock()
+  defer d.mutex.Unlock()
+
+  if allowed {
+    d.breaker.record(err == nil, d.now(), d.config.FailureThreshold,
+                     d.config.Cooldown)
+  }
+  if err == nil {
+    d.store(word, isWord)
+    return isWord, nil
+  }
+  d.stats.RemoteErrors++
+  if d.config.Fallback == nil {
+    return false, err
+  }
+  // The fallback is only a short list, so it can vouch for a word but not
+  // rule one out. A miss fails like any other lookup, so the ruling waits
+  // rather than going against the player.
+  if isWord, _ := d.config.Fallback.IsWord(word); !isWord {
+    return false, err
+  }
+  d.stats.Fallbacks++
+  return true, nil
+}
+
+// Searches the remote dictionary, or the fallback while the remote one is
+// failing. Searches aren't cached.
+func (d *CachedDictionary) WordsWithSubsequence(stem string,
+                                                limit int) ([]string, error) {
+  remote, ok := d.remote.(WordFinder)
+  if !ok {
+    return d.fallbackWordsWithSubsequence(stem, limit,
+                                          ErrDictionaryUnavailable)
+  }
+  d.mutex.Lock()
+  allowed := d.breaker.allow(d.now())
+  d.mutex.Unlock()
+  if !allowed {
+    return d.fallbackWordsWithSubsequence(stem, limit,
+                                          ErrDictionaryUnavailable)
+  }
+
+  words, err := remote.WordsWithSubsequence(stem, limit)
+
+  d.mutex.Lock()
+  d.breaker.record(err == nil, d.now(), d.config.FailureThreshold,
+                   d.config.Cooldown)
+  d.mutex.Unlock()
+  if err != nil {
+    return d.fallbackWordsWithSubsequence(stem, limit, err)
+  }
+  return words, nil
+}
+
+func (d *CachedDictionary) fallbackWordsWithSubsequence(
+    stem string, limit int, err error) ([]string, error) {
+  if finder, ok := d.config.Fallback.(WordFinder); ok {
+    return finder.WordsWithSubsequence(stem, limit)
+  }
+  return nil, err
+}
+
+func (d *CachedDictionary) Stats() DictionaryStats {
+  d.mutex.Lock()
+  defer d.mutex.Unlock()
+
+  stats := d.stats
+  if lookups := stats.Hits + stats.Misses; lookups > 0 {
+    stats.HitRatio = float64(stats.Hits) / float64(lookups)
+  }
+  stats.Entries = d.order.Len()
+  stats.BreakerOpen = d.breaker.isOpen(d.now())
+  return stats
+}
+
+// Writes the cache to path, most recently used first, so it can be loaded
+// again after a restart. The file is replaced all at once.
+func (d *CachedDictionary) Save(path string) error {
+  d.mutex.Lock()
+  entries := make([]cacheEntry, 0, d.order.Len())
+  for e := d.order.Front(); e != nil; e = e.Next() {
+    entries = append(entries, *e.Value.(*cacheEntry))
+  }
+  d.mutex.Unlock()
+
+  b, err := json.Marshal(entries)
+  if err != nil {
+    return err
+  }
+  tmp := path + ".tmp"
+  if err := os.WriteFile(tmp, b, 0644); err != nil {
+    return err
+  }
+  return os.Rename(tmp, path)
+}
+
+// Adds the entries saved at path to the cache. A missing file is not an error;
+// expired entries are skipped.
+func (d *CachedDictionary) Load(path string) error {
+  b, err := os.ReadFile(path)
+  if os.IsNotExist(err) {
+    return nil
+  } else if err != nil {
+    return err
+  }
+  var entries []cacheEntry
+  if err := json.Unmarshal(b, &entries); err != nil {
+    return err
+  }
+
+  d.mutex.Lock()
+  defer d.mutex.Unlock()
+
+  now := d.now()
+  // Oldest first, so the most recently used end up at the front again
+  for i := len(entries) - 1; i >= 0; i-- {
+    entry := entries[i]
+    if entry.Expires.IsZero() || now.Before(entry.Expires) {
+      d.insert(&entry)
+    }
+  }
+  return nil
+}
+
+// Returns the cached answer for word, if there's one that hasn't expired. The
+// lock must be held.
+func (d *CachedDictionary) lookup(word string) (isWord bool, ok bool) {
+  e, ok := d.entries[word]
+  if !ok {
+    return false, false
+  }
+  entry := e.Value.(*cacheEntry)
+  if !entry.Expires.IsZero() && !d.now().Before(entry.Expires) {
+    d.order.Remove(e)
+    delete(d.entries, word)
+    return false, false
+  }
+  d.order.MoveToFront(e)
+  d.stats.Hits++
+  if !entry.IsWord {
+    d.stats.NegativeHits++
+  }
+  return entry.IsWord, true
+}
+
+// The lock must be held
+func (d *CachedDictionary) store(word string, isWord bool) {
+  entry := &cacheEntry{ Word: word, IsWord: isWord }
+  if !isWord {
+    entry.Expires = d.now().Add(d.config.NegativeTTL)
+  }
+  d.insert(entry)
+}
+
+// Puts entry at the front, replacing any entry for the same word and evicting
+// the least recently used if the cache is full. The lock must be held.
+func (d *CachedDictionary) insert(entry *cacheEntry) {
+  if e, ok := d.entries[entry.Word]; ok {
+    e.Value = entry
+    d.order.MoveToFront(e)
+    return
+  }
+  d.entries[entry.Word] = d.order.PushFront(entry)
+  for d.order.Len() > d.config.Size {
+    oldest := d.order.Back()
+    d.order.Remove(oldest)
+    delete(d.entries, oldest.Value.(*cacheEntry).Word)
+    d.stats.Evictions++
+  }
+}
+
+// Counts failures in a row. Once there are enough, it opens and nothing is
+// allowed through until the cooldown has passed. Then a single lookup is let
+// through to see if things have recovered: if it fails, the breaker opens
+// again straight away.
+type circuitBreaker struct {
+  failures int
+  openUntil time.Time
+  // Whether a lookup is out testing the remote after a cooldown
+  probing bool
+}
+
+func (cb *circuitBreaker) isOpen(now time.Time) bool {
+  return now.Before(cb.openUntil)
+}
+
+func (cb *circuitBreaker) allow(now time.Time) bool {
+  if cb.isOpen(now) {
+    return false
+  }
+  if cb.openUntil.IsZero() {
+    return true
+  }
+  // The cooldown is over. Only one lookup gets to find out if it's working.
+  if cb.probing {
+    return false
+  }
+  cb.probing = true
+  return true
+}
+
+func (cb *circuitBreaker) record(ok bool, now time.Time, threshold int,
+                                 cooldown time.Duration) {
+  cb.probing = false
+  if ok {
+    cb.failures = 0
+    cb.openUntil = time.Time{}
+    return
+  }
+  cb.failures++
+  if cb.failures >= threshold || !cb.openUntil.IsZero() {
+    cb.openUntil = now.Add(cooldown)
+  }
+}
@@ -0,0 +1,92 @@
+package superghost
+
+import (
+  "fmt"
+)
+
+// Error is the type of every error a Room method returns to its caller. Code
+// is stable and machine-readable (clients can key translations off of it);
+// Message is a human-readable English description that may include details
+// about the specific request.
+type Error struct {
+  Code string
+  Message string
+
+  cause error
+}
+
+func (e *Error) Error() string {
+  return e.Message
+}
+
+func (e *Error) Unwrap() error {
+  return e.cause
+}
+
+// Two errors are the same for errors.Is purposes iff they share a code, so
+// callers can compare against the sentinels below regardless of the message.
+func (e *Error) Is(target error) bool {
+  t, ok := target.(*Error)
+  return ok && t.Code == e.Code
+}
+
+// Returns a copy of the sentinel with a more specific message.
+func (e *Error) withMessage(format string, a ...interface{}) *Error {
+  return &Error{ Code: e.Code, Message: fmt.Sprintf(format, a...) }
+}
+
+// Returns a copy of the sentinel that wraps the underlying cause.
+func (e *Error) wrap(cause error) *Error {
+  return &Error{ Code: e.Code, Message: e.Message, cause: cause }
+}
+
+func newError(code, message string) *Error {
+  return &Error{ Code: code, Message: message }
+}
+
+var (
+  ErrInvalidCredentials = newError("invalid-credentials",
+                                   "could not verify credentials")
+  ErrNotYourTurn = newError("not-your-turn", "it is not your turn")
+  ErrWrongState = newError("wrong-state", "cannot do that right now")
+  ErrInvalidMove = newError("invalid-move", "invalid move")
+  ErrBelowMinLength = newError("below-min-length",
+                               "minimum word length not met")
+  ErrEmptyStem = newError("empty-stem", "the stem is empty")
+  ErrInvalidWord = newError("invalid-word", "word is invalid format")
+  ErrWordUsed = newError("word-used", "word has already been used")
+  ErrDictionaryUnavailable = newError("dictionary-unavailable",
+                                      "the dictionary could not be reached")
+  ErrRoomFull = newError("room-full", "player limit reached")
+  ErrInvalidUsername = newError("invalid-username",
+                                "username must be alphanumeric")
+  ErrUsernameTaken = newError("username-taken", "username already in use")
+  ErrPlayerNotFound = newError("player-not-found", "player not found")
+  ErrNotHost = newError("not-host", "only the host can do that")
+  ErrEliminated = newError("eliminated", "cannot do that when eliminated")
+  ErrEmptyMessage = newError("empty-message", "empty message")
+  ErrMessageTooLong = newError("message-too-long", "message too long")
+  ErrMessageBlocked = newError("message-blocked",
+                               "the message was blocked by the chat filter")
+  ErrMessageNotFound = newError("message-not-found", "message not found")
+  ErrMuted = newError("muted", "the host has muted you")
+  ErrAlreadyLeaving = newError("already-leaving",
+                               "player already scheduled to leave")
+  ErrInvalidTeam = newError("invalid-team", "no such team")
+  ErrPaused = newError("paused", "the game is paused")
+  ErrNotJuror = newError("not-juror",
+                         "players in the challenge can't vote on it")
+  ErrNotPractice = newError("not-practice",
+                            "hints are only given in practice rooms")
+  ErrNoAnalysis = newError("no-analysis", "no analysis for that game")
+  ErrNotEntrant = newError("not-entrant",
+                           "only the players drawn into this room may join")
+  ErrNotOrganizer = newError("not-organizer",
+                             "only the tournament's organizer can do that")
+  ErrWrongPassword = newError("wrong-password", "wrong password")
+  ErrInvalidInvite = newError("invalid-invite",
+                              "the invite is invalid or has expired")
+  ErrNoPuzzle = newError("no-puzzle",
+                         "no puzzle could be made from the word list")
+  ErrInvalidLanguage = newError("invalid-language", "unknown language")
+)
@@ -0,0 +1,254 @@
+package superghost
+
+import (
+  "net/http"
+  "sort"
+)
+
+// The most moves a hint suggests
+const kMaxHintMoves = 3
+
+// How many words hints and analyses ask the dictionary for at a time
+const kMaxFoundWords = 100
+
+// What a player in a practice room is told when they ask for help
+type Hint struct {
+  // Moves that can be played without spelling a word or leaving the stem in
+  // no word at all, as far as the room's word index knows
+  Moves []HintMove
+  // A word containing the stem, or empty if the index doesn't know one
+  Example string `json:",omitempty"`
+}
+
+// One move that can be played: a letter added as Prefix or Suffix, Letter
+// inserted before the Index-th letter (superduperghost), or the stem reversed
+// (xghost)
+type HintMove struct {
+  Prefix string `json:",omitempty"`
+  Suffix string `json:",omitempty"`
+  Letter string `json:",omitempty"`
+  Index *int `json:",omitempty"`
+  Reverse bool `json:",omitempty"`
+}
+
+// The stem after the move
+func (m HintMove) apply(stem string) string {
+  switch {
+    case m.Reverse:
+      return reverse(stem)
+    case m.Index != nil:
+      letters := []rune(stem)
+      return string(letters[:*m.Index]) + m.Letter + string(letters[*m.Index:])
+    default:
+      return m.Prefix + stem + m.Suffix
+  }
+}
+
+// Helps the player whose turn it is. Only practice rooms give hints, and every
+// hint is counted and logged.
+func (r *Room) Hint(cookies []*http.Cookie) (*Hint, error) {
+  r.mutex.Lock()
+  defer r.mutex.Unlock()
+
+  r.updateLastTouch()
+
+  if !r.config.Practice {
+    return nil, ErrNotPractice
+  }
+  if _, ok := r.pm.getInTurnCookie(cookies); !ok {
+    return nil, ErrNotYourTurn
+  }
+  if r.isPaused {
+    return nil, ErrPaused
+  }
+  if r.state != kEdit {
+    return nil, ErrWrongState.withMessage(
+        "hints are only given when adding a letter")
+  }
+
+  hint := r.hint()
+  r.pm.currentPlayer().hintsUsed++
+  r.log.flush()
+  r.log.appendHint(r.pm.currentPlayerUsername())
+  return hint, nil
+}
+
+// The words hints and analyses are worked out from, and the room's rules for
+// playing them
+type wordIndex struct {
+  // Shared with every other room using the list, so it's never copied
+  list *WordList
+  // The room's allowed words, and any the dictionary found
+  extra map[string]bool
+  excluded map[string]bool
+  // Set when the room's dictionary isn't a word list but can search
+  finder WordFinder
+  variant Variant
+  minWordLength int
+  letters []string
+  allowsPrefixes bool
+}
+
+// Words already used this game are left out if they can't be played again and
+// excludeUsed is set
+func (r *Room) wordIndex(excludeUsed bool) *wordIndex {
+  var used map[string]bool
+  if excludeUsed && !r.config.AllowRepeatWords {
+    used = r.usedWords
+  }
+  return newWordIndex(r.config, r.alphabet, used)
+}
+
+// Looking words up online one at a time would take far too long, so rooms
+// without a word list of their own use their language's bundled one, plus
+// whatever their dictionary finds if it can search, plus their allowed words,
+// minus their blocked ones and any in used
+func newWordIndex(config *Config, alphabet *Alphabet,
+                  used map[string]bool) *wordIndex {
+  list, ok := config.Dictionary.(*WordList)
+  var finder WordFinder
+  if !ok {
+    list = config.Language.WordList()
+    finder, _ = config.Dictionary.(WordFinder)
+  }
+  wi := &wordIndex{
+    list: list,
+    extra: make(map[string]bool),
+    excluded: make(map[string]bool),
+    finder: finder,
+    variant: config.Variant,
+    minWordLength: config.MinWordLength,
+    letters: alphabet.letterList(),
+    allowsPrefixes: config.Variant.allowsPrefixes(),
+  }
+  for _, w := range config.AllowedWords {
+    wi.extra[w] = true
+  }
+  for _, w := range config.BlockedWords {
+    wi.excluded[w] = true
+  }
+  for w := range used {
+    wi.excluded[w] = true
+  }
+  return wi
+}
+
+func (wi *wordIndex) isWord(w string) bool {
+  return (wi.list.words[w] || wi.extra[w]) && !wi.excluded[w]
+}
+
+// Calls f with every word, in no particular order
+func (wi *wordIndex) each(f func(w string)) {
+  for _, w := range wi.list.list {
+    if !wi.excluded[w] {
+      f(w)
+    }
+  }
+  for w := range wi.extra {
+    if !wi.list.words[w] && !wi.excluded[w] {
+      f(w)
+    }
+  }
+}
+
+// Every word stem can still become, shortest (the easiest to see the stem in)
+// first
+func (wi *wordIndex) continuations(stem string) []string {
+  wi.find(stem)
+  words := make([]string, 0)
+  wi.each(func(w string) {
+    if wi.variant.continues(stem, w) {
+      words = append(words, w)
+    }
+  })
+  sort.Slice(words, func(i, j int) bool {
+    if letterCount(words[i]) != letterCount(words[j]) {
+      return letterCount(words[i]) < letterCount(words[j])
+    }
+    return words[i] < words[j]
+  })
+  return words
+}
+
+// Asks the dictionary for words stem can become. Every variant's
+// continuations contain the stem as a subsequence, so nothing is missed, and
+// continuations filters out the rest. The bundled list still answers if the
+// dictionary can't, so errors are ignored.
+func (wi *wordIndex) find(stem string) {
+  if wi.finder == nil || stem == "" {
+    return
+  }
+  words, err := wi.finder.WordsWithSubsequence(stem, kMaxFoundWords)
+  if err != nil {
+    return
+  }
+  for _, w := range words {
+    wi.extra[w] = true
+  }
+}
+
+// Up to limit moves that can be played on stem without spelling a word or
+// leaving it in no word at all (0 means no limit), and the shortest word stem
+// can become. Reversing is only suggested if canReverse is set. Moves that
+// make the same stem are only suggested once.
+func (wi *wordIndex) analyze(stem string, limit int,
+                             canReverse bool) (moves []HintMove,
+                                               example string) {
+  words := wi.continuations(stem)
+  if len(words) > 0 {
+    example = words[0]
+  }
+  // Letters added to a stem only narrow down its continuations, but a
+  // reversed stem can be in any word
+  safe := func(stem string, words []string) bool {
+    if wi.isWord(stem) && letterCount(stem) >= wi.minWordLength {
+      return false
+    }
+    for _, w := range words {
+      if wi.variant.continues(stem, w) {
+        return true
+      }
+    }
+    return false
+  }
+
+  moves = make([]HintMove, 0)
+  suggested := make(map[string]bool)
+  try := func(move HintMove, words []string) {
+    next := move.apply(stem)
+    if !suggested[next] && safe(next, words) {
+      suggested[next] = true
+      moves = append(moves, move)
+    }
+  }
+  for _, letter := range wi.letters {
+    if limit > 0 && len(moves) >= limit {
+      break
+    }
+    try(HintMove{ Suffix: letter }, words)
+    if wi.allowsPrefixes {
+      try(HintMove{ Prefix: letter }, words)
+    }
+    if wi.variant.allowsInsertion() {
+      // The ends are already covered by the prefix and suffix
+      for i := 1; i < letterCount(stem); i++ {
+        index := i
+        try(HintMove{ Letter: letter, Index: &index }, words)
+      }
+    }
+  }
+  if canReverse && reverse(stem) != stem {
+    try(HintMove{ Reverse: true }, wi.continuations(reverse(stem)))
+  }
+  if limit > 0 && len(moves) > limit {
+    moves = moves[:limit]
+  }
+  return moves, example
+}
+
+func (r *Room) hint() *Hint {
+  canReverse := r.config.Variant.allowsReversal() && !r.isReversed
+  moves, example := r.wordIndex(true).analyze(r.stem, kMaxHintMoves,
+                                               canReverse)
+  return &Hint{ Moves: moves, Example: example }
+}
@@ -0,0 +1,193 @@
+package superghost
+
+import (
+  "net/http"
+  "strings"
+  "time"
+)
+
+// In jury mode, a dictionary ruling is put to the players who weren't part of
+// the challenge before the round is scored
+type jury struct {
+  word string
+  isWord bool  // The dictionary's ruling
+  // Who loses the round if the ruling stands, and if it's overturned
+  loser string
+  overturnedLoser string
+  // Each juror's vote: true to overturn
+  votes map[string]bool
+  deadline time.Time
+  closeCh chan struct{}
+}
+
+type JJury struct {
+  Word string
+  IsWord bool
+  Jurors []string
+  Deadline time.Time
+  VotesToOverturn int
+  VotesToUphold int
+}
+
+func (j *jury) tally() (overturn int, uphold int) {
+  for _, v := range j.votes {
+    if v {
+      overturn++
+    } else {
+      uphold++
+    }
+  }
+  return overturn, uphold
+}
+
+// Scores a dictionary ruling on word or, in jury mode, puts it to a vote first.
+// The player who was challenged is the last player.
+func (r *Room) rule(word string, isWord bool) {
+  loser := r.pm.currentPlayerUsername()
+  overturnedLoser := r.pm.lastPlayerUsername
+  if isWord {
+    loser, overturnedLoser = overturnedLoser, loser
+  }
+  var definition *Definition
+  if isWord && r.config.Definitions != nil {
+    definition = r.config.Definitions.Define(word)
+  }
+  r.log.appendChallengeResult(strings.ToUpper(word), isWord, loser,
+                              definition)
+
+  j := &jury {
+    word: word,
+    isWord: isWord,
+    loser: loser,
+    overturnedLoser: overturnedLoser,
+    votes: make(map[string]bool),
+  }
+  if r.config.JuryTime == 0 || len(r.jurors(j)) == 0 {
+    r.settleRuling(word, isWord, loser)
+    return
+  }
+  r.convene(j)
+}
+
+func (r *Room) settleRuling(word string, isWord bool, loser string) {
+  if isWord {
+    r.usedWords[word] = true
+  }
+  if p, ok := r.pm.usernameToPlayer[loser]; ok && r.penalize(p) {
+    r.log.appendElimination(p.sideName())
+  }
+  r.endRound()
+}
+
+// Everyone but the two players in the challenge
+func (r *Room) jurors(j *jury) []string {
+  var jurors []string
+  for _, p := range r.pm.players {
+    if p.username != j.loser && p.username != j.overturnedLoser {
+      jurors = append(jurors, p.username)
+    }
+  }
+  return jurors
+}
+
+// Starts the vote, which closes when everyone has voted or time runs out
+func (r *Room) convene(j *jury) {
+  j.deadline = time.Now().Add(r.config.JuryTime)
+  j.closeCh = make(chan struct{})
+  r.jury = j
+  r.state = kJury
+
+  timesUp := time.NewTimer(r.config.JuryTime)
+  go func() {
+    select {
+      case <-timesUp.C:
+        r.mutex.Lock()
+        defer r.mutex.Unlock()
+
+        if r.jury != j {
+          return  // Closed while we waited for the mutex
+        }
+        r.log.flush()
+        r.closeJury()
+        r.notifyAsyncUpdate()
+
+      case <-j.closeCh:
+        timesUp.Stop()
+    }
+  }()
+}
+
+// Tallies the votes and scores the round. A majority of the votes cast
+// overturns the ruling; a tie lets it stand.
+func (r *Room) closeJury() {
+  j := r.jury
+  r.dismissJury()
+
+  overturn, uphold := j.tally()
+  isOverturned := overturn > uphold
+  isWord, loser := j.isWord, j.loser
+  if isOverturned {
+    isWord, loser = !isWord, j.overturnedLoser
+  }
+  r.log.appendJuryVerdict(strings.ToUpper(j.word), isOverturned, loser,
+                          overturn, uphold)
+  r.settleRuling(j.word, isWord, loser)
+}
+
+// Ends the vote without a verdict
+func (r *Room) dismissJury() {
+  if r.jury != nil {
+    close(r.jury.closeCh)  // This will stop the timer thread
+    r.jury = nil
+  }
+}
+
+// Closes the vote early if every juror still here has voted
+func (r *Room) closeJuryIfDone() {
+  for _, username := range r.jurors(r.jury) {
+    if _, ok := r.jury.votes[username]; !ok {
+      return
+    }
+  }
+  r.closeJury()
+}
+
+// Votes on the dictionary's ruling in jury mode: overturn it or let it stand.
+// Only players who weren't part of the challenge may vote, and they can change
+// their vote until it closes.
+func (r *Room) VoteOnRuling(cookies []*http.Cookie, overturn bool) error {
+  r.mutex.Lock()
+  defer r.mutex.Unlock()
+
+  r.updateLastTouch()
+
+  username, ok := r.pm.getValidCookie(cookies)
+  if !ok {
+    return ErrInvalidCredentials
+  }
+  if r.state != kJury {
+    return ErrWrongState.withMessage("there is no ruling to vote on")
+  }
+  if username == r.jury.loser || username == r.jury.overturnedLoser {
+    return ErrNotJuror
+  }
+
+  r.jury.votes[username] = overturn
+  r.log.flush()
+  r.closeJuryIfDone()
+  return nil
+}
+
+func (r *Room) jJury() *JJury {
+  if r.jury == nil {
+    return nil
+  }
+  jj := &JJury {
+    Word: strings.ToUpper(r.jury.word),
+    IsWord: r.jury.isWord,
+    Jurors: r.jurors(r.jury),
+    Deadline: r.jury.deadline,
+  }
+  jj.VotesToOverturn, jj.VotesToUphold = r.jury.tally()
+  return jj
+}
@@ -10,6 +10,8 @@ const (
   kChallengedPlayerLeft logItemType = "ChallengedPlayerLeft"
   kRebuttal logItemType = "Rebut"
   kAffix logItemType = "Affix"
+  kInsert logItemType = "Insert"
+  kReverse logItemType = "Reverse"
   kConcede logItemType = "Concede"
   kEliminated logItemType = "Eliminated"
   kKick logItemType = "Kick"
@@ -18,26 +20,44 @@ const (
   kTimeout logItemType = "Timeout"
   kInsufficientPlayers logItemType = "InsufficientPlayers"
   kReadyUp logItemType = "ReadyUp"
+  kSuddenDeath logItemType = "SuddenDeath"
+  kPause logItemType = "Pause"
+  kResume logItemType = "Resume"
+  kJuryVerdict logItemType = "JuryVerdict"
+  kHint logItemType = "Hint"
 )
 
-type logItem struct {
+type LogItem struct {
   Type logItemType
   From string `json:",omitempty"`
   To string `json:",omitempty"`
   Prefix string `json:",omitempty"`
   Suffix string `json:",omitempty"`
   Stem string `json:",omitempty"`
+  // The letter inserted and where (for kInsert)
+  Letter string `json:",omitempty"`
+  Index *int `json:",omitempty"`
+  // A rebuttal given as a whole word rather than a prefix and suffix
+  Word string `json:",omitempty"`
   Success *bool `json:",omitempty"`
+  // How a jury voted on a ruling (for kJuryVerdict)
+  VotesToOverturn int `json:",omitempty"`
+  VotesToUphold int `json:",omitempty"`
+  // What the word means, when a challenge finds it's one (for
+  // kChallengeResult)
+  Definition *Definition `json:",omitempty"`
+  // Which of the room's games just ended (for kGameOver)
+  Game int `json:",omitempty"`
 }
 
 type BufferedLog struct {
-  history []logItem
+  history []LogItem
   itemsPushed int  // The number of log items already sent to clients
 }
 
 func newBufferedLog() *BufferedLog {
   bl := new(BufferedLog)
-  bl.history = make([]logItem, 0)
+  bl.history = make([]LogItem, 0)
   return bl
 }
 
@@ -46,7 +66,7 @@ func (bl *BufferedLog) flush() {
 }
 
 func (bl *BufferedLog) appendJoin(username string) {
-  bl.history = append(bl.history, logItem{
+  bl.history = append(bl.history, LogItem{
                         Type: kJoin,
                         From: username,
                       })
@@ -54,20 +74,23 @@ func (bl *BufferedLog) appendJoin(username string) {
 
 func (bl *BufferedLog) appendChallengeIsWord(challenger string,
                                              recipient string) {
-  bl.history = append(bl.history, logItem{
+  bl.history = append(bl.history, LogItem{
                         Type: kChallengeIsWord,
                         From: challenger,
                         To: recipient,
                       })
 }
 
+// definition is nil if the stem isn't a word or isn't defined
 func (bl *BufferedLog) appendChallengeResult(stem string, isWord bool,
-                                             loser string) {
-  tmp := logItem{
+                                             loser string,
+                                             definition *Definition) {
+  tmp := LogItem{
     Type: kChallengeResult,
     Stem: stem,
     Success: new(bool),
     To: loser,
+    Definition: definition,
   }
   *tmp.Success = isWord
   bl.history = append(bl.history, tmp)
@@ -75,7 +98,7 @@ func (bl *BufferedLog) appendChallengeResult(stem string, isWord bool,
 
 func (bl *BufferedLog) appendChallengedPlayerLeft(challenger,
                                                   recipient string) {
-  bl.history = append(bl.history, logItem{
+  bl.history = append(bl.history, LogItem{
                         Type: kChallengedPlayerLeft,
                         From: challenger,
                         To: recipient,
@@ -84,7 +107,7 @@ func (bl *BufferedLog) appendChallengedPlayerLeft(challenger,
 
 func (bl *BufferedLog) appendChallengeContinuation(challenger,
                                                    recipient string) {
-  bl.history = append(bl.history, logItem{
+  bl.history = append(bl.history, LogItem{
                         Type: kChallengeContinuation,
                         From: challenger,
                         To: recipient,
@@ -92,7 +115,7 @@ func (bl *BufferedLog) appendChallengeContinuation(challenger,
 }
 
 func (bl *BufferedLog) appendRebuttal(username, stem, prefix, suffix string) {
-  bl.history = append(bl.history, logItem{
+  bl.history = append(bl.history, LogItem{
                         Type: kRebuttal,
                         From: username,
                         Stem: stem,
@@ -102,7 +125,7 @@ func (bl *BufferedLog) appendRebuttal(username, stem, prefix, suffix string) {
 }
 
 func (bl *BufferedLog) appendAffixation(username, prefix, stem, suffix string) {
-  bl.history = append(bl.history, logItem{
+  bl.history = append(bl.history, LogItem{
                         Type: kAffix,
                         From: username,
                         Stem: stem,
@@ -111,60 +134,133 @@ func (bl *BufferedLog) appendAffixation(username, prefix, stem, suffix string) {
                       })
 }
 
+func (bl *BufferedLog) appendInsertion(username, stem string, index int,
+                                       letter string) {
+  tmp := LogItem{
+    Type: kInsert,
+    From: username,
+    Stem: stem,
+    Letter: letter,
+    Index: new(int),
+  }
+  *tmp.Index = index
+  bl.history = append(bl.history, tmp)
+}
+
+// stem is the stem before it was reversed
+func (bl *BufferedLog) appendReversal(username, stem string) {
+  bl.history = append(bl.history, LogItem{
+                        Type: kReverse,
+                        From: username,
+                        Stem: stem,
+                      })
+}
+
+func (bl *BufferedLog) appendWordRebuttal(username, stem, word string) {
+  bl.history = append(bl.history, LogItem{
+                        Type: kRebuttal,
+                        From: username,
+                        Stem: stem,
+                        Word: word,
+                      })
+}
+
 func (bl *BufferedLog) appendLeave(username string) {
-  bl.history = append(bl.history, logItem{
+  bl.history = append(bl.history, LogItem{
                         Type: kLeave,
                         From: username,
                       })
 }
 
 func (bl *BufferedLog) appendConcession(username string) {
-  bl.history = append(bl.history, logItem{
+  bl.history = append(bl.history, LogItem{
                         Type: kConcede,
                         From: username,
                       })
 }
 
 func (bl *BufferedLog) appendElimination(username string) {
-  bl.history = append(bl.history, logItem{
+  bl.history = append(bl.history, LogItem{
                         Type: kEliminated,
                         From: username,
                       })
 }
 
 func (bl *BufferedLog) appendKick(from, to string) {
-  bl.history = append(bl.history, logItem{
+  bl.history = append(bl.history, LogItem{
                         Type: kKick,
                         From: from,
                         To: to,
                       })
 }
 
-func (bl *BufferedLog) appendGameOver(username string) {
-bl.history = append(bl.history, logItem{
+// game is the game's number, for looking up its analysis
+func (bl *BufferedLog) appendGameOver(username string, game int) {
+bl.history = append(bl.history, LogItem{
                       Type: kGameOver,
                       To: username,
+                      Game: game,
                     })
 }
 
 func (bl *BufferedLog) appendTimeout(username string) {
-  bl.history = append(bl.history, logItem{
+  bl.history = append(bl.history, LogItem{
                         Type: kTimeout,
                         From: username,
                       })
 }
 
 func (bl *BufferedLog) appendInsufficientPlayers() {
-  bl.history = append(bl.history, logItem{ Type: kInsufficientPlayers })
+  bl.history = append(bl.history, LogItem{ Type: kInsufficientPlayers })
 }
 
 func (bl *BufferedLog) appendGameStart() {
-  bl.history = append(bl.history, logItem{ Type: kGameStart })
+  bl.history = append(bl.history, LogItem{ Type: kGameStart })
+}
+
+func (bl *BufferedLog) appendSuddenDeath() {
+  bl.history = append(bl.history, LogItem{ Type: kSuddenDeath })
 }
 
 func (bl *BufferedLog) appendReadyUp(username string) {
-  bl.history = append(bl.history, logItem{
+  bl.history = append(bl.history, LogItem{
                         Type: kReadyUp,
                         From: username,
                       })
 }
+
+func (bl *BufferedLog) appendPause(username string) {
+  bl.history = append(bl.history, LogItem{
+                        Type: kPause,
+                        From: username,
+                      })
+}
+
+func (bl *BufferedLog) appendResume(username string) {
+  bl.history = append(bl.history, LogItem{
+                        Type: kResume,
+                        From: username,
+                      })
+}
+
+// Success is whether the ruling on stem was overturned
+func (bl *BufferedLog) appendJuryVerdict(stem string, isOverturned bool,
+                                         loser string, overturn, uphold int) {
+  tmp := LogItem{
+    Type: kJuryVerdict,
+    Stem: stem,
+    Success: new(bool),
+    To: loser,
+    VotesToOverturn: overturn,
+    VotesToUphold: uphold,
+  }
+  *tmp.Success = isOverturned
+  bl.history = append(bl.history, tmp)
+}
+
+func (bl *BufferedLog) appendHint(username string) {
+  bl.history = append(bl.history, LogItem{
+                        Type: kHint,
+                        From: username,
+                      })
+}
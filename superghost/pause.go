@@ -0,0 +1,94 @@
+package superghost
+
+import (
+  "net/http"
+  "sort"
+)
+
+// Stops the clock until the game is resumed. The host pauses straight away;
+// anyone else's request is a vote, and the game pauses once every player but
+// the host has voted.
+func (r *Room) Pause(cookies []*http.Cookie) error {
+  r.mutex.Lock()
+  defer r.mutex.Unlock()
+
+  r.updateLastTouch()
+
+  username, ok := r.pm.getValidCookie(cookies)
+  if !ok {
+    return ErrInvalidCredentials
+  }
+  if r.state == kWaitingToStart || r.state == kJury || r.isPaused {
+    return ErrWrongState.withMessage("cannot pause right now")
+  }
+  if !r.votePause(username) {
+    return nil
+  }
+
+  r.isPaused = true
+  r.pauseVotes = make(map[string]bool)
+  // The player keeps whatever time they had left
+  r.isClockPaused = r.pm.doesDeadlineExist()
+  if r.isClockPaused {
+    r.stopClock(0)
+  }
+  r.log.flush()
+  r.log.appendPause(username)
+  return nil
+}
+
+// Undoes Pause, by the host or by the other players' unanimous vote. The current player's clock
+// restarts from what they had left when the game was paused (a rebuttal on
+// its own clock starts over).
+func (r *Room) Resume(cookies []*http.Cookie) error {
+  r.mutex.Lock()
+  defer r.mutex.Unlock()
+
+  r.updateLastTouch()
+
+  username, ok := r.pm.getValidCookie(cookies)
+  if !ok {
+    return ErrInvalidCredentials
+  }
+  if !r.isPaused {
+    return ErrWrongState.withMessage("the game isn't paused")
+  }
+  if !r.votePause(username) {
+    return nil
+  }
+
+  r.isPaused = false
+  r.pauseVotes = make(map[string]bool)
+  r.log.flush()
+  r.log.appendResume(username)
+  if r.isClockPaused {
+    r.isClockPaused = false
+    r.startTurnAndCountdown(r.pm.currentPlayerUsername())
+  }
+  return nil
+}
+
+// Records username's vote to pause or resume. Returns whether that carries it:
+// the host decides alone, or else every other player has to agree.
+func (r *Room) votePause(username string) bool {
+  host := r.pm.hostPlayer().username
+  if username == host {
+    return true
+  }
+  r.pauseVotes[username] = true
+  votes := len(r.pauseVotes)
+  // A player who voted and then became host doesn't count twice
+  if r.pauseVotes[host] {
+    votes--
+  }
+  return votes >= len(r.pm.players) - 1
+}
+
+func (r *Room) pauseVoters() []string {
+  voters := make([]string, 0, len(r.pauseVotes))
+  for username := range r.pauseVotes {
+    voters = append(voters, username)
+  }
+  sort.Strings(voters)
+  return voters
+}
@@ -10,8 +10,13 @@ type Player struct {
   username string
   cookie *http.Cookie
 
+  // Unused when the player is on a team, which keeps score instead
   score uint
   isEliminated bool
+  team *team
+
+  // How many hints the player has asked for (practice rooms only)
+  hintsUsed int
 
   // Not a countdown timer-- only accurate when it is not this player's turn
   timeRemaining time.Duration
@@ -22,15 +27,29 @@ type JPlayer struct {
   Score uint
   IsEliminated bool
   TimeRemaining time.Duration
+  // The score as the room's scoring rule shows it, e.g. "GH---"
+  ScoreDisplay string `json:",omitempty"`
+  // 0 when the room isn't playing in teams
+  Team int `json:",omitempty"`
+  HintsUsed int `json:",omitempty"`
 }
 
 func (p *Player) MarshalJSON() ([]byte, error) {
-  return json.Marshal(JPlayer {
+  return json.Marshal(p.jPlayer())
+}
+
+func (p *Player) jPlayer() JPlayer {
+  jp := JPlayer {
     Username: p.username,
-    Score: p.score,
-    IsEliminated: p.isEliminated,
+    Score: p.points(),
+    IsEliminated: p.eliminated(),
     TimeRemaining: p.timeRemaining,
-  })
+    HintsUsed: p.hintsUsed,
+  }
+  if p.team != nil {
+    jp.Team = p.team.number
+  }
+  return jp
 }
 
 func NewPlayer(username string, path string,
@@ -43,11 +62,47 @@ func NewPlayer(username string, path string,
   return p
 }
 
-func (p *Player) incrementScore(eliminationThreshold int) (isEliminated bool) {
-  p.score++
-  if eliminationThreshold > 0 && int(p.score) >= eliminationThreshold {
+// The player's score, or their team's
+func (p *Player) points() uint {
+  if p.team != nil {
+    return p.team.score
+  }
+  return p.score
+}
+
+func (p *Player) eliminated() bool {
+  if p.team != nil {
+    return p.team.isEliminated
+  }
+  return p.isEliminated
+}
+
+// Who wins or loses along with the player: their team, or just them
+func (p *Player) sideName() string {
+  if p.team != nil {
+    return p.team.name()
+  }
+  return p.username
+}
+
+func (p *Player) incrementScore(points uint,
+                                sp scoringPolicy) (isEliminated bool) {
+  if p.team != nil {
+    return p.team.incrementScore(points, sp)
+  }
+  p.score += points
+  if sp.isEliminated(p.score) {
     p.isEliminated = true
   }
   return p.isEliminated
 }
 
+// Knocks the player (or their team) out regardless of score
+func (p *Player) eliminate() {
+  if p.team != nil {
+    p.team.isEliminated = true
+  } else {
+    p.isEliminated = true
+  }
+}
+
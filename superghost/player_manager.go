@@ -1,7 +1,6 @@
 package superghost
 
 import (
-  "fmt"
   "net/http"
   "time"
 )
@@ -9,6 +8,9 @@ import (
 type playerManager struct {
   players []*Player
   usernameToPlayer map[string]*Player
+  // Empty unless the room plays in teams
+  teams []*team
+  scoring scoringPolicy
 
   currentPlayerIdx int
   currentPlayerDeadline time.Time
@@ -17,13 +19,100 @@ type playerManager struct {
   startingPlayerIdx int
 }
 
-func newPlayerManager() *playerManager {
+// Fewer than 2 teams means no teams at all
+func newPlayerManager(teamCount int, scoring scoringPolicy) *playerManager {
   pm := new(playerManager)
+  pm.scoring = scoring
   pm.players = make([]*Player, 0)
   pm.usernameToPlayer = make(map[string]*Player)
+  pm.teams = make([]*team, 0)
+  for i := 1; teamCount >= 2 && i <= teamCount; i++ {
+    pm.teams = append(pm.teams, newTeam(i))
+  }
   return pm
 }
 
+func (pm *playerManager) hasTeams() bool {
+  return len(pm.teams) > 0
+}
+
+// The team's players in the order they joined
+func (pm *playerManager) members(t *team) []*Player {
+  members := make([]*Player, 0)
+  for _, p := range pm.players {
+    if p.team == t {
+      members = append(members, p)
+    }
+  }
+  return members
+}
+
+// How many players (or teams with players) there are to play against each
+// other
+func (pm *playerManager) sides() int {
+  if !pm.hasTeams() {
+    return len(pm.players)
+  }
+  n := 0
+  for _, t := range pm.teams {
+    if len(pm.members(t)) > 0 {
+      n++
+    }
+  }
+  return n
+}
+
+func (pm *playerManager) indexOf(p *Player) int {
+  for i := range pm.players {
+    if pm.players[i] == p {
+      return i
+    }
+  }
+  return -1
+}
+
+// The team that plays after the one at fromIdx, skipping eliminated and empty
+// teams, and the member of it whose turn it is. Not ok if every other team is
+// out.
+func (pm *playerManager) nextTeamPlayer(fromIdx int) (*Player, bool) {
+  from := pm.players[fromIdx % len(pm.players)]
+  for k := 1; k < len(pm.teams); k++ {
+    t := pm.teams[(from.team.number - 1 + k) % len(pm.teams)]
+    members := pm.members(t)
+    if t.isEliminated || len(members) == 0 {
+      continue
+    }
+    // Whoever comes after the last member to play (or the first member if
+    // they've left)
+    next := 0
+    for i, p := range members {
+      if p == t.lastPlayer {
+        next = (i + 1) % len(members)
+      }
+    }
+    return members[next], true
+  }
+  return nil, false
+}
+
+func (pm *playerManager) jTeams() []JTeam {
+  jTeams := make([]JTeam, len(pm.teams))
+  for i, t := range pm.teams {
+    jTeams[i] = JTeam {
+      Number: t.number,
+      Name: t.name(),
+      Score: t.score,
+      ScoreDisplay: pm.scoring.display(t.score),
+      IsEliminated: t.isEliminated,
+      Usernames: make([]string, 0),
+    }
+    for _, p := range pm.members(t) {
+      jTeams[i].Usernames = append(jTeams[i].Usernames, p.username)
+    }
+  }
+  return jTeams
+}
+
 func (pm *playerManager) currentPlayerUsername() string {
   if len(pm.players) == 0 {
     return ""
@@ -35,40 +124,77 @@ func (pm *playerManager) currentPlayer() *Player {
   return pm.players[pm.currentPlayerIdx]
 }
 
+func (pm *playerManager) jPlayers() []JPlayer {
+  jPlayers := make([]JPlayer, len(pm.players))
+  for i, p := range pm.players {
+    jPlayers[i] = p.jPlayer()
+    jPlayers[i].ScoreDisplay = pm.scoring.display(p.points())
+  }
+  return jPlayers
+}
+
 func (pm *playerManager) hostPlayer() *Player {
   return pm.players[0]
 }
 
+// teamNumber picks the player's team (from 1). 0 puts them on the smallest
+// team, or on none if the room isn't playing in teams.
 func (pm *playerManager) addPlayer(username string, path string,
-                                   startingTime time.Duration) (
-    *http.Cookie, error) {
-  if !_usernamePattern.MatchString(username) {
-    return nil, fmt.Errorf("username must be alphanumeric")
+                                   startingTime time.Duration,
+                                   teamNumber int) (*http.Cookie, error) {
+  if !IsValidUsername(username) {
+    return nil, ErrInvalidUsername
   }
   if _, ok := pm.usernameToPlayer[username]; ok {
-    return nil, fmt.Errorf("username '%s' already in use", username)
+    return nil, ErrUsernameTaken.withMessage("username '%s' already in use",
+                                             username)
+  }
+
+  var t *team
+  if pm.hasTeams() {
+    if teamNumber < 0 || teamNumber > len(pm.teams) {
+      return nil, ErrInvalidTeam.withMessage(
+          "team must be between 1 and %d", len(pm.teams))
+    }
+    if teamNumber == 0 {
+      t = pm.smallestTeam()
+    } else {
+      t = pm.teams[teamNumber - 1]
+    }
+  } else if teamNumber != 0 {
+    return nil, ErrInvalidTeam.withMessage("this room isn't playing in teams")
   }
 
   p := NewPlayer(username, path, startingTime)
+  p.team = t
   pm.players = append(pm.players, p)
   pm.usernameToPlayer[username] = p
 
   return p.cookie, nil
 }
 
+func (pm *playerManager) smallestTeam() *team {
+  smallest := pm.teams[0]
+  for _, t := range pm.teams[1:] {
+    if len(pm.members(t)) < len(pm.members(smallest)) {
+      smallest = t
+    }
+  }
+  return smallest
+}
+
 func (pm *playerManager) removePlayer(username string) error {
   for i, p := range pm.players {
     if p.username == username {
       return pm.removePlayerByIdx(i)
-      break
     }
   }
-  return fmt.Errorf("player not found")
+  return ErrPlayerNotFound
 }
 
 func (pm *playerManager) removePlayerByIdx(index int) error {
   if index > len(pm.players) {
-    return fmt.Errorf("index out of bounds")
+    return ErrPlayerNotFound.withMessage("index out of bounds")
   }
   if index < pm.currentPlayerIdx {
     pm.currentPlayerIdx--
@@ -125,10 +251,20 @@ func (pm *playerManager) incrementCurrentPlayer() (ok bool) {
     pm.currentPlayerIdx = 0  // Seems extremely unlikely but I'd rather be safe
     return false
   }
+  if pm.hasTeams() {
+    current := pm.players[pm.currentPlayerIdx]
+    current.team.lastPlayer = current
+    p, ok := pm.nextTeamPlayer(pm.currentPlayerIdx)
+    if ok {
+      pm.lastPlayerUsername = pm.players[pm.currentPlayerIdx].username
+      pm.currentPlayerIdx = pm.indexOf(p)
+    }
+    return ok
+  }
   for i := (pm.currentPlayerIdx + 1) % len(pm.players);
       i != pm.currentPlayerIdx;
       i = (i + 1) % len(pm.players) {
-    if !pm.players[i].isEliminated {
+    if !pm.players[i].eliminated() {
       pm.lastPlayerUsername = pm.players[pm.currentPlayerIdx].username
       pm.currentPlayerIdx = i
       return true
@@ -142,10 +278,18 @@ func (pm *playerManager) incrementStartingPlayer() (ok bool) {
   if len(pm.players) == 0 {
     return false
   }
+  if pm.hasTeams() {
+    p, ok := pm.nextTeamPlayer(pm.startingPlayerIdx)
+    if ok {
+      pm.lastPlayerUsername = ""
+      pm.startingPlayerIdx = pm.indexOf(p)
+    }
+    return ok
+  }
   for i := (pm.startingPlayerIdx + 1) % len(pm.players);
       i != pm.startingPlayerIdx;
       i = (i + 1) % len(pm.players) {
-    if !pm.players[i].isEliminated {
+    if !pm.players[i].eliminated() {
       pm.lastPlayerUsername = ""
       pm.startingPlayerIdx = i
       return true
@@ -171,18 +315,27 @@ func (pm *playerManager) resetScores() {
     p.score = 0
     p.isEliminated = false
   }
+  for _, t := range pm.teams {
+    t.score = 0
+    t.isEliminated = false
+    t.lastPlayer = nil
+  }
 }
 
 func (pm *playerManager) allScoresAreZero() bool {
   for _, p := range pm.players {
-    if p.score > 0 {
+    if p.points() > 0 {
       return false
     }
   }
   return true
 }
 
+// In team play, this is about teams: the winner is the last team standing.
 func (pm *playerManager) onlyOnePlayerNotEliminated() (string, bool) {
+  if pm.hasTeams() {
+    return pm.onlyOneTeamNotEliminated()
+  }
   nRemaining := 0
   var winner string
   for _, p := range pm.players {
@@ -197,22 +350,64 @@ func (pm *playerManager) onlyOnePlayerNotEliminated() (string, bool) {
   return winner, true
 }
 
+func (pm *playerManager) onlyOneTeamNotEliminated() (string, bool) {
+  nRemaining := 0
+  var winner string
+  for _, t := range pm.teams {
+    if !t.isEliminated && len(pm.members(t)) > 0 {
+      nRemaining++
+      if nRemaining > 1 {
+        return "", false
+      }
+      winner = t.name()
+    }
+  }
+  return winner, true
+}
+
+// The scores of whoever is still in the game: every player who isn't
+// eliminated, or in team play, every team with players that isn't
+func (pm *playerManager) remainingScores() []uint {
+  scores := make([]uint, 0)
+  if pm.hasTeams() {
+    for _, t := range pm.teams {
+      if !t.isEliminated && len(pm.members(t)) > 0 {
+        scores = append(scores, t.score)
+      }
+    }
+    return scores
+  }
+  for _, p := range pm.players {
+    if !p.isEliminated {
+      scores = append(scores, p.score)
+    }
+  }
+  return scores
+}
+
 func (pm *playerManager) resetPlayerTimes(startingTime time.Duration) {
   for _, p :=  range pm.players {
     p.timeRemaining = startingTime
   }
 }
 
-func (pm *playerManager) updateDeadline() {
-  pm.currentPlayerDeadline = time.Now().Add(pm.currentPlayer().timeRemaining)
+func (pm *playerManager) updateDeadline(d time.Duration) {
+  pm.currentPlayerDeadline = time.Now().Add(d)
   return
 }
 
-func (pm *playerManager) endTurn() {
+// increment is added to the player's bank if they were on the clock
+func (pm *playerManager) endTurn(increment time.Duration) {
   // Update that player's remaining time according to how much time they used
-  // if the timer was running during their turn
+  // if the timer was running during their turn. Any of the delay they didn't
+  // use is lost rather than banked.
   if pm.doesDeadlineExist() {
-    pm.currentPlayer().timeRemaining = time.Until(pm.currentPlayerDeadline)
+    p := pm.currentPlayer()
+    remaining := time.Until(pm.currentPlayerDeadline)
+    if remaining > p.timeRemaining {
+      remaining = p.timeRemaining
+    }
+    p.timeRemaining = remaining + increment
   }
   // The deadline is now invalid-- make sure it looks like a bug if it gets
   // reused (because it is!)
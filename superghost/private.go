@@ -0,0 +1,157 @@
+package superghost
+
+import (
+  "crypto/hmac"
+  "crypto/rand"
+  "crypto/sha256"
+  "crypto/subtle"
+  "encoding/base64"
+  "net/http"
+  "strconv"
+  "strings"
+  "time"
+
+  "golang.org/x/crypto/scrypt"
+)
+
+const (
+  kDefaultInviteLifetime = 24 * time.Hour
+  // Longer lifetimes are cut down to this
+  kMaxInviteLifetime = 7 * 24 * time.Hour
+  // scrypt cost parameters. Each guess at a password costs tens of
+  // milliseconds and 32MB.
+  kScryptN = 1 << 15
+  kScryptR = 8
+  kScryptP = 1
+  kPasswordHashLength = 32
+)
+
+// What a player shows to get into a room with a password: the password, or an
+// invite from the host. Rooms without a password let anyone in.
+type JoinCredentials struct {
+  Password string
+  Invite string
+  // The player's cookies. Tournament match rooms look for the one the player
+  // was given when they registered.
+  Cookies []*http.Cookie
+}
+
+// A token that lets whoever has it into the room without the password, until
+// it expires
+type Invite struct {
+  Token string
+  Expires time.Time
+}
+
+// The password is salted and hashed, so the config never holds it. The lock
+// must be held.
+func (r *Room) setPassword(password string) {
+  if password == "" {
+    return
+  }
+  r.passwordSalt = make([]byte, 16)
+  if _, err := rand.Read(r.passwordSalt); err != nil {
+    panic(err)
+  }
+  r.passwordHash = r.hashPassword(password)
+}
+
+// Deliberately slow, so a leaked hash is hard to brute force
+func (r *Room) hashPassword(password string) []byte {
+  key, err := scrypt.Key([]byte(password), r.passwordSalt,
+                         kScryptN, kScryptR, kScryptP, kPasswordHashLength)
+  if err != nil {
+    // Only for bad parameters, which are constant
+    panic(err)
+  }
+  return key
+}
+
+// Checks credentials against the room's password, or its invites. The lock
+// must be held.
+func (r *Room) admit(credentials JoinCredentials) error {
+  if credentials.Invite != "" {
+    if !r.isValidInvite(credentials.Invite, time.Now()) {
+      return ErrInvalidInvite
+    }
+    return nil
+  }
+  if r.passwordHash == nil {
+    return nil
+  }
+  if credentials.Password == "" {
+    return ErrWrongPassword.withMessage(
+        "this room needs a password or an invite")
+  }
+  if subtle.ConstantTimeCompare(r.hashPassword(credentials.Password),
+                                r.passwordHash) != 1 {
+    return ErrWrongPassword
+  }
+  return nil
+}
+
+// Rooms with a password only show their state, chat, config and analyses to
+// their players. Anyone can see the rest.
+func (r *Room) CheckViewer(cookies []*http.Cookie) error {
+  r.mutex.RLock()
+  defer r.mutex.RUnlock()
+
+  if r.passwordHash == nil {
+    return nil
+  }
+  if _, ok := r.pm.getValidCookie(cookies); !ok {
+    return ErrInvalidCredentials.withMessage(
+        "only the room's players can see a room with a password")
+  }
+  return nil
+}
+
+// Makes an invite lasting lifetime (0 means a day, and nothing lasts more
+// than a week). Only the host can invite players. Invites aren't kept: each
+// one is signed with a key only the room knows, so it can't be forged or
+// extended, and all of them stop working when the room goes away.
+func (r *Room) Invite(cookies []*http.Cookie,
+                      lifetime time.Duration) (*Invite, error) {
+  r.mutex.Lock()
+  defer r.mutex.Unlock()
+
+  r.updateLastTouch()
+
+  username, ok := r.pm.getValidCookie(cookies)
+  if !ok {
+    return nil, ErrInvalidCredentials
+  }
+  if username != r.pm.hostPlayer().username {
+    return nil, ErrNotHost.withMessage("only the host can invite players")
+  }
+  if lifetime <= 0 {
+    lifetime = kDefaultInviteLifetime
+  }
+  if lifetime > kMaxInviteLifetime {
+    lifetime = kMaxInviteLifetime
+  }
+  expires := time.Now().Add(lifetime).Truncate(time.Second)
+  expiry := strconv.FormatInt(expires.Unix(), 10)
+  return &Invite{ Token: expiry + "." + r.signInvite(expiry),
+                  Expires: expires }, nil
+}
+
+func (r *Room) signInvite(expiry string) string {
+  mac := hmac.New(sha256.New, r.inviteKey)
+  mac.Write([]byte(expiry))
+  return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Tokens are the expiry, in seconds since the epoch, and its signature
+func (r *Room) isValidInvite(token string, now time.Time) bool {
+  parts := strings.SplitN(token, ".", 2)
+  if len(parts) != 2 {
+    return false
+  }
+  expiry, signature := parts[0], parts[1]
+  seconds, err := strconv.ParseInt(expiry, 10, 64)
+  if err != nil || !now.Before(time.Unix(seconds, 0)) {
+    return false
+  }
+  return hmac.Equal([]byte(signature), []byte(r.signInvite(expiry)))
+}
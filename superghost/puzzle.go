@@ -0,0 +1,367 @@
+package superghost
+
+import (
+  "hash/fnv"
+  "net/http"
+  "sort"
+  "sync"
+  "time"
+)
+
+const (
+  kPuzzleStemLength = 3
+  kPuzzleMinWordLength = 4
+  // Stems with fewer continuations than this are too hard to be fun, and with
+  // more than the max too easy
+  kMinPuzzleSolutions = 2
+  kMaxPuzzleSolutions = 20
+  kPuzzleCookieName = "puzzle-player"
+  // Long enough that streaks survive a holiday
+  kPuzzleCookieLifetime = 365 * 24 * time.Hour
+)
+
+// Dates are in UTC, so everyone gets the same puzzle on the same day
+const kPuzzleDateFormat = "2006-01-02"
+
+// One day's puzzle: find words containing Stem
+type Puzzle struct {
+  Date string
+  Stem string
+  // How many words in the puzzle's word list contain the stem. Words found
+  // in the dictionary but not the list count too, so this isn't a limit.
+  Solutions int
+  MinWordLength int
+}
+
+// Where a player is with today's puzzle, and with the ones before it
+type PuzzleProgress struct {
+  Puzzle Puzzle
+  // Words found today, in the order they were found
+  Found []string
+  // Guesses today that weren't words
+  Misses int
+  // Days in a row, up to today or yesterday, with at least one word found
+  Streak int
+  LongestStreak int
+}
+
+// The result of one guess. Guesses that aren't words aren't errors; like a
+// rebuttal, they just don't count.
+type PuzzleGuess struct {
+  Word string
+  IsWord bool
+  Progress PuzzleProgress
+}
+
+type puzzlePlayer struct {
+  // The day found and misses are for
+  date string
+  found []string
+  misses int
+  // The last day a word was found
+  lastSolved string
+  streak int
+  longestStreak int
+}
+
+// Makes a puzzle each day from a word list, and keeps track of the players
+// solving them. Players are known by a cookie rather than a username, since
+// there's no room to join. Like rooms, players are only kept in memory, and
+// only from their first guess until their streak has lapsed. Safe for
+// concurrent use.
+type PuzzleBook struct {
+  // Where guesses not in the word list are looked up. Nil means only words in
+  // the list are accepted. Set it before the book is used.
+  Dictionary Dictionary
+
+  language Language
+  alphabet *Alphabet
+
+  mutex sync.Mutex
+  // Every stem with a good number of continuations, sorted, or nil until
+  // they're first needed
+  stems []string
+  // Stem to the words in the list containing it
+  continuations map[string][]string
+  players map[string]*puzzlePlayer
+  // The day players were last evicted
+  evictedOn string
+
+  now func() time.Time
+}
+
+// Puzzles are made from language's bundled word list
+func NewPuzzleBook(language Language) *PuzzleBook {
+  if !language.IsValid() {
+    language = LanguageEnglish
+  }
+  pb := new(PuzzleBook)
+  pb.language = language
+  pb.alphabet = language.Alphabet()
+  pb.players = make(map[string]*puzzlePlayer)
+  pb.now = time.Now
+  return pb
+}
+
+// Returns the ID of the player cookies belong to, or an empty string for new
+// players (and ones from before a restart or whose streak has lapsed)
+func (pb *PuzzleBook) Identify(cookies []*http.Cookie) string {
+  pb.mutex.Lock()
+  defer pb.mutex.Unlock()
+
+  for _, c := range cookies {
+    if _, ok := pb.players[c.Value]; c.Name == kPuzzleCookieName && ok {
+      return c.Value
+    }
+  }
+  return ""
+}
+
+// Today's puzzle
+func (pb *PuzzleBook) Today() (*Puzzle, error) {
+  pb.mutex.Lock()
+  defer pb.mutex.Unlock()
+
+  return pb.puzzle(pb.today())
+}
+
+// New players haven't found anything yet
+func (pb *PuzzleBook) Progress(playerID string) (*PuzzleProgress, error) {
+  pb.mutex.Lock()
+  defer pb.mutex.Unlock()
+
+  player, ok := pb.players[playerID]
+  if !ok {
+    player = new(puzzlePlayer)
+  }
+  return pb.progress(player)
+}
+
+// Guesses prefix + stem + suffix for today's puzzle, checked like a rebuttal.
+// A new player's first checked guess is where they start being kept: they're
+// given a cookie scoped to path, which must be set for them to keep their
+// streak.
+func (pb *PuzzleBook) Guess(playerID string, path string, prefix string,
+                            suffix string) (*PuzzleGuess, *http.Cookie,
+                                            error) {
+  date, word, used, err := pb.prepareGuess(playerID, prefix, suffix)
+  if err != nil {
+    return nil, nil, err
+  }
+  // Every player shares the lock, so it isn't held while the dictionary is
+  // asked
+  isWord, err := validateWord(word, used, false, pb.dictionary(), pb.alphabet)
+  if err != nil {
+    return nil, nil, err
+  }
+
+  pb.mutex.Lock()
+  defer pb.mutex.Unlock()
+
+  today := pb.today()
+  if today != date {
+    return nil, nil, ErrWrongState.withMessage(
+        "a new puzzle started while the word was being checked")
+  }
+  pb.evictLapsedPlayers(today)
+  var cookie *http.Cookie
+  player, ok := pb.players[playerID]
+  if !ok {
+    cookie = new(http.Cookie)
+    cookie.Name = kPuzzleCookieName
+    cookie.Value = GetRandBase64String(32)
+    cookie.Expires = pb.now().Add(kPuzzleCookieLifetime)
+    cookie.Path = path
+    player = new(puzzlePlayer)
+    pb.players[cookie.Value] = player
+  }
+  pb.startDay(player, today)
+  for _, w := range player.found {
+    // Found by another guess while this one was being looked up
+    if w == word {
+      return nil, nil, ErrWordUsed
+    }
+  }
+  if isWord {
+    player.found = append(player.found, word)
+    if player.lastSolved != today {
+      if player.lastSolved == pb.yesterday() {
+        player.streak++
+      } else {
+        player.streak = 1
+      }
+      player.lastSolved = today
+      if player.streak > player.longestStreak {
+        player.longestStreak = player.streak
+      }
+    }
+  } else {
+    player.misses++
+  }
+  progress, err := pb.progress(player)
+  if err != nil {
+    return nil, nil, err
+  }
+  return &PuzzleGuess{ Word: word, IsWord: isWord, Progress: *progress },
+         cookie, nil
+}
+
+// Checks everything about a guess except whether it's a word. Returns the
+// puzzle's date, the word and the words the player has already found.
+func (pb *PuzzleBook) prepareGuess(
+    playerID string, prefix string,
+    suffix string) (date string, word string, used map[string]bool,
+                    err error) {
+  pb.mutex.Lock()
+  defer pb.mutex.Unlock()
+
+  today := pb.today()
+  puzzle, err := pb.puzzle(today)
+  if err != nil {
+    return "", "", nil, err
+  }
+  used = make(map[string]bool)
+  if player, ok := pb.players[playerID]; ok {
+    pb.startDay(player, today)
+    for _, w := range player.found {
+      used[w] = true
+    }
+  }
+
+  if prefix == "" && suffix == "" {
+    return "", "", nil, ErrInvalidMove.withMessage(
+        "add at least one letter to '%s'", puzzle.Stem)
+  }
+  prefix, suffix = pb.alphabet.Normalize(prefix), pb.alphabet.Normalize(suffix)
+  word = prefix + puzzle.Stem + suffix
+  if letterCount(word) < puzzle.MinWordLength {
+    return "", "", nil, ErrBelowMinLength
+  }
+  return today, word, used, nil
+}
+
+// The lock must be held
+func (pb *PuzzleBook) progress(player *puzzlePlayer) (*PuzzleProgress, error) {
+  today := pb.today()
+  puzzle, err := pb.puzzle(today)
+  if err != nil {
+    return nil, err
+  }
+  pb.startDay(player, today)
+  progress := &PuzzleProgress{
+    Puzzle: *puzzle,
+    Found: append(make([]string, 0, len(player.found)), player.found...),
+    Misses: player.misses,
+    LongestStreak: player.longestStreak,
+  }
+  // A streak is still alive until a whole day goes by without a word
+  if player.lastSolved == today || player.lastSolved == pb.yesterday() {
+    progress.Streak = player.streak
+  }
+  return progress, nil
+}
+
+// Clears yesterday's guesses. The lock must be held.
+func (pb *PuzzleBook) startDay(player *puzzlePlayer, today string) {
+  if player.date != today {
+    player.date = today
+    player.found = make([]string, 0)
+    player.misses = 0
+  }
+}
+
+// Forgets players who haven't been back since before yesterday, once a day.
+// Their streak has lapsed, so all that's lost is their longest one. The lock
+// must be held.
+func (pb *PuzzleBook) evictLapsedPlayers(today string) {
+  if pb.evictedOn == today {
+    return
+  }
+  pb.evictedOn = today
+  yesterday := pb.yesterday()
+  for id, player := range pb.players {
+    if player.date != today && player.date != yesterday {
+      delete(pb.players, id)
+    }
+  }
+}
+
+// Words are checked against the list first, which is what the puzzle was made
+// from, and only then the dictionary
+func (pb *PuzzleBook) dictionary() Dictionary {
+  list := pb.language.WordList()
+  if pb.Dictionary == nil {
+    return list
+  }
+  return puzzleDictionary{ list: list, fallback: pb.Dictionary }
+}
+
+type puzzleDictionary struct {
+  list *WordList
+  fallback Dictionary
+}
+
+func (d puzzleDictionary) IsWord(word string) (bool, error) {
+  if isWord, _ := d.list.IsWord(word); isWord {
+    return true, nil
+  }
+  return d.fallback.IsWord(word)
+}
+
+func (pb *PuzzleBook) today() string {
+  return pb.now().UTC().Format(kPuzzleDateFormat)
+}
+
+func (pb *PuzzleBook) yesterday() string {
+  return pb.now().UTC().AddDate(0, 0, -1).Format(kPuzzleDateFormat)
+}
+
+// The puzzle for date. The same date always gets the same stem, for as long
+// as the word list stays the same. The lock must be held.
+func (pb *PuzzleBook) puzzle(date string) (*Puzzle, error) {
+  if pb.stems == nil {
+    pb.findStems()
+  }
+  if len(pb.stems) == 0 {
+    return nil, ErrNoPuzzle
+  }
+  h := fnv.New64a()
+  h.Write([]byte(date))
+  stem := pb.stems[h.Sum64() % uint64(len(pb.stems))]
+  return &Puzzle{
+    Date: date,
+    Stem: stem,
+    Solutions: len(pb.continuations[stem]),
+    MinWordLength: kPuzzleMinWordLength,
+  }, nil
+}
+
+// Finds every stem of kPuzzleStemLength letters that has between
+// kMinPuzzleSolutions and kMaxPuzzleSolutions continuations in the word list.
+// The lock must be held.
+func (pb *PuzzleBook) findStems() {
+  pb.continuations = make(map[string][]string)
+  for word := range pb.language.WordList().words {
+    letters := []rune(word)
+    if len(letters) < kPuzzleMinWordLength {
+      continue
+    }
+    seen := make(map[string]bool)
+    for i := 0; i + kPuzzleStemLength <= len(letters); i++ {
+      stem := string(letters[i:i + kPuzzleStemLength])
+      if !seen[stem] {
+        seen[stem] = true
+        pb.continuations[stem] = append(pb.continuations[stem], word)
+      }
+    }
+  }
+  pb.stems = make([]string, 0)
+  for stem, words := range pb.continuations {
+    if len(words) >= kMinPuzzleSolutions && len(words) <= kMaxPuzzleSolutions {
+      pb.stems = append(pb.stems, stem)
+    } else {
+      delete(pb.continuations, stem)
+    }
+  }
+  sort.Strings(pb.stems)
+}
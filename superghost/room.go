@@ -1,8 +1,8 @@
 package superghost
 
 import (
+  "crypto/rand"
   "encoding/json"
-  "fmt"
   "net/http"
   "strings"
   "sync"
@@ -14,6 +14,8 @@ const (
   kEdit State = iota
   kRebut
   kWaitingToStart
+  // Players are voting on a dictionary ruling (jury mode)
+  kJury
 )
 func (p State) String() string {
   switch p {
@@ -23,24 +25,126 @@ func (p State) String() string {
       return "rebut"
     case kWaitingToStart:
       return "waiting to start"
+    case kJury:
+      return "jury"
     default:
       panic("invalid State value")
   }
 }
 
+// Which rules a room plays by
+type Variant string
+const (
+  // Letters may be added to either end of the stem
+  VariantSuperghost Variant = "superghost"
+  // Letters may only be added to the end of the stem, so every word must
+  // start with it
+  VariantGhost Variant = "ghost"
+  // Letters may also be inserted anywhere in the stem, so every word must
+  // contain its letters in order
+  VariantSuperduperghost Variant = "superduperghost"
+  // Superghost, except that once per round a player may reverse the stem
+  // instead of adding a letter
+  VariantXghost Variant = "xghost"
+)
+
+func (v Variant) IsValid() bool {
+  return v == VariantSuperghost || v == VariantGhost ||
+      v == VariantSuperduperghost || v == VariantXghost
+}
+
+func (v Variant) allowsPrefixes() bool {
+  return v != VariantGhost
+}
+
+func (v Variant) allowsInsertion() bool {
+  return v == VariantSuperduperghost
+}
+
+func (v Variant) allowsReversal() bool {
+  return v == VariantXghost
+}
+
+// Whether word is a valid continuation of stem under these rules
+func (v Variant) continues(stem, word string) bool {
+  switch v {
+    case VariantGhost:
+      return strings.HasPrefix(word, stem)
+    case VariantSuperduperghost:
+      return isSubsequence(stem, word)
+    default:
+      return strings.Contains(word, stem)
+  }
+}
+
 type Config struct {
+  Variant Variant
   MaxPlayers int
   MinWordLength int
   IsPublic bool
   EliminationThreshold int
   AllowRepeatWords bool
+  // Each player's time per round, refilled when a round starts
   PlayerTimePerWord time.Duration
+  // Each player's time for the whole game; replaces PlayerTimePerWord. A
+  // player who runs out loses the round and starts over with a full bank.
+  PlayerTimePerGame time.Duration
+  // Added to a player's bank after each of their moves (Fischer)
+  TimeIncrement time.Duration
+  // How long each turn runs before the player's bank starts counting down
+  // (Bronstein). Unused delay isn't banked.
+  TimeDelay time.Duration
+  // Rebuttals are played on their own clock of this long, leaving the
+  // player's bank alone. 0 means rebuttals use the bank like any other turn.
+  RebuttalTime time.Duration
   PauseAtRoundStart bool
-}
-
-type Message struct {
-  Sender string
-  Content string
+  // How long players outside a challenge get to vote on overturning the
+  // dictionary's ruling before the round is scored. 0 means no vote.
+  JuryTime time.Duration
+  // Words accepted or rejected whatever the dictionary says (jargon, names,
+  // profanity, ...). Upper case and sorted; the host can change them between
+  // games.
+  AllowedWords []string `json:",omitempty"`
+  BlockedWords []string `json:",omitempty"`
+  // Play in this many teams, which share a score. 0 (or 1) means everyone
+  // plays for themselves.
+  TeamCount int
+  // How rounds are scored; empty means ScoringLetters
+  Scoring ScoringRule
+  // With ScoringLetters, players are out once they've spelled this (so it
+  // replaces EliminationThreshold). Empty means use EliminationThreshold.
+  EliminationWord string
+  // When the last two players are tied and one loss from elimination, the
+  // next round eliminates its loser whatever the score
+  SuddenDeath bool
+  // Players may ask for hints on their turn. Hints are counted and logged, so
+  // games in practice rooms don't count for much.
+  Practice bool
+  // Which letters can be played and, unless Dictionary is set, which
+  // dictionary is used. Empty means LanguageEnglish.
+  Language Language
+  // Only these usernames may join, as in a tournament match. Empty means
+  // anyone may.
+  Entrants []string `json:",omitempty"`
+  // Players need this, or an invite from the host, to join. The room only
+  // keeps a hash of it, so it's never sent back.
+  Password string `json:",omitempty"`
+  // Give the room an ID too long to guess, rather than one short enough to
+  // read out. Private rooms should use it.
+  LongID bool `json:",omitempty"`
+
+  // Where words are looked up. Not part of the JSON config; nil means the
+  // language's own dictionary (WordsAPIDictionary for English).
+  Dictionary Dictionary `json:"-"`
+  // Where words found in challenges are defined. Not part of the JSON config;
+  // nil means the language's bundled definitions, if it has any.
+  Definitions Definer `json:"-"`
+  // What chat goes through before anyone sees it. Not part of the JSON
+  // config; nil means messages aren't filtered.
+  ChatFilter ChatFilter `json:"-"`
+  // The cookie values a tournament gave its entrants when they registered,
+  // by username. Entrants without one are known by username alone.
+  entrantCookies map[string]string
 }
 
 type Room struct {
@@ -50,66 +154,117 @@ type Room struct {
 
   stem string
   state State
+  // Whether someone has reversed the stem this round (xghost)
+  isReversed bool
+  scoring scoringPolicy
+  // Whether this round's loser is out no matter what
+  isSuddenDeath bool
+  isPaused bool
+  // Whether the current player's clock was running when the game was paused
+  isClockPaused bool
+  // Who has voted to pause (or, when paused, to resume)
+  pauseVotes map[string]bool
+  // The vote on the last ruling, while in kJury
+  jury *jury
   usedWords map[string]bool
+  // The config's dictionary with its custom words applied
+  dictionary Dictionary
+  alphabet *Alphabet
 
   log *BufferedLog
+  // Where in the log the current game started
+  gameLogStart int
+  // One for each game that has finished with a winner, in order
+  games []*finishedGame
 
   mutex sync.RWMutex
 
   endTurnCh chan struct{}
   asyncUpdateCh chan<- struct{}
+  // Closed by Teardown, after which nothing listens on asyncUpdateCh
+  doneCh chan struct{}
   usernameToCancelLeaveCh map[string]chan struct{}
 
   turnID int;
 
+  // The most recent kChatHistoryLength messages, oldest first
+  chat []Message
+  lastMessageID int
+  muted map[string]bool
+
+  // Nil if the room has no password
+  passwordHash []byte
+  passwordSalt []byte
+  // Signs invites
+  inviteKey []byte
+
   lastTouch time.Time
 }
 
 type JRoom struct { // publicly visible version of gamestate
-  Players []*Player
+  Players []JPlayer
+  Teams []JTeam `json:",omitempty"`
+  SuddenDeath bool `json:",omitempty"`
+  Paused bool `json:",omitempty"`
+  // Who has voted to pause or resume the game so far
+  PauseVotes []string `json:",omitempty"`
+  Jury *JJury `json:",omitempty"`
+  // Players the host has muted
+  Muted []string `json:",omitempty"`
   Stem string
   State string
   CurrentPlayerUsername string
   CurrentPlayerDeadline time.Time
   LastPlayerUsername string
   StartingPlayerIdx int
-  LogPush []logItem
+  LogPush []LogItem
+  // The most recent chat messages, oldest first. Only in the full state (the
+  // one with the whole log), so players see what was said before they came.
+  Chat []Message `json:",omitempty"`
 }
 
-func (r *Room) MarshalJSON() ([]byte, error) {
-  r.mutex.RLock()
-  defer r.mutex.RUnlock()
-
-  return json.Marshal(JRoom {
-    Players: r.pm.players,
+// The lock must be held
+func (r *Room) jRoom(logPush []LogItem) JRoom {
+  return JRoom {
+    Players: r.pm.jPlayers(),
+    Teams: r.pm.jTeams(),
     Stem: strings.ToUpper(r.stem),
     State: r.state.String(),
     CurrentPlayerUsername: r.pm.currentPlayerUsername(),
     CurrentPlayerDeadline: r.pm.currentPlayerDeadline,
     LastPlayerUsername: r.pm.lastPlayerUsername,
     StartingPlayerIdx: r.pm.startingPlayerIdx,
-    LogPush: r.log.history[r.log.itemsPushed:],
-  })
+    SuddenDeath: r.isSuddenDeath,
+    Paused: r.isPaused,
+    PauseVotes: r.pauseVoters(),
+    Jury: r.jJury(),
+    Muted: r.mutedUsernames(),
+    LogPush: logPush,
+  }
+}
+
+func (r *Room) MarshalJSON() ([]byte, error) {
+  r.mutex.RLock()
+  defer r.mutex.RUnlock()
+
+  return json.Marshal(r.jRoom(r.log.history[r.log.itemsPushed:]))
 }
 
 func (r *Room) MarshalJSONConfig() ([]byte, error) {
+  r.mutex.RLock()
+  defer r.mutex.RUnlock()
+
   return json.Marshal(r.config)
 }
 
+// The whole log, and the chat history
 func (r *Room) MarshalJSONFullLog() ([]byte, error) {
   r.mutex.RLock()
   defer r.mutex.RUnlock()
 
-  return json.Marshal(JRoom {
-    Players: r.pm.players,
-    Stem: strings.ToUpper(r.stem),
-    State: r.state.String(),
-    CurrentPlayerUsername: r.pm.currentPlayerUsername(),
-    CurrentPlayerDeadline: r.pm.currentPlayerDeadline,
-    LastPlayerUsername: r.pm.lastPlayerUsername,
-    StartingPlayerIdx: r.pm.startingPlayerIdx,
-    LogPush: r.log.history,
-  })
+  room := r.jRoom(r.log.history)
+  room.Chat = append(make([]Message, 0, len(r.chat)), r.chat...)
+  return json.Marshal(room)
 }
 
 type JRoomMetadata struct {
@@ -117,20 +272,81 @@ type JRoomMetadata struct {
   MaxPlayers int
   EliminationThreshold int
   MinWordLength int
+  Variant Variant
+  Language Language
+  TeamCount int `json:",omitempty"`
+  Practice bool `json:",omitempty"`
+  // Whether players need a password or an invite to join
+  HasPassword bool `json:",omitempty"`
   ID string
 }
 
-func NewRoom(config Config, asyncUpdateCh chan<- struct{}) *Room {
+// Fails if the config's language isn't one rooms can be played in
+func NewRoom(config Config, asyncUpdateCh chan<- struct{}) (*Room, error) {
+  if config.Language == "" {
+    config.Language = LanguageEnglish
+  }
+  if !config.Language.IsValid() {
+    return nil, ErrInvalidLanguage.withMessage("unknown language '%s'",
+                                               config.Language)
+  }
+
   r := new(Room)
 
   r.config = new(Config)
+  r.config.Variant = config.Variant
+  if r.config.Variant == "" {
+    r.config.Variant = VariantSuperghost
+  }
   r.config.MaxPlayers = config.MaxPlayers
   r.config.MinWordLength = config.MinWordLength
   r.config.IsPublic = config.IsPublic
   r.config.EliminationThreshold = config.EliminationThreshold
   r.config.PlayerTimePerWord = config.PlayerTimePerWord
+  r.config.PlayerTimePerGame = config.PlayerTimePerGame
+  r.config.TimeIncrement = config.TimeIncrement
+  r.config.TimeDelay = config.TimeDelay
+  r.config.RebuttalTime = config.RebuttalTime
+  r.config.AllowRepeatWords = config.AllowRepeatWords
+  r.config.PauseAtRoundStart = config.PauseAtRoundStart
+  r.config.JuryTime = config.JuryTime
+  r.config.TeamCount = config.TeamCount
+  r.config.Scoring = config.Scoring
+  if r.config.Scoring == "" {
+    r.config.Scoring = ScoringLetters
+  }
+  r.config.EliminationWord = strings.ToUpper(config.EliminationWord)
+  r.config.SuddenDeath = config.SuddenDeath
+  r.config.Practice = config.Practice
+  r.config.Entrants = append([]string(nil), config.Entrants...)
+  r.config.entrantCookies = config.entrantCookies
+  r.config.LongID = config.LongID
+  r.setPassword(config.Password)
+  r.inviteKey = make([]byte, 32)
+  if _, err := rand.Read(r.inviteKey); err != nil {
+    panic(err)
+  }
+  r.config.Language = config.Language
+  r.alphabet = r.config.Language.Alphabet()
+  r.config.Dictionary = config.Dictionary
+  if r.config.Dictionary == nil {
+    r.config.Dictionary = r.config.Language.defaultDictionary()
+  }
+  r.config.Definitions = config.Definitions
+  if r.config.Definitions == nil {
+    r.config.Definitions = r.config.Language.defaultDefinitions()
+  }
+  r.config.ChatFilter = config.ChatFilter
+  // Callers should have checked these already, so just drop bad words
+  r.config.AllowedWords, _ = NormalizeWordList(config.AllowedWords,
+                                               r.alphabet)
+  r.config.BlockedWords, _ = NormalizeWordList(config.BlockedWords,
+                                               r.alphabet)
+  r.dictionary = newCustomWords(r.config.Dictionary, r.config.AllowedWords,
+                                r.config.BlockedWords)
 
   r.asyncUpdateCh = asyncUpdateCh
+  r.doneCh = make(chan struct{})
   // The default value, but for clarity I am explicitly making this the case.
   // Iff this is non-nil, a turn is in progress and this channel is being
   // listened to.
@@ -138,11 +354,14 @@ func NewRoom(config Config, asyncUpdateCh chan<- struct{}) *Room {
   r.usernameToCancelLeaveCh = make(map[string]chan struct{})
 
   r.turnID = 0
-  r.pm = newPlayerManager()
+  r.scoring = newScoringPolicy(r.config)
+  r.pm = newPlayerManager(r.config.TeamCount, r.scoring)
   r.waitToStart()
   r.usedWords = make(map[string]bool)
   r.log = newBufferedLog()
-  return r
+  r.chat = make([]Message, 0)
+  r.muted = make(map[string]bool)
+  return r, nil
 }
 
 func (r *Room) Metadata(ID string) JRoomMetadata {
@@ -154,6 +373,11 @@ func (r *Room) Metadata(ID string) JRoomMetadata {
     MaxPlayers: r.config.MaxPlayers,
     EliminationThreshold: r.config.EliminationThreshold,
     MinWordLength: r.config.MinWordLength,
+    Variant: r.config.Variant,
+    Language: r.config.Language,
+    TeamCount: r.config.TeamCount,
+    Practice: r.config.Practice,
+    HasPassword: r.passwordHash != nil,
     ID: ID,
   }
 }
@@ -162,6 +386,18 @@ func (r *Room) IsPublic() bool {
   return r.config.IsPublic
 }
 
+// The usernames of the players in the room, in turn order
+func (r *Room) Usernames() []string {
+  r.mutex.RLock()
+  defer r.mutex.RUnlock()
+
+  usernames := make([]string, 0, len(r.pm.players))
+  for _, p := range r.pm.players {
+    usernames = append(usernames, p.username)
+  }
+  return usernames
+}
+
 func (r *Room) LastTouch() time.Time {
   return r.lastTouch
 }
@@ -179,16 +415,42 @@ func (r *Room) GetValidCookie(cookies []*http.Cookie) (string, bool) {
 
 
 func (r *Room) AddPlayer(username string, path string) (*http.Cookie, error) {
+  return r.AddPlayerToTeam(username, path, 0)
+}
+
+// Like AddPlayer, but in team play the player can pick their team (numbered
+// from 1). 0 picks the smallest team.
+func (r *Room) AddPlayerToTeam(username string, path string,
+                               teamNumber int) (*http.Cookie, error) {
+  return r.AddPlayerWithCredentials(username, path, teamNumber,
+                                    JoinCredentials{})
+}
+
+// Like AddPlayerToTeam, for rooms with a password
+func (r *Room) AddPlayerWithCredentials(
+    username string, path string, teamNumber int,
+    credentials JoinCredentials) (*http.Cookie, error) {
   r.mutex.Lock()
   defer r.mutex.Unlock()
 
   r.updateLastTouch()
 
+  if err := r.admit(credentials); err != nil {
+    return nil, err
+  }
   if len(r.pm.players) >= r.config.MaxPlayers {
-    return nil, fmt.Errorf("player limit reached")
+    return nil, ErrRoomFull
+  }
+  if !r.isEntrant(username) {
+    return nil, ErrNotEntrant
+  }
+  if !r.hasEntrantCookie(username, credentials.Cookies) {
+    return nil, ErrNotEntrant.withMessage(
+        "join with the cookie you were given when you registered")
   }
 
-  cookie, err := r.pm.addPlayer(username, path, r.config.PlayerTimePerWord)
+  cookie, err := r.pm.addPlayer(username, path, r.config.startingTime(),
+                               teamNumber)
   if err != nil {
     return nil, err
   }
@@ -196,14 +458,26 @@ func (r *Room) AddPlayer(username string, path string) (*http.Cookie, error) {
   r.log.flush()
   r.log.appendJoin(username)
 
-  // Start game if enough players have joined
-  if len(r.pm.players) >= 2 {
+  // Start game if enough players (or teams) have joined
+  if r.state == kWaitingToStart && r.pm.sides() >= 2 {
     r.state = kEdit
   }
 
   return cookie, nil
 }
 
+func (r *Room) isEntrant(username string) bool {
+  if len(r.config.Entrants) == 0 {
+    return true
+  }
+  for _, entrant := range r.config.Entrants {
+    if entrant == username {
+      return true
+    }
+  }
+  return false
+}
+
 func (r *Room) ChallengeIsWord(cookies []*http.Cookie) error {
   r.mutex.Lock()
   defer r.mutex.Unlock()
@@ -211,43 +485,32 @@ func (r *Room) ChallengeIsWord(cookies []*http.Cookie) error {
   r.updateLastTouch()
 
   if _, ok := r.pm.getInTurnCookie(cookies); !ok {
-    return fmt.Errorf("it is not your turn")
+    return ErrNotYourTurn
+  }
+  if r.isPaused {
+    return ErrPaused
   }
   if r.state != kEdit {
-    return fmt.Errorf("cannot challenge right now")
+    return ErrWrongState.withMessage("cannot challenge right now")
   }
-  if len(r.stem) < r.config.MinWordLength {
-    return fmt.Errorf("minimum word length not met")
+  if letterCount(r.stem) < r.config.MinWordLength {
+    return ErrBelowMinLength
   }
 
-  r.log.flush()
-  r.log.appendChallengeIsWord(r.pm.currentPlayerUsername(),
-                              r.pm.lastPlayerUsername)
-
   // Even if the player's time expires here, we have the mutex, so it won't be
-  // acted on until after we validate the word. If the validation errors,
-  // however, the player is SOL
-  isWord, err := validateWord(r.stem, r.usedWords, r.config.AllowRepeatWords)
+  // acted on until after we validate the word. The word is checked before
+  // anything is logged so that a failed lookup leaves the room as it was.
+  isWord, err := validateWord(r.stem, r.usedWords, r.config.AllowRepeatWords,
+                              r.dictionary, r.alphabet)
   if err != nil {
     return err
   }
 
+  r.log.flush()
+  r.log.appendChallengeIsWord(r.pm.currentPlayerUsername(),
+                              r.pm.lastPlayerUsername)
   r.endTurn()
-
-  var loser string
-  if isWord {
-    r.usedWords[r.stem] = true
-    loser = r.pm.lastPlayerUsername
-    if p, ok := r.pm.usernameToPlayer[r.pm.lastPlayerUsername]; ok {
-      p.incrementScore(r.config.EliminationThreshold)
-    }
-  } else {
-    p := r.pm.players[r.pm.currentPlayerIdx]
-    p.incrementScore(r.config.EliminationThreshold)
-    loser = p.username
-  }
-  r.log.appendChallengeResult(strings.ToUpper(r.stem), isWord, loser)
-  r.endRound()
+  r.rule(r.stem, isWord)
   return nil
 }
 
@@ -258,13 +521,16 @@ func (r *Room) ChallengeContinuation(cookies []*http.Cookie) error {
   r.updateLastTouch()
 
   if _, ok := r.pm.getInTurnCookie(cookies); !ok {
-    return fmt.Errorf("it is not your turn")
+    return ErrNotYourTurn
+  }
+  if r.isPaused {
+    return ErrPaused
   }
   if r.state != kEdit {
-    return fmt.Errorf("cannot challenge right now")
+    return ErrWrongState.withMessage("cannot challenge right now")
   }
   if len(r.stem) < 1 {
-    return fmt.Errorf("cannot challenge empty stem")
+    return ErrEmptyStem.withMessage("cannot challenge empty stem")
   }
 
   r.endTurn()
@@ -277,10 +543,11 @@ func (r *Room) ChallengeContinuation(cookies []*http.Cookie) error {
     r.endRound()
     return nil
   }
+  // The state decides which clock the rebuttal is played on
+  r.state = kRebut
   r.startTurnAndCountdown(r.pm.currentPlayerUsername())
   r.log.appendChallengeContinuation(r.pm.lastPlayerUsername,
                                     r.pm.currentPlayerUsername())
-  r.state = kRebut
   return nil
 }
 
@@ -292,49 +559,88 @@ func (r *Room) RebutChallenge(cookies []*http.Cookie,
 
   r.updateLastTouch()
 
+  if r.isPaused {
+    return ErrPaused
+  }
   if r.state != kRebut {
-    return fmt.Errorf("cannot rebut right now")
+    return ErrWrongState.withMessage("cannot rebut right now")
   }
   if _, ok := r.pm.getInTurnCookie(cookies); !ok {
-    return fmt.Errorf("it is not your turn")
+    return ErrNotYourTurn
   }
 
-  continuation := strings.ToUpper(prefix + r.stem + suffix)
-  if len(continuation) < r.config.MinWordLength {
-    return fmt.Errorf("minimum word length not met")
+  if prefix != "" && !r.config.Variant.allowsPrefixes() {
+    return ErrInvalidMove.withMessage(
+        "in %s, the word must start with the stem", r.config.Variant)
+  }
+  prefix, suffix = r.alphabet.Normalize(prefix), r.alphabet.Normalize(suffix)
+  continuation := prefix + r.stem + suffix
+  if letterCount(continuation) < r.config.MinWordLength {
+    return ErrBelowMinLength
   }
 
-  r.log.flush()
-  r.log.appendRebuttal(r.pm.currentPlayerUsername(), r.stem,
-                       strings.ToUpper(prefix), strings.ToUpper(suffix))
-  // check if it is a word
   isWord, err := validateWord(continuation, r.usedWords,
-                              r.config.AllowRepeatWords)
+                              r.config.AllowRepeatWords, r.dictionary,
+                              r.alphabet)
   if err != nil {
     return err
   }
 
-  r.endTurn()
+  r.log.flush()
+  r.log.appendRebuttal(r.pm.currentPlayerUsername(), r.stem, prefix, suffix)
+  r.resolveRebuttal(continuation, isWord)
+  return nil
+}
 
-  // update game Room accordingly
-  var loser string
-  if isWord {
-    // challenger gets a letter
-    r.usedWords[continuation] = true
-    loser = r.pm.lastPlayerUsername
-    if p, ok := r.pm.usernameToPlayer[r.pm.lastPlayerUsername]; ok {
-      p.incrementScore(r.config.EliminationThreshold)
-    }
-  } else {
-    p := r.pm.players[r.pm.currentPlayerIdx]
-    p.incrementScore(r.config.EliminationThreshold)
-    loser = p.username
+// Rebuts with a whole word. This is the only way to rebut with a word that
+// has letters inside the stem, which superduperghost allows.
+func (r *Room) RebutChallengeWithWord(cookies []*http.Cookie,
+                                      word string) error {
+  r.mutex.Lock()
+  defer r.mutex.Unlock()
+
+  r.updateLastTouch()
+
+  if r.isPaused {
+    return ErrPaused
   }
-  r.log.appendChallengeResult(continuation, isWord, loser)
-  r.endRound()
+  if r.state != kRebut {
+    return ErrWrongState.withMessage("cannot rebut right now")
+  }
+  if _, ok := r.pm.getInTurnCookie(cookies); !ok {
+    return ErrNotYourTurn
+  }
+
+  word = r.alphabet.Normalize(word)
+  if !r.config.Variant.continues(r.stem, word) {
+    return ErrInvalidMove.withMessage(
+        "'%s' is not a continuation of '%s' in %s", word, r.stem,
+        r.config.Variant)
+  }
+  if letterCount(word) < r.config.MinWordLength {
+    return ErrBelowMinLength
+  }
+
+  isWord, err := validateWord(word, r.usedWords, r.config.AllowRepeatWords,
+                              r.dictionary, r.alphabet)
+  if err != nil {
+    return err
+  }
+
+  r.log.flush()
+  r.log.appendWordRebuttal(r.pm.currentPlayerUsername(), r.stem, word)
+  r.resolveRebuttal(word, isWord)
   return nil
 }
 
+// Scores the round on a checked rebuttal. The rebuttal must already be
+// logged.
+func (r *Room) resolveRebuttal(continuation string, isWord bool) {
+  r.endTurn()
+  // If it's a word, the challenger loses
+  r.rule(continuation, isWord)
+}
+
 func (r *Room) AffixLetter(
     cookies []*http.Cookie, prefix string, suffix string) error {
   r.mutex.Lock()
@@ -342,26 +648,129 @@ func (r *Room) AffixLetter(
 
   r.updateLastTouch()
 
+  if r.isPaused {
+    return ErrPaused
+  }
   if r.state != kEdit {
-    return fmt.Errorf("cannot affix right now")
+    return ErrWrongState.withMessage("cannot affix right now")
   }
   if _, ok := r.pm.getInTurnCookie(cookies); !ok {
-    return fmt.Errorf("it is not your turn")
+    return ErrNotYourTurn
   }
-  if !_alphaPattern.MatchString(prefix + suffix) || len(prefix + suffix) > 1{
-    return fmt.Errorf(
+  prefix, suffix = r.alphabet.Normalize(prefix), r.alphabet.Normalize(suffix)
+  if !r.alphabet.IsLetters(prefix + suffix) ||
+      letterCount(prefix + suffix) > 1 {
+    return ErrInvalidMove.withMessage(
         "exactly one alphabetical prefix OR suffix must be provided " +
         "(received: {prefix: '%s', suffix: '%s'})", prefix, suffix)
   }
+  if prefix != "" && !r.config.Variant.allowsPrefixes() {
+    return ErrInvalidMove.withMessage(
+        "only suffixes are allowed in %s", r.config.Variant)
+  }
 
   r.endTurn()
 
   // update log
   r.log.flush()
-  r.log.appendAffixation(r.pm.currentPlayerUsername(), strings.ToUpper(prefix),
-                         r.stem, strings.ToUpper(suffix))
+  r.log.appendAffixation(r.pm.currentPlayerUsername(), prefix, r.stem,
+                         suffix)
+
+  r.stem = prefix + r.stem + suffix
+
+  r.pm.incrementCurrentPlayer()
+  r.startTurnAndCountdown(r.pm.currentPlayerUsername())
+
+  return nil
+}
+
+// Puts letter into the stem before the index-th letter (so 0 is a prefix and
+// len(stem) is a suffix). Only allowed in superduperghost.
+func (r *Room) InsertLetter(
+    cookies []*http.Cookie, index int, letter string) error {
+  r.mutex.Lock()
+  defer r.mutex.Unlock()
+
+  r.updateLastTouch()
+
+  if !r.config.Variant.allowsInsertion() {
+    return ErrInvalidMove.withMessage("cannot insert letters in %s",
+                                      r.config.Variant)
+  }
+  if r.isPaused {
+    return ErrPaused
+  }
+  if r.state != kEdit {
+    return ErrWrongState.withMessage("cannot insert right now")
+  }
+  if _, ok := r.pm.getInTurnCookie(cookies); !ok {
+    return ErrNotYourTurn
+  }
+  letter = r.alphabet.Normalize(letter)
+  if !r.alphabet.IsLetters(letter) || letterCount(letter) != 1 {
+    return ErrInvalidMove.withMessage(
+        "exactly one alphabetical letter must be provided (received: '%s')",
+        letter)
+  }
+  // Counted in letters, not bytes
+  stem := []rune(r.stem)
+  if index < 0 || index > len(stem) {
+    return ErrInvalidMove.withMessage(
+        "index must be between 0 and %d (received: %d)", len(stem), index)
+  }
+
+  r.endTurn()
+
+  r.log.flush()
+  r.log.appendInsertion(r.pm.currentPlayerUsername(), r.stem, index, letter)
+
+  r.stem = string(stem[:index]) + letter + string(stem[index:])
+
+  r.pm.incrementCurrentPlayer()
+  r.startTurnAndCountdown(r.pm.currentPlayerUsername())
+
+  return nil
+}
+
+// Replaces the stem with its reverse, using up the turn. Only allowed in
+// xghost, and only once per round.
+func (r *Room) ReverseStem(cookies []*http.Cookie) error {
+  r.mutex.Lock()
+  defer r.mutex.Unlock()
+
+  r.updateLastTouch()
+
+  if !r.config.Variant.allowsReversal() {
+    return ErrInvalidMove.withMessage("cannot reverse the stem in %s",
+                                      r.config.Variant)
+  }
+  if r.isPaused {
+    return ErrPaused
+  }
+  if r.state != kEdit {
+    return ErrWrongState.withMessage("cannot reverse right now")
+  }
+  if _, ok := r.pm.getInTurnCookie(cookies); !ok {
+    return ErrNotYourTurn
+  }
+  if r.isReversed {
+    return ErrInvalidMove.withMessage(
+        "the stem can only be reversed once per round")
+  }
+  reversed := reverse(r.stem)
+  if reversed == r.stem {
+    // Covers the empty stem and single letters too
+    return ErrInvalidMove.withMessage(
+        "reversing '%s' wouldn't change it", r.stem)
+  }
+
+  r.endTurn()
+
+  r.log.flush()
+  r.log.appendReversal(r.pm.currentPlayerUsername(), r.stem)
 
-  r.stem = strings.ToUpper(prefix + r.stem + suffix)
+  r.stem = reversed
+  r.isReversed = true
 
   r.pm.incrementCurrentPlayer()
   r.startTurnAndCountdown(r.pm.currentPlayerUsername())
@@ -369,27 +778,59 @@ func (r *Room) AffixLetter(
   return nil
 }
 
+// Scores a lost round (which ended on the current stem) against p
+func (r *Room) penalize(p *Player) (isEliminated bool) {
+  isEliminated = p.incrementScore(r.scoring.penalty(r.stem), r.scoring)
+  if r.isSuddenDeath && !isEliminated {
+    p.eliminate()
+    isEliminated = true
+  }
+  return isEliminated
+}
+
+// Sudden death is when the last two are tied and the smallest loss would
+// knock either out
+func (r *Room) isSuddenDeathNext() bool {
+  if !r.config.SuddenDeath {
+    return false
+  }
+  scores := r.pm.remainingScores()
+  return len(scores) == 2 && scores[0] == scores[1] &&
+      r.scoring.onBrink(scores[0])
+}
+
 func (r *Room) endRound() {
   r.stem = ""
+  r.isReversed = false
+  r.isSuddenDeath = false
+  r.isClockPaused = false  // The next round starts off the clock anyway
+  r.dismissJury()
   r.state = kEdit
 
   // Test for end of GAME
   if winner, weHaveAWinner := r.pm.onlyOnePlayerNotEliminated();
-      len(r.pm.players) < 2 || weHaveAWinner {
+      r.pm.sides() < 2 || weHaveAWinner {
     // Log the reason for the end of game
-    if len(r.pm.players) < 2 {
+    if r.pm.sides() < 2 {
       r.log.appendInsufficientPlayers()
     } else if weHaveAWinner {
-      r.log.appendGameOver(winner)
+      r.log.appendGameOver(winner, r.finishGame(winner))
     }
+    r.gameLogStart = len(r.log.history)
     // Start a new game
     r.pm.resetScores()
+    r.pm.resetPlayerTimes(r.config.startingTime())
     r.waitToStart()
+  } else if r.isSuddenDeathNext() {
+    r.isSuddenDeath = true
+    r.log.appendSuddenDeath()
   }
   // Start a new round
   r.pm.incrementStartingPlayer()
   r.pm.currentPlayerIdx = r.pm.startingPlayerIdx
-  r.pm.resetPlayerTimes(r.config.PlayerTimePerWord)
+  if r.config.PlayerTimePerGame == 0 {
+    r.pm.resetPlayerTimes(r.config.PlayerTimePerWord)
+  }
   r.pm.clearDeadline()
 }
 
@@ -401,7 +842,7 @@ func (r *Room) Leave(cookies []*http.Cookie) error {
 
   username, ok := r.pm.getValidCookie(cookies)
   if !ok {
-    return fmt.Errorf("could not verify credentials")
+    return ErrInvalidCredentials
   }
 
   if err := r.removePlayer(username); err != nil {
@@ -423,37 +864,39 @@ func (r *Room) Concede(cookies []*http.Cookie) error {
 
   username, ok := r.pm.getValidCookie(cookies)
   if !ok {
-    return fmt.Errorf("could not verify credentials")
+    return ErrInvalidCredentials
+  }
+  if r.isPaused {
+    return ErrPaused
   }
   switch r.state {
 
-    case kWaitingToStart:
-      return fmt.Errorf("cannot concede right now")
+    case kWaitingToStart, kJury:
+      return ErrWrongState.withMessage("cannot concede right now")
 
     case kEdit:
       if len(r.stem) == 0 {
-        return fmt.Errorf("cannot concede when word is empty")
+        return ErrEmptyStem.withMessage("cannot concede when word is empty")
       }
-      if r.pm.usernameToPlayer[username].isEliminated {
-        return fmt.Errorf("cannot concede when eliminated")
+      if r.pm.usernameToPlayer[username].eliminated() {
+        return ErrEliminated.withMessage("cannot concede when eliminated")
       }
 
     case kRebut:
       if (username != r.pm.lastPlayerUsername &&
           username != r.pm.currentPlayerUsername()) {
-        return fmt.Errorf("it is not your turn")
+        return ErrNotYourTurn
       }
   }
 
   r.endTurn()
 
-  isEliminated := r.pm.usernameToPlayer[username].incrementScore(
-      r.config.EliminationThreshold)
+  isEliminated := r.penalize(r.pm.usernameToPlayer[username])
 
   r.log.flush()
   r.log.appendConcession(username)
   if isEliminated {
-    r.log.appendElimination(username)
+    r.log.appendElimination(r.pm.usernameToPlayer[username].sideName())
   }
 
   r.endRound()
@@ -469,15 +912,16 @@ func (r *Room) Kick(cookies []*http.Cookie,
 
   kickerUsername, ok := r.pm.getValidCookie(cookies)
   if !ok {
-    return fmt.Errorf("could not verify credentials")
+    return ErrInvalidCredentials
   }
   if kickerUsername != r.pm.hostPlayer().username {
-    return fmt.Errorf("only the host can kick other players")
+    return ErrNotHost.withMessage("only the host can kick other players")
   }
 
   kickRecipient, ok := r.pm.usernameToPlayer[kickRecipientUsername]
   if !ok {
-    return fmt.Errorf("recipient '%s' not found", kickRecipientUsername);
+    return ErrPlayerNotFound.withMessage("recipient '%s' not found",
+                                         kickRecipientUsername)
   }
 
   if err := r.removePlayer(kickRecipient.username); err != nil {
@@ -488,40 +932,24 @@ func (r *Room) Kick(cookies []*http.Cookie,
   return nil
 }
 
-// Just validate request & return a message object so that the server can
-// broadcast it.
-func (r *Room) Chat(cookies []*http.Cookie, content string) (*Message, error) {
-  r.mutex.Lock()
-  r.updateLastTouch()
-  r.mutex.Unlock()
-
-  username, ok := r.GetValidCookie(cookies)
-  if !ok {
-    return nil, fmt.Errorf("could not verify credentials")
-  }
-  if len(content) == 0 {
-    return nil, fmt.Errorf("empty message")
-  }
-
-  msg := new(Message)
-  msg.Sender = username
-  msg.Content = content
-  return msg, nil
-}
-
 func (r *Room) startTurnAndCountdown(expectedPlayerUsername string) {
   // Outside the `go` section, this is a synchronous function that runs only
   // when called by another mutex-protected function (therefor DO NOT grab the
   // mutex outside the go func() part!)
-  if r.config.PlayerTimePerWord == 0 * time.Second {
+  budget, ok := r.turnBudget()
+  if !ok {
     return
   }
-  r.pm.updateDeadline()
+  r.pm.updateDeadline(budget + r.config.TimeDelay)
   timesUp := time.NewTimer(time.Until(r.pm.currentPlayerDeadline))
   if r.endTurnCh != nil {
     panic("trying to start a new turn when the previous one was not finished!")
   }
-  r.endTurnCh = make(chan struct{})
+  // The goroutine keeps its own copy: by the time it selects, the field may
+  // already belong to the next turn
+  endTurnCh := make(chan struct{})
+  r.endTurnCh = endTurnCh
+  turnID := r.turnID
 
   go func() {
     select {
@@ -530,8 +958,8 @@ func (r *Room) startTurnAndCountdown(expectedPlayerUsername string) {
         r.mutex.Lock()
         defer r.mutex.Unlock()
 
-        // TODO: replace with turn ID
-        if expectedPlayerUsername != r.pm.currentPlayerUsername() {
+        if r.turnID != turnID ||
+            expectedPlayerUsername != r.pm.currentPlayerUsername() {
           // The player sent their response at the moment they ran out of time
           // but before we got the mutex lock. For now I'll just give it to
           // them but I can see this being very unintuitive if the time is
@@ -541,20 +969,28 @@ func (r *Room) startTurnAndCountdown(expectedPlayerUsername string) {
           // Nothing to clean up since the timer fired, just exit the function
           return
         }
+        // Paused while we waited for the mutex. Resuming keeps the turn but
+        // starts a new countdown, and that one times the player out.
+        if r.isPaused || r.endTurnCh != endTurnCh {
+          return
+        }
 
         r.log.flush()
         r.log.appendTimeout(r.pm.currentPlayerUsername())
 
-        if r.pm.currentPlayer().incrementScore(r.config.EliminationThreshold) {
-          r.log.appendElimination(r.pm.currentPlayerUsername())
+        if r.penalize(r.pm.currentPlayer()) {
+          r.log.appendElimination(r.pm.currentPlayer().sideName())
+        }
+        if r.usesBank() && r.config.PlayerTimePerGame > 0 {
+          r.pm.currentPlayer().timeRemaining = r.config.PlayerTimePerGame
         }
 
         r.endTurnCh = nil // Don't need this anymore
         r.endRound()
         // notify the frontend of the update to game state
-        r.asyncUpdateCh<-struct{}{}
+        r.notifyAsyncUpdate()
 
-      case <-r.endTurnCh:
+      case <-endTurnCh:
         // The player beat the clock (and currently has control over the mutex).
         // Just stop the timer and let the synchronous code take care of the
         // rest.
@@ -564,16 +1000,25 @@ func (r *Room) startTurnAndCountdown(expectedPlayerUsername string) {
 }
 
 func (r *Room) endTurn() {
-  if r.config.PlayerTimePerWord > 0 {
-    if r.endTurnCh != nil {
-      close(r.endTurnCh) // This will stop the countdown thread
-      r.endTurnCh = nil
-    }
-    r.pm.endTurn()
+  if r.config.isTimed() {
+    r.stopClock(r.config.TimeIncrement)
   }
   r.turnID++
 }
 
+// Stops the countdown, if any, banking the current player's time
+func (r *Room) stopClock(increment time.Duration) {
+  if r.endTurnCh != nil {
+    close(r.endTurnCh) // This will stop the countdown thread
+    r.endTurnCh = nil
+  }
+  if r.usesBank() {
+    r.pm.endTurn(increment)
+  } else {
+    r.pm.clearDeadline()  // The rebuttal clock isn't banked
+  }
+}
+
 func (r *Room) removePlayer(username string) error {
   wasActivePlayer := false
   if r.pm.currentPlayerUsername() == username {
@@ -585,8 +1030,12 @@ func (r *Room) removePlayer(username string) error {
   if err != nil {
     return err
   }
-  if len(r.pm.players) < 2 {
+  delete(r.pauseVotes, username)
+  if r.pm.sides() < 2 {
     r.endRound()
+  } else if r.state == kJury {
+    delete(r.jury.votes, username)
+    r.closeJuryIfDone()
   } else if wasActivePlayer && r.pm.doesDeadlineExist() {
     r.startTurnAndCountdown(r.pm.currentPlayerUsername())
   }
@@ -596,6 +1045,9 @@ func (r *Room) removePlayer(username string) error {
 func (r *Room) waitToStart() {
   r.state = kWaitingToStart
   r.pm.clearDeadline()
+  r.isPaused = false
+  r.isClockPaused = false
+  r.pauseVotes = make(map[string]bool)
 }
 
 func (r *Room) ScheduleLeave(cookies []*http.Cookie) error {
@@ -604,18 +1056,19 @@ func (r *Room) ScheduleLeave(cookies []*http.Cookie) error {
 
   username, ok := r.pm.getValidCookie(cookies)
   if !ok {
-    return fmt.Errorf("could not verify credentials")
+    return ErrInvalidCredentials
   }
 
   // If they are already scheduled to leave, use the previously scheduled time
   // rather than restarting the countdown.
   if _, ok := r.usernameToCancelLeaveCh[username]; ok {
-    return fmt.Errorf("player already scheduled to leave")
+    return ErrAlreadyLeaving
   }
 
   // set up a timer & channel to cancel
   deadline := time.NewTimer(500 * time.Millisecond)
-  r.usernameToCancelLeaveCh[username] = make(chan struct{})
+  cancelCh := make(chan struct{})
+  r.usernameToCancelLeaveCh[username] = cancelCh
 
   // Create a new thread to wait for the deadline to expire (and kick the player
   // or for the leave to to be cancelled.
@@ -625,6 +1078,9 @@ func (r *Room) ScheduleLeave(cookies []*http.Cookie) error {
         r.mutex.Lock()
         defer r.mutex.Unlock()
 
+        if r.usernameToCancelLeaveCh[username] != cancelCh {
+          return  // Cancelled while we waited for the mutex
+        }
         delete(r.usernameToCancelLeaveCh, username)
 
         r.log.flush()
@@ -637,11 +1093,10 @@ func (r *Room) ScheduleLeave(cookies []*http.Cookie) error {
           return
         }
 
-        r.asyncUpdateCh <- struct{}{}
+        r.notifyAsyncUpdate()
 
-      case <-r.usernameToCancelLeaveCh[username]:
+      case <-cancelCh:
         deadline.Stop()
-        delete(r.usernameToCancelLeaveCh, username)  // We are done with this
     }
   }()
 
@@ -654,24 +1109,44 @@ func (r *Room) CancelLeaveIfScheduled(cookies []*http.Cookie) error {
 
   username, ok := r.pm.getValidCookie(cookies)
   if !ok {
-    return fmt.Errorf("could not verify credentials")
-  }
-
-  ch, ok := r.usernameToCancelLeaveCh[username]
-  if ok && ch != nil {
-    ch <- struct{}{}
+    return ErrInvalidCredentials
   }
 
+  r.cancelLeave(username)
   return nil
 }
 
+// Stops the countdown, if any. Closing rather than sending means this can't
+// block on a countdown that fired and is waiting for the lock. The lock must
+// be held.
+func (r *Room) cancelLeave(username string) {
+  if ch, ok := r.usernameToCancelLeaveCh[username]; ok {
+    close(ch)
+    delete(r.usernameToCancelLeaveCh, username)
+  }
+}
+
 func (r *Room) Teardown() {
+  // Before taking the lock, since a timer may hold it while it waits to send
+  close(r.doneCh)
+
+  r.mutex.Lock()
+  defer r.mutex.Unlock()
+
   // Safely kill any threads
-  for _, ch := range r.usernameToCancelLeaveCh {
-    if ch != nil {
-      ch <- struct{}{}
-    }
+  for username := range r.usernameToCancelLeaveCh {
+    r.cancelLeave(username)
   }
   r.endTurn()
+  r.dismissJury()
+}
+
+// Tells the server about a change no request made. Once the room is torn down
+// nothing listens, so the update is dropped. The lock must be held.
+func (r *Room) notifyAsyncUpdate() {
+  select {
+    case r.asyncUpdateCh <- struct{}{}:
+    case <-r.doneCh:
+  }
 }
 
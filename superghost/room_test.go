@@ -1,9 +1,15 @@
 package superghost
 
 import (
+  "bytes"
+  "crypto/sha256"
+  "encoding/json"
+  "errors"
+  "fmt"
   "github.com/stretchr/testify/assert"
   "net/http"
   "strconv"
+  "strings"
   "testing"
   "time"
 )
@@ -14,10 +20,20 @@ type testRoomUtils struct {
   usernameToCookie map[string]*http.Cookie
 }
 
+// Words the test dictionary knows about. Tests should never need the network.
+var _testWords = []string{"GHOST", "TESTING", "STEM", "ABSTAIN", "BASTE"}
+
 func newTestRoomUtils(config Config) *testRoomUtils {
+  if config.Dictionary == nil {
+    config.Dictionary = NewWordList(_testWords)
+  }
   tru := new(testRoomUtils)
   tru.asyncUpdateCh = make(chan struct{})
-  tru.room = NewRoom(config, tru.asyncUpdateCh)
+  room, err := NewRoom(config, tru.asyncUpdateCh)
+  if err != nil {
+    panic(err)
+  }
+  tru.room = room
   tru.usernameToCookie = make(map[string]*http.Cookie)
   return tru
 }
@@ -55,6 +71,11 @@ func (tru *testRoomUtils) getCookiesFromPlayerIdx(idx int) []*http.Cookie {
   return []*http.Cookie{tru.room.pm.players[idx].cookie}
 }
 
+// Unlike an index, a username still finds the player after others leave
+func (tru *testRoomUtils) cookiesOf(username string) []*http.Cookie {
+  return []*http.Cookie{tru.usernameToCookie[username]}
+}
+
 func TestNoDeadlineAtRoundStart(t *testing.T) {
   tru := newDefaultTimedNoEliminationTestRoomUtils()
   err := tru.addNPlayers(2)
@@ -285,6 +306,34 @@ func TestCancellableLeaveToOnePlayerEndsGame(t *testing.T) {
   }
 }
 
+// Nobody reads the async channel here, as after the server stops listening
+func TestTeardownWithPendingUpdate(t *testing.T) {
+  tru := newTestRoomUtils(Config {
+    MaxPlayers: 2,
+    MinWordLength: 4,
+    PlayerTimePerWord: 10 * time.Millisecond,
+  })
+  assert.NoError(t, tru.addNPlayers(2))
+  assert.NoError(t, tru.room.ScheduleLeave(tru.getCookiesFromPlayerIdx(0)))
+  assert.NoError(t, tru.room.AffixLetter(tru.currentPlayerCookies(), "", "a"))
+  // Let the turn time out and wait to send its update
+  time.Sleep(100 * time.Millisecond)
+
+  done := make(chan struct{})
+  go func() {
+    tru.room.Teardown()
+    close(done)
+  }()
+  select {
+    case <-done:
+    case <-time.After(time.Second):
+      t.Fatalf("teardown got stuck behind the timer")
+  }
+  tru.room.mutex.Lock()
+  defer tru.room.mutex.Unlock()
+  assert.Empty(t, tru.room.usernameToCancelLeaveCh)
+}
+
 func TestGameLoop(t *testing.T) {
   tru := newDefaultTimedNoEliminationTestRoomUtils()
   err := tru.addNPlayers(2)
@@ -340,3 +389,2026 @@ func TestTurnIDIsZeroAtStart(t *testing.T) {
 
   assert.Equal(t, 0, tru.room.turnID)
 }
+
+func TestErrorsMatchSentinels(t *testing.T) {
+  tru := newDefaultTimedNoEliminationTestRoomUtils()
+  assert.NoError(t, tru.addNPlayers(2))
+
+  notInTurnCookies := tru.getCookiesFromPlayerIdx(
+      (tru.room.pm.currentPlayerIdx + 1) % 2)
+
+  err := tru.room.AffixLetter(notInTurnCookies, "", "a")
+  assert.ErrorIs(t, err, ErrNotYourTurn)
+
+  err = tru.room.AffixLetter(tru.currentPlayerCookies(), "a", "b")
+  assert.ErrorIs(t, err, ErrInvalidMove)
+  // The message should be specific to the request even though the code is not
+  assert.Contains(t, err.Error(), "received")
+
+  err = tru.room.RebutChallenge(tru.currentPlayerCookies(), "", "a")
+  assert.ErrorIs(t, err, ErrWrongState)
+
+  err = tru.room.ChallengeContinuation(tru.currentPlayerCookies())
+  assert.ErrorIs(t, err, ErrEmptyStem)
+
+  assert.NoError(t, tru.room.AffixLetter(tru.currentPlayerCookies(), "", "a"))
+  err = tru.room.ChallengeIsWord(tru.currentPlayerCookies())
+  assert.ErrorIs(t, err, ErrBelowMinLength)
+
+  err = tru.room.Concede([]*http.Cookie{})
+  assert.ErrorIs(t, err, ErrInvalidCredentials)
+
+  err = tru.room.Kick(tru.getCookiesFromPlayerIdx(1), "0")
+  assert.ErrorIs(t, err, ErrNotHost)
+
+  err = tru.room.Kick(tru.getCookiesFromPlayerIdx(0), "nobody")
+  assert.ErrorIs(t, err, ErrPlayerNotFound)
+
+  _, err = tru.room.AddPlayer("0", "xyz")
+  assert.ErrorIs(t, err, ErrUsernameTaken)
+
+  _, err = tru.room.AddPlayer("not valid", "xyz")
+  assert.ErrorIs(t, err, ErrInvalidUsername)
+
+  _, err = tru.room.Chat(tru.getCookiesFromPlayerIdx(0), "")
+  assert.ErrorIs(t, err, ErrEmptyMessage)
+
+  // Length is in characters, not bytes
+  _, err = tru.room.Chat(tru.getCookiesFromPlayerIdx(0),
+                         strings.Repeat("é", kMaxMessageLength))
+  assert.NoError(t, err)
+  _, err = tru.room.Chat(tru.getCookiesFromPlayerIdx(0),
+                         strings.Repeat("a", kMaxMessageLength + 1))
+  assert.ErrorIs(t, err, ErrMessageTooLong)
+}
+
+func TestChatHistory(t *testing.T) {
+  tru := newDefaultTimedNoEliminationTestRoomUtils()
+  assert.NoError(t, tru.addNPlayers(2))
+
+  for i := 0; i < kChatHistoryLength + 5; i++ {
+    msg, err := tru.room.Chat(tru.getCookiesFromPlayerIdx(i % 2),
+                              strconv.Itoa(i))
+    assert.NoError(t, err)
+    assert.Equal(t, i + 1, msg.ID)
+  }
+
+  // Only the full state has the history, and only the most recent of it
+  var room JRoom
+  b, err := tru.room.MarshalJSON()
+  assert.NoError(t, err)
+  assert.NoError(t, json.Unmarshal(b, &room))
+  assert.Empty(t, room.Chat)
+  b, err = tru.room.MarshalJSONFullLog()
+  assert.NoError(t, err)
+  assert.NoError(t, json.Unmarshal(b, &room))
+  assert.Len(t, room.Chat, kChatHistoryLength)
+  assert.Equal(t, 6, room.Chat[0].ID)
+  assert.Equal(t, "5", room.Chat[0].Content)
+  assert.Equal(t, "0", room.Chat[1].Sender)
+}
+
+func TestDeleteMessage(t *testing.T) {
+  tru := newDefaultTimedNoEliminationTestRoomUtils()
+  assert.NoError(t, tru.addNPlayers(2))
+
+  first, err := tru.room.Chat(tru.getCookiesFromPlayerIdx(1), "first")
+  assert.NoError(t, err)
+  _, err = tru.room.Chat(tru.getCookiesFromPlayerIdx(1), "second")
+  assert.NoError(t, err)
+
+  _, err = tru.room.DeleteMessage(tru.getCookiesFromPlayerIdx(1), first.ID)
+  assert.ErrorIs(t, err, ErrNotHost)
+  notice, err := tru.room.DeleteMessage(tru.getCookiesFromPlayerIdx(0),
+                                        first.ID)
+  assert.NoError(t, err)
+  assert.Equal(t, Message{ ID: first.ID, Deleted: true }, *notice)
+  _, err = tru.room.DeleteMessage(tru.getCookiesFromPlayerIdx(0), first.ID)
+  assert.ErrorIs(t, err, ErrMessageNotFound)
+
+  assert.Len(t, tru.room.chat, 1)
+  assert.Equal(t, "second", tru.room.chat[0].Content)
+}
+
+func TestMute(t *testing.T) {
+  tru := newDefaultTimedNoEliminationTestRoomUtils()
+  assert.NoError(t, tru.addNPlayers(2))
+
+  err := tru.room.Mute(tru.getCookiesFromPlayerIdx(1), "0", true)
+  assert.ErrorIs(t, err, ErrNotHost)
+  err = tru.room.Mute(tru.getCookiesFromPlayerIdx(0), "nobody", true)
+  assert.ErrorIs(t, err, ErrPlayerNotFound)
+
+  assert.NoError(t, tru.room.Mute(tru.getCookiesFromPlayerIdx(0), "1", true))
+  _, err = tru.room.Chat(tru.getCookiesFromPlayerIdx(1), "hi")
+  assert.ErrorIs(t, err, ErrMuted)
+  assert.Equal(t, []string{"1"}, tru.room.mutedUsernames())
+
+  // Muted players can still play, and hosts can mute themselves
+  assert.NoError(t, tru.room.Mute(tru.getCookiesFromPlayerIdx(0), "0", true))
+  assert.NoError(t, tru.room.AffixLetter(tru.currentPlayerCookies(), "", "a"))
+  assert.NoError(t, tru.room.Mute(tru.getCookiesFromPlayerIdx(0), "0", false))
+
+  assert.NoError(t, tru.room.Mute(tru.getCookiesFromPlayerIdx(0), "1", false))
+  _, err = tru.room.Chat(tru.getCookiesFromPlayerIdx(1), "hi")
+  assert.NoError(t, err)
+}
+
+type blockingChatFilter struct{}
+
+func (blockingChatFilter) Filter(content string) (string, error) {
+  if strings.Contains(content, "http") {
+    return "", fmt.Errorf("no links")
+  }
+  return content, nil
+}
+
+func TestChatFilter(t *testing.T) {
+  f := NewWordFilter(ParseWordList("darn, Heck"))
+  filtered, err := f.Filter("Darn it, what the heck? Darning is fine.")
+  assert.NoError(t, err)
+  assert.Equal(t, "**** it, what the ****? Darning is fine.", filtered)
+
+  tru := newTestRoomUtils(Config {
+    MaxPlayers: 2,
+    MinWordLength: 3,
+    ChatFilter: f,
+  })
+  assert.NoError(t, tru.addNPlayers(2))
+  msg, err := tru.room.Chat(tru.getCookiesFromPlayerIdx(0), "heck")
+  assert.NoError(t, err)
+  assert.Equal(t, "****", msg.Content)
+  assert.Equal(t, "****", tru.room.chat[0].Content)
+
+  tru = newTestRoomUtils(Config {
+    MaxPlayers: 2,
+    MinWordLength: 3,
+    ChatFilter: blockingChatFilter{},
+  })
+  assert.NoError(t, tru.addNPlayers(2))
+  _, err = tru.room.Chat(tru.getCookiesFromPlayerIdx(0), "see http://x")
+  assert.ErrorIs(t, err, ErrMessageBlocked)
+  assert.Empty(t, tru.room.chat)
+}
+
+func TestValidateWordRejectsUsedWords(t *testing.T) {
+  usedWords := map[string]bool{"GHOST": true}
+
+  dictionary := NewWordList(_testWords)
+
+  _, err := validateWord("GHOST", usedWords, false, dictionary,
+                        LanguageEnglish.Alphabet())
+  assert.ErrorIs(t, err, ErrWordUsed)
+
+  _, err = validateWord("GH0ST", usedWords, false, dictionary,
+                       LanguageEnglish.Alphabet())
+  assert.ErrorIs(t, err, ErrInvalidWord)
+}
+
+func newClassicGhostTestRoomUtils() *testRoomUtils {
+  return newTestRoomUtils(Config {
+    Variant: VariantGhost,
+    MaxPlayers: 16,
+    MinWordLength: 4,
+    EliminationThreshold: 0,
+    PlayerTimePerWord: time.Second * 60,
+  })
+}
+
+func TestVariantDefaultsToSuperghost(t *testing.T) {
+  tru := newDefaultTimedNoEliminationTestRoomUtils()
+  assert.Equal(t, VariantSuperghost, tru.room.config.Variant)
+  assert.Equal(t, VariantSuperghost, tru.room.Metadata("x").Variant)
+  assert.True(t, VariantGhost.IsValid())
+  assert.False(t, Variant("hangman").IsValid())
+}
+
+func TestClassicGhostOnlyAllowsSuffixes(t *testing.T) {
+  tru := newClassicGhostTestRoomUtils()
+  assert.NoError(t, tru.addNPlayers(2))
+  assert.Equal(t, VariantGhost, tru.room.Metadata("x").Variant)
+
+  err := tru.room.AffixLetter(tru.currentPlayerCookies(), "g", "")
+  assert.ErrorIs(t, err, ErrInvalidMove)
+  assert.Zero(t, tru.room.turnID)
+
+  assert.NoError(t, tru.room.AffixLetter(tru.currentPlayerCookies(), "", "g"))
+  assert.NoError(t, tru.room.AffixLetter(tru.currentPlayerCookies(), "", "h"))
+  assert.Equal(t, "GH", tru.room.stem)
+}
+
+func TestClassicGhostRebuttalMustStartWithStem(t *testing.T) {
+  tru := newClassicGhostTestRoomUtils()
+  assert.NoError(t, tru.addNPlayers(2))
+
+  assert.NoError(t, tru.room.AffixLetter(tru.currentPlayerCookies(), "", "s"))
+  assert.NoError(t, tru.room.AffixLetter(tru.currentPlayerCookies(), "", "t"))
+  assert.NoError(t, tru.room.ChallengeContinuation(tru.currentPlayerCookies()))
+
+  // TESTING contains ST, but doesn't start with it
+  err := tru.room.RebutChallenge(tru.currentPlayerCookies(), "te", "ing")
+  assert.ErrorIs(t, err, ErrInvalidMove)
+  assert.Equal(t, kRebut, tru.room.state)
+
+  assert.NoError(t, tru.room.RebutChallenge(tru.currentPlayerCookies(), "",
+                                            "em"))
+  assert.Equal(t, kEdit, tru.room.state)
+  assert.Equal(t, "", tru.room.stem)
+}
+
+func newSuperduperghostTestRoomUtils() *testRoomUtils {
+  return newTestRoomUtils(Config {
+    Variant: VariantSuperduperghost,
+    MaxPlayers: 16,
+    MinWordLength: 4,
+    EliminationThreshold: 0,
+    PlayerTimePerWord: time.Second * 60,
+  })
+}
+
+func TestInsertLetter(t *testing.T) {
+  tru := newSuperduperghostTestRoomUtils()
+  assert.NoError(t, tru.addNPlayers(2))
+
+  assert.NoError(t, tru.room.InsertLetter(tru.currentPlayerCookies(), 0, "g"))
+  assert.NoError(t, tru.room.InsertLetter(tru.currentPlayerCookies(), 1, "t"))
+  assert.NoError(t, tru.room.InsertLetter(tru.currentPlayerCookies(), 1, "s"))
+  assert.Equal(t, "GST", tru.room.stem)
+  assert.Equal(t, 3, tru.room.turnID)
+
+  last := tru.room.log.history[len(tru.room.log.history) - 1]
+  assert.Equal(t, kInsert, last.Type)
+  assert.Equal(t, "GT", last.Stem)
+  assert.Equal(t, "S", last.Letter)
+  assert.Equal(t, 1, *last.Index)
+
+  for _, index := range []int{-1, 4} {
+    err := tru.room.InsertLetter(tru.currentPlayerCookies(), index, "a")
+    assert.ErrorIs(t, err, ErrInvalidMove)
+  }
+  err := tru.room.InsertLetter(tru.currentPlayerCookies(), 0, "ab")
+  assert.ErrorIs(t, err, ErrInvalidMove)
+  assert.Equal(t, 3, tru.room.turnID)
+}
+
+func TestInsertLetterOnlyInSuperduperghost(t *testing.T) {
+  tru := newDefaultTimedNoEliminationTestRoomUtils()
+  assert.NoError(t, tru.addNPlayers(2))
+  err := tru.room.InsertLetter(tru.currentPlayerCookies(), 0, "a")
+  assert.ErrorIs(t, err, ErrInvalidMove)
+}
+
+func TestSuperduperghostRebuttalIsSubsequence(t *testing.T) {
+  tru := newSuperduperghostTestRoomUtils()
+  assert.NoError(t, tru.addNPlayers(2))
+
+  assert.NoError(t, tru.room.InsertLetter(tru.currentPlayerCookies(), 0, "g"))
+  assert.NoError(t, tru.room.InsertLetter(tru.currentPlayerCookies(), 1, "s"))
+  assert.NoError(t, tru.room.ChallengeContinuation(tru.currentPlayerCookies()))
+
+  // STEM doesn't contain G and S in order
+  err := tru.room.RebutChallengeWithWord(tru.currentPlayerCookies(), "stem")
+  assert.ErrorIs(t, err, ErrInvalidMove)
+  assert.Equal(t, kRebut, tru.room.state)
+
+  rebutter := tru.room.pm.currentPlayerUsername()
+  assert.NoError(t, tru.room.RebutChallengeWithWord(tru.currentPlayerCookies(),
+                                                    "ghost"))
+  assert.Equal(t, kEdit, tru.room.state)
+  last := tru.room.log.history[len(tru.room.log.history) - 1]
+  assert.Equal(t, kChallengeResult, last.Type)
+  assert.Equal(t, "GHOST", last.Stem)
+  assert.True(t, *last.Success)
+  assert.NotEqual(t, rebutter, last.To)
+}
+
+func TestWordsWithSubsequence(t *testing.T) {
+  wl := NewWordList(_testWords)
+  words, err := wl.WordsWithSubsequence("ST", 10)
+  assert.NoError(t, err)
+  assert.Equal(t, []string{"ABSTAIN", "BASTE", "GHOST", "STEM", "TESTING"},
+               words)
+
+  words, err = wl.WordsWithSubsequence("BSE", 10)
+  assert.NoError(t, err)
+  assert.Equal(t, []string{"BASTE"}, words)
+
+  words, err = wl.WordsWithSubsequence("ST", 2)
+  assert.NoError(t, err)
+  assert.Len(t, words, 2)
+}
+
+func TestReverseStem(t *testing.T) {
+  tru := newTestRoomUtils(Config {
+    Variant: VariantXghost,
+    MaxPlayers: 16,
+    MinWordLength: 4,
+    EliminationThreshold: 0,
+    PlayerTimePerWord: time.Second * 60,
+  })
+  assert.NoError(t, tru.addNPlayers(2))
+
+  // Nothing to reverse yet
+  err := tru.room.ReverseStem(tru.currentPlayerCookies())
+  assert.ErrorIs(t, err, ErrInvalidMove)
+  assert.NoError(t, tru.room.AffixLetter(tru.currentPlayerCookies(), "", "t"))
+  err = tru.room.ReverseStem(tru.currentPlayerCookies())
+  assert.ErrorIs(t, err, ErrInvalidMove)
+  assert.NoError(t, tru.room.AffixLetter(tru.currentPlayerCookies(), "", "s"))
+
+  reverser := tru.room.pm.currentPlayerUsername()
+  assert.NoError(t, tru.room.ReverseStem(tru.currentPlayerCookies()))
+  assert.Equal(t, "ST", tru.room.stem)
+  assert.Equal(t, 3, tru.room.turnID)
+  assert.NotEqual(t, reverser, tru.room.pm.currentPlayerUsername())
+  last := tru.room.log.history[len(tru.room.log.history) - 1]
+  assert.Equal(t, kReverse, last.Type)
+  assert.Equal(t, "TS", last.Stem)
+
+  // Only once per round
+  err = tru.room.ReverseStem(tru.currentPlayerCookies())
+  assert.ErrorIs(t, err, ErrInvalidMove)
+  assert.NoError(t, tru.room.Concede(tru.currentPlayerCookies()))
+  assert.NoError(t, tru.room.AffixLetter(tru.currentPlayerCookies(), "", "a"))
+  assert.NoError(t, tru.room.AffixLetter(tru.currentPlayerCookies(), "", "b"))
+  assert.NoError(t, tru.room.ReverseStem(tru.currentPlayerCookies()))
+  assert.Equal(t, "BA", tru.room.stem)
+}
+
+func TestReverseStemOnlyInXghost(t *testing.T) {
+  tru := newDefaultTimedNoEliminationTestRoomUtils()
+  assert.NoError(t, tru.addNPlayers(2))
+  assert.NoError(t, tru.room.AffixLetter(tru.currentPlayerCookies(), "", "t"))
+  assert.NoError(t, tru.room.AffixLetter(tru.currentPlayerCookies(), "", "s"))
+  err := tru.room.ReverseStem(tru.currentPlayerCookies())
+  assert.ErrorIs(t, err, ErrInvalidMove)
+}
+
+func newTeamTestRoomUtils(teamCount int) *testRoomUtils {
+  return newTestRoomUtils(Config {
+    MaxPlayers: 16,
+    MinWordLength: 4,
+    EliminationThreshold: 2,
+    TeamCount: teamCount,
+  })
+}
+
+// Adds players to the given teams, named "1", "2", ..
+func (tru *testRoomUtils) addPlayersToTeams(teams ...int) error {
+  start := len(tru.room.pm.players)
+  for i, team := range teams {
+    username := strconv.Itoa(start + i)
+    var err error
+    tru.usernameToCookie[username], err =
+        tru.room.AddPlayerToTeam(username, "xyz", team)
+    if err != nil {
+      return err
+    }
+  }
+  return nil
+}
+
+func TestTeamsAssignment(t *testing.T) {
+  tru := newTeamTestRoomUtils(2)
+  // Auto-assignment fills the smallest team
+  assert.NoError(t, tru.addPlayersToTeams(0, 0, 1, 0))
+  teams := tru.room.pm.jTeams()
+  assert.Equal(t, []string{"0", "2"}, teams[0].Usernames)
+  assert.Equal(t, []string{"1", "3"}, teams[1].Usernames)
+
+  assert.ErrorIs(t, tru.addPlayersToTeams(3), ErrInvalidTeam)
+  assert.ErrorIs(t, tru.addPlayersToTeams(-1), ErrInvalidTeam)
+
+  solo := newDefaultTimedNoEliminationTestRoomUtils()
+  assert.ErrorIs(t, solo.addPlayersToTeams(1), ErrInvalidTeam)
+  assert.Empty(t, solo.room.pm.jTeams())
+}
+
+func TestTeamsNeedTwoTeamsToStart(t *testing.T) {
+  tru := newTeamTestRoomUtils(2)
+  assert.NoError(t, tru.addPlayersToTeams(1, 1))
+  assert.Equal(t, kWaitingToStart, tru.room.state)
+  assert.NoError(t, tru.addPlayersToTeams(2))
+  assert.Equal(t, kEdit, tru.room.state)
+}
+
+func TestTeamsAlternateTurns(t *testing.T) {
+  tru := newTeamTestRoomUtils(2)
+  // Team 1: 0, 1, 2. Team 2: 3, 4
+  assert.NoError(t, tru.addPlayersToTeams(1, 1, 1, 2, 2))
+
+  order := make([]string, 0)
+  for _, letter := range []string{"a", "b", "c", "d", "e", "f", "g"} {
+    order = append(order, tru.room.pm.currentPlayerUsername())
+    assert.NoError(t, tru.room.AffixLetter(tru.currentPlayerCookies(), "",
+                                           letter))
+  }
+  assert.Equal(t, []string{"0", "3", "1", "4", "2", "3", "0"}, order)
+}
+
+func TestTeamsShareScore(t *testing.T) {
+  tru := newTeamTestRoomUtils(2)
+  assert.NoError(t, tru.addPlayersToTeams(1, 2, 1, 2))
+
+  // Each concession costs the whole team a letter
+  for i := 0; i < 2; i++ {
+    assert.NoError(t, tru.room.AffixLetter(tru.currentPlayerCookies(), "", "a"))
+    assert.NoError(t, tru.room.Concede(tru.getCookiesFromPlayerIdx(2)))
+    if i == 0 {
+      for _, p := range tru.room.pm.jPlayers() {
+        assert.Equal(t, uint(2 - p.Team), p.Score)
+      }
+    }
+  }
+
+  // Team 1 is out, so team 2 won
+  last := tru.room.log.history[len(tru.room.log.history) - 1]
+  assert.Equal(t, kGameOver, last.Type)
+  assert.Equal(t, "Team 2", last.To)
+  assert.Equal(t, kWaitingToStart, tru.room.state)
+}
+
+func TestLetterScoring(t *testing.T) {
+  sp := newScoringPolicy(&Config{ EliminationWord: "GHOST",
+                                  EliminationThreshold: 2 })
+  assert.Equal(t, uint(1), sp.penalty("ABCDEF"))
+  assert.Equal(t, "-----", sp.display(0))
+  assert.Equal(t, "GH---", sp.display(2))
+  assert.Equal(t, "GHOST +1", sp.display(6))
+  // The word decides, not the threshold
+  assert.False(t, sp.isEliminated(4))
+  assert.True(t, sp.onBrink(4))
+  assert.True(t, sp.isEliminated(5))
+
+  sp = newScoringPolicy(&Config{ EliminationThreshold: 3 })
+  assert.Equal(t, "WOR--", sp.display(3))
+  assert.True(t, sp.isEliminated(3))
+}
+
+func TestPenaltyScoring(t *testing.T) {
+  sp := newScoringPolicy(&Config{ Scoring: ScoringPenalty,
+                                  EliminationThreshold: 20,
+                                  MinWordLength: 4 })
+  assert.Equal(t, uint(6), sp.penalty("ABCDEF"))
+  assert.Equal(t, uint(1), sp.penalty(""))
+  assert.Equal(t, "7 / 20 pts", sp.display(7))
+  assert.False(t, sp.onBrink(18))
+  assert.True(t, sp.onBrink(19))
+  assert.True(t, sp.isEliminated(20))
+}
+
+// The smallest loss is a timeout on a one letter stem, which costs a point
+// whatever the minimum word length
+func TestPenaltyScoringOneLetterTimeout(t *testing.T) {
+  tru := newTestRoomUtils(Config {
+    MaxPlayers: 2,
+    MinWordLength: 4,
+    Scoring: ScoringPenalty,
+    EliminationThreshold: 5,
+    SuddenDeath: true,
+    PlayerTimePerWord: 20 * time.Millisecond,
+  })
+  assert.NoError(t, tru.addNPlayers(2))
+  for _, p := range tru.room.pm.players {
+    p.score = 3
+  }
+  assert.False(t, tru.room.isSuddenDeathNext())
+
+  // Nothing is counting down yet, but it will be once the letter is down
+  loser := tru.room.pm.players[(tru.room.pm.currentPlayerIdx + 1) % 2]
+  assert.NoError(t, tru.room.AffixLetter(tru.currentPlayerCookies(), "", "a"))
+  select {
+    case <-tru.asyncUpdateCh:
+    case <-time.After(time.Second):
+      t.Fatalf("the turn never timed out")
+  }
+  tru.room.mutex.Lock()
+  defer tru.room.mutex.Unlock()
+  assert.Equal(t, uint(4), loser.score)
+  assert.False(t, loser.isEliminated)
+  assert.False(t, tru.room.isSuddenDeathNext())
+  for _, p := range tru.room.pm.players {
+    p.score = 4
+  }
+  assert.True(t, tru.room.isSuddenDeathNext())
+}
+
+func TestPenaltyScoringWeightsStemLength(t *testing.T) {
+  tru := newTestRoomUtils(Config {
+    MaxPlayers: 16,
+    MinWordLength: 4,
+    Scoring: ScoringPenalty,
+    EliminationThreshold: 100,
+  })
+  assert.NoError(t, tru.addNPlayers(2))
+  for _, letter := range []string{"a", "b", "c"} {
+    assert.NoError(t, tru.room.AffixLetter(tru.currentPlayerCookies(), "",
+                                           letter))
+  }
+  loser := tru.room.pm.currentPlayer()
+  assert.NoError(t, tru.room.Concede(tru.currentPlayerCookies()))
+  assert.Equal(t, uint(3), loser.score)
+  for _, p := range tru.room.pm.jPlayers() {
+    if p.Username == loser.username {
+      assert.Equal(t, "3 / 100 pts", p.ScoreDisplay)
+    }
+  }
+}
+
+func TestSuddenDeath(t *testing.T) {
+  tru := newTestRoomUtils(Config {
+    MaxPlayers: 16,
+    MinWordLength: 4,
+    Scoring: ScoringPenalty,
+    EliminationThreshold: 10,
+    SuddenDeath: true,
+  })
+  assert.NoError(t, tru.addNPlayers(2))
+
+  // Both players lose a 4 letter round: 8 points each, 2 short of the limit
+  for i := 0; i < 2; i++ {
+    for _, letter := range []string{"a", "b", "c", "d"} {
+      assert.NoError(t, tru.room.AffixLetter(tru.currentPlayerCookies(), "",
+                                             letter))
+    }
+    assert.NoError(t, tru.room.Concede(tru.currentPlayerCookies()))
+    if i == 0 {
+      assert.False(t, tru.room.isSuddenDeath)
+      assert.NoError(t, tru.room.AffixLetter(tru.currentPlayerCookies(), "",
+                                             "z"))
+      assert.NoError(t, tru.room.Concede(tru.currentPlayerCookies()))
+      assert.False(t, tru.room.isSuddenDeath)
+    }
+  }
+  // 5 each... keep losing alternately until both are tied on the brink
+  for tru.room.pm.players[0].score != tru.room.pm.players[1].score ||
+      !tru.room.scoring.onBrink(tru.room.pm.players[0].score) {
+    behind := tru.room.pm.players[0]
+    if tru.room.pm.players[1].score < behind.score {
+      behind = tru.room.pm.players[1]
+    }
+    assert.NoError(t, tru.room.AffixLetter(tru.currentPlayerCookies(), "",
+                                           "z"))
+    assert.NoError(t, tru.room.Concede(
+        []*http.Cookie{behind.cookie}))
+  }
+  assert.True(t, tru.room.isSuddenDeath)
+  last := tru.room.log.history[len(tru.room.log.history) - 1]
+  assert.Equal(t, kSuddenDeath, last.Type)
+
+  // A one letter loss is only 1 point, but it's sudden death
+  assert.NoError(t, tru.room.AffixLetter(tru.currentPlayerCookies(), "", "z"))
+  assert.NoError(t, tru.room.Concede(tru.currentPlayerCookies()))
+  assert.Equal(t, kWaitingToStart, tru.room.state)
+  assert.False(t, tru.room.isSuddenDeath)
+}
+
+func TestFischerIncrement(t *testing.T) {
+  tru := newTestRoomUtils(Config {
+    MaxPlayers: 16,
+    MinWordLength: 4,
+    PlayerTimePerWord: time.Second * 60,
+    TimeIncrement: time.Second * 5,
+  })
+  assert.NoError(t, tru.addNPlayers(2))
+
+  // The first move of a round isn't timed
+  assert.NoError(t, tru.room.AffixLetter(tru.currentPlayerCookies(), "", "a"))
+  p := tru.room.pm.currentPlayer()
+  assert.NoError(t, tru.room.AffixLetter(tru.currentPlayerCookies(), "", "b"))
+  assert.InDelta(t, float64(65 * time.Second), float64(p.timeRemaining),
+                 float64(time.Second))
+}
+
+func TestBronsteinDelayIsNotBanked(t *testing.T) {
+  tru := newTestRoomUtils(Config {
+    MaxPlayers: 16,
+    MinWordLength: 4,
+    PlayerTimePerWord: time.Second * 60,
+    TimeDelay: time.Second * 10,
+  })
+  assert.NoError(t, tru.addNPlayers(2))
+
+  assert.NoError(t, tru.room.AffixLetter(tru.currentPlayerCookies(), "", "a"))
+  assert.InDelta(t, float64(70 * time.Second),
+                 float64(time.Until(tru.room.pm.currentPlayerDeadline)),
+                 float64(time.Second))
+  p := tru.room.pm.currentPlayer()
+  assert.NoError(t, tru.room.AffixLetter(tru.currentPlayerCookies(), "", "b"))
+  assert.Equal(t, 60 * time.Second, p.timeRemaining)
+}
+
+func TestPerGameBankCarriesOverRounds(t *testing.T) {
+  tru := newTestRoomUtils(Config {
+    MaxPlayers: 16,
+    MinWordLength: 4,
+    EliminationThreshold: 3,
+    PlayerTimePerWord: time.Second * 5,
+    PlayerTimePerGame: time.Second * 60,
+  })
+  assert.NoError(t, tru.addNPlayers(2))
+  assert.Equal(t, 60 * time.Second, tru.room.pm.players[0].timeRemaining)
+
+  assert.NoError(t, tru.room.AffixLetter(tru.currentPlayerCookies(), "", "a"))
+  p := tru.room.pm.currentPlayer()
+  time.Sleep(10 * time.Millisecond)
+  assert.NoError(t, tru.room.AffixLetter(tru.currentPlayerCookies(), "", "b"))
+  assert.NoError(t, tru.room.Concede(tru.currentPlayerCookies()))
+  assert.Less(t, p.timeRemaining, 60 * time.Second)
+  assert.Greater(t, p.timeRemaining, 59 * time.Second)
+}
+
+func TestPerGameBankRunsOut(t *testing.T) {
+  tru := newTestRoomUtils(Config {
+    MaxPlayers: 16,
+    MinWordLength: 4,
+    PlayerTimePerGame: time.Millisecond * 50,
+  })
+  assert.NoError(t, tru.addNPlayers(2))
+
+  p := tru.room.pm.players[1]
+  assert.NoError(t, tru.room.AffixLetter(tru.currentPlayerCookies(), "", "a"))
+  select {
+    case <-time.After(time.Second):
+      t.Fatal("the player's bank never ran out")
+    case <-tru.asyncUpdateCh:
+  }
+  tru.room.mutex.Lock()
+  defer tru.room.mutex.Unlock()
+  assert.Equal(t, uint(1), p.score)
+  assert.Equal(t, 50 * time.Millisecond, p.timeRemaining)
+}
+
+func TestRebuttalClock(t *testing.T) {
+  tru := newTestRoomUtils(Config {
+    MaxPlayers: 16,
+    MinWordLength: 4,
+    PlayerTimePerGame: time.Second * 60,
+    RebuttalTime: time.Second * 5,
+  })
+  assert.NoError(t, tru.addNPlayers(2))
+
+  assert.NoError(t, tru.room.AffixLetter(tru.currentPlayerCookies(), "", "s"))
+  assert.NoError(t, tru.room.AffixLetter(tru.currentPlayerCookies(), "", "t"))
+  assert.NoError(t, tru.room.ChallengeContinuation(tru.currentPlayerCookies()))
+
+  rebutter := tru.room.pm.currentPlayer()
+  bank := rebutter.timeRemaining
+  assert.InDelta(t, float64(5 * time.Second),
+                 float64(time.Until(tru.room.pm.currentPlayerDeadline)),
+                 float64(time.Second))
+  time.Sleep(10 * time.Millisecond)
+  assert.NoError(t, tru.room.RebutChallenge(tru.currentPlayerCookies(), "",
+                                            "em"))
+  assert.Equal(t, bank, rebutter.timeRemaining)
+}
+
+// A countdown that runs out just as the game is paused waits for the lock,
+// and must not time the player out once it gets it
+func TestPauseStopsExpiredCountdown(t *testing.T) {
+  tru := newTestRoomUtils(Config {
+    MaxPlayers: 2,
+    MinWordLength: 4,
+    EliminationThreshold: 0,
+    PlayerTimePerWord: 20 * time.Millisecond,
+  })
+  assert.NoError(t, tru.addNPlayers(2))
+  assert.NoError(t, tru.room.AffixLetter(tru.currentPlayerCookies(), "", "a"))
+  p := tru.room.pm.currentPlayer()
+
+  // What Pause (and then Resume) do, after the countdown has run out but
+  // before it gets the lock
+  pauseLate := func(resume bool) {
+    tru.room.mutex.Lock()
+    defer tru.room.mutex.Unlock()
+    time.Sleep(50 * time.Millisecond)
+    tru.room.isPaused = true
+    tru.room.isClockPaused = true
+    tru.room.stopClock(0)
+    if resume {
+      tru.room.isPaused = false
+      tru.room.isClockPaused = false
+      tru.room.startTurnAndCountdown(tru.room.pm.currentPlayerUsername())
+    }
+  }
+  pauseLate(false)
+  select {
+    case <-tru.asyncUpdateCh:
+      t.Fatalf("the player was timed out while the game was paused")
+    case <-time.After(50 * time.Millisecond):
+  }
+  assert.Equal(t, uint(0), p.score)
+
+  // Likewise when the game is resumed before the stale countdown gets the
+  // lock: only the new countdown may time the player out, and only once
+  assert.NoError(t, tru.room.Resume(tru.getCookiesFromPlayerIdx(0)))
+  pauseLate(true)
+  select {
+    case <-tru.asyncUpdateCh:
+    case <-time.After(time.Second):
+      t.Fatalf("the resumed countdown never ran out")
+  }
+  select {
+    case <-tru.asyncUpdateCh:
+      t.Fatalf("the player was timed out twice")
+    case <-time.After(50 * time.Millisecond):
+  }
+  tru.room.mutex.Lock()
+  defer tru.room.mutex.Unlock()
+  assert.Equal(t, uint(1), p.score)
+  assert.Nil(t, tru.room.endTurnCh)
+}
+
+func TestHostPauseKeepsTimeRemaining(t *testing.T) {
+  tru := newDefaultTimedNoEliminationTestRoomUtils()
+  assert.NoError(t, tru.addNPlayers(2))
+  host := tru.getCookiesFromPlayerIdx(0)
+
+  err := tru.room.Resume(host)
+  assert.ErrorIs(t, err, ErrWrongState)
+
+  assert.NoError(t, tru.room.AffixLetter(tru.currentPlayerCookies(), "", "a"))
+  p := tru.room.pm.currentPlayer()
+  time.Sleep(10 * time.Millisecond)
+  assert.NoError(t, tru.room.Pause(host))
+  assert.True(t, tru.room.isPaused)
+  assert.False(t, tru.room.pm.doesDeadlineExist())
+  assert.Nil(t, tru.room.endTurnCh)
+  assert.Less(t, p.timeRemaining, 60 * time.Second)
+  assert.Greater(t, p.timeRemaining, 59 * time.Second)
+  last := tru.room.log.history[len(tru.room.log.history) - 1]
+  assert.Equal(t, kPause, last.Type)
+  assert.Equal(t, "0", last.From)
+
+  err = tru.room.AffixLetter(tru.currentPlayerCookies(), "", "b")
+  assert.ErrorIs(t, err, ErrPaused)
+  err = tru.room.Concede(tru.currentPlayerCookies())
+  assert.ErrorIs(t, err, ErrPaused)
+
+  remaining := p.timeRemaining
+  assert.NoError(t, tru.room.Resume(host))
+  assert.False(t, tru.room.isPaused)
+  assert.InDelta(t, float64(remaining),
+                 float64(time.Until(tru.room.pm.currentPlayerDeadline)),
+                 float64(time.Second))
+  last = tru.room.log.history[len(tru.room.log.history) - 1]
+  assert.Equal(t, kResume, last.Type)
+  assert.NoError(t, tru.room.AffixLetter(tru.currentPlayerCookies(), "", "b"))
+}
+
+func TestUnanimousPauseVote(t *testing.T) {
+  tru := newDefaultTimedNoEliminationTestRoomUtils()
+  assert.NoError(t, tru.addNPlayers(4))
+
+  // Nothing to pause before the first move, but the game has started. The
+  // host never votes, so the other players carry it alone.
+  assert.NoError(t, tru.room.Pause(tru.cookiesOf("1")))
+  assert.False(t, tru.room.isPaused)
+  assert.Equal(t, []string{"1"}, tru.room.pauseVoters())
+  assert.NoError(t, tru.room.Pause(tru.cookiesOf("2")))
+  assert.False(t, tru.room.isPaused)
+  assert.NoError(t, tru.room.Pause(tru.cookiesOf("3")))
+  assert.True(t, tru.room.isPaused)
+  assert.False(t, tru.room.isClockPaused)
+  assert.Empty(t, tru.room.pauseVoters())
+
+  // Resuming takes everyone too, and a player leaving drops their vote
+  assert.NoError(t, tru.room.Resume(tru.cookiesOf("1")))
+  assert.NoError(t, tru.room.Resume(tru.cookiesOf("2")))
+  assert.NoError(t, tru.room.Leave(tru.cookiesOf("1")))
+  assert.Equal(t, []string{"2"}, tru.room.pauseVoters())
+  assert.True(t, tru.room.isPaused)
+  assert.NoError(t, tru.room.Resume(tru.cookiesOf("3")))
+  assert.False(t, tru.room.isPaused)
+}
+
+// A vote cast before becoming host doesn't stand in for another player's
+func TestPauseVoteOfNewHost(t *testing.T) {
+  tru := newDefaultTimedNoEliminationTestRoomUtils()
+  assert.NoError(t, tru.addNPlayers(4))
+
+  assert.NoError(t, tru.room.Pause(tru.cookiesOf("1")))
+  assert.NoError(t, tru.room.Leave(tru.cookiesOf("0")))
+  assert.Equal(t, "1", tru.room.pm.hostPlayer().username)
+  assert.NoError(t, tru.room.Pause(tru.cookiesOf("2")))
+  assert.False(t, tru.room.isPaused)
+  assert.NoError(t, tru.room.Pause(tru.cookiesOf("3")))
+  assert.True(t, tru.room.isPaused)
+}
+
+func newJuryTestRoomUtils(juryTime time.Duration) *testRoomUtils {
+  return newTestRoomUtils(Config {
+    MaxPlayers: 16,
+    MinWordLength: 4,
+    JuryTime: juryTime,
+  })
+}
+
+// Has the current player spell out GHOST, ending on the next player's turn
+func (tru *testRoomUtils) spellGhost(t *testing.T) {
+  for _, letter := range []string{"g", "h", "o", "s", "t"} {
+    assert.NoError(t, tru.room.AffixLetter(tru.currentPlayerCookies(), "",
+                                           letter))
+  }
+}
+
+func TestJuryOverturnsRuling(t *testing.T) {
+  tru := newJuryTestRoomUtils(time.Minute)
+  assert.NoError(t, tru.addNPlayers(4))
+  tru.spellGhost(t)
+
+  challenger := tru.room.pm.currentPlayer()
+  challenged := tru.room.pm.usernameToPlayer[tru.room.pm.lastPlayerUsername]
+  assert.NoError(t, tru.room.ChallengeIsWord(tru.currentPlayerCookies()))
+  assert.Equal(t, kJury, tru.room.state)
+  // Nobody is scored until the vote closes
+  assert.Zero(t, challenged.score)
+  last := tru.room.log.history[len(tru.room.log.history) - 1]
+  assert.Equal(t, kChallengeResult, last.Type)
+  assert.Equal(t, challenged.username, last.To)
+
+  jurors := tru.room.jJury().Jurors
+  assert.Len(t, jurors, 2)
+  assert.ErrorIs(t, tru.room.VoteOnRuling([]*http.Cookie{challenger.cookie},
+                                          true), ErrNotJuror)
+  err := tru.room.AffixLetter(tru.currentPlayerCookies(), "", "s")
+  assert.ErrorIs(t, err, ErrWrongState)
+
+  juror := func(i int) []*http.Cookie {
+    return []*http.Cookie{tru.room.pm.usernameToPlayer[jurors[i]].cookie}
+  }
+  assert.NoError(t, tru.room.VoteOnRuling(juror(0), true))
+  assert.Equal(t, kJury, tru.room.state)
+  assert.NoError(t, tru.room.VoteOnRuling(juror(1), true))
+
+  // Everyone voted, so it closed early
+  assert.Equal(t, kEdit, tru.room.state)
+  assert.Nil(t, tru.room.jury)
+  assert.Zero(t, challenged.score)
+  assert.Equal(t, uint(1), challenger.score)
+  assert.False(t, tru.room.usedWords["GHOST"])
+  last = tru.room.log.history[len(tru.room.log.history) - 1]
+  assert.Equal(t, kJuryVerdict, last.Type)
+  assert.True(t, *last.Success)
+  assert.Equal(t, challenger.username, last.To)
+  assert.Equal(t, 2, last.VotesToOverturn)
+}
+
+func TestJuryEliminationIsLogged(t *testing.T) {
+  tru := newTestRoomUtils(Config {
+    MaxPlayers: 16,
+    MinWordLength: 4,
+    EliminationThreshold: 1,
+    JuryTime: time.Minute,
+  })
+  assert.NoError(t, tru.addNPlayers(4))
+  tru.spellGhost(t)
+
+  challenged := tru.room.pm.lastPlayerUsername
+  assert.NoError(t, tru.room.ChallengeIsWord(tru.currentPlayerCookies()))
+  for _, juror := range tru.room.jJury().Jurors {
+    assert.NoError(t, tru.room.VoteOnRuling(
+        []*http.Cookie{tru.room.pm.usernameToPlayer[juror].cookie}, false))
+  }
+  assert.Nil(t, tru.room.jury)
+  assert.True(t, tru.room.pm.usernameToPlayer[challenged].isEliminated)
+
+  history := tru.room.log.history
+  for i, item := range history {
+    if item.Type == kJuryVerdict {
+      assert.Greater(t, len(history), i + 1)
+      assert.Equal(t, kEliminated, history[i + 1].Type)
+      assert.Equal(t, challenged, history[i + 1].From)
+      return
+    }
+  }
+  t.Fatalf("no verdict in the log: %+v", history)
+}
+
+func TestJuryTieUpholdsRulingWhenTimeRunsOut(t *testing.T) {
+  tru := newJuryTestRoomUtils(50 * time.Millisecond)
+  assert.NoError(t, tru.addNPlayers(5))
+  tru.spellGhost(t)
+
+  challenged := tru.room.pm.usernameToPlayer[tru.room.pm.lastPlayerUsername]
+  assert.NoError(t, tru.room.ChallengeIsWord(tru.currentPlayerCookies()))
+  // One for, one against and one abstention
+  jurors := tru.room.jJury().Jurors
+  assert.Len(t, jurors, 3)
+  assert.NoError(t, tru.room.VoteOnRuling(
+      []*http.Cookie{tru.room.pm.usernameToPlayer[jurors[0]].cookie}, true))
+  assert.NoError(t, tru.room.VoteOnRuling(
+      []*http.Cookie{tru.room.pm.usernameToPlayer[jurors[1]].cookie}, false))
+
+  select {
+    case <-time.After(time.Second):
+      t.Fatal("the vote never closed")
+    case <-tru.asyncUpdateCh:
+  }
+  tru.room.mutex.Lock()
+  defer tru.room.mutex.Unlock()
+  assert.Equal(t, kEdit, tru.room.state)
+  assert.Equal(t, uint(1), challenged.score)
+  assert.True(t, tru.room.usedWords["GHOST"])
+  last := tru.room.log.history[len(tru.room.log.history) - 1]
+  assert.Equal(t, kJuryVerdict, last.Type)
+  assert.False(t, *last.Success)
+}
+
+func TestNoJuryWithoutJurors(t *testing.T) {
+  tru := newJuryTestRoomUtils(time.Minute)
+  assert.NoError(t, tru.addNPlayers(2))
+  tru.spellGhost(t)
+
+  assert.NoError(t, tru.room.ChallengeIsWord(tru.currentPlayerCookies()))
+  assert.Equal(t, kEdit, tru.room.state)
+  assert.Nil(t, tru.room.jury)
+}
+
+func TestNormalizeWordList(t *testing.T) {
+  english := LanguageEnglish.Alphabet()
+  words, err := NormalizeWordList(
+      ParseWordList("stem, Ghost\nghost  wordy"), english)
+  assert.NoError(t, err)
+  assert.Equal(t, []string{"GHOST", "STEM", "WORDY"}, words)
+
+  words, err = NormalizeWordList([]string{"ok", "not ok", "r2d2"}, english)
+  assert.ErrorIs(t, err, ErrInvalidWord)
+  assert.Equal(t, []string{"OK"}, words)
+}
+
+func TestWordListsOverrideDictionary(t *testing.T) {
+  tru := newTestRoomUtils(Config {
+    MaxPlayers: 16,
+    MinWordLength: 4,
+    AllowedWords: []string{"wordy"},
+    BlockedWords: []string{"ghost", "wordy"},
+  })
+  assert.Equal(t, []string{"WORDY"}, tru.room.config.AllowedWords)
+  assert.NoError(t, tru.addNPlayers(2))
+  tru.spellGhost(t)
+
+  // GHOST is in the dictionary, but blocked
+  challenger := tru.room.pm.currentPlayer()
+  assert.NoError(t, tru.room.ChallengeIsWord(tru.currentPlayerCookies()))
+  assert.Equal(t, uint(1), challenger.score)
+
+  isWord, err := tru.room.dictionary.IsWord("WORDY")
+  assert.NoError(t, err)
+  assert.False(t, isWord)
+}
+
+func TestSetWordLists(t *testing.T) {
+  tru := newTestRoomUtils(Config {
+    MaxPlayers: 16,
+    MinWordLength: 4,
+  })
+  assert.NoError(t, tru.addNPlayers(1))
+  host := tru.getCookiesFromPlayerIdx(0)
+
+  err := tru.room.SetWordLists(host, []string{"x-ray"}, nil)
+  assert.ErrorIs(t, err, ErrInvalidWord)
+  assert.NoError(t, tru.room.SetWordLists(host, []string{"wordy"},
+                                          []string{"stem"}))
+  assert.Equal(t, []string{"WORDY"}, tru.room.config.AllowedWords)
+  assert.Equal(t, []string{"STEM"}, tru.room.config.BlockedWords)
+  isWord, err := tru.room.dictionary.IsWord("WORDY")
+  assert.NoError(t, err)
+  assert.True(t, isWord)
+
+  assert.NoError(t, tru.addNPlayers(1))
+  err = tru.room.SetWordLists(tru.getCookiesFromPlayerIdx(1), nil, nil)
+  assert.ErrorIs(t, err, ErrNotHost)
+  // Not once the game has started
+  err = tru.room.SetWordLists(host, nil, nil)
+  assert.ErrorIs(t, err, ErrWrongState)
+}
+
+func TestAlphabetNormalize(t *testing.T) {
+  spanish := LanguageSpanish.Alphabet()
+  assert.Equal(t, "NIÑO", spanish.Normalize("niño"))
+  assert.Equal(t, "CANCION", spanish.Normalize("canción"))
+  // n followed by a combining tilde
+  assert.Equal(t, "AÑO", spanish.Normalize("an\u0303o"))
+  assert.True(t, spanish.IsLetters("NIÑO"))
+
+  german := LanguageGerman.Alphabet()
+  assert.Equal(t, "STRASSE", german.Normalize("Straße"))
+  assert.Equal(t, "BÄR", german.Normalize("Bär"))
+
+  french := LanguageFrench.Alphabet()
+  assert.Equal(t, "COEUR", french.Normalize("cœur"))
+  assert.Equal(t, "ETE", french.Normalize("e\u0301te\u0301"))
+
+  english := LanguageEnglish.Alphabet()
+  assert.False(t, english.IsLetters(english.Normalize("café")))
+  assert.False(t, spanish.IsLetters(spanish.Normalize("r2d2")))
+  assert.False(t, german.IsLetters(""))
+}
+
+func TestLanguageDictionaries(t *testing.T) {
+  for language, word := range map[Language]string {
+    LanguageSpanish: "NINO",  // Not a word: Ñ is its own letter
+    LanguageGerman: "STRASSE",
+    LanguageFrench: "FENETRE",
+  } {
+    isWord, err := language.defaultDictionary().IsWord(word)
+    assert.NoError(t, err)
+    assert.Equal(t, language != LanguageSpanish, isWord, word)
+  }
+  isWord, _ := LanguageSpanish.defaultDictionary().IsWord("NIÑO")
+  assert.True(t, isWord)
+}
+
+func TestLanguageWordLists(t *testing.T) {
+  words := map[Language][]string {
+    LanguageSpanish: {"NIÑOS", "CANTABAMOS", "ARBOLES"},
+    LanguageGerman: {"HÄUSER", "STRASSEN", "GRÜNEN", "FLUGHAFEN"},
+    LanguageFrench: {"GARCON", "ELEVES", "CHANTERIONS"},
+  }
+  for language, list := range words {
+    wl := language.WordList()
+    assert.Greater(t, wl.Len(), 100000, language)
+    for _, w := range list {
+      ok, err := wl.IsWord(w)
+      assert.NoError(t, err)
+      assert.True(t, ok, w)
+    }
+  }
+  room, err := NewRoom(Config{ MaxPlayers: 2, Language: LanguageFrench },
+                       make(chan struct{}))
+  assert.NoError(t, err)
+  assert.Equal(t, LanguageFrench, room.config.Language)
+  assert.Equal(t, LanguageFrench.WordList(), room.config.Dictionary)
+
+  // Unknown languages are an error, not English
+  _, err = NewRoom(Config{ MaxPlayers: 2, Language: "tlh" },
+                   make(chan struct{}))
+  assert.ErrorIs(t, err, ErrInvalidLanguage)
+  room, err = NewRoom(Config{ MaxPlayers: 2 }, make(chan struct{}))
+  assert.NoError(t, err)
+  assert.Equal(t, LanguageEnglish, room.config.Language)
+}
+
+func TestSpanishRoom(t *testing.T) {
+  tru := newTestRoomUtils(Config {
+    Variant: VariantSuperduperghost,
+    MaxPlayers: 16,
+    MinWordLength: 4,
+    Language: LanguageSpanish,
+    Dictionary: NewWordList([]string{"NIÑO"}),
+  })
+  assert.NoError(t, tru.addNPlayers(2))
+
+  assert.NoError(t, tru.room.AffixLetter(tru.currentPlayerCookies(), "", "ñ"))
+  assert.NoError(t, tru.room.InsertLetter(tru.currentPlayerCookies(), 0, "í"))
+  // The index counts letters, so this is the end of the stem
+  assert.NoError(t, tru.room.InsertLetter(tru.currentPlayerCookies(), 2, "o"))
+  assert.Equal(t, "IÑO", tru.room.stem)
+  err := tru.room.AffixLetter(tru.currentPlayerCookies(), "", "ç")
+  assert.ErrorIs(t, err, ErrInvalidMove)
+
+  assert.NoError(t, tru.room.ChallengeContinuation(tru.currentPlayerCookies()))
+  challenger := tru.room.pm.usernameToPlayer[tru.room.pm.lastPlayerUsername]
+  assert.NoError(t, tru.room.RebutChallengeWithWord(tru.currentPlayerCookies(),
+                                                    "niño"))
+  assert.Equal(t, uint(1), challenger.score)
+}
+
+// A remote dictionary that knows _testWords, counts its lookups and can be
+// made to fail
+type flakyDictionary struct {
+  lookups int
+  failing bool
+}
+
+func (d *flakyDictionary) IsWord(word string) (bool, error) {
+  d.lookups++
+  if d.failing {
+    return false, ErrDictionaryUnavailable
+  }
+  return NewWordList(_testWords).IsWord(word)
+}
+
+func (d *flakyDictionary) WordsWithSubsequence(stem string,
+                                               limit int) ([]string, error) {
+  d.lookups++
+  if d.failing {
+    return nil, ErrDictionaryUnavailable
+  }
+  return NewWordList(_testWords).WordsWithSubsequence(stem, limit)
+}
+
+func TestCachedDictionarySearches(t *testing.T) {
+  remote := new(flakyDictionary)
+  d := NewCachedDictionary(remote, DictionaryCacheConfig {
+    FailureThreshold: 1,
+    Cooldown: time.Minute,
+    Fallback: NewWordList([]string{"STEM"}),
+  })
+  words, err := d.WordsWithSubsequence("SE", 10)
+  assert.NoError(t, err)
+  assert.Equal(t, []string{"BASTE", "STEM"}, words)
+
+  // The fallback answers while the remote dictionary is failing
+  remote.failing = true
+  words, err = d.WordsWithSubsequence("SE", 10)
+  assert.NoError(t, err)
+  assert.Equal(t, []string{"STEM"}, words)
+  assert.Equal(t, 2, remote.lookups)
+  d.WordsWithSubsequence("SE", 10)
+  assert.Equal(t, 2, remote.lookups)
+}
+
+func TestCachedDictionaryRemembersAnswers(t *testing.T) {
+  remote := new(flakyDictionary)
+  now := time.Now()
+  d := NewCachedDictionary(remote, DictionaryCacheConfig {
+    NegativeTTL: time.Hour,
+  })
+  d.now = func() time.Time { return now }
+
+  for i := 0; i < 2; i++ {
+    isWord, err := d.IsWord("GHOST")
+    assert.NoError(t, err)
+    assert.True(t, isWord)
+    isWord, err = d.IsWord("GHOS")
+    assert.NoError(t, err)
+    assert.False(t, isWord)
+  }
+  assert.Equal(t, 2, remote.lookups)
+  stats := d.Stats()
+  assert.Equal(t, 2, stats.Hits)
+  assert.Equal(t, 1, stats.NegativeHits)
+  assert.Equal(t, 0.5, stats.HitRatio)
+
+  // Only the negative entry expires
+  now = now.Add(2 * time.Hour)
+  d.IsWord("GHOST")
+  d.IsWord("GHOS")
+  assert.Equal(t, 3, remote.lookups)
+}
+
+func TestCachedDictionaryEvictsLeastRecentlyUsed(t *testing.T) {
+  remote := new(flakyDictionary)
+  d := NewCachedDictionary(remote, DictionaryCacheConfig { Size: 2 })
+  d.IsWord("GHOST")
+  d.IsWord("STEM")
+  d.IsWord("GHOST")
+  d.IsWord("BASTE")  // Evicts STEM
+  assert.Equal(t, 3, remote.lookups)
+  d.IsWord("GHOST")
+  assert.Equal(t, 3, remote.lookups)
+  d.IsWord("STEM")
+  assert.Equal(t, 4, remote.lookups)
+  assert.Equal(t, 2, d.Stats().Evictions)
+}
+
+func TestCachedDictionaryBreakerFallsBack(t *testing.T) {
+  remote := &flakyDictionary{ failing: true }
+  now := time.Now()
+  d := NewCachedDictionary(remote, DictionaryCacheConfig {
+    FailureThreshold: 2,
+    Cooldown: time.Minute,
+    Fallback: NewWordList([]string{"STEM"}),
+  })
+  d.now = func() time.Time { return now }
+
+  for i := 0; i < 4; i++ {
+    isWord, err := d.IsWord("STEM")
+    assert.NoError(t, err)
+    assert.True(t, isWord)
+  }
+  // The breaker opened after two failures
+  assert.Equal(t, 2, remote.lookups)
+  assert.True(t, d.Stats().BreakerOpen)
+  assert.Equal(t, 4, d.Stats().Fallbacks)
+  // The fallback can't tell a word it doesn't know from a non-word
+  _, err := d.IsWord("GHOST")
+  assert.ErrorIs(t, err, ErrDictionaryUnavailable)
+  _, err = d.IsWord("GHOS")
+  assert.ErrorIs(t, err, ErrDictionaryUnavailable)
+  assert.Equal(t, 4, d.Stats().Fallbacks)
+
+  // Once the cooldown is over, one lookup tries the remote again. The
+  // fallback's answers weren't cached.
+  now = now.Add(time.Minute)
+  remote.failing = false
+  isWord, err := d.IsWord("GHOST")
+  assert.NoError(t, err)
+  assert.True(t, isWord)
+  assert.Equal(t, 3, remote.lookups)
+  assert.False(t, d.Stats().BreakerOpen)
+  d.IsWord("STEM")
+  assert.Equal(t, 4, remote.lookups)
+}
+
+func TestCachedDictionaryWithoutFallbackFails(t *testing.T) {
+  d := NewCachedDictionary(&flakyDictionary{ failing: true },
+                           DictionaryCacheConfig{})
+  _, err := d.IsWord("GHOST")
+  assert.ErrorIs(t, err, ErrDictionaryUnavailable)
+}
+
+func TestCachedDictionarySaveAndLoad(t *testing.T) {
+  path := t.TempDir() + "/cache.json"
+  d := NewCachedDictionary(new(flakyDictionary), DictionaryCacheConfig{})
+  d.IsWord("GHOST")
+  d.IsWord("GHOS")
+  assert.NoError(t, d.Save(path))
+
+  remote := new(flakyDictionary)
+  loaded := NewCachedDictionary(remote, DictionaryCacheConfig{})
+  assert.NoError(t, loaded.Load(path))
+  isWord, _ := loaded.IsWord("GHOST")
+  assert.True(t, isWord)
+  isWord, _ = loaded.IsWord("GHOS")
+  assert.False(t, isWord)
+  assert.Equal(t, 0, remote.lookups)
+
+  assert.NoError(t, loaded.Load(path + ".missing"))
+}
+
+func TestFailedLookupLeavesRoomUnchanged(t *testing.T) {
+  tru := newTestRoomUtils(Config {
+    MaxPlayers: 16,
+    MinWordLength: 4,
+    Dictionary: &flakyDictionary{ failing: true },
+  })
+  assert.NoError(t, tru.addNPlayers(2))
+  tru.spellGhost(t)
+
+  logLength := len(tru.room.log.history)
+  err := tru.room.ChallengeIsWord(tru.currentPlayerCookies())
+  assert.ErrorIs(t, err, ErrDictionaryUnavailable)
+  assert.Equal(t, logLength, len(tru.room.log.history))
+  assert.Equal(t, kEdit, tru.room.state)
+}
+
+func newTestDefinitionFile(lines ...string) *DefinitionFile {
+  data := []byte(strings.Join(lines, "\n"))
+  df := NewDefinitionFile(bytes.NewReader(data), int64(len(data)))
+  <-df.indexed
+  return df
+}
+
+func TestDefinitionFile(t *testing.T) {
+  df := newTestDefinitionFile(
+    `{"word": "ghost", "origin": "Old English gāst", "meanings": [` +
+        `{"partOfSpeech": "noun", "definitions": [{"definition": "A spirit"}]}]}`,
+    `not json`,
+    `{"word": "ghost", "meanings": [{"partOfSpeech": "verb", ` +
+        `"definitions": [{"definition": "To haunt"}]}]}`,
+    `{"word": "stem", "meanings": []}`)
+
+  assert.Equal(t, &Definition{
+    PartOfSpeech: "noun",
+    Text: "A spirit",
+    Etymology: "Old English gāst",
+  }, df.Define("GHOST"))
+  assert.Nil(t, df.Define("STEM"))
+  assert.Nil(t, df.Define("BASTE"))
+  assert.Len(t, df.cache, 3)
+}
+
+func TestChallengeResultIsDefined(t *testing.T) {
+  tru := newTestRoomUtils(Config {
+    MaxPlayers: 16,
+    MinWordLength: 4,
+    Definitions: newTestDefinitionFile(
+        `{"word": "ghost", "meanings": [{"partOfSpeech": "noun", ` +
+            `"definitions": [{"definition": "A spirit"}]}]}`),
+  })
+  assert.NoError(t, tru.addNPlayers(2))
+  tru.spellGhost(t)
+
+  assert.NoError(t, tru.room.ChallengeIsWord(tru.currentPlayerCookies()))
+  var result LogItem
+  for _, item := range tru.room.log.history {
+    if item.Type == kChallengeResult {
+      result = item
+    }
+  }
+  if assert.NotNil(t, result.Definition) {
+    assert.Equal(t, "A spirit", result.Definition.Text)
+  }
+}
+
+func TestBundledDefinitions(t *testing.T) {
+  df := LanguageEnglish.defaultDefinitions().(*DefinitionFile)
+  <-df.indexed
+  assert.Contains(t, df.Define("AARDVARK").Text, "burrowing")
+  assert.Nil(t, LanguageSpanish.defaultDefinitions())
+}
+
+func newPracticeTestRoomUtils(variant Variant) *testRoomUtils {
+  return newTestRoomUtils(Config {
+    Variant: variant,
+    MaxPlayers: 16,
+    MinWordLength: 4,
+    Practice: true,
+  })
+}
+
+func TestHintOnlyInPracticeRooms(t *testing.T) {
+  tru := newDefaultTimedNoEliminationTestRoomUtils()
+  assert.NoError(t, tru.addNPlayers(2))
+  _, err := tru.room.Hint(tru.currentPlayerCookies())
+  assert.ErrorIs(t, err, ErrNotPractice)
+
+  tru = newPracticeTestRoomUtils(VariantSuperghost)
+  assert.NoError(t, tru.addNPlayers(2))
+  _, err = tru.room.Hint(tru.getCookiesFromPlayerIdx(
+      (tru.room.pm.currentPlayerIdx + 1) % 2))
+  assert.ErrorIs(t, err, ErrNotYourTurn)
+}
+
+func TestHintSuggestsSafeLetters(t *testing.T) {
+  tru := newPracticeTestRoomUtils(VariantSuperghost)
+  assert.NoError(t, tru.addNPlayers(2))
+  assert.NoError(t, tru.room.AffixLetter(tru.currentPlayerCookies(), "", "s"))
+  assert.NoError(t, tru.room.AffixLetter(tru.currentPlayerCookies(), "", "t"))
+
+  player := tru.room.pm.currentPlayer()
+  hint, err := tru.room.Hint(tru.currentPlayerCookies())
+  assert.NoError(t, err)
+  // ABSTAIN, BASTE and ABSTAIN again, in alphabetical order
+  assert.Equal(t, []HintMove{{ Suffix: "A" }, { Prefix: "A" }, { Prefix: "B" }},
+               hint.Moves)
+  assert.Equal(t, "STEM", hint.Example)
+  assert.Equal(t, 1, player.jPlayer().HintsUsed)
+  last := tru.room.log.history[len(tru.room.log.history) - 1]
+  assert.Equal(t, kHint, last.Type)
+  assert.Equal(t, player.username, last.From)
+}
+
+func TestHintAvoidsSpellingWords(t *testing.T) {
+  tru := newPracticeTestRoomUtils(VariantGhost)
+  assert.NoError(t, tru.addNPlayers(2))
+  for _, letter := range []string{"g", "h", "o", "s"} {
+    assert.NoError(t, tru.room.AffixLetter(tru.currentPlayerCookies(), "",
+                                           letter))
+  }
+  hint, err := tru.room.Hint(tru.currentPlayerCookies())
+  assert.NoError(t, err)
+  assert.Empty(t, hint.Moves)
+  assert.Equal(t, "GHOST", hint.Example)
+}
+
+// A dictionary that isn't a word list but can be searched, like WordsAPI
+type searchableDictionary struct {
+  words *WordList
+  searches int
+}
+
+func (d *searchableDictionary) IsWord(word string) (bool, error) {
+  return d.words.IsWord(word)
+}
+
+func (d *searchableDictionary) WordsWithSubsequence(
+    stem string, limit int) ([]string, error) {
+  d.searches++
+  return d.words.WordsWithSubsequence(stem, limit)
+}
+
+func TestHintSearchesTheDictionary(t *testing.T) {
+  d := &searchableDictionary{ words: NewWordList([]string{"QUIXOTIC"}) }
+  tru := newTestRoomUtils(Config {
+    Variant: VariantGhost,
+    MaxPlayers: 2,
+    MinWordLength: 4,
+    Practice: true,
+    Dictionary: d,
+  })
+  assert.NoError(t, tru.addNPlayers(2))
+  for _, letter := range []string{"q", "u", "i", "x"} {
+    assert.NoError(t, tru.room.AffixLetter(tru.currentPlayerCookies(), "",
+                                           letter))
+  }
+  // The bundled English list knows no word starting QUIX
+  hint, err := tru.room.Hint(tru.currentPlayerCookies())
+  assert.NoError(t, err)
+  assert.Equal(t, []HintMove{{ Suffix: "O" }}, hint.Moves)
+  assert.Equal(t, "QUIXOTIC", hint.Example)
+  assert.Equal(t, 1, d.searches)
+}
+
+func TestGameAnalysis(t *testing.T) {
+  tru := newTestRoomUtils(Config {
+    MaxPlayers: 2,
+    MinWordLength: 4,
+    EliminationThreshold: 1,
+  })
+  assert.NoError(t, tru.addNPlayers(2))
+  _, err := tru.room.Analysis(1)
+  assert.ErrorIs(t, err, ErrNoAnalysis)
+
+  for _, letter := range []string{"s", "t", "e", "m"} {
+    assert.NoError(t, tru.room.AffixLetter(tru.currentPlayerCookies(), "",
+                                           letter))
+  }
+  loser := tru.room.pm.lastPlayerUsername
+  assert.NoError(t, tru.room.ChallengeIsWord(tru.currentPlayerCookies()))
+  last := tru.room.log.history[len(tru.room.log.history) - 1]
+  assert.Equal(t, kGameOver, last.Type)
+  assert.Equal(t, 1, last.Game)
+
+  analysis, err := tru.room.Analysis(1)
+  assert.NoError(t, err)
+  assert.Equal(t, 1, analysis.Game)
+  assert.Len(t, analysis.Rounds, 1)
+  moves := analysis.Rounds[0].Moves
+  assert.Len(t, moves, 4)
+  assert.True(t, moves[0].WasSafe)
+  assert.Equal(t, "STEM", moves[0].Example)
+
+  // STEM spelled a word, when A (BASTE) was safe
+  assert.Equal(t, loser, moves[3].Player)
+  assert.Equal(t, "STE", moves[3].Stem)
+  assert.False(t, moves[3].WasSafe)
+  assert.False(t, moves[3].ForcedLosing)
+  assert.Equal(t, []HintMove{{ Prefix: "A" }}, moves[3].SafeMoves)
+}
+
+func TestGameAnalysisWithInsertions(t *testing.T) {
+  tru := newTestRoomUtils(Config {
+    Variant: VariantSuperduperghost,
+    MaxPlayers: 2,
+    MinWordLength: 4,
+    EliminationThreshold: 2,
+    Dictionary: NewWordList([]string{"CART"}),
+  })
+  assert.NoError(t, tru.addNPlayers(2))
+  first := tru.getCookiesFromPlayerIdx(0)
+  second := tru.getCookiesFromPlayerIdx(1)
+
+  // The first round ends with a concession on C
+  assert.NoError(t, tru.room.AffixLetter(first, "", "c"))
+  assert.NoError(t, tru.room.Concede(first))
+
+  assert.NoError(t, tru.room.AffixLetter(second, "", "c"))
+  assert.NoError(t, tru.room.AffixLetter(first, "", "t"))
+  assert.NoError(t, tru.room.InsertLetter(second, 1, "a"))
+  assert.NoError(t, tru.room.InsertLetter(first, 2, "r"))
+  assert.NoError(t, tru.room.ChallengeIsWord(second))
+  // Not worked out until it's asked for
+  assert.Nil(t, tru.room.games[0].analysis)
+
+  analysis, err := tru.room.Analysis(1)
+  assert.NoError(t, err)
+  assert.Equal(t, tru.room.pm.players[1].username, analysis.Winner)
+  if assert.Len(t, analysis.Rounds, 2) {
+    assert.Len(t, analysis.Rounds[0].Moves, 1)
+    moves := analysis.Rounds[1].Moves
+    assert.Len(t, moves, 4)
+    // Nothing can be added to either end of CT, but A or R can go in the
+    // middle
+    assert.Equal(t, "CT", moves[2].Stem)
+    assert.True(t, moves[2].WasSafe)
+    assert.False(t, moves[2].ForcedLosing)
+    one := 1
+    assert.Equal(t, []HintMove{{ Letter: "A", Index: &one },
+                               { Letter: "R", Index: &one }},
+                 moves[2].SafeMoves)
+    assert.True(t, moves[3].ForcedLosing)
+    assert.Equal(t, "R", moves[3].Letter)
+  }
+  again, err := tru.room.Analysis(1)
+  assert.NoError(t, err)
+  assert.Same(t, analysis, again)
+}
+
+func TestHintSuggestsReversing(t *testing.T) {
+  tru := newPracticeTestRoomUtils(VariantXghost)
+  assert.NoError(t, tru.addNPlayers(2))
+  assert.NoError(t, tru.room.AffixLetter(tru.currentPlayerCookies(), "", "h"))
+  assert.NoError(t, tru.room.AffixLetter(tru.currentPlayerCookies(), "", "g"))
+  // HG is in no word, but GH is in GHOST
+  hint, err := tru.room.Hint(tru.currentPlayerCookies())
+  assert.NoError(t, err)
+  assert.Equal(t, []HintMove{{ Reverse: true }}, hint.Moves)
+
+  // Only once per round
+  tru.room.isReversed = true
+  hint, err = tru.room.Hint(tru.currentPlayerCookies())
+  assert.NoError(t, err)
+  assert.Empty(t, hint.Moves)
+}
+
+func newTestPuzzleBook(now *time.Time) *PuzzleBook {
+  pb := NewPuzzleBook(LanguageEnglish)
+  pb.now = func() time.Time { return *now }
+  return pb
+}
+
+func TestPuzzleIsTheSameAllDay(t *testing.T) {
+  now := time.Date(2026, 3, 14, 1, 0, 0, 0, time.UTC)
+  pb := newTestPuzzleBook(&now)
+  morning, err := pb.Today()
+  assert.NoError(t, err)
+  assert.Equal(t, "2026-03-14", morning.Date)
+  assert.Equal(t, kPuzzleStemLength, letterCount(morning.Stem))
+  assert.GreaterOrEqual(t, morning.Solutions, kMinPuzzleSolutions)
+  assert.LessOrEqual(t, morning.Solutions, kMaxPuzzleSolutions)
+
+  now = now.Add(22 * time.Hour)
+  evening, err := newTestPuzzleBook(&now).Today()
+  assert.NoError(t, err)
+  assert.Equal(t, morning, evening)
+
+  // Some day soon has a different stem
+  different := false
+  for i := 0; i < 7 && !different; i++ {
+    now = now.AddDate(0, 0, 1)
+    puzzle, err := pb.Today()
+    assert.NoError(t, err)
+    different = puzzle.Stem != morning.Stem
+  }
+  assert.True(t, different)
+}
+
+func TestPuzzleGuesses(t *testing.T) {
+  now := time.Date(2026, 3, 14, 12, 0, 0, 0, time.UTC)
+  pb := newTestPuzzleBook(&now)
+  // Looking at the puzzle doesn't make a player
+  progress, err := pb.Progress(pb.Identify(nil))
+  assert.NoError(t, err)
+  assert.Empty(t, progress.Found)
+  assert.Empty(t, pb.players)
+
+  puzzle, err := pb.Today()
+  assert.NoError(t, err)
+  word := pb.continuations[puzzle.Stem][0]
+  i := strings.Index(word, puzzle.Stem)
+  prefix, suffix := word[:i], word[i + len(puzzle.Stem):]
+
+  // Nor does a guess that isn't checked
+  _, cookie, err := pb.Guess("", "/api/v1/puzzle", "", "")
+  assert.ErrorIs(t, err, ErrInvalidMove)
+  assert.Nil(t, cookie)
+  assert.Empty(t, pb.players)
+
+  guess, cookie, err := pb.Guess("", "/api/v1/puzzle",
+                                 strings.ToLower(prefix),
+                                 strings.ToLower(suffix))
+  assert.NoError(t, err)
+  assert.Equal(t, word, guess.Word)
+  assert.True(t, guess.IsWord)
+  assert.Equal(t, []string{word}, guess.Progress.Found)
+  assert.Equal(t, 1, guess.Progress.Streak)
+  if !assert.NotNil(t, cookie) {
+    return
+  }
+  assert.Equal(t, "/api/v1/puzzle", cookie.Path)
+  id := pb.Identify([]*http.Cookie{cookie})
+  assert.Equal(t, cookie.Value, id)
+
+  _, _, err = pb.Guess(id, "/", prefix, suffix)
+  assert.ErrorIs(t, err, ErrWordUsed)
+  guess, cookie, err = pb.Guess(id, "/", "QQ", "ZZ")
+  assert.NoError(t, err)
+  assert.Nil(t, cookie)
+  assert.False(t, guess.IsWord)
+  assert.Equal(t, 1, guess.Progress.Misses)
+  assert.Len(t, guess.Progress.Found, 1)
+}
+
+// Finds any word for today's puzzle, as the player with id or a new one.
+// Returns the player's ID.
+func solvePuzzle(t *testing.T, pb *PuzzleBook,
+                 id string) (string, *PuzzleGuess) {
+  puzzle, err := pb.Today()
+  assert.NoError(t, err)
+  word := pb.continuations[puzzle.Stem][0]
+  i := strings.Index(word, puzzle.Stem)
+  guess, cookie, err := pb.Guess(id, "/", word[:i],
+                                 word[i + len(puzzle.Stem):])
+  assert.NoError(t, err)
+  assert.True(t, guess.IsWord)
+  if cookie != nil {
+    id = cookie.Value
+  }
+  return id, guess
+}
+
+func TestPuzzleStreaks(t *testing.T) {
+  now := time.Date(2026, 3, 14, 12, 0, 0, 0, time.UTC)
+  pb := newTestPuzzleBook(&now)
+  id := ""
+
+  for day := 1; day <= 3; day++ {
+    var guess *PuzzleGuess
+    id, guess = solvePuzzle(t, pb, id)
+    assert.Equal(t, day, guess.Progress.Streak)
+    now = now.AddDate(0, 0, 1)
+  }
+  // The streak lasts until a whole day is missed, and yesterday's words are
+  // forgotten
+  progress, err := pb.Progress(id)
+  assert.NoError(t, err)
+  assert.Equal(t, 3, progress.Streak)
+  assert.Empty(t, progress.Found)
+
+  now = now.AddDate(0, 0, 1)
+  progress, err = pb.Progress(id)
+  assert.NoError(t, err)
+  assert.Equal(t, 0, progress.Streak)
+  assert.Equal(t, 3, progress.LongestStreak)
+
+  id, guess := solvePuzzle(t, pb, id)
+  assert.Equal(t, 1, guess.Progress.Streak)
+  assert.Equal(t, 3, guess.Progress.LongestStreak)
+  assert.Equal(t, id, pb.Identify([]*http.Cookie{{
+    Name: kPuzzleCookieName, Value: id,
+  }}))
+}
+
+func TestPuzzleEvictsLapsedPlayers(t *testing.T) {
+  now := time.Date(2026, 3, 14, 12, 0, 0, 0, time.UTC)
+  pb := newTestPuzzleBook(&now)
+  lapsed, _ := solvePuzzle(t, pb, "")
+
+  // The first player's streak is still alive today
+  now = now.AddDate(0, 0, 1)
+  kept, _ := solvePuzzle(t, pb, "")
+  assert.Contains(t, pb.players, lapsed)
+
+  now = now.AddDate(0, 0, 1)
+  solvePuzzle(t, pb, kept)
+  assert.NotContains(t, pb.players, lapsed)
+  assert.Contains(t, pb.players, kept)
+  assert.Len(t, pb.players, 1)
+}
+
+func TestPuzzleUsesDictionary(t *testing.T) {
+  now := time.Date(2026, 3, 14, 12, 0, 0, 0, time.UTC)
+  pb := newTestPuzzleBook(&now)
+  puzzle, err := pb.Today()
+  assert.NoError(t, err)
+  invented := "QX" + puzzle.Stem
+
+  guess, cookie, err := pb.Guess("", "/", "QX", "")
+  assert.NoError(t, err)
+  assert.False(t, guess.IsWord)
+  id := cookie.Value
+
+  pb.Dictionary = NewWordList([]string{invented})
+  guess, _, err = pb.Guess(id, "/", "QX", "")
+  assert.NoError(t, err)
+  assert.True(t, guess.IsWord)
+
+  pb.Dictionary = new(flakyDictionary)
+  pb.Dictionary.(*flakyDictionary).failing = true
+  _, _, err = pb.Guess(id, "/", "ZQ", "")
+  assert.ErrorIs(t, err, ErrDictionaryUnavailable)
+}
+
+func TestEntrantsOnly(t *testing.T) {
+  tru := newTestRoomUtils(Config {
+    MaxPlayers: 3,
+    Entrants: []string{"alice", "bob"},
+  })
+  _, err := tru.room.AddPlayer("alice", "/")
+  assert.NoError(t, err)
+  _, err = tru.room.AddPlayer("mallory", "/")
+  assert.ErrorIs(t, err, ErrNotEntrant)
+}
+
+// Rooms that are only remembered, by the entrants drawn into them
+type testMatchRooms struct {
+  t *testing.T
+  // Room ID to entrants
+  entrantsByRoom map[string][]string
+  made int
+  // Who's in each room, for forfeits
+  present map[string][]string
+  // How many more rooms can be made, or -1 for any number
+  spare int
+}
+
+func (mr *testMatchRooms) Create(config Config) (string, error) {
+  assert.Equal(mr.t, 2, config.MaxPlayers)
+  if mr.spare == 0 {
+    return "", errors.New("no rooms to spare")
+  }
+  mr.spare--
+  mr.made++
+  roomID := strconv.Itoa(mr.made)
+  mr.entrantsByRoom[roomID] = config.Entrants
+  return roomID, nil
+}
+
+func (mr *testMatchRooms) Remove(roomID string) {
+  delete(mr.entrantsByRoom, roomID)
+}
+
+func (mr *testMatchRooms) Players(roomID string) []string {
+  return mr.present[roomID]
+}
+
+type testTournament struct {
+  *Tournament
+  *testMatchRooms
+  organizer []*http.Cookie
+  entrants map[string]*http.Cookie
+}
+
+func newTestTournament(t *testing.T, format TournamentFormat,
+                       players ...string) *testTournament {
+  tt := &testTournament{
+    testMatchRooms: &testMatchRooms{
+      t: t,
+      entrantsByRoom: make(map[string][]string),
+      present: make(map[string][]string),
+      spare: -1,
+    },
+    entrants: make(map[string]*http.Cookie),
+  }
+  var cookie *http.Cookie
+  tt.Tournament, cookie = NewTournament(TournamentConfig {
+    Format: format,
+    Room: Config{ MinWordLength: 4 },
+  }, "/", tt.testMatchRooms)
+  tt.organizer = []*http.Cookie{cookie}
+  for _, p := range players {
+    cookie, err := tt.Register(p, "/")
+    assert.NoError(t, err)
+    tt.entrants[p] = cookie
+  }
+  return tt
+}
+
+func (tt *testTournament) currentRound() TournamentRound {
+  return *tt.rounds[len(tt.rounds) - 1]
+}
+
+func TestBracketOrder(t *testing.T) {
+  assert.Equal(t, []int{1, 2}, bracketOrder(2))
+  assert.Equal(t, []int{1, 8, 4, 5, 2, 7, 3, 6}, bracketOrder(8))
+}
+
+func TestTournamentRegistration(t *testing.T) {
+  tt := newTestTournament(t, "", "alice")
+  _, err := tt.Register("alice", "/")
+  assert.ErrorIs(t, err, ErrUsernameTaken)
+  _, err = tt.Register("not ok", "/")
+  assert.ErrorIs(t, err, ErrInvalidUsername)
+  assert.ErrorIs(t, tt.Start(tt.organizer), ErrWrongState)
+
+  _, err = tt.Register("bob", "/")
+  assert.NoError(t, err)
+  assert.ErrorIs(t, tt.Start(nil), ErrNotOrganizer)
+  assert.NoError(t, tt.Start(tt.organizer))
+  _, err = tt.Register("carol", "/")
+  assert.ErrorIs(t, err, ErrWrongState)
+  assert.ErrorIs(t, tt.Start(tt.organizer), ErrWrongState)
+}
+
+func TestTournamentRoomsNeedTheEntrantCookie(t *testing.T) {
+  tt := newTestTournament(t, "", "alice", "bob")
+  var config Config
+  tt.Tournament.rooms = roomsFunc(func(c Config) { config = c })
+  assert.NoError(t, tt.Start(tt.organizer))
+
+  room, err := NewRoom(config, make(chan struct{}))
+  assert.NoError(t, err)
+  _, err = room.AddPlayer("alice", "/")
+  assert.ErrorIs(t, err, ErrNotEntrant)
+  // Someone else's cookie won't do
+  _, err = room.AddPlayerWithCredentials("alice", "/", 0, JoinCredentials {
+    Cookies: []*http.Cookie{{
+      Name: tt.entrants["alice"].Name,
+      Value: tt.entrants["bob"].Value,
+    }},
+  })
+  assert.ErrorIs(t, err, ErrNotEntrant)
+  _, err = room.AddPlayerWithCredentials("alice", "/", 0, JoinCredentials {
+    Cookies: []*http.Cookie{tt.entrants["bob"], tt.entrants["alice"]},
+  })
+  assert.NoError(t, err)
+}
+
+// Remembers the config of the last room made
+type roomsFunc func(Config)
+
+func (f roomsFunc) Create(config Config) (string, error) {
+  f(config)
+  return "1", nil
+}
+
+func (f roomsFunc) Remove(roomID string) {}
+
+func (f roomsFunc) Players(roomID string) []string {
+  return nil
+}
+
+func TestTournamentRoundsAreDrawnWhole(t *testing.T) {
+  tt := newTestTournament(t, "", "p1", "p2", "p3", "p4")
+  // Only one of the two first round rooms can be made
+  tt.spare = 1
+  assert.Error(t, tt.Start(tt.organizer))
+  assert.Empty(t, tt.rounds)
+  assert.Empty(t, tt.entrantsByRoom)
+  assert.Equal(t, kRegistering, tt.state)
+
+  tt.spare = 2
+  assert.NoError(t, tt.Start(tt.organizer))
+  round := tt.currentRound()
+  assert.NoError(t, tt.ReportWinner(round.Matches[0].RoomID, "p1"))
+  // No room for the final yet: the round is tried again later
+  assert.NoError(t, tt.ReportWinner(round.Matches[1].RoomID, "p2"))
+  assert.Len(t, tt.rounds, 1)
+  b, err := tt.MarshalJSON()
+  assert.NoError(t, err)
+  var jt JTournament
+  assert.NoError(t, json.Unmarshal(b, &jt))
+  assert.Equal(t, "no rooms to spare", jt.DrawError)
+  assert.Equal(t, "playing", jt.State)
+
+  tt.spare = -1
+  tt.mutex.Lock()
+  tt.advance()
+  tt.mutex.Unlock()
+  assert.Len(t, tt.rounds, 2)
+  assert.Nil(t, tt.drawErr)
+  assert.Equal(t, []string{"p1", "p2"}, tt.currentRound().Matches[0].Players)
+}
+
+func TestTournamentForfeits(t *testing.T) {
+  tt := newTestTournament(t, "", "p1", "p2", "p3", "p4", "p5", "p6", "p7",
+                          "p8")
+  assert.NoError(t, tt.Start(tt.organizer))
+  round := tt.currentRound()
+  // p1 and p8 are still playing, p7 left (or never came) and neither p3 nor
+  // p6 did. p4 and p5 finished.
+  tt.present[round.Matches[0].RoomID] = []string{"p1", "p8"}
+  tt.present[round.Matches[2].RoomID] = []string{"p7"}
+  assert.Equal(t, []string{"p2", "p7"}, round.Matches[2].Players)
+  assert.Equal(t, []string{"p3", "p6"}, round.Matches[3].Players)
+  assert.NoError(t, tt.ReportWinner(round.Matches[1].RoomID, "p5"))
+
+  tt.expireMatches(1)
+  round = tt.currentRound()
+  assert.Equal(t, 1, round.Number)
+  assert.Equal(t, "", round.Matches[0].Winner)
+  assert.False(t, round.Matches[1].Forfeit)
+  assert.Equal(t, Match{ Number: 3, Players: []string{"p2", "p7"},
+                         RoomID: round.Matches[2].RoomID, Winner: "p7",
+                         Forfeit: true }, round.Matches[2])
+  assert.Equal(t, "p3", round.Matches[3].Winner)
+  assert.True(t, round.Matches[3].Forfeit)
+
+  // Once the room is gone (deleted for being idle, say), the better seed goes
+  // through
+  delete(tt.present, round.Matches[0].RoomID)
+  tt.expireMatches(1)
+  assert.Equal(t, "p1", round.Matches[0].Winner)
+  assert.Equal(t, 2, tt.currentRound().Number)
+  // A deadline from an earlier round does nothing
+  tt.expireMatches(1)
+  assert.Equal(t, "", tt.currentRound().Matches[0].Winner)
+}
+
+func TestSingleEliminationTournament(t *testing.T) {
+  tt := newTestTournament(t, "", "p1", "p2", "p3", "p4", "p5")
+  assert.NoError(t, tt.Start(tt.organizer))
+  assert.Equal(t, 3, tt.roundCount)
+
+  // The top three seeds have byes; only 4 and 5 play
+  round := tt.currentRound()
+  assert.Len(t, round.Matches, 4)
+  assert.Equal(t, []string{"p4", "p5"}, round.Matches[1].Players)
+  for _, i := range []int{0, 2, 3} {
+    assert.True(t, round.Matches[i].Bye)
+  }
+  assert.Equal(t, map[string][]string{"1": {"p4", "p5"}}, tt.entrantsByRoom)
+
+  // Only the first game counts, and only for players in the match
+  assert.ErrorIs(t, tt.ReportWinner("1", "p1"), ErrPlayerNotFound)
+  assert.NoError(t, tt.ReportWinner("1", "p5"))
+  assert.NoError(t, tt.ReportWinner("1", "p4"))
+  round = tt.currentRound()
+  assert.Equal(t, 2, round.Number)
+  assert.Equal(t, []string{"p1", "p5"}, round.Matches[0].Players)
+  assert.Equal(t, []string{"p2", "p3"}, round.Matches[1].Players)
+
+  assert.NoError(t, tt.ReportWinner(round.Matches[0].RoomID, "p5"))
+  assert.Equal(t, 2, tt.currentRound().Number)
+  assert.NoError(t, tt.ReportWinner(round.Matches[1].RoomID, "p2"))
+  round = tt.currentRound()
+  assert.Equal(t, []string{"p5", "p2"}, round.Matches[0].Players)
+  assert.NoError(t, tt.ReportWinner(round.Matches[0].RoomID, "p5"))
+
+  b, err := tt.MarshalJSON()
+  assert.NoError(t, err)
+  var jt JTournament
+  assert.NoError(t, json.Unmarshal(b, &jt))
+  assert.Equal(t, "finished", jt.State)
+  assert.Equal(t, "p5", jt.Winner)
+  // p4 won nothing, p1 had a bye and p2 a bye and a win
+  assert.Equal(t, TournamentStanding{ Username: "p5", Wins: 3,
+                                      OpponentWins: 3 }, jt.Standings[0])
+  assert.True(t, jt.Standings[1].Eliminated)
+}
+
+func TestSwissTournament(t *testing.T) {
+  tt := newTestTournament(t, TournamentSwiss, "p1", "p2", "p3")
+  assert.NoError(t, tt.Start(tt.organizer))
+  assert.Equal(t, 2, tt.roundCount)
+
+  // The lowest seed sits out
+  round := tt.currentRound()
+  assert.Equal(t, []string{"p1", "p2"}, round.Matches[0].Players)
+  assert.Equal(t, []string{"p3"}, round.Matches[1].Players)
+  assert.NoError(t, tt.ReportWinner(round.Matches[0].RoomID, "p2"))
+
+  // p2 and p3 have a win each, and p1 gets the bye p3 already had. p2 and p3
+  // haven't played each other.
+  round = tt.currentRound()
+  assert.Equal(t, []string{"p2", "p3"}, round.Matches[0].Players)
+  assert.Equal(t, []string{"p1"}, round.Matches[1].Players)
+  assert.NoError(t, tt.ReportWinner(round.Matches[0].RoomID, "p3"))
+
+  standings := tt.standings()
+  assert.Equal(t, "p3", standings[0].Username)
+  assert.Equal(t, 2, standings[0].Wins)
+  assert.False(t, standings[1].Eliminated)
+  assert.Equal(t, kFinished, tt.state)
+}
+
+func TestRoomPassword(t *testing.T) {
+  tru := newTestRoomUtils(Config {
+    MaxPlayers: 3,
+    Password: "hunter2",
+  })
+  assert.Equal(t, "", tru.room.config.Password)
+  assert.True(t, tru.room.Metadata("id").HasPassword)
+  // Not a fast hash of the salted password
+  fast := sha256.Sum256(append(append([]byte{}, tru.room.passwordSalt...),
+                               "hunter2"...))
+  assert.NotEqual(t, fast[:], tru.room.passwordHash)
+
+  _, err := tru.room.AddPlayer("alice", "/")
+  assert.ErrorIs(t, err, ErrWrongPassword)
+  _, err = tru.room.AddPlayerWithCredentials(
+      "alice", "/", 0, JoinCredentials{ Password: "hunter3" })
+  assert.ErrorIs(t, err, ErrWrongPassword)
+  host, err := tru.room.AddPlayerWithCredentials(
+      "alice", "/", 0, JoinCredentials{ Password: "hunter2" })
+  assert.NoError(t, err)
+
+  b, err := tru.room.MarshalJSONConfig()
+  assert.NoError(t, err)
+  assert.NotContains(t, string(b), "hunter2")
+  assert.False(t, newTestRoomUtils(Config{}).room.Metadata("id").HasPassword)
+
+  // Only players see the room
+  assert.ErrorIs(t, tru.room.CheckViewer(nil), ErrInvalidCredentials)
+  assert.NoError(t, tru.room.CheckViewer([]*http.Cookie{host}))
+  assert.NoError(t, newTestRoomUtils(Config{}).room.CheckViewer(nil))
+
+  // Only the host invites
+  guest, err := tru.room.AddPlayerWithCredentials(
+      "bob", "/", 0, JoinCredentials{ Password: "hunter2" })
+  assert.NoError(t, err)
+  _, err = tru.room.Invite([]*http.Cookie{guest}, 0)
+  assert.ErrorIs(t, err, ErrNotHost)
+  invite, err := tru.room.Invite([]*http.Cookie{host}, 0)
+  assert.NoError(t, err)
+  assert.WithinDuration(t, time.Now().Add(kDefaultInviteLifetime),
+                        invite.Expires, 2 * time.Second)
+  _, err = tru.room.AddPlayerWithCredentials(
+      "carol", "/", 0, JoinCredentials{ Invite: invite.Token })
+  assert.NoError(t, err)
+}
+
+func TestInvites(t *testing.T) {
+  tru := newTestRoomUtils(Config {
+    MaxPlayers: 3,
+    Password: "hunter2",
+  })
+  host, err := tru.room.AddPlayerWithCredentials(
+      "alice", "/", 0, JoinCredentials{ Password: "hunter2" })
+  assert.NoError(t, err)
+  invite, err := tru.room.Invite([]*http.Cookie{host}, 365 * 24 * time.Hour)
+  assert.NoError(t, err)
+  assert.WithinDuration(t, time.Now().Add(kMaxInviteLifetime),
+                        invite.Expires, 2 * time.Second)
+
+  now := time.Now()
+  assert.True(t, tru.room.isValidInvite(invite.Token, now))
+  assert.False(t, tru.room.isValidInvite(invite.Token,
+                                         invite.Expires.Add(time.Second)))
+  // Pushing the expiry back breaks the signature
+  parts := strings.SplitN(invite.Token, ".", 2)
+  later := strconv.FormatInt(invite.Expires.Unix() + 1, 10)
+  assert.False(t, tru.room.isValidInvite(later + "." + parts[1], now))
+  assert.False(t, tru.room.isValidInvite("garbage", now))
+
+  // Another room's invites don't work here
+  other := newTestRoomUtils(Config{ MaxPlayers: 2, Password: "hunter2" })
+  assert.False(t, other.room.isValidInvite(invite.Token, now))
+  _, err = other.room.AddPlayerWithCredentials(
+      "bob", "/", 0, JoinCredentials{ Invite: invite.Token })
+  assert.ErrorIs(t, err, ErrInvalidInvite)
+}
@@ -0,0 +1,116 @@
+package superghost
+
+import (
+  "strconv"
+  "strings"
+)
+
+// How losing a round is scored
+type ScoringRule string
+const (
+  // A letter per round lost. Players are out once they've spelled the
+  // elimination word (or have EliminationThreshold letters, if there's no
+  // word).
+  ScoringLetters ScoringRule = "letters"
+  // A point per letter in the stem when the round was lost, so losing long
+  // rounds hurts more. Players are out at EliminationThreshold points.
+  ScoringPenalty ScoringRule = "penalty"
+)
+
+// Shown in place of a bare count when a room has no elimination word
+const kDefaultScoreWord = "WORDY"
+
+func (sr ScoringRule) IsValid() bool {
+  return sr == ScoringLetters || sr == ScoringPenalty
+}
+
+// Elimination words are spelled out a letter at a time, so letters only
+func IsValidEliminationWord(word string) bool {
+  return _alphaPattern.MatchString(word)
+}
+
+type scoringPolicy interface {
+  // Points for losing a round that ended on stem
+  penalty(stem string) uint
+  isEliminated(score uint) bool
+  // Whether the smallest possible loss would eliminate a player with score
+  onBrink(score uint) bool
+  display(score uint) string
+}
+
+func newScoringPolicy(config *Config) scoringPolicy {
+  if config.Scoring == ScoringPenalty {
+    sp := new(penaltyScoring)
+    sp.threshold = config.EliminationThreshold
+    return sp
+  }
+  sp := new(letterScoring)
+  sp.word = strings.ToUpper(config.EliminationWord)
+  sp.threshold = config.EliminationThreshold
+  if sp.word != "" {
+    sp.threshold = len(sp.word)
+  } else {
+    sp.word = kDefaultScoreWord
+  }
+  return sp
+}
+
+type letterScoring struct {
+  word string
+  threshold int  // 0 means nobody is ever eliminated
+}
+
+func (sp *letterScoring) penalty(stem string) uint {
+  return 1
+}
+
+func (sp *letterScoring) isEliminated(score uint) bool {
+  return sp.threshold > 0 && int(score) >= sp.threshold
+}
+
+func (sp *letterScoring) onBrink(score uint) bool {
+  return sp.isEliminated(score + 1)
+}
+
+// Spells as much of the word as the score allows, e.g. "GH---" for 2. Scores
+// past the end of the word are added on: "GHOST +1".
+func (sp *letterScoring) display(score uint) string {
+  n := len(sp.word)
+  if int(score) < n {
+    n = int(score)
+  }
+  s := sp.word[:n] + strings.Repeat("-", len(sp.word) - n)
+  if int(score) > len(sp.word) {
+    s += " +" + strconv.Itoa(int(score) - len(sp.word))
+  }
+  return s
+}
+
+type penaltyScoring struct {
+  threshold int  // 0 means nobody is ever eliminated
+}
+
+func (sp *penaltyScoring) penalty(stem string) uint {
+  if letterCount(stem) < 1 {
+    return 1
+  }
+  return uint(letterCount(stem))
+}
+
+func (sp *penaltyScoring) isEliminated(score uint) bool {
+  return sp.threshold > 0 && int(score) >= sp.threshold
+}
+
+// Timeouts and concessions can end a round on a stem of any length, so the
+// smallest loss is the penalty for an empty stem, not for a word
+func (sp *penaltyScoring) onBrink(score uint) bool {
+  return sp.isEliminated(score + sp.penalty(""))
+}
+
+func (sp *penaltyScoring) display(score uint) string {
+  if sp.threshold > 0 {
+    return strconv.Itoa(int(score)) + " / " + strconv.Itoa(sp.threshold) +
+        " pts"
+  }
+  return strconv.Itoa(int(score)) + " pts"
+}
@@ -0,0 +1,43 @@
+package superghost
+
+import "strconv"
+
+// In team play, everyone on a team shares one score and is eliminated
+// together.
+type team struct {
+  number int  // 1-based, as shown to players
+  score uint
+  isEliminated bool
+
+  // The member who played for the team most recently, so turns rotate
+  // between its members
+  lastPlayer *Player
+}
+
+type JTeam struct {
+  Number int
+  Name string
+  Score uint
+  ScoreDisplay string
+  IsEliminated bool
+  Usernames []string
+}
+
+func newTeam(number int) *team {
+  t := new(team)
+  t.number = number
+  return t
+}
+
+func (t *team) name() string {
+  return "Team " + strconv.Itoa(t.number)
+}
+
+func (t *team) incrementScore(points uint,
+                              sp scoringPolicy) (isEliminated bool) {
+  t.score += points
+  if sp.isEliminated(t.score) {
+    t.isEliminated = true
+  }
+  return t.isEliminated
+}
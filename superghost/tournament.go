@@ -0,0 +1,588 @@
+package superghost
+
+import (
+  "crypto/subtle"
+  "encoding/json"
+  "math/bits"
+  "net/http"
+  "sort"
+  "sync"
+  "time"
+)
+
+// How a tournament pairs its players
+type TournamentFormat string
+const (
+  // Winners go through to the next round until one is left
+  TournamentSingleElimination TournamentFormat = "single-elimination"
+  // Everyone plays every round, against someone with the same number of wins
+  // they haven't played yet. The most wins after the last round takes it.
+  TournamentSwiss TournamentFormat = "swiss"
+)
+
+func (f TournamentFormat) IsValid() bool {
+  return f == TournamentSingleElimination || f == TournamentSwiss
+}
+
+type tournamentState int
+const (
+  kRegistering tournamentState = iota
+  kPlaying
+  kFinished
+)
+
+func (s tournamentState) String() string {
+  switch s {
+    case kRegistering:
+      return "registering"
+    case kPlaying:
+      return "playing"
+    case kFinished:
+      return "finished"
+    default:
+      panic("invalid tournamentState value")
+  }
+}
+
+const (
+  kOrganizerCookieName = "organizer"
+  // Followed by the username
+  kEntrantCookiePrefix = "entrant-"
+  kTournamentCookieLifetime = 7 * 24 * time.Hour
+  kDefaultMatchTime = time.Hour
+  // How long until a round that couldn't be drawn is tried again
+  kRedrawDelay = time.Minute
+)
+
+type TournamentConfig struct {
+  Name string
+  // Empty means TournamentSingleElimination
+  Format TournamentFormat
+  // How many rounds a Swiss tournament lasts. 0 means enough for one player
+  // to win them all: log2 of the number of players, rounded up.
+  Rounds int
+  // Every match is played in a room made from this. Its MaxPlayers and
+  // Entrants are set for each match.
+  Room Config
+  // How long each round's matches have to be won. After that, a player in
+  // their match's room wins it if their opponent isn't there; if neither is,
+  // the player drawn first (the better seed, or the higher in the standings)
+  // does. Matches with both players still there get as long again. 0 means
+  // kDefaultMatchTime.
+  MatchTime time.Duration
+}
+
+// Two players drawn against each other, or one with a bye
+type Match struct {
+  // Numbered from 1 in each round
+  Number int
+  Players []string
+  // Empty for byes
+  RoomID string `json:",omitempty"`
+  // Whoever won the first game in the room, or the player with the bye
+  Winner string `json:",omitempty"`
+  Bye bool `json:",omitempty"`
+  // The match ran out of time and was given to Winner (see
+  // TournamentConfig.MatchTime)
+  Forfeit bool `json:",omitempty"`
+}
+
+type TournamentRound struct {
+  Number int
+  Matches []Match
+}
+
+type TournamentStanding struct {
+  Username string
+  // Byes count as wins
+  Wins int
+  Losses int
+  // The sum of every opponent's wins, which breaks ties: beating players who
+  // went on to win counts for more
+  OpponentWins int
+  // Out of a single-elimination tournament
+  Eliminated bool `json:",omitempty"`
+}
+
+type JTournament struct {
+  Name string
+  Format TournamentFormat
+  // How many rounds there'll be once the tournament starts, or 0 before
+  RoundCount int
+  State string
+  // In registration order, which is also seed order
+  Players []string
+  Rounds []TournamentRound
+  // Best first
+  Standings []TournamentStanding
+  Winner string `json:",omitempty"`
+  // Why the next round hasn't been drawn, while it's being tried again
+  DrawError string `json:",omitempty"`
+}
+
+// Where a tournament's matches are played. The tournament is locked while
+// it calls these, so they must not call back into it.
+type MatchRooms interface {
+  // Makes a room for a match. Returns its ID.
+  Create(config Config) (string, error)
+  // Gets rid of a room made for a round that couldn't be drawn in full
+  Remove(roomID string)
+  // The usernames of the players in the room, or nil if it's gone (it may
+  // have been deleted for being idle)
+  Players(roomID string) []string
+}
+
+// Registers players, draws them into matches round by round and moves the
+// winners on. Matches are played in ordinary rooms made by the MatchRooms the
+// tournament is given; whoever owns the rooms reports each room's first winner
+// back with ReportWinner. Safe for concurrent use.
+type Tournament struct {
+  mutex sync.Mutex
+
+  config TournamentConfig
+  organizerCookie *http.Cookie
+  rooms MatchRooms
+
+  state tournamentState
+  players []string
+  // Username to the value of the cookie they were given when they registered
+  entrantCookies map[string]string
+  // Fixed once the tournament starts
+  roundCount int
+  rounds []*TournamentRound
+  // Fires when the current round's matches run out of time
+  deadline *time.Timer
+  // Why the next round couldn't be drawn, until it is
+  drawErr error
+}
+
+// The organizer's cookie, scoped to path, is the only one that can start the
+// tournament
+func NewTournament(config TournamentConfig, path string,
+                   rooms MatchRooms) (*Tournament, *http.Cookie) {
+  t := new(Tournament)
+  t.config = config
+  if t.config.Format == "" {
+    t.config.Format = TournamentSingleElimination
+  }
+  if t.config.MatchTime <= 0 {
+    t.config.MatchTime = kDefaultMatchTime
+  }
+  t.config.Room.MaxPlayers = 2
+  t.rooms = rooms
+  t.organizerCookie = newCookie(path, kOrganizerCookieName)
+  t.organizerCookie.Expires = time.Now().Add(kTournamentCookieLifetime)
+  t.players = make([]string, 0)
+  t.entrantCookies = make(map[string]string)
+  t.rounds = make([]*TournamentRound, 0)
+  return t, t.organizerCookie
+}
+
+func (t *Tournament) isOrganizer(cookies []*http.Cookie) bool {
+  for _, c := range cookies {
+    if c.Name == kOrganizerCookieName &&
+        c.Value == t.organizerCookie.Value {
+      return true
+    }
+  }
+  return false
+}
+
+// Returns the player's cookie, scoped to path, without which they can't join
+// their matches' rooms. path must cover the rooms.
+func (t *Tournament) Register(username string,
+                              path string) (*http.Cookie, error) {
+  t.mutex.Lock()
+  defer t.mutex.Unlock()
+
+  if t.state != kRegistering {
+    return nil, ErrWrongState.withMessage("registration has closed")
+  }
+  if !IsValidUsername(username) {
+    return nil, ErrInvalidUsername
+  }
+  for _, p := range t.players {
+    if p == username {
+      return nil, ErrUsernameTaken.withMessage(
+          "'%s' has already registered", username)
+    }
+  }
+  t.players = append(t.players, username)
+  cookie := newCookie(path, kEntrantCookiePrefix + username)
+  cookie.Expires = time.Now().Add(kTournamentCookieLifetime)
+  t.entrantCookies[username] = cookie.Value
+  return cookie, nil
+}
+
+// Closes registration and draws the first round. If the round can't be drawn,
+// registration stays open.
+func (t *Tournament) Start(cookies []*http.Cookie) error {
+  t.mutex.Lock()
+  defer t.mutex.Unlock()
+
+  if !t.isOrganizer(cookies) {
+    return ErrNotOrganizer
+  }
+  if t.state != kRegistering {
+    return ErrWrongState.withMessage("the tournament has already started")
+  }
+  if len(t.players) < 2 {
+    return ErrWrongState.withMessage("a tournament needs at least 2 players")
+  }
+
+  // Enough rounds to halve the field down to one player
+  t.roundCount = bits.Len(uint(len(t.players) - 1))
+  if t.config.Format == TournamentSwiss && t.config.Rounds > 0 {
+    t.roundCount = t.config.Rounds
+  }
+  if err := t.nextRound(); err != nil {
+    return err
+  }
+  t.state = kPlaying
+  return nil
+}
+
+// Records winner as the winner of the match played in roomID. Anything after
+// a match's first game, and games in rooms that aren't this tournament's, are
+// ignored. If the next round can't be drawn yet, that's retried, not
+// returned.
+func (t *Tournament) ReportWinner(roomID string, winner string) error {
+  t.mutex.Lock()
+  defer t.mutex.Unlock()
+
+  if t.state != kPlaying {
+    return nil
+  }
+  round := t.rounds[len(t.rounds) - 1]
+  for i := range round.Matches {
+    match := &round.Matches[i]
+    if match.RoomID != roomID || match.Winner != "" {
+      continue
+    }
+    if winner != match.Players[0] && winner != match.Players[1] {
+      return ErrPlayerNotFound.withMessage(
+          "%s isn't playing match %d", winner, match.Number)
+    }
+    match.Winner = winner
+  }
+  t.advance()
+  return nil
+}
+
+// Gives the current round's matches that are out of time to whoever turned
+// up for them (see TournamentConfig.MatchTime). Does nothing if the round is
+// over.
+func (t *Tournament) expireMatches(roundNumber int) {
+  t.mutex.Lock()
+  defer t.mutex.Unlock()
+
+  if t.state != kPlaying || len(t.rounds) != roundNumber {
+    return
+  }
+  round := t.rounds[roundNumber - 1]
+  isStillPlaying := false
+  for i := range round.Matches {
+    match := &round.Matches[i]
+    if match.Winner != "" {
+      continue
+    }
+    present := make(map[string]bool)
+    for _, username := range t.rooms.Players(match.RoomID) {
+      present[username] = true
+    }
+    first, second := match.Players[0], match.Players[1]
+    switch {
+      case present[first] && present[second]:
+        isStillPlaying = true
+        continue
+      case present[second]:
+        match.Winner = second
+      default:
+        match.Winner = first
+    }
+    match.Forfeit = true
+  }
+  if isStillPlaying {
+    t.startDeadline(roundNumber)
+    return
+  }
+  t.advance()
+}
+
+// The lock must be held
+func (t *Tournament) startDeadline(roundNumber int) {
+  t.deadline = time.AfterFunc(t.config.MatchTime, func() {
+    t.expireMatches(roundNumber)
+  })
+}
+
+// Once every match in the current round has a winner, finishes the
+// tournament or draws the next round. A round that can't be drawn (the server
+// may have no rooms to spare) is tried again after kRedrawDelay. The lock
+// must be held.
+func (t *Tournament) advance() {
+  for _, match := range t.rounds[len(t.rounds) - 1].Matches {
+    if match.Winner == "" {
+      return
+    }
+  }
+  if t.deadline != nil {
+    t.deadline.Stop()
+  }
+  if len(t.rounds) == t.roundCount {
+    t.state = kFinished
+    return
+  }
+  if t.drawErr = t.nextRound(); t.drawErr != nil {
+    drawn := len(t.rounds)
+    time.AfterFunc(kRedrawDelay, func() {
+      t.mutex.Lock()
+      defer t.mutex.Unlock()
+
+      if t.state == kPlaying && len(t.rounds) == drawn {
+        t.advance()
+      }
+    })
+  }
+}
+
+// Draws the next round and makes its rooms. The round is only added once
+// every room has been made; if one can't be, the others are removed. The lock
+// must be held.
+func (t *Tournament) nextRound() error {
+  var pairings [][]string
+  if t.config.Format == TournamentSwiss {
+    pairings = t.swissPairings()
+  } else {
+    pairings = t.eliminationPairings()
+  }
+
+  round := &TournamentRound{
+    Number: len(t.rounds) + 1,
+    Matches: make([]Match, 0, len(pairings)),
+  }
+  for i, players := range pairings {
+    match := Match{ Number: i + 1, Players: players }
+    if len(players) == 1 {
+      match.Bye = true
+      match.Winner = players[0]
+    } else {
+      config := t.config.Room
+      config.Entrants = players
+      config.entrantCookies = map[string]string {
+        players[0]: t.entrantCookies[players[0]],
+        players[1]: t.entrantCookies[players[1]],
+      }
+      roomID, err := t.rooms.Create(config)
+      if err != nil {
+        for _, made := range round.Matches {
+          if made.RoomID != "" {
+            t.rooms.Remove(made.RoomID)
+          }
+        }
+        return err
+      }
+      match.RoomID = roomID
+    }
+    round.Matches = append(round.Matches, match)
+  }
+  t.rounds = append(t.rounds, round)
+  t.startDeadline(round.Number)
+  return nil
+}
+
+// Seeds the first round so the top seeds can only meet late on, and get the
+// byes if the field isn't a power of 2. After that, the winners of each pair
+// of matches meet. The lock must be held.
+func (t *Tournament) eliminationPairings() [][]string {
+  pairings := make([][]string, 0)
+  if len(t.rounds) > 0 {
+    previous := t.rounds[len(t.rounds) - 1].Matches
+    for i := 0; i + 1 < len(previous); i += 2 {
+      pairings = append(pairings, []string{previous[i].Winner,
+                                           previous[i + 1].Winner})
+    }
+    return pairings
+  }
+
+  order := bracketOrder(1 << t.roundCount)
+  for i := 0; i + 1 < len(order); i += 2 {
+    // The second seed of a pair is always the lower one, so it's the one that
+    // might not exist
+    players := []string{t.players[order[i] - 1]}
+    if order[i + 1] <= len(t.players) {
+      players = append(players, t.players[order[i + 1] - 1])
+    }
+    pairings = append(pairings, players)
+  }
+  return pairings
+}
+
+// The seeds (from 1) in bracket order for a bracket of size players, so that
+// 1 plays size, 2 plays size - 1 and so on, and 1 and 2 can only meet in the
+// final
+func bracketOrder(size int) []int {
+  order := []int{1}
+  for n := 2; n <= size; n *= 2 {
+    next := make([]int, 0, n)
+    for _, seed := range order {
+      next = append(next, seed, n + 1 - seed)
+    }
+    order = next
+  }
+  return order
+}
+
+// Pairs players in order of the standings with the next player they haven't
+// played. With an odd number of players, the lowest one who hasn't had a bye
+// yet gets one. The lock must be held.
+func (t *Tournament) swissPairings() [][]string {
+  standings := t.standings()
+  opponents := t.opponents()
+  byes := make(map[string]bool)
+  for _, round := range t.rounds {
+    for _, match := range round.Matches {
+      if match.Bye {
+        byes[match.Winner] = true
+      }
+    }
+  }
+
+  unpaired := make([]string, 0, len(standings))
+  for _, s := range standings {
+    unpaired = append(unpaired, s.Username)
+  }
+  var bye string
+  if len(unpaired) % 2 == 1 {
+    i := len(unpaired) - 1
+    for i > 0 && byes[unpaired[i]] {
+      i--
+    }
+    bye = unpaired[i]
+    unpaired = append(unpaired[:i], unpaired[i + 1:]...)
+  }
+
+  pairings := make([][]string, 0)
+  for len(unpaired) > 0 {
+    // A rematch only if there's nobody else left
+    j := 1
+    for j < len(unpaired) - 1 && opponents[unpaired[0]][unpaired[j]] {
+      j++
+    }
+    pairings = append(pairings, []string{unpaired[0], unpaired[j]})
+    unpaired = append(unpaired[1:j], unpaired[j + 1:]...)
+  }
+  if bye != "" {
+    pairings = append(pairings, []string{bye})
+  }
+  return pairings
+}
+
+// Who each player has played. The lock must be held.
+func (t *Tournament) opponents() map[string]map[string]bool {
+  opponents := make(map[string]map[string]bool)
+  for _, p := range t.players {
+    opponents[p] = make(map[string]bool)
+  }
+  for _, round := range t.rounds {
+    for _, match := range round.Matches {
+      if len(match.Players) == 2 {
+        opponents[match.Players[0]][match.Players[1]] = true
+        opponents[match.Players[1]][match.Players[0]] = true
+      }
+    }
+  }
+  return opponents
+}
+
+// Most wins first, then most opponent wins, then seed. The lock must be held.
+func (t *Tournament) standings() []TournamentStanding {
+  byUsername := make(map[string]*TournamentStanding)
+  seeds := make(map[string]int)
+  for i, p := range t.players {
+    byUsername[p] = &TournamentStanding{ Username: p }
+    seeds[p] = i
+  }
+  for _, round := range t.rounds {
+    for _, match := range round.Matches {
+      if match.Winner == "" {
+        continue
+      }
+      byUsername[match.Winner].Wins++
+      for _, p := range match.Players {
+        if p != match.Winner {
+          byUsername[p].Losses++
+          byUsername[p].Eliminated =
+              t.config.Format == TournamentSingleElimination
+        }
+      }
+    }
+  }
+  for p, opponents := range t.opponents() {
+    for o := range opponents {
+      byUsername[p].OpponentWins += byUsername[o].Wins
+    }
+  }
+
+  standings := make([]TournamentStanding, 0, len(t.players))
+  for _, p := range t.players {
+    standings = append(standings, *byUsername[p])
+  }
+  sort.SliceStable(standings, func(i, j int) bool {
+    a, b := standings[i], standings[j]
+    if a.Wins != b.Wins {
+      return a.Wins > b.Wins
+    }
+    if a.OpponentWins != b.OpponentWins {
+      return a.OpponentWins > b.OpponentWins
+    }
+    return seeds[a.Username] < seeds[b.Username]
+  })
+  return standings
+}
+
+func (t *Tournament) MarshalJSON() ([]byte, error) {
+  t.mutex.Lock()
+  defer t.mutex.Unlock()
+
+  rounds := make([]TournamentRound, 0, len(t.rounds))
+  for _, round := range t.rounds {
+    rounds = append(rounds, *round)
+  }
+  standings := t.standings()
+  var winner, drawErr string
+  if t.state == kFinished {
+    winner = standings[0].Username
+  }
+  if t.drawErr != nil {
+    drawErr = t.drawErr.Error()
+  }
+  return json.Marshal(JTournament {
+    Name: t.config.Name,
+    Format: t.config.Format,
+    RoundCount: t.roundCount,
+    State: t.state.String(),
+    Players: t.players,
+    Rounds: rounds,
+    Standings: standings,
+    Winner: winner,
+    DrawError: drawErr,
+  })
+}
+
+// Tournament match rooms are only for the players who registered, so
+// usernames alone aren't enough. The lock must be held.
+func (r *Room) hasEntrantCookie(username string,
+                                cookies []*http.Cookie) bool {
+  want, ok := r.config.entrantCookies[username]
+  if !ok {
+    return true
+  }
+  for _, c := range cookies {
+    if c.Name == kEntrantCookiePrefix + username &&
+        subtle.ConstantTimeCompare([]byte(c.Value), []byte(want)) == 1 {
+      return true
+    }
+  }
+  return false
+}
@@ -7,32 +7,46 @@ import(
   "net/http"
   "regexp"
   "time"
-  "fmt"
-  "os"
 )
 
 var _usernamePattern *regexp.Regexp
 var _alphaPattern *regexp.Regexp
 
-func validateWord(word string, usedWords map[string]bool, allowRepeats bool) (
-    isWord bool, err error) {
-  if !_alphaPattern.MatchString(word) {
-    return false, fmt.Errorf("word is invalid format")
+func validateWord(word string, usedWords map[string]bool, allowRepeats bool,
+                  dictionary Dictionary, alphabet *Alphabet) (isWord bool,
+                                                             err error) {
+  if !alphabet.IsLetters(word) {
+    return false, ErrInvalidWord
   }
   if _, ok := usedWords[word]; !allowRepeats && ok {
-    return false, fmt.Errorf("word has already been used")
+    return false, ErrWordUsed
   }
-  // https://github.com/ngocsangyem/freedictionaryapi
-  url := "https://wordsapiv1.p.rapidapi.com/words/" + word
-  req, _ := http.NewRequest("GET", url, nil)
-  req.Header.Add("X-RapidAPI-Key", os.Getenv("RAPIDAPI_KEY"))
-  req.Header.Add("X-RapidAPI-Host", "wordsapiv1.p.rapidapi.com")
-  // Execute the request
-  res, err := http.DefaultClient.Do(req)
-  if err != nil {
-    return false, err
+  return dictionary.IsWord(word)
+}
+
+// Whether the letters of sub appear in s in order (not necessarily next to
+// each other)
+func isSubsequence(sub, s string) bool {
+  subLetters := []rune(sub)
+  i := 0
+  for _, c := range s {
+    if i < len(subLetters) && c == subLetters[i] {
+      i++
+    }
   }
-  return res.StatusCode == http.StatusOK, nil
+  return i == len(subLetters)
+}
+
+func IsValidUsername(username string) bool {
+  return _usernamePattern.MatchString(username)
+}
+
+func reverse(s string) string {
+  r := []rune(s)
+  for i, j := 0, len(r) - 1; i < j; i, j = i + 1, j - 1 {
+    r[i], r[j] = r[j], r[i]
+  }
+  return string(r)
 }
 
 func newCookie(path string, username string) *http.Cookie {
@@ -66,29 +80,3 @@ func init() {
   _usernamePattern = regexp.MustCompile("^[[:alnum:]]+$")
   _alphaPattern = regexp.MustCompile("^[[:alpha:]]+$")
 }
-
-// Generated by https://mholt.github.io/json-to-go/
-// type Entry []struct {
-// 	Word      string `json:"word"`
-// 	Phonetic  string `json:"phonetic,omitempty"`
-// 	Phonetics []struct {
-// 		Text      string `json:"text"`
-// 		Audio     string `json:"audio"`
-// 		SourceURL string `json:"sourceUrl,omitempty"`
-// 	} `json:"phonetics"`
-// 	Meanings []struct {
-// 		PartOfSpeech string `json:"partOfSpeech"`
-// 		Definitions  []struct {
-// 			Definition string        `json:"definition"`
-// 			Synonyms   []string `json:"synonyms"`
-// 			Antonyms   []string `json:"antonyms"`
-// 		} `json:"definitions"`
-// 		Synonyms []string `json:"synonyms"`
-// 		Antonyms []string `json:"antonyms"`
-// 	} `json:"meanings"`
-// 	License struct {
-// 		Name string `json:"name"`
-// 		URL  string `json:"url"`
-// 	} `json:"license"`
-//	SourceUrls []string `json:"sourceUrls"`
-// }
@@ -0,0 +1,118 @@
+package superghost
+
+import (
+  "net/http"
+  "sort"
+  "strings"
+)
+
+// No room needs more than this many words of its own
+const kMaxCustomWords = 1000
+
+// A room's own allowed and blocked words, which override its dictionary. A
+// word on both lists is blocked.
+type customWords struct {
+  dictionary Dictionary
+  allowed map[string]bool
+  blocked map[string]bool
+}
+
+func newCustomWords(dictionary Dictionary,
+                    allowed, blocked []string) *customWords {
+  d := new(customWords)
+  d.dictionary = dictionary
+  d.allowed = make(map[string]bool)
+  for _, w := range allowed {
+    d.allowed[w] = true
+  }
+  d.blocked = make(map[string]bool)
+  for _, w := range blocked {
+    d.blocked[w] = true
+  }
+  return d
+}
+
+func (d *customWords) IsWord(word string) (bool, error) {
+  if d.blocked[word] {
+    return false, nil
+  }
+  if d.allowed[word] {
+    return true, nil
+  }
+  return d.dictionary.IsWord(word)
+}
+
+// Splits pasted or uploaded text into words. Words may be separated by
+// whitespace or commas.
+func ParseWordList(text string) []string {
+  return strings.FieldsFunc(text, func(c rune) bool {
+    return c == ',' || c == ' ' || c == '\t' || c == '\n' || c == '\r'
+  })
+}
+
+// Normalizes words to alphabet, then sorts and de-duplicates them. If any
+// aren't letters only, or there are too many, returns an error along with the
+// words that are fine.
+func NormalizeWordList(words []string, alphabet *Alphabet) ([]string, error) {
+  var err error
+  seen := make(map[string]bool)
+  normalized := make([]string, 0, len(words))
+  for _, w := range words {
+    w = alphabet.Normalize(strings.TrimSpace(w))
+    if !alphabet.IsLetters(w) {
+      if err == nil {
+        err = ErrInvalidWord.withMessage(
+            "'%s' can't be in a word list: letters only", w)
+      }
+      continue
+    }
+    if !seen[w] {
+      seen[w] = true
+      normalized = append(normalized, w)
+    }
+  }
+  sort.Strings(normalized)
+  if len(normalized) > kMaxCustomWords {
+    normalized = normalized[:kMaxCustomWords]
+    if err == nil {
+      err = ErrInvalidWord.withMessage(
+          "word lists can't be longer than %d words", kMaxCustomWords)
+    }
+  }
+  return normalized, err
+}
+
+// Replaces the room's allowed and blocked words. Only the host can, and only
+// between games.
+func (r *Room) SetWordLists(cookies []*http.Cookie,
+                            allowed, blocked []string) error {
+  r.mutex.Lock()
+  defer r.mutex.Unlock()
+
+  r.updateLastTouch()
+
+  username, ok := r.pm.getValidCookie(cookies)
+  if !ok {
+    return ErrInvalidCredentials
+  }
+  if username != r.pm.hostPlayer().username {
+    return ErrNotHost.withMessage("only the host can change the word lists")
+  }
+  if r.state != kWaitingToStart {
+    return ErrWrongState.withMessage(
+        "word lists can only be changed before the game starts")
+  }
+  allowed, err := NormalizeWordList(allowed, r.alphabet)
+  if err != nil {
+    return err
+  }
+  blocked, err = NormalizeWordList(blocked, r.alphabet)
+  if err != nil {
+    return err
+  }
+
+  r.config.AllowedWords = allowed
+  r.config.BlockedWords = blocked
+  r.dictionary = newCustomWords(r.config.Dictionary, allowed, blocked)
+  return nil
+}